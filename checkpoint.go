@@ -0,0 +1,209 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+// Checkpoint holds the body of a checkpoint, the signed-note form of a tree
+// head that is published by static (tile-based) CT logs and consumed by
+// witnesses.  The body of a checkpoint is:
+//
+//	<origin>\n
+//	<tree size, decimal>\n
+//	<root hash, base64>\n
+//	[<extension line>\n]...
+//
+// The signature lines that follow the body in a signed note are not part
+// of the Checkpoint.
+type Checkpoint struct {
+	// Origin is the unique identifier of the log, e.g. "example.com/log2020".
+	Origin string
+	// TreeSize is the number of entries in the log's Merkle tree.
+	TreeSize uint64
+	// SHA256RootHash is the root hash of the log's Merkle tree.
+	SHA256RootHash SHA256Hash
+	// Extensions holds any optional extension lines following the root hash,
+	// without their trailing newlines.
+	Extensions []string
+}
+
+// Marshal returns the text form of the checkpoint body.
+func (c Checkpoint) Marshal() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n%d\n%s\n", c.Origin, c.TreeSize, c.SHA256RootHash.Base64String())
+	for _, ext := range c.Extensions {
+		fmt.Fprintf(&b, "%s\n", ext)
+	}
+	return b.Bytes()
+}
+
+// Unmarshal parses the text form of a checkpoint body into c.
+func (c *Checkpoint) Unmarshal(data []byte) error {
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		return errors.New("checkpoint body must end with a newline")
+	}
+	lines := bytes.Split(data[:len(data)-1], []byte("\n"))
+	if len(lines) < 3 {
+		return fmt.Errorf("checkpoint body has %d lines, want at least 3", len(lines))
+	}
+	origin := string(lines[0])
+	if len(origin) == 0 {
+		return errors.New("checkpoint has empty origin")
+	}
+	size, err := strconv.ParseUint(string(lines[1]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse checkpoint tree size %q: %v", lines[1], err)
+	}
+	var hash SHA256Hash
+	if err := hash.FromBase64String(string(lines[2])); err != nil {
+		return fmt.Errorf("failed to parse checkpoint root hash: %v", err)
+	}
+	var exts []string
+	for _, ext := range lines[3:] {
+		if len(ext) == 0 {
+			return errors.New("checkpoint body has empty extension line")
+		}
+		exts = append(exts, string(ext))
+	}
+	*c = Checkpoint{
+		Origin:         origin,
+		TreeSize:       size,
+		SHA256RootHash: hash,
+		Extensions:     exts,
+	}
+	return nil
+}
+
+// ToCheckpoint returns the STH as a signed checkpoint for the given origin,
+// i.e. a signed note whose body is the Checkpoint for the STH and whose single
+// signature line carries the log's RFC 6962 tree head signature, so that the
+// STH can be consumed by checkpoint-based tooling such as witnesses.
+//
+// The signature line is formed as for static CT logs: the key name is the
+// origin, the key ID is the first four bytes of
+// SHA-256(origin || 0x0A || 0x05 || logKeyDER), and the signature is the STH
+// timestamp (as a big-endian uint64) followed by the TLS-encoded
+// DigitallySigned tree head signature.  As the key ID covers the log's public
+// key, which is not part of the STH, the DER-encoded SubjectPublicKeyInfo of
+// the log must be provided.
+//
+// Note that this does not verify the STH's signature; callers should do so
+// before publishing the result.
+func (s SignedTreeHead) ToCheckpoint(origin string, logKeyDER []byte) ([]byte, error) {
+	if s.Version != V1 {
+		return nil, fmt.Errorf("unsupported STH version %d", s.Version)
+	}
+	if len(origin) == 0 || strings.ContainsAny(origin, " +\n\t") {
+		return nil, fmt.Errorf("invalid checkpoint origin %q", origin)
+	}
+	if len(logKeyDER) == 0 {
+		return nil, errors.New("log public key is required")
+	}
+	sig, err := tls.Marshal(s.TreeHeadSignature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tree head signature: %v", err)
+	}
+
+	keyID := RFC6962NoteKeyID(origin, logKeyDER)
+	noteSig := make([]byte, 0, len(keyID)+8+len(sig))
+	noteSig = append(noteSig, keyID[:]...)
+	noteSig = append(noteSig, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(noteSig[len(keyID):], s.Timestamp)
+	noteSig = append(noteSig, sig...)
+
+	var b bytes.Buffer
+	b.Write(Checkpoint{Origin: origin, TreeSize: s.TreeSize, SHA256RootHash: s.SHA256RootHash}.Marshal())
+	fmt.Fprintf(&b, "\n\u2014 %s %s\n", origin, base64.StdEncoding.EncodeToString(noteSig))
+	return b.Bytes(), nil
+}
+
+// RFC6962NoteKeyID returns the signed-note key ID for the RFC 6962 signatures
+// of the log with the given origin and DER-encoded public key.
+func RFC6962NoteKeyID(origin string, logKeyDER []byte) [4]byte {
+	h := sha256.New()
+	h.Write([]byte(origin))
+	h.Write([]byte{0x0a, 0x05})
+	h.Write(logKeyDER)
+	var id [4]byte
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+// STHFromCheckpoint parses a signed checkpoint as published by a static CT log
+// (the inverse of ToCheckpoint), returning the checkpoint body together with
+// the STH that it carries.  The STH's timestamp and tree head signature are
+// taken from the RFC 6962 signature line whose key name is the checkpoint's
+// origin and whose key ID is that of the log with the given DER-encoded
+// public key; other signature lines (e.g. from witnesses) are ignored.
+//
+// Note that this does not verify the STH's signature; callers should do so
+// before relying on the result.
+func STHFromCheckpoint(data, logKeyDER []byte) (*SignedTreeHead, *Checkpoint, error) {
+	sep := bytes.Index(data, []byte("\n\n"))
+	if sep < 0 {
+		return nil, nil, errors.New("checkpoint has no signature lines")
+	}
+	var cp Checkpoint
+	if err := cp.Unmarshal(data[:sep+1]); err != nil {
+		return nil, nil, err
+	}
+	sigs := data[sep+2:]
+	if len(sigs) == 0 || sigs[len(sigs)-1] != '\n' {
+		return nil, nil, errors.New("checkpoint signature lines must end with a newline")
+	}
+
+	keyID := RFC6962NoteKeyID(cp.Origin, logKeyDER)
+	for _, line := range strings.Split(string(sigs[:len(sigs)-1]), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "\u2014" {
+			return nil, nil, fmt.Errorf("malformed checkpoint signature line %q", line)
+		}
+		if fields[1] != cp.Origin {
+			continue
+		}
+		noteSig, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode checkpoint signature: %v", err)
+		}
+		if len(noteSig) < len(keyID)+8 || !bytes.Equal(noteSig[:len(keyID)], keyID[:]) {
+			continue
+		}
+		sth := SignedTreeHead{
+			Version:        V1,
+			TreeSize:       cp.TreeSize,
+			Timestamp:      binary.BigEndian.Uint64(noteSig[len(keyID):]),
+			SHA256RootHash: cp.SHA256RootHash,
+			LogID:          sha256.Sum256(logKeyDER),
+		}
+		if rest, err := tls.Unmarshal(noteSig[len(keyID)+8:], &sth.TreeHeadSignature); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse checkpoint tree head signature: %v", err)
+		} else if len(rest) > 0 {
+			return nil, nil, fmt.Errorf("trailing data (%d bytes) after checkpoint tree head signature", len(rest))
+		}
+		return &sth, &cp, nil
+	}
+	return nil, nil, fmt.Errorf("checkpoint has no signature from log %q with key ID %x", cp.Origin, keyID)
+}
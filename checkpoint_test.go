@@ -0,0 +1,221 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+func TestCheckpointUnmarshal(t *testing.T) {
+	root := "mbk+d5FDW3oNZJCbOi828rqC3hf+qhigTWVGcXEZq1U="
+	var want SHA256Hash
+	if err := want.FromBase64String(root); err != nil {
+		t.Fatalf("FromBase64String()=%v", err)
+	}
+	tests := []struct {
+		desc    string
+		in      string
+		want    Checkpoint
+		wantErr string
+	}{
+		{
+			desc: "valid",
+			in:   "example.com/log\n123\n" + root + "\n",
+			want: Checkpoint{Origin: "example.com/log", TreeSize: 123, SHA256RootHash: want},
+		},
+		{
+			desc: "extensions",
+			in:   "example.com/log\n123\n" + root + "\nfoo\nbar\n",
+			want: Checkpoint{Origin: "example.com/log", TreeSize: 123, SHA256RootHash: want, Extensions: []string{"foo", "bar"}},
+		},
+		{desc: "no trailing newline", in: "example.com/log\n123\n" + root, wantErr: "newline"},
+		{desc: "too short", in: "example.com/log\n123\n", wantErr: "lines"},
+		{desc: "empty origin", in: "\n123\n" + root + "\n", wantErr: "origin"},
+		{desc: "bad size", in: "example.com/log\nabc\n" + root + "\n", wantErr: "tree size"},
+		{desc: "bad hash", in: "example.com/log\n123\nAAAA\n", wantErr: "root hash"},
+		{desc: "empty extension", in: "example.com/log\n123\n" + root + "\n\n", wantErr: "extension"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var got Checkpoint
+			err := got.Unmarshal([]byte(test.in))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("Unmarshal()=%v; want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal()=%v; want nil", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Unmarshal()=%+v; want %+v", got, test.want)
+			}
+			if data := got.Marshal(); string(data) != test.in {
+				t.Errorf("Marshal()=%q; want %q", data, test.in)
+			}
+		})
+	}
+}
+
+func TestSTHToCheckpoint(t *testing.T) {
+	block, _ := pem.Decode([]byte(sigTestEC256PublicKeyPEM))
+	if block == nil {
+		t.Fatal("failed to decode sigTestEC256PublicKeyPEM")
+	}
+	keyDER := block.Bytes
+	v := mustCreateSignatureVerifier(t, sigTestECPublicKey(t))
+	sth := sigTestDefaultSTH(t)
+	origin := "log.example.com/2020"
+
+	data, err := sth.ToCheckpoint(origin, keyDER)
+	if err != nil {
+		t.Fatalf("ToCheckpoint()=_,%v; want nil", err)
+	}
+	parts := bytes.SplitN(data, []byte("\n\n"), 2)
+	if len(parts) != 2 {
+		t.Fatalf("ToCheckpoint()=%q; want body and signature separated by blank line", data)
+	}
+	body, sigLine := append(parts[0], '\n'), string(parts[1])
+
+	var cp Checkpoint
+	if err := cp.Unmarshal(body); err != nil {
+		t.Fatalf("Unmarshal()=%v; want nil", err)
+	}
+	want := Checkpoint{Origin: origin, TreeSize: sth.TreeSize, SHA256RootHash: sth.SHA256RootHash}
+	if !reflect.DeepEqual(cp, want) {
+		t.Errorf("Unmarshal()=%+v; want %+v", cp, want)
+	}
+
+	fields := strings.Fields(strings.TrimSuffix(sigLine, "\n"))
+	if len(fields) != 3 || fields[0] != "\u2014" || fields[1] != origin || !strings.HasSuffix(sigLine, "\n") {
+		t.Fatalf("signature line=%q; want \"\u2014 %s <signature>\\n\"", sigLine, origin)
+	}
+	noteSig, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		t.Fatalf("failed to decode note signature: %v", err)
+	}
+	if len(noteSig) < 12 {
+		t.Fatalf("note signature has %d bytes; want at least 12", len(noteSig))
+	}
+	if keyID := RFC6962NoteKeyID(origin, keyDER); !bytes.Equal(noteSig[:4], keyID[:]) {
+		t.Errorf("note key ID=%x; want %x", noteSig[:4], keyID)
+	}
+
+	// Rebuild the STH from the checkpoint and check its signature still holds.
+	got := SignedTreeHead{
+		Version:        V1,
+		TreeSize:       cp.TreeSize,
+		SHA256RootHash: cp.SHA256RootHash,
+		Timestamp:      binary.BigEndian.Uint64(noteSig[4:12]),
+	}
+	if rest, err := tls.Unmarshal(noteSig[12:], &got.TreeHeadSignature); err != nil || len(rest) > 0 {
+		t.Fatalf("failed to unmarshal tree head signature: %v (%d trailing bytes)", err, len(rest))
+	}
+	if err := v.VerifySTHSignature(got); err != nil {
+		t.Errorf("VerifySTHSignature(round-tripped)=%v; want nil", err)
+	}
+}
+
+func TestSTHToCheckpointErrors(t *testing.T) {
+	sth := sigTestDefaultSTH(t)
+	v2 := sth
+	v2.Version = 1
+	tests := []struct {
+		desc   string
+		sth    SignedTreeHead
+		origin string
+		key    []byte
+	}{
+		{desc: "empty origin", sth: sth, key: []byte{1}},
+		{desc: "origin with space", sth: sth, origin: "log example", key: []byte{1}},
+		{desc: "origin with newline", sth: sth, origin: "log\nexample", key: []byte{1}},
+		{desc: "no key", sth: sth, origin: "log.example.com"},
+		{desc: "bad version", sth: v2, origin: "log.example.com", key: []byte{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got, err := test.sth.ToCheckpoint(test.origin, test.key); err == nil {
+				t.Errorf("ToCheckpoint()=%q,nil; want error", got)
+			}
+		})
+	}
+}
+
+func TestSTHFromCheckpoint(t *testing.T) {
+	block, _ := pem.Decode([]byte(sigTestEC256PublicKeyPEM))
+	if block == nil {
+		t.Fatal("failed to decode sigTestEC256PublicKeyPEM")
+	}
+	keyDER := block.Bytes
+	v := mustCreateSignatureVerifier(t, sigTestECPublicKey(t))
+	sth := sigTestDefaultSTH(t)
+	origin := "log.example.com/2020"
+	data, err := sth.ToCheckpoint(origin, keyDER)
+	if err != nil {
+		t.Fatalf("ToCheckpoint()=_,%v; want nil", err)
+	}
+	// A witness cosignature alongside the log's signature.
+	witnessed := append(append([]byte(nil), data...), "\u2014 witness.example.com AAAAAAAA\n"...)
+	// A signature line for the origin, but from a different key.
+	otherKey := "\u2014 " + origin + " " + base64.StdEncoding.EncodeToString(make([]byte, 16)) + "\n"
+	body := data[:bytes.Index(data, []byte("\n\n"))+2]
+
+	tests := []struct {
+		desc    string
+		in      []byte
+		wantErr string
+	}{
+		{desc: "valid", in: data},
+		{desc: "witnessed", in: witnessed},
+		{desc: "other-key-first", in: append(append(append([]byte(nil), body...), otherKey...), data[len(body):]...)},
+		{desc: "no-signatures", in: data[:len(body)-1], wantErr: "no signature lines"},
+		{desc: "only-other-key", in: append(append([]byte(nil), body...), otherKey...), wantErr: "no signature from log"},
+		{desc: "no-trailing-newline", in: data[:len(data)-1], wantErr: "newline"},
+		{desc: "malformed-line", in: append(append([]byte(nil), body...), "not a signature\n"...), wantErr: "malformed"},
+		{desc: "bad-body", in: []byte("origin\nsize\nhash\n\n\u2014 origin AAAA\n"), wantErr: "tree size"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, cp, err := STHFromCheckpoint(test.in, keyDER)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("STHFromCheckpoint()=%+v,_,%v; want error containing %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("STHFromCheckpoint()=nil,nil,%v; want _,_,nil", err)
+			}
+			if cp.Origin != origin {
+				t.Errorf("STHFromCheckpoint().Origin=%q; want %q", cp.Origin, origin)
+			}
+			if got.TreeSize != sth.TreeSize || got.Timestamp != sth.Timestamp || got.SHA256RootHash != sth.SHA256RootHash {
+				t.Errorf("STHFromCheckpoint()=%+v; want tree size, timestamp and root hash of %+v", got, sth)
+			}
+			if err := v.VerifySTHSignature(*got); err != nil {
+				t.Errorf("VerifySTHSignature(STHFromCheckpoint())=%v; want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// WithFailover returns a CheckLogClient that makes each request using the
+// first of the given clients (e.g. for a log's primary and mirror URLs), and
+// fails over to the next in turn if the request fails with a transient error
+// (see IsTransient).  Once a client has succeeded, later requests start with
+// it.  Other errors, such as a 404 response, are returned without failing
+// over, and BaseURI returns that of the first client.
+//
+// The returned client also provides the AddLogClient methods, GetRawEntries
+// and GetEntryAndProof, with failover, for use with clients (such as a
+// *LogClient) that implement them; a client that does not gives an error.
+// Other methods of the clients are reachable through Primary.
+func WithFailover(clients ...CheckLogClient) CheckLogClient {
+	return &failoverClient{clients: clients}
+}
+
+// Primary returns the first of the clients that c fails over between, if c
+// was built by WithFailover, and c itself otherwise.
+func Primary(c CheckLogClient) CheckLogClient {
+	if f, ok := c.(*failoverClient); ok && len(f.clients) > 0 {
+		return f.clients[0]
+	}
+	return c
+}
+
+type failoverClient struct {
+	clients []CheckLogClient
+
+	mu      sync.Mutex
+	current int // index of the client to try first
+}
+
+// do invokes fn with each client in turn, starting with the current one,
+// until it succeeds or fails with an error that is not transient, returning
+// the last error.
+func (f *failoverClient) do(ctx context.Context, fn func(c CheckLogClient) error) error {
+	if len(f.clients) == 0 {
+		return errors.New("no clients to fail over between")
+	}
+	f.mu.Lock()
+	start := f.current
+	f.mu.Unlock()
+
+	var err error
+	for i := range f.clients {
+		which := (start + i) % len(f.clients)
+		err = fn(f.clients[which])
+		if err == nil {
+			f.mu.Lock()
+			f.current = which
+			f.mu.Unlock()
+			return nil
+		}
+		if !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (f *failoverClient) BaseURI() string {
+	if len(f.clients) == 0 {
+		return ""
+	}
+	return f.clients[0].BaseURI()
+}
+
+func (f *failoverClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	var sth *ct.SignedTreeHead
+	err := f.do(ctx, func(c CheckLogClient) error {
+		var err error
+		sth, err = c.GetSTH(ctx)
+		return err
+	})
+	return sth, err
+}
+
+func (f *failoverClient) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	var proof [][]byte
+	err := f.do(ctx, func(c CheckLogClient) error {
+		var err error
+		proof, err = c.GetSTHConsistency(ctx, first, second)
+		return err
+	})
+	return proof, err
+}
+
+func (f *failoverClient) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	var rsp *ct.GetProofByHashResponse
+	err := f.do(ctx, func(c CheckLogClient) error {
+		var err error
+		rsp, err = c.GetProofByHash(ctx, hash, treeSize)
+		return err
+	})
+	return rsp, err
+}
+
+func (f *failoverClient) AddChain(ctx context.Context, chain []ct.ASN1Cert) (*ct.SignedCertificateTimestamp, error) {
+	var sct *ct.SignedCertificateTimestamp
+	err := f.do(ctx, func(c CheckLogClient) error {
+		ac, err := addLogClient(c)
+		if err == nil {
+			sct, err = ac.AddChain(ctx, chain)
+		}
+		return err
+	})
+	return sct, err
+}
+
+func (f *failoverClient) AddPreChain(ctx context.Context, chain []ct.ASN1Cert) (*ct.SignedCertificateTimestamp, error) {
+	var sct *ct.SignedCertificateTimestamp
+	err := f.do(ctx, func(c CheckLogClient) error {
+		ac, err := addLogClient(c)
+		if err == nil {
+			sct, err = ac.AddPreChain(ctx, chain)
+		}
+		return err
+	})
+	return sct, err
+}
+
+func (f *failoverClient) GetAcceptedRoots(ctx context.Context) ([]ct.ASN1Cert, error) {
+	var roots []ct.ASN1Cert
+	err := f.do(ctx, func(c CheckLogClient) error {
+		ac, err := addLogClient(c)
+		if err == nil {
+			roots, err = ac.GetAcceptedRoots(ctx)
+		}
+		return err
+	})
+	return roots, err
+}
+
+func addLogClient(c CheckLogClient) (AddLogClient, error) {
+	ac, ok := c.(AddLogClient)
+	if !ok {
+		return nil, fmt.Errorf("client for %s does not support add-chain", c.BaseURI())
+	}
+	return ac, nil
+}
+
+func (f *failoverClient) GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error) {
+	var rsp *ct.GetEntriesResponse
+	err := f.do(ctx, func(c CheckLogClient) error {
+		ec, ok := c.(interface {
+			GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error)
+		})
+		if !ok {
+			return fmt.Errorf("client for %s does not support get-entries", c.BaseURI())
+		}
+		var err error
+		rsp, err = ec.GetRawEntries(ctx, start, end)
+		return err
+	})
+	return rsp, err
+}
+
+func (f *failoverClient) GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error) {
+	var rsp *ct.GetEntryAndProofResponse
+	err := f.do(ctx, func(c CheckLogClient) error {
+		ec, ok := c.(interface {
+			GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error)
+		})
+		if !ok {
+			return fmt.Errorf("client for %s does not support get-entry-and-proof", c.BaseURI())
+		}
+		var err error
+		rsp, err = ec.GetEntryAndProof(ctx, index, treeSize)
+		return err
+	})
+	return rsp, err
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+func TestWithFailover(t *testing.T) {
+	ctx := context.Background()
+	methods := []struct {
+		name string
+		call func(c CheckLogClient) error
+	}{
+		{name: "GetSTH", call: func(c CheckLogClient) error {
+			_, err := c.GetSTH(ctx)
+			return err
+		}},
+		{name: "GetSTHConsistency", call: func(c CheckLogClient) error {
+			_, err := c.GetSTHConsistency(ctx, 1, 2)
+			return err
+		}},
+		{name: "GetProofByHash", call: func(c CheckLogClient) error {
+			_, err := c.GetProofByHash(ctx, []byte{0x01}, 2)
+			return err
+		}},
+	}
+	tests := []struct {
+		desc                    string
+		primaryErrs, mirrorErrs []error
+		wantPrimary, wantMirror int
+		wantErr                 bool
+	}{
+		{desc: "primary-ok", wantPrimary: 1},
+		{desc: "primary-5xx", primaryErrs: []error{httpErr(http.StatusServiceUnavailable)}, wantPrimary: 1, wantMirror: 1},
+		{desc: "primary-unreachable", primaryErrs: []error{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, wantPrimary: 1, wantMirror: 1},
+		{desc: "primary-bad-response", primaryErrs: []error{RspError{Err: &ParseError{Err: errors.New("bad JSON")}, StatusCode: http.StatusOK}}, wantPrimary: 1, wantErr: true},
+		{desc: "primary-not-found", primaryErrs: []error{httpErr(http.StatusNotFound)}, wantPrimary: 1, wantErr: true},
+		{desc: "mirror-rate-limited", primaryErrs: []error{httpErr(http.StatusBadGateway)}, mirrorErrs: []error{httpErr(http.StatusTooManyRequests)}, wantPrimary: 1, wantMirror: 1, wantErr: true},
+		{desc: "all-down", primaryErrs: []error{httpErr(500)}, mirrorErrs: []error{httpErr(500)}, wantPrimary: 1, wantMirror: 1, wantErr: true},
+	}
+	for _, method := range methods {
+		for _, test := range tests {
+			t.Run(method.name+"/"+test.desc, func(t *testing.T) {
+				primary := &flakyClient{errs: append([]error(nil), test.primaryErrs...)}
+				mirror := &flakyClient{errs: append([]error(nil), test.mirrorErrs...)}
+				err := method.call(WithFailover(primary, mirror))
+				if gotErr := err != nil; gotErr != test.wantErr {
+					t.Errorf("%s()=%v; want error %v", method.name, err, test.wantErr)
+				}
+				if primary.calls != test.wantPrimary || mirror.calls != test.wantMirror {
+					t.Errorf("%s() made %d,%d calls to primary,mirror; want %d,%d", method.name, primary.calls, mirror.calls, test.wantPrimary, test.wantMirror)
+				}
+			})
+		}
+	}
+}
+
+func TestWithFailoverSticky(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyClient{errs: []error{httpErr(http.StatusServiceUnavailable)}}
+	mirror := &flakyClient{}
+	fc := WithFailover(primary, mirror)
+	for i := 0; i < 3; i++ {
+		if _, err := fc.GetSTH(ctx); err != nil {
+			t.Fatalf("GetSTH()=_,%v; want _,nil", err)
+		}
+	}
+	// After failing over, requests go to the mirror until it fails.
+	if primary.calls != 1 || mirror.calls != 3 {
+		t.Errorf("GetSTH() made %d,%d calls to primary,mirror; want 1,3", primary.calls, mirror.calls)
+	}
+	mirror.errs = []error{httpErr(http.StatusInternalServerError)}
+	if _, err := fc.GetSTH(ctx); err != nil {
+		t.Fatalf("GetSTH()=_,%v; want _,nil", err)
+	}
+	if primary.calls != 2 || mirror.calls != 4 {
+		t.Errorf("GetSTH() made %d,%d calls to primary,mirror; want 2,4", primary.calls, mirror.calls)
+	}
+	if got, want := fc.BaseURI(), primary.BaseURI(); got != want {
+		t.Errorf("BaseURI()=%q; want %q", got, want)
+	}
+}
+
+func TestWithFailoverContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	primary := &flakyClient{errs: []error{context.Canceled}}
+	mirror := &flakyClient{}
+	if _, err := WithFailover(primary, mirror).GetSTH(ctx); err != context.Canceled {
+		t.Errorf("GetSTH()=_,%v; want _,%v", err, context.Canceled)
+	}
+	if mirror.calls != 0 {
+		t.Errorf("GetSTH() made %d calls to mirror; want 0", mirror.calls)
+	}
+	if _, err := WithFailover().GetSTH(context.Background()); err == nil {
+		t.Error("GetSTH(no clients)=_,nil; want _,non-nil")
+	}
+}
+
+// fullClient is a flakyClient that also provides the optional client methods.
+type fullClient struct {
+	flakyClient
+}
+
+func (f *fullClient) AddChain(ctx context.Context, chain []ct.ASN1Cert) (*ct.SignedCertificateTimestamp, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &ct.SignedCertificateTimestamp{Timestamp: 1}, nil
+}
+
+func (f *fullClient) AddPreChain(ctx context.Context, chain []ct.ASN1Cert) (*ct.SignedCertificateTimestamp, error) {
+	return f.AddChain(ctx, chain)
+}
+
+func (f *fullClient) GetAcceptedRoots(ctx context.Context) ([]ct.ASN1Cert, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return []ct.ASN1Cert{{Data: []byte{0x01}}}, nil
+}
+
+func (f *fullClient) GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &ct.GetEntriesResponse{}, nil
+}
+
+func (f *fullClient) GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &ct.GetEntryAndProofResponse{}, nil
+}
+
+func TestWithFailoverOptionalMethods(t *testing.T) {
+	ctx := context.Background()
+	methods := []struct {
+		name string
+		call func(c CheckLogClient) error
+	}{
+		{name: "AddChain", call: func(c CheckLogClient) error {
+			_, err := c.(AddLogClient).AddChain(ctx, nil)
+			return err
+		}},
+		{name: "AddPreChain", call: func(c CheckLogClient) error {
+			_, err := c.(AddLogClient).AddPreChain(ctx, nil)
+			return err
+		}},
+		{name: "GetAcceptedRoots", call: func(c CheckLogClient) error {
+			_, err := c.(AddLogClient).GetAcceptedRoots(ctx)
+			return err
+		}},
+		{name: "GetRawEntries", call: func(c CheckLogClient) error {
+			_, err := c.(interface {
+				GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error)
+			}).GetRawEntries(ctx, 0, 1)
+			return err
+		}},
+		{name: "GetEntryAndProof", call: func(c CheckLogClient) error {
+			_, err := c.(interface {
+				GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error)
+			}).GetEntryAndProof(ctx, 0, 1)
+			return err
+		}},
+	}
+	for _, method := range methods {
+		t.Run(method.name, func(t *testing.T) {
+			primary := &fullClient{flakyClient{errs: []error{httpErr(http.StatusServiceUnavailable)}}}
+			mirror := &fullClient{}
+			if err := method.call(WithFailover(primary, mirror)); err != nil {
+				t.Errorf("%s()=%v; want nil", method.name, err)
+			}
+			if primary.calls != 1 || mirror.calls != 1 {
+				t.Errorf("%s() made %d,%d calls to primary,mirror; want 1,1", method.name, primary.calls, mirror.calls)
+			}
+
+			// A client without the method gives an error, without failing over.
+			mirror = &fullClient{}
+			if err := method.call(WithFailover(&flakyClient{}, mirror)); err == nil {
+				t.Errorf("%s(unsupported)=nil; want error", method.name)
+			}
+			if mirror.calls != 0 {
+				t.Errorf("%s(unsupported) made %d calls to mirror; want 0", method.name, mirror.calls)
+			}
+		})
+	}
+}
+
+func TestPrimary(t *testing.T) {
+	primary, mirror := &fullClient{}, &fullClient{}
+	if got := Primary(WithFailover(primary, mirror)); got != primary {
+		t.Errorf("Primary(WithFailover())=%p; want primary %p", got, primary)
+	}
+	if got := Primary(mirror); got != mirror {
+		t.Errorf("Primary(client)=%p; want %p", got, mirror)
+	}
+}
@@ -17,6 +17,7 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
 
 	ct "github.com/google/certificate-transparency-go"
@@ -51,8 +52,13 @@ func (c *LogClient) GetRawEntries(ctx context.Context, start, end int64) (*ct.Ge
 // GetEntries attempts to retrieve the entries in the sequence [start, end] from the CT log server
 // (RFC6962 s4.6) as parsed [pre-]certificates for convenience, held in a slice of ct.LogEntry structures.
 // However, this does mean that any certificate parsing failures will cause a failure of the whole
-// retrieval operation; for more robust retrieval of parsed certificates, use GetRawEntries() and invoke
-// ct.LogEntryFromLeaf() on each individual entry.
+// retrieval operation; for more robust retrieval of parsed certificates, use GetParsedEntries(), or
+// GetRawEntries() and invoke ct.LogEntryFromLeaf() on each individual entry.
+//
+// Logs may cap the number of entries returned for a single request, so the
+// result may hold only the first part of the range; callers that need the
+// whole range should use GetEntriesFull, rather than treating a short result
+// as the end of the log.
 func (c *LogClient) GetEntries(ctx context.Context, start, end int64) ([]ct.LogEntry, error) {
 	resp, err := c.GetRawEntries(ctx, start, end)
 	if err != nil {
@@ -69,3 +75,300 @@ func (c *LogClient) GetEntries(ctx context.Context, start, end int64) ([]ct.LogE
 	}
 	return entries, nil
 }
+
+// EntryError describes a log entry that could not be parsed, holding the
+// entry as returned by the log so that callers can examine or store it.
+type EntryError struct {
+	Index int64
+	Leaf  ct.LeafEntry
+	Err   error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("failed to parse entry %d: %v", e.Index, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// EntryErrors is the error returned by GetParsedEntries when some of the
+// entries retrieved could not be parsed, with an EntryError for each.
+type EntryErrors []*EntryError
+
+func (e EntryErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("failed to parse %d entries, first: %v", len(e), e[0])
+}
+
+// GetParsedEntries retrieves the entries in the sequence [start, end] from the
+// log as for GetEntries, with the X509Cert or Precert of each entry populated.
+// For a precertificate entry, Precert holds the TBSCertificate from the leaf,
+// parsed as a certificate, along with the submitted precertificate from the
+// entry's extra_data; the issuing chain is held in Chain.
+//
+// Unlike GetEntries, an entry that cannot be parsed does not fail the whole
+// retrieval: the entries that could be parsed are returned along with an
+// EntryErrors error for the others, which is then the only error returned.
+// The result may therefore skip indices, so callers should use the Index of
+// each entry.  Entries with non-fatal parsing errors are returned as for
+// GetEntries.
+func (c *LogClient) GetParsedEntries(ctx context.Context, start, end int64) ([]ct.LogEntry, error) {
+	resp, err := c.GetRawEntries(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ct.LogEntry, 0, len(resp.Entries))
+	var errs EntryErrors
+	for i, leaf := range resp.Entries {
+		index := start + int64(i)
+		entry, err := ct.LogEntryFromLeaf(index, &leaf)
+		if x509.IsFatal(err) {
+			errs = append(errs, &EntryError{Index: index, Leaf: leaf, Err: err})
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	if len(errs) > 0 {
+		return entries, errs
+	}
+	return entries, nil
+}
+
+// ErrPartialResult is wrapped by the error returned by GetEntriesFull when
+// the entries retrieved do not cover the requested range, because the range
+// extends beyond the log's tree size or the log stops returning entries.
+var ErrPartialResult = errors.New("log returned fewer entries than requested")
+
+// errNoEntries is wrapped by the error from getLeaves when the log returns no
+// entries at all.
+var errNoEntries = errors.New("log returned no entries")
+
+// GetEntriesFull retrieves the entries in the sequence [start, end] from the
+// log as for GetEntries, but makes as many get-entries requests as are needed
+// to cover the range when the log returns fewer entries than requested.  The
+// range is first limited to the log's current tree size (from get-sth).  If
+// it then extends beyond the tree size, or the log returns no entries before
+// the end of the range, the entries retrieved so far are returned along with
+// an error wrapping ErrPartialResult, so the number of entries retrieved is
+// the length of the result.
+func (c *LogClient) GetEntriesFull(ctx context.Context, start, end int64) ([]ct.LogEntry, error) {
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("invalid range [%d, %d]", start, end)
+	}
+	sth, err := c.GetSTH(ctx)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(sth.TreeSize)
+	if start >= size {
+		return nil, fmt.Errorf("start %d is beyond tree size %d", start, size)
+	}
+	last := end
+	if last >= size {
+		last = size - 1
+	}
+	entries, err := c.getEntryRange(ctx, start, last)
+	if errors.Is(err, errNoEntries) {
+		return entries, fmt.Errorf("%w: got %d of %d entries: %v", ErrPartialResult, len(entries), end-start+1, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if last < end {
+		return entries, fmt.Errorf("%w: got %d of %d entries, limited by tree size %d", ErrPartialResult, len(entries), end-start+1, size)
+	}
+	return entries, nil
+}
+
+// EntryIterator pages through a range of log entries, fetching them from the
+// log in batches.  It is created with LogClient.EntryIterator.
+type EntryIterator struct {
+	c         *LogClient
+	ctx       context.Context
+	next, end int64
+	batchSize int
+	buf       []ct.LeafEntry
+}
+
+// EntryIterator returns an iterator over the entries in the sequence
+// [start, end] of the log, which fetches up to batchSize entries with each
+// get-entries request (a batchSize <= 0 means 1000).  Logs may return fewer
+// entries than requested, in which case the iterator requests the remainder
+// in the next batch.
+func (c *LogClient) EntryIterator(ctx context.Context, start, end int64, batchSize int) *EntryIterator {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	return &EntryIterator{c: c, ctx: ctx, next: start, end: end, batchSize: batchSize}
+}
+
+// Next returns the next entry in the range, with true, or nil and false once
+// the end of the range has been reached.  If the entries cannot be fetched or
+// the next entry cannot be parsed, Next returns an error; the caller may then
+// call Next again to continue the scan, which retries the failed request or
+// skips the unparseable entry respectively.
+func (it *EntryIterator) Next() (*ct.LogEntry, bool, error) {
+	if it.next > it.end {
+		return nil, false, nil
+	}
+	if len(it.buf) == 0 {
+		end := it.next + int64(it.batchSize) - 1
+		if end > it.end {
+			end = it.end
+		}
+		leaves, err := it.c.getLeaves(it.ctx, it.next, end)
+		if err != nil {
+			return nil, false, err
+		}
+		it.buf = leaves
+	}
+
+	index := it.next
+	leaf := it.buf[0]
+	it.buf = it.buf[1:]
+	it.next++
+	entry, err := ct.LogEntryFromLeaf(index, &leaf)
+	if x509.IsFatal(err) {
+		return nil, false, fmt.Errorf("failed to parse entry %d: %v", index, err)
+	}
+	return entry, true, nil
+}
+
+// maxScanChunk is the largest number of entries that ScanRange assigns to a
+// worker at a time.
+const maxScanChunk = 1000
+
+// ScanRange retrieves the entries in the sequence [start, end] of the log and
+// sends them to out, in index order.  The range is split into chunks that are
+// fetched concurrently by up to workers goroutines; chunks that complete out of
+// order are buffered until the preceding entries have been sent, and to bound
+// memory use, fetching does not get more than 2*workers chunks ahead of out.
+// Logs may return fewer entries than requested, in which case the remainder
+// of a chunk is requested again.
+//
+// ScanRange returns once every entry has been sent, or on the first error
+// fetching entries or fatal error parsing an entry, or when the context is
+// done; outstanding requests are then cancelled.  Entries with non-fatal
+// parsing errors are sent as for GetEntries.  ScanRange does not close out.
+func (c *LogClient) ScanRange(ctx context.Context, start, end int64, workers int, out chan<- ct.LogEntry) error {
+	if start < 0 || end < start {
+		return fmt.Errorf("invalid range [%d, %d]", start, end)
+	}
+	if workers < 1 {
+		return fmt.Errorf("invalid number of workers %d", workers)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Make sure that each worker has something to do for small ranges.
+	count := end - start + 1
+	chunkSize := (count + int64(workers) - 1) / int64(workers)
+	if chunkSize > maxScanChunk {
+		chunkSize = maxScanChunk
+	}
+	chunks := (count + chunkSize - 1) / chunkSize
+
+	type chunk struct {
+		index   int64
+		entries []ct.LogEntry
+		err     error
+	}
+	results := make(chan chunk)
+	// window holds a token for each chunk that has been dispatched but not yet
+	// sent to out, and busy holds a token for each chunk in flight.
+	window := make(chan struct{}, 2*workers)
+	busy := make(chan struct{}, workers)
+	go func() {
+		for i := int64(0); i < chunks; i++ {
+			for _, tokens := range []chan struct{}{window, busy} {
+				select {
+				case tokens <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			go func(i int64) {
+				defer func() { <-busy }()
+				first := start + i*chunkSize
+				last := first + chunkSize - 1
+				if last > end {
+					last = end
+				}
+				entries, err := c.getEntryRange(ctx, first, last)
+				select {
+				case results <- chunk{index: i, entries: entries, err: err}:
+				case <-ctx.Done():
+				}
+			}(i)
+		}
+	}()
+
+	pending := make(map[int64][]ct.LogEntry)
+	for next := int64(0); next < chunks; {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				return r.err
+			}
+			pending[r.index] = r.entries
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for entries, ok := pending[next]; ok; entries, ok = pending[next] {
+			for _, entry := range entries {
+				select {
+				case out <- entry:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			delete(pending, next)
+			next++
+			<-window
+		}
+	}
+	return nil
+}
+
+// getEntryRange retrieves and parses the entries [first, last] from the log,
+// making as many get-entries requests as are needed to cover the range.  On
+// failure, the entries retrieved before the failure are returned along with
+// the error, which wraps errNoEntries if the log returned no entries.
+func (c *LogClient) getEntryRange(ctx context.Context, first, last int64) ([]ct.LogEntry, error) {
+	entries := make([]ct.LogEntry, 0, last-first+1)
+	for next := first; next <= last; {
+		leaves, err := c.getLeaves(ctx, next, last)
+		if err != nil {
+			return entries, err
+		}
+		for _, leaf := range leaves {
+			entry, err := ct.LogEntryFromLeaf(next, &leaf)
+			if x509.IsFatal(err) {
+				return entries, fmt.Errorf("failed to parse entry %d: %v", next, err)
+			}
+			entries = append(entries, *entry)
+			next++
+		}
+	}
+	return entries, nil
+}
+
+// getLeaves makes a single get-entries request for the entries [first, last],
+// returning between one and last-first+1 of the leaves from the start of the
+// range, as logs may return fewer entries than requested.
+func (c *LogClient) getLeaves(ctx context.Context, first, last int64) ([]ct.LeafEntry, error) {
+	rsp, err := c.GetRawEntries(ctx, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entries [%d, %d]: %w", first, last, err)
+	}
+	if len(rsp.Entries) == 0 {
+		return nil, fmt.Errorf("%w for [%d, %d]", errNoEntries, first, last)
+	}
+	if max := last - first + 1; int64(len(rsp.Entries)) > max {
+		rsp.Entries = rsp.Entries[:max]
+	}
+	return rsp.Entries, nil
+}
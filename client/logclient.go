@@ -23,6 +23,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/jsonclient"
@@ -32,6 +34,11 @@ import (
 // LogClient represents a client for a given CT Log instance
 type LogClient struct {
 	jsonclient.JSONClient
+
+	rootsMu         sync.Mutex
+	roots           []ct.ASN1Cert // last roots fetched by GetRootsCached
+	rootsValidators jsonclient.Validators
+	rootsFetched    time.Time
 }
 
 // CheckLogClient is an interface that allows (just) checking of various log contents.
@@ -45,7 +52,8 @@ type CheckLogClient interface {
 // New constructs a new LogClient instance.
 // |uri| is the base URI of the CT log instance to interact with, e.g.
 // https://ct.googleapis.com/pilot
-// |hc| is the underlying client to be used for HTTP requests to the CT log.
+// |hc| is the underlying client to be used for all HTTP requests to the CT
+// log, including its Transport (e.g. for a proxy or client certificates).
 // |opts| can be used to provide a custom logger interface and a public key
 // for signature verification.
 func New(uri string, hc *http.Client, opts jsonclient.Options) (*LogClient, error) {
@@ -53,12 +61,21 @@ func New(uri string, hc *http.Client, opts jsonclient.Options) (*LogClient, erro
 	if err != nil {
 		return nil, err
 	}
-	return &LogClient{*logClient}, err
+	return &LogClient{JSONClient: *logClient}, err
 }
 
 // RspError represents a server error including HTTP information.
 type RspError = jsonclient.RspError
 
+// ParseError is the Err of a RspError for a response that could not be parsed.
+type ParseError = jsonclient.ParseError
+
+// IsTransient indicates whether a request that failed with the given error may
+// succeed if retried; see jsonclient.IsTransient.
+func IsTransient(err error) bool {
+	return jsonclient.IsTransient(err)
+}
+
 // Attempts to add |chain| to the log, using the api end-point specified by
 // |path|. If provided context expires before submission is complete an
 // error will be returned.
@@ -76,10 +93,10 @@ func (c *LogClient) addChainWithRetry(ctx context.Context, ctype ct.LogEntryType
 
 	var ds ct.DigitallySigned
 	if rest, err := tls.Unmarshal(resp.Signature, &ds); err != nil {
-		return nil, RspError{Err: err, StatusCode: httpRsp.StatusCode, Body: body}
+		return nil, RspError{Err: &ParseError{Err: err}, StatusCode: httpRsp.StatusCode, Body: body}
 	} else if len(rest) > 0 {
 		return nil, RspError{
-			Err:        fmt.Errorf("trailing data (%d bytes) after DigitallySigned", len(rest)),
+			Err:        &ParseError{Err: fmt.Errorf("trailing data (%d bytes) after DigitallySigned", len(rest))},
 			StatusCode: httpRsp.StatusCode,
 			Body:       body,
 		}
@@ -88,7 +105,7 @@ func (c *LogClient) addChainWithRetry(ctx context.Context, ctype ct.LogEntryType
 	exts, err := base64.StdEncoding.DecodeString(resp.Extensions)
 	if err != nil {
 		return nil, RspError{
-			Err:        fmt.Errorf("invalid base64 data in Extensions (%q): %v", resp.Extensions, err),
+			Err:        &ParseError{Err: fmt.Errorf("invalid base64 data in Extensions (%q): %v", resp.Extensions, err)},
 			StatusCode: httpRsp.StatusCode,
 			Body:       body,
 		}
@@ -129,10 +146,10 @@ func (c *LogClient) AddJSON(ctx context.Context, data interface{}) (*ct.SignedCe
 	}
 	var ds ct.DigitallySigned
 	if rest, err := tls.Unmarshal(resp.Signature, &ds); err != nil {
-		return nil, RspError{Err: err, StatusCode: httpRsp.StatusCode, Body: body}
+		return nil, RspError{Err: &ParseError{Err: err}, StatusCode: httpRsp.StatusCode, Body: body}
 	} else if len(rest) > 0 {
 		return nil, RspError{
-			Err:        fmt.Errorf("trailing data (%d bytes) after DigitallySigned", len(rest)),
+			Err:        &ParseError{Err: fmt.Errorf("trailing data (%d bytes) after DigitallySigned", len(rest))},
 			StatusCode: httpRsp.StatusCode,
 			Body:       body,
 		}
@@ -160,7 +177,7 @@ func (c *LogClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
 
 	sth, err := resp.ToSignedTreeHead()
 	if err != nil {
-		return nil, RspError{Err: err, StatusCode: httpRsp.StatusCode, Body: body}
+		return nil, RspError{Err: &ParseError{Err: err}, StatusCode: httpRsp.StatusCode, Body: body}
 	}
 
 	if err := c.VerifySTHSignature(*sth); err != nil {
@@ -229,17 +246,72 @@ func (c *LogClient) GetAcceptedRoots(ctx context.Context) ([]ct.ASN1Cert, error)
 	if err != nil {
 		return nil, err
 	}
+	return parseRoots(&resp, httpRsp, body)
+}
+
+// ErrNotModified is returned by GetAcceptedRootsIfModified when the log's
+// accepted roots have not changed.
+var ErrNotModified = jsonclient.ErrNotModified
+
+// GetAcceptedRootsIfModified retrieves the set of acceptable root certificates
+// for a log, as for GetAcceptedRoots, but only if they have changed since they
+// were fetched with the given validators (e.g. as persisted by the caller).
+// Returns the roots and their validators; if the roots have not changed, the
+// given cached roots and validators are returned with ErrNotModified.
+func (c *LogClient) GetAcceptedRootsIfModified(ctx context.Context, cached []ct.ASN1Cert, v jsonclient.Validators) ([]ct.ASN1Cert, jsonclient.Validators, error) {
+	var resp ct.GetRootsResponse
+	httpRsp, body, err := c.GetAndParseIfModified(ctx, ct.GetRootsPath, nil, v, &resp)
+	if err == ErrNotModified {
+		return cached, v, err
+	}
+	if err != nil {
+		return nil, jsonclient.Validators{}, err
+	}
+	roots, err := parseRoots(&resp, httpRsp, body)
+	if err != nil {
+		return nil, jsonclient.Validators{}, err
+	}
+	return roots, jsonclient.ValidatorsFrom(httpRsp), nil
+}
+
+func parseRoots(resp *ct.GetRootsResponse, httpRsp *http.Response, body []byte) ([]ct.ASN1Cert, error) {
 	var roots []ct.ASN1Cert
 	for _, cert64 := range resp.Certificates {
 		cert, err := base64.StdEncoding.DecodeString(cert64)
 		if err != nil {
-			return nil, RspError{Err: err, StatusCode: httpRsp.StatusCode, Body: body}
+			return nil, RspError{Err: &ParseError{Err: err}, StatusCode: httpRsp.StatusCode, Body: body}
 		}
 		roots = append(roots, ct.ASN1Cert{Data: cert})
 	}
 	return roots, nil
 }
 
+// GetRootsCached retrieves the set of acceptable root certificates for a log,
+// as for GetAcceptedRoots, but only re-fetches them from the log once ttl has
+// elapsed since they were last fetched.  If a refresh fails, the error is
+// returned along with the last roots successfully fetched (if any), so that
+// the caller can decide whether to carry on with them.  Refreshes are
+// conditional requests (see GetAcceptedRootsIfModified), so unchanged roots
+// are not downloaded again.  The returned slice is shared between callers and
+// must not be modified.
+func (c *LogClient) GetRootsCached(ctx context.Context, ttl time.Duration) ([]ct.ASN1Cert, error) {
+	c.rootsMu.Lock()
+	defer c.rootsMu.Unlock()
+	if c.roots != nil && time.Since(c.rootsFetched) < ttl {
+		return c.roots, nil
+	}
+	var v jsonclient.Validators
+	if c.roots != nil {
+		v = c.rootsValidators
+	}
+	roots, v, err := c.GetAcceptedRootsIfModified(ctx, c.roots, v)
+	if err != nil && err != ErrNotModified {
+		return c.roots, err
+	}
+	c.roots, c.rootsValidators, c.rootsFetched = roots, v, time.Now()
+	return roots, nil
+}
+
 // GetEntryAndProof returns a log entry and audit path for the index of a leaf.
 func (c *LogClient) GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error) {
 	base10 := 10
@@ -20,6 +20,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -183,6 +184,340 @@ func TestGetEntries(t *testing.T) {
 	}
 }
 
+func TestGetParsedEntries(t *testing.T) {
+	ts := serveHandlerAt(t, "/ct/v1/get-entries", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"entries":[{"leaf_input": "%s","extra_data": "%s"},{"leaf_input": "AAEC","extra_data": ""},{"leaf_input": "%s","extra_data": "%s"}]}`,
+			PrecertEntryB64,
+			PrecertEntryExtraDataB64,
+			CertEntryB64,
+			CertEntryExtraDataB64)
+	})
+	defer ts.Close()
+	lc, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	entries, err := lc.GetParsedEntries(context.Background(), 10, 12)
+	var errs client.EntryErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("GetParsedEntries()=_,%v; want _,EntryErrors with 1 error", err)
+	}
+	if got, want := errs[0].Index, int64(11); got != want {
+		t.Errorf("GetParsedEntries() error Index=%d; want %d", got, want)
+	}
+	if got, want := errs[0].Leaf.LeafInput, []byte{0, 1, 2}; !bytes.Equal(got, want) {
+		t.Errorf("GetParsedEntries() error LeafInput=%x; want %x", got, want)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("GetParsedEntries()=%d entries,_; want 2", len(entries))
+	}
+	precert, cert := entries[0], entries[1]
+	if precert.Index != 10 || precert.Precert == nil || precert.Precert.TBSCertificate == nil || len(precert.Precert.Submitted.Data) == 0 || len(precert.Chain) == 0 {
+		t.Errorf("GetParsedEntries()[0]=%+v; want parsed precertificate entry 10 with chain", precert)
+	}
+	if cert.Index != 12 || cert.X509Cert == nil || len(cert.Chain) == 0 {
+		t.Errorf("GetParsedEntries()[1]=%+v; want parsed certificate entry 12 with chain", cert)
+	}
+}
+
+func TestEntryIterator(t *testing.T) {
+	var requests []string
+	failed := false
+	ts := serveHandlerAt(t, "/ct/v1/get-entries", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("end"), 10, 64)
+		requests = append(requests, fmt.Sprintf("%d-%d", start, end))
+		if start == 2 && !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// Return at most two entries, as a log with a lower page size would.
+		if end > start+1 {
+			end = start + 1
+		}
+		var entries []string
+		for i := start; i <= end; i++ {
+			entries = append(entries, fmt.Sprintf(`{"leaf_input": "%s","extra_data": "%s"}`, CertEntryB64, CertEntryExtraDataB64))
+		}
+		if _, err := fmt.Fprintf(w, `{"entries":[%s]}`, strings.Join(entries, ",")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer ts.Close()
+	lc, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	it := lc.EntryIterator(context.Background(), 0, 4, 3)
+	var got []int64
+	errs := 0
+	for {
+		entry, ok, err := it.Next()
+		if err != nil {
+			errs++
+			if errs > 1 {
+				t.Fatalf("Next()=_,_,%v; want no repeated error", err)
+			}
+			continue
+		}
+		if !ok {
+			break
+		}
+		got = append(got, entry.Index)
+	}
+	if want := []int64{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EntryIterator(0, 4) indices=%v; want %v", got, want)
+	}
+	if errs != 1 {
+		t.Errorf("EntryIterator(0, 4) returned %d errors; want 1", errs)
+	}
+	if want := []string{"0-2", "2-4", "2-4", "4-4"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("EntryIterator(0, 4) requests=%v; want %v", requests, want)
+	}
+	if entry, ok, err := it.Next(); entry != nil || ok || err != nil {
+		t.Errorf("Next() after end=%v,%t,%v; want nil,false,nil", entry, ok, err)
+	}
+}
+
+func TestEntryIteratorErrors(t *testing.T) {
+	var tests = []struct {
+		desc, rsp, want string
+	}{
+		{desc: "no entries", rsp: `{"entries":[]}`, want: "no entries"},
+		{desc: "bad json", rsp: `{"entries":[{"leaf_input":"bbbb","extra_data":"bbbb"}]}`, want: "failed to parse entry 5"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ts := serveRspAt(t, "/ct/v1/get-entries", test.rsp)
+			defer ts.Close()
+			lc, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			entry, ok, err := lc.EntryIterator(context.Background(), 5, 5, 10).Next()
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Next()=%v,%t,%v; want nil,false,err containing %q", entry, ok, err, test.want)
+			}
+		})
+	}
+}
+
+// serveEntries returns a server whose get-entries handler returns at most
+// pageSize entries per request, taking longer to respond for lower indices so
+// that concurrent requests complete out of order.  Requests for the entry at
+// failAt, if non-negative, fail.
+func serveEntries(t *testing.T, pageSize, failAt int64) *httptest.Server {
+	t.Helper()
+	return serveHandlerAt(t, "/ct/v1/get-entries", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("end"), 10, 64)
+		if end >= start+pageSize {
+			end = start + pageSize - 1
+		}
+		if failAt >= start && failAt <= end {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if delay := 20 - start; delay > 0 {
+			time.Sleep(time.Duration(delay) * time.Millisecond)
+		}
+		var entries []string
+		for i := start; i <= end; i++ {
+			entries = append(entries, fmt.Sprintf(`{"leaf_input": "%s","extra_data": "%s"}`, CertEntryB64, CertEntryExtraDataB64))
+		}
+		fmt.Fprintf(w, `{"entries":[%s]}`, strings.Join(entries, ","))
+	})
+}
+
+func TestScanRange(t *testing.T) {
+	ts := serveEntries(t, 3, -1)
+	defer ts.Close()
+	lc, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for _, test := range []struct {
+		start, end int64
+		workers    int
+	}{
+		{start: 0, end: 0, workers: 1},
+		{start: 0, end: 49, workers: 1},
+		{start: 0, end: 49, workers: 4},
+		{start: 7, end: 30, workers: 10},
+		{start: 5, end: 8, workers: 20},
+	} {
+		t.Run(fmt.Sprintf("%d-%d/%d", test.start, test.end, test.workers), func(t *testing.T) {
+			out := make(chan ct.LogEntry)
+			errc := make(chan error, 1)
+			go func() {
+				errc <- lc.ScanRange(context.Background(), test.start, test.end, test.workers, out)
+				close(out)
+			}()
+			var got, want []int64
+			for entry := range out {
+				if entry.X509Cert == nil {
+					t.Errorf("ScanRange() sent entry %d without a certificate", entry.Index)
+				}
+				got = append(got, entry.Index)
+			}
+			if err := <-errc; err != nil {
+				t.Fatalf("ScanRange()=%v; want nil", err)
+			}
+			for i := test.start; i <= test.end; i++ {
+				want = append(want, i)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ScanRange() sent indices %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestScanRangeErrors(t *testing.T) {
+	ts := serveEntries(t, 3, 20)
+	defer ts.Close()
+	lc, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		desc       string
+		start, end int64
+		workers    int
+		want       string
+	}{
+		{desc: "bad range", start: 5, end: 4, workers: 1, want: "invalid range"},
+		{desc: "negative start", start: -1, end: 4, workers: 1, want: "invalid range"},
+		{desc: "no workers", start: 0, end: 4, workers: 0, want: "invalid number of workers"},
+		{desc: "server error", start: 0, end: 49, workers: 4, want: "failed to get entries"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			out := make(chan ct.LogEntry, 100)
+			err := lc.ScanRange(ctx, test.start, test.end, test.workers, out)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("ScanRange()=%v; want error containing %q", err, test.want)
+			}
+			close(out)
+			// Only entries before the failure may have been sent, in order.
+			next := test.start
+			for entry := range out {
+				if entry.Index != next || entry.Index >= 20 {
+					t.Errorf("ScanRange() sent entry %d; want %d, before 20", entry.Index, next)
+				}
+				next++
+			}
+		})
+	}
+
+	// Nobody reads from out, so ScanRange stops when the context is done.
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := lc.ScanRange(cctx, 0, 10, 2, make(chan ct.LogEntry)); err != context.DeadlineExceeded {
+		t.Errorf("ScanRange(unread)=%v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestGetEntriesFull(t *testing.T) {
+	const treeSize = 10
+	sthRsp := fmt.Sprintf(`{"tree_size": %d, "timestamp": %d, "sha256_root_hash": "%s", "tree_head_signature": "%s"}`,
+		treeSize, int64(ValidSTHResponseTimestamp), ValidSTHResponseSHA256RootHash, ValidSTHResponseTreeHeadSignature)
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ct/v1/get-sth" {
+			fmt.Fprint(w, sthRsp)
+			return
+		}
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("end"), 10, 64)
+		requests = append(requests, fmt.Sprintf("%d-%d", start, end))
+		if start >= treeSize {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Return at most three entries, as a log with a lower page size would.
+		if end > start+2 {
+			end = start + 2
+		}
+		if end >= treeSize {
+			end = treeSize - 1
+		}
+		var entries []string
+		for i := start; i <= end; i++ {
+			entries = append(entries, fmt.Sprintf(`{"leaf_input": "%s","extra_data": "%s"}`, CertEntryB64, CertEntryExtraDataB64))
+		}
+		fmt.Fprintf(w, `{"entries":[%s]}`, strings.Join(entries, ","))
+	}))
+	defer ts.Close()
+	lc, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		desc         string
+		start, end   int64
+		want         int
+		wantPartial  bool
+		wantErr      bool
+		wantRequests []string
+	}{
+		{desc: "whole-range", start: 0, end: 9, want: 10, wantRequests: []string{"0-9", "3-9", "6-9", "9-9"}},
+		{desc: "single-batch", start: 2, end: 3, want: 2, wantRequests: []string{"2-3"}},
+		{desc: "beyond-tree-size", start: 5, end: 14, want: 5, wantPartial: true, wantRequests: []string{"5-9", "8-9"}},
+		{desc: "all-beyond-tree-size", start: 12, end: 14, wantErr: true},
+		{desc: "invalid-range", start: 4, end: 3, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			requests = nil
+			entries, err := lc.GetEntriesFull(ctx, test.start, test.end)
+			if gotPartial := errors.Is(err, client.ErrPartialResult); gotPartial != test.wantPartial {
+				t.Errorf("GetEntriesFull()=_,%v; want partial result %t", err, test.wantPartial)
+			}
+			if gotErr := err != nil && !test.wantPartial; gotErr != test.wantErr {
+				t.Errorf("GetEntriesFull()=_,%v; want error %t", err, test.wantErr)
+			}
+			if len(entries) != test.want {
+				t.Errorf("GetEntriesFull() returned %d entries; want %d", len(entries), test.want)
+			}
+			for i, entry := range entries {
+				if want := test.start + int64(i); entry.Index != want {
+					t.Errorf("GetEntriesFull()[%d].Index=%d; want %d", i, entry.Index, want)
+				}
+			}
+			if !reflect.DeepEqual(requests, test.wantRequests) {
+				t.Errorf("GetEntriesFull() requests=%v; want %v", requests, test.wantRequests)
+			}
+		})
+	}
+
+	// A log that stops returning entries gives a partial result too.
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ct/v1/get-sth" {
+			fmt.Fprint(w, sthRsp)
+			return
+		}
+		fmt.Fprint(w, `{"entries":[]}`)
+	}))
+	defer empty.Close()
+	lc, err = client.New(empty.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if entries, err := lc.GetEntriesFull(ctx, 0, 4); len(entries) != 0 || !errors.Is(err, client.ErrPartialResult) {
+		t.Errorf("GetEntriesFull(no entries)=%d entries,%v; want 0 entries, error wrapping ErrPartialResult", len(entries), err)
+	}
+}
+
 func TestGetEntriesErrors(t *testing.T) {
 	ctx := context.Background()
 	var tests = []struct {
@@ -310,6 +645,53 @@ func TestGetSTH(t *testing.T) {
 	}
 }
 
+// redirectTransport is an http.RoundTripper that sends every request to a
+// fixed host with an extra header, as a stand-in for an authenticating proxy.
+type redirectTransport struct {
+	host  string
+	calls int
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	req = req.Clone(req.Context())
+	req.URL.Host = rt.host
+	req.Header.Set("X-Proxy-Auth", "let-me-in")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestGetSTHCustomTransport(t *testing.T) {
+	ts := serveHandlerAt(t, "/ct/v1/get-sth", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Proxy-Auth"); got != "let-me-in" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"tree_size": %d, "timestamp": %d, "sha256_root_hash": "%s", "tree_head_signature": "%s"}`,
+			ValidSTHResponseTreeSize,
+			int64(ValidSTHResponseTimestamp),
+			ValidSTHResponseSHA256RootHash,
+			ValidSTHResponseTreeHeadSignature)
+	})
+	defer ts.Close()
+	rt := &redirectTransport{host: strings.TrimPrefix(ts.URL, "http://")}
+	// The log's host does not resolve, so requests only succeed if they go
+	// through the custom transport.
+	lc, err := client.New("http://ct.invalid", &http.Client{Transport: rt}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	sth, err := lc.GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("GetSTH()=_,%v; want _,nil", err)
+	}
+	if sth.TreeSize != ValidSTHResponseTreeSize {
+		t.Errorf("GetSTH().TreeSize=%d; want %d", sth.TreeSize, ValidSTHResponseTreeSize)
+	}
+	if rt.calls != 1 {
+		t.Errorf("GetSTH() made %d call(s) to custom transport; want 1", rt.calls)
+	}
+}
+
 func TestGetSTHErrors(t *testing.T) {
 	ctx := context.Background()
 	var tests = []struct {
@@ -716,6 +1098,117 @@ func TestGetAcceptedRoots(t *testing.T) {
 	}
 }
 
+func TestGetRootsCached(t *testing.T) {
+	requests := 0
+	fail := false
+	hs := serveHandlerAt(t, "/ct/v1/get-roots", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := fmt.Fprint(w, GetRootsResp); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer hs.Close()
+	lc, err := client.New(hs.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	first, err := lc.GetRootsCached(ctx, time.Hour)
+	if err != nil || len(first) < 1 {
+		t.Fatalf("GetRootsCached()=%d roots,%v; want roots,nil", len(first), err)
+	}
+	if got, err := lc.GetRootsCached(ctx, time.Hour); err != nil || !reflect.DeepEqual(got, first) {
+		t.Errorf("GetRootsCached() within TTL=%d roots,%v; want cached roots,nil", len(got), err)
+	}
+	if requests != 1 {
+		t.Errorf("GetRootsCached() within TTL made %d requests; want 1", requests)
+	}
+
+	// A zero TTL forces a refresh; when that fails, the cached roots are
+	// returned along with the error.
+	fail = true
+	got, err := lc.GetRootsCached(ctx, 0)
+	if err == nil {
+		t.Error("GetRootsCached() with failing refresh=_,nil; want error")
+	}
+	if !reflect.DeepEqual(got, first) {
+		t.Errorf("GetRootsCached() with failing refresh=%d roots; want %d cached roots", len(got), len(first))
+	}
+	if requests != 2 {
+		t.Errorf("GetRootsCached() after TTL made %d requests in total; want 2", requests)
+	}
+}
+
+func TestGetAcceptedRootsIfModified(t *testing.T) {
+	etag := `"v1"`
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	var conditional, full int
+	hs := serveHandlerAt(t, "/ct/v1/get-roots", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-None-Match") == etag {
+			if got := r.Header.Get("If-Modified-Since"); got != lastModified {
+				t.Errorf("If-Modified-Since=%q; want %q", got, lastModified)
+			}
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		if _, err := fmt.Fprint(w, GetRootsResp); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer hs.Close()
+	lc, err := client.New(hs.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	roots, v, err := lc.GetAcceptedRootsIfModified(ctx, nil, jsonclient.Validators{})
+	if err != nil || len(roots) < 1 {
+		t.Fatalf("GetAcceptedRootsIfModified(no validators)=%d roots,_,%v; want roots,_,nil", len(roots), err)
+	}
+	if want := (jsonclient.Validators{ETag: etag, LastModified: lastModified}); v != want {
+		t.Errorf("GetAcceptedRootsIfModified().Validators=%+v; want %+v", v, want)
+	}
+
+	got, gotV, err := lc.GetAcceptedRootsIfModified(ctx, roots, v)
+	if err != client.ErrNotModified {
+		t.Errorf("GetAcceptedRootsIfModified(unchanged)=_,_,%v; want _,_,ErrNotModified", err)
+	}
+	if !reflect.DeepEqual(got, roots) || gotV != v {
+		t.Errorf("GetAcceptedRootsIfModified(unchanged)=%d roots,%+v; want cached %d roots,%+v", len(got), gotV, len(roots), v)
+	}
+
+	// Once the roots change, they are fetched again.
+	etag = `"v2"`
+	if _, v, err = lc.GetAcceptedRootsIfModified(ctx, roots, v); err != nil || v.ETag != etag {
+		t.Errorf("GetAcceptedRootsIfModified(changed)=_,%+v,%v; want _,ETag %s,nil", v, err, etag)
+	}
+	if conditional != 1 || full != 2 {
+		t.Errorf("GetAcceptedRootsIfModified() made %d conditional and %d full responses; want 1 and 2", conditional, full)
+	}
+
+	// GetRootsCached refreshes with conditional requests.
+	first, err := lc.GetRootsCached(ctx, 0)
+	if err != nil {
+		t.Fatalf("GetRootsCached()=_,%v; want _,nil", err)
+	}
+	if got, err := lc.GetRootsCached(ctx, 0); err != nil || !reflect.DeepEqual(got, first) {
+		t.Errorf("GetRootsCached(unchanged)=%d roots,%v; want %d cached roots,nil", len(got), err, len(first))
+	}
+	if conditional != 2 || full != 3 {
+		t.Errorf("GetRootsCached() made %d conditional and %d full responses in total; want 2 and 3", conditional, full)
+	}
+}
+
 func TestGetAcceptedRootsErrors(t *testing.T) {
 	ctx := context.Background()
 	var tests = []struct {
@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// Metrics receives observations of the requests made to a log.
+type Metrics interface {
+	// ObserveRequest is called once each request completes, with the name of
+	// the CheckLogClient method (e.g. "GetSTH"), the request's latency and its
+	// error (nil on success).
+	ObserveRequest(method string, latency time.Duration, err error)
+}
+
+// NoopMetrics is a Metrics implementation that discards all observations.
+type NoopMetrics struct{}
+
+// ObserveRequest does nothing.
+func (NoopMetrics) ObserveRequest(string, time.Duration, error) {}
+
+// WithMetrics returns a CheckLogClient that performs each request using the
+// given client, reporting its outcome to m.  If m is nil, NoopMetrics is used.
+func WithMetrics(c CheckLogClient, m Metrics) CheckLogClient {
+	if m == nil {
+		m = NoopMetrics{}
+	}
+	return &metricsClient{c: c, m: m}
+}
+
+type metricsClient struct {
+	c CheckLogClient
+	m Metrics
+}
+
+func (mc *metricsClient) observe(method string, start time.Time, err error) {
+	mc.m.ObserveRequest(method, time.Since(start), err)
+}
+
+func (mc *metricsClient) BaseURI() string {
+	return mc.c.BaseURI()
+}
+
+func (mc *metricsClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	start := time.Now()
+	sth, err := mc.c.GetSTH(ctx)
+	mc.observe("GetSTH", start, err)
+	return sth, err
+}
+
+func (mc *metricsClient) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	start := time.Now()
+	proof, err := mc.c.GetSTHConsistency(ctx, first, second)
+	mc.observe("GetSTHConsistency", start, err)
+	return proof, err
+}
+
+func (mc *metricsClient) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	start := time.Now()
+	rsp, err := mc.c.GetProofByHash(ctx, hash, treeSize)
+	mc.observe("GetProofByHash", start, err)
+	return rsp, err
+}
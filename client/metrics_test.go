@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	method string
+	failed bool
+}
+
+type recordingMetrics struct {
+	observed []observation
+}
+
+func (r *recordingMetrics) ObserveRequest(method string, latency time.Duration, err error) {
+	r.observed = append(r.observed, observation{method: method, failed: err != nil})
+}
+
+func TestWithMetrics(t *testing.T) {
+	ctx := context.Background()
+	fc := &flakyClient{errs: []error{nil, httpErr(http.StatusNotFound), nil, httpErr(http.StatusServiceUnavailable)}}
+	rm := &recordingMetrics{}
+	// Retried requests are observed individually when metrics are innermost.
+	c := WithRetry(WithMetrics(fc, rm), RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+
+	c.GetSTH(ctx)
+	c.GetProofByHash(ctx, []byte{0x01}, 2)
+	c.GetSTHConsistency(ctx, 1, 2)
+	c.GetSTHConsistency(ctx, 1, 2)
+
+	want := []observation{
+		{method: "GetSTH"},
+		{method: "GetProofByHash", failed: true},
+		{method: "GetSTHConsistency"},
+		{method: "GetSTHConsistency", failed: true},
+		{method: "GetSTHConsistency"},
+	}
+	if len(rm.observed) != len(want) {
+		t.Fatalf("observed %+v; want %+v", rm.observed, want)
+	}
+	for i := range want {
+		if rm.observed[i] != want[i] {
+			t.Errorf("observed[%d]=%+v; want %+v", i, rm.observed[i], want[i])
+		}
+	}
+
+	// A nil Metrics is a no-op.
+	if _, err := WithMetrics(&flakyClient{}, nil).GetSTH(ctx); err != nil {
+		t.Errorf("GetSTH()=_,%v; want _,nil", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+
+	ct "github.com/google/certificate-transparency-go"
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit returns a CheckLogClient that waits on the limiter before each
+// request made using the given client.  If the request's context is done
+// before the limiter allows the request, the context's error is returned.
+func WithRateLimit(c CheckLogClient, limiter *rate.Limiter) CheckLogClient {
+	return &rateLimitClient{c: c, limiter: limiter}
+}
+
+type rateLimitClient struct {
+	c       CheckLogClient
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitClient) BaseURI() string {
+	return r.c.BaseURI()
+}
+
+func (r *rateLimitClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	if err := r.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.c.GetSTH(ctx)
+}
+
+func (r *rateLimitClient) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	if err := r.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.c.GetSTHConsistency(ctx, first, second)
+}
+
+func (r *rateLimitClient) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	if err := r.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.c.GetProofByHash(ctx, hash, treeSize)
+}
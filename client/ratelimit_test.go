@@ -0,0 +1,64 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	ctx := context.Background()
+	const interval = 20 * time.Millisecond
+	fc := &flakyClient{}
+	c := WithRateLimit(fc, rate.NewLimiter(rate.Every(interval), 1))
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetSTH(ctx); err != nil {
+			t.Fatalf("GetSTH()=_,%v; want _,nil", err)
+		}
+		if _, err := c.GetSTHConsistency(ctx, 1, 2); err != nil {
+			t.Fatalf("GetSTHConsistency()=_,%v; want _,nil", err)
+		}
+		if _, err := c.GetProofByHash(ctx, []byte{0x01}, 2); err != nil {
+			t.Fatalf("GetProofByHash()=_,%v; want _,nil", err)
+		}
+	}
+	// The first call uses the initial burst; each of the other five must wait.
+	if got, want := time.Since(start), 5*interval; got < want {
+		t.Errorf("6 calls took %v; want at least %v", got, want)
+	}
+	if fc.calls != 6 {
+		t.Errorf("made %d calls; want 6", fc.calls)
+	}
+
+	// A context that expires before the limiter allows a call fails it.
+	slow := WithRateLimit(fc, rate.NewLimiter(rate.Every(time.Hour), 1))
+	if _, err := slow.GetSTH(ctx); err != nil {
+		t.Fatalf("GetSTH()=_,%v; want _,nil", err)
+	}
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := slow.GetSTH(cctx); err == nil {
+		t.Error("GetSTH(context expires before limiter)=_,nil; want _,non-nil")
+	}
+	if fc.calls != 7 {
+		t.Errorf("made %d calls; want 7", fc.calls)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// RetryPolicy controls how requests to a log are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts at each request,
+	// including the first.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; the delay is
+	// multiplied by Multiplier for each subsequent retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Retryable, if set, decides whether a failed request should be retried;
+	// by default IsTransient is used.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy returns a new RetryPolicy with sensible defaults.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// WithRetry returns a CheckLogClient that performs each request using the
+// given client, retrying failed requests according to the policy.
+func WithRetry(c CheckLogClient, policy RetryPolicy) CheckLogClient {
+	if policy.Retryable == nil {
+		policy.Retryable = IsTransient
+	}
+	return &retryClient{c: c, policy: policy}
+}
+
+type retryClient struct {
+	c      CheckLogClient
+	policy RetryPolicy
+}
+
+// do invokes fn until it succeeds, fails with an error that is not retryable,
+// or the attempts are exhausted, returning the last error.
+func (r *retryClient) do(ctx context.Context, fn func() error) error {
+	wait := r.policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.policy.MaxAttempts || !r.policy.Retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if r.policy.Multiplier > 1 {
+			wait = time.Duration(float64(wait) * r.policy.Multiplier)
+		}
+		if r.policy.MaxBackoff > 0 && wait > r.policy.MaxBackoff {
+			wait = r.policy.MaxBackoff
+		}
+	}
+}
+
+func (r *retryClient) BaseURI() string {
+	return r.c.BaseURI()
+}
+
+func (r *retryClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	var sth *ct.SignedTreeHead
+	err := r.do(ctx, func() error {
+		var err error
+		sth, err = r.c.GetSTH(ctx)
+		return err
+	})
+	return sth, err
+}
+
+func (r *retryClient) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	var proof [][]byte
+	err := r.do(ctx, func() error {
+		var err error
+		proof, err = r.c.GetSTHConsistency(ctx, first, second)
+		return err
+	})
+	return proof, err
+}
+
+func (r *retryClient) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	var rsp *ct.GetProofByHashResponse
+	err := r.do(ctx, func() error {
+		var err error
+		rsp, err = r.c.GetProofByHash(ctx, hash, treeSize)
+		return err
+	})
+	return rsp, err
+}
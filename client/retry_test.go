@@ -0,0 +1,138 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// flakyClient is a CheckLogClient whose methods fail with the given errors
+// in turn, and succeed once the errors are exhausted.
+type flakyClient struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyClient) next() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyClient) BaseURI() string {
+	return "https://log.example.com"
+}
+
+func (f *flakyClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &ct.SignedTreeHead{TreeSize: 10}, nil
+}
+
+func (f *flakyClient) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return [][]byte{{0x01}}, nil
+}
+
+func (f *flakyClient) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &ct.GetProofByHashResponse{LeafIndex: 1}, nil
+}
+
+func httpErr(code int) error {
+	return RspError{Err: fmt.Errorf("got HTTP Status %q", http.StatusText(code)), StatusCode: code}
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Multiplier: 2}
+	methods := []struct {
+		name string
+		call func(c CheckLogClient) error
+	}{
+		{name: "GetSTH", call: func(c CheckLogClient) error {
+			_, err := c.GetSTH(ctx)
+			return err
+		}},
+		{name: "GetSTHConsistency", call: func(c CheckLogClient) error {
+			_, err := c.GetSTHConsistency(ctx, 1, 2)
+			return err
+		}},
+		{name: "GetProofByHash", call: func(c CheckLogClient) error {
+			_, err := c.GetProofByHash(ctx, []byte{0x01}, 2)
+			return err
+		}},
+	}
+	tests := []struct {
+		desc      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{desc: "success", wantCalls: 1},
+		{desc: "transient-5xx", errs: []error{httpErr(http.StatusServiceUnavailable), httpErr(http.StatusBadGateway)}, wantCalls: 3},
+		{desc: "transient-network", errs: []error{&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}}, wantCalls: 2},
+		{desc: "unparseable", errs: []error{RspError{Err: &ParseError{Err: errors.New("bad JSON")}, StatusCode: http.StatusOK}}, wantCalls: 1, wantErr: true},
+		{desc: "permanent", errs: []error{errors.New("invalid request")}, wantCalls: 1, wantErr: true},
+		{desc: "rate-limited", errs: []error{httpErr(http.StatusTooManyRequests)}, wantCalls: 2},
+		{desc: "not-found", errs: []error{httpErr(http.StatusNotFound)}, wantCalls: 1, wantErr: true},
+		{desc: "bad-request", errs: []error{httpErr(http.StatusBadRequest)}, wantCalls: 1, wantErr: true},
+		{desc: "exhausted", errs: []error{httpErr(500), httpErr(500), httpErr(500), httpErr(500)}, wantCalls: 3, wantErr: true},
+	}
+	for _, method := range methods {
+		for _, test := range tests {
+			t.Run(method.name+"/"+test.desc, func(t *testing.T) {
+				fc := &flakyClient{errs: append([]error(nil), test.errs...)}
+				err := method.call(WithRetry(fc, policy))
+				if gotErr := err != nil; gotErr != test.wantErr {
+					t.Errorf("%s()=%v; want error %v", method.name, err, test.wantErr)
+				}
+				if fc.calls != test.wantCalls {
+					t.Errorf("%s() made %d calls; want %d", method.name, fc.calls, test.wantCalls)
+				}
+			})
+		}
+	}
+}
+
+func TestWithRetryContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fc := &flakyClient{errs: []error{httpErr(http.StatusServiceUnavailable)}}
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	cancel()
+	if _, err := WithRetry(fc, policy).GetSTH(ctx); err != context.Canceled {
+		t.Errorf("GetSTH()=_,%v; want _,%v", err, context.Canceled)
+	}
+	if fc.calls != 1 {
+		t.Errorf("GetSTH() made %d calls; want 1", fc.calls)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// Bounds on the memory used by a StaticLogClient for caching, and on the
+// number of level-0 tiles that GetProofByHash scans for a leaf that is not
+// already indexed.
+const (
+	maxCachedTiles   = 1024
+	maxIndexedLeaves = 1 << 20
+	maxScannedTiles  = 64
+)
+
+// StaticLogClient is a CheckLogClient for a static (tiled) CT log, which
+// serves a checkpoint and the tiles of its Merkle tree rather than the RFC
+// 6962 get-sth, get-sth-consistency and get-proof-by-hash entrypoints.  Proofs
+// are computed locally from the log's tiles, which are cached.
+//
+// As a static log has no index of its leaf hashes, GetProofByHash finds the
+// index of a leaf by scanning the log's leaf hashes, starting from the most
+// recent; the indices of the leaf hashes seen are remembered for later calls,
+// up to a limit.  The scan is bounded, so a leaf that is older than the most
+// recent few thousand leaves of the tree, and not already indexed, gives an
+// error (which is not a 404, as the leaf may well be in the tree).
+// Proofs for earlier tree sizes are computed from the tiles published for the
+// latest checkpoint, as the log need not keep the partial tiles of earlier
+// tree sizes.  Where the index of a leaf is already known (e.g. from the leaf_index
+// extension of its SCT), GetProofByIndex avoids the scan.
+//
+// It is safe for concurrent use.
+type StaticLogClient struct {
+	uri     string
+	fetcher TileFetcher
+	keyDER  []byte
+	maxScan uint64 // level-0 tiles scanned by findLeaf
+
+	mu        sync.Mutex
+	published uint64                       // tree size of the latest checkpoint seen
+	indices   map[[sha256.Size]byte]uint64 // leaf hash => index
+	scanned   map[uint64]bool              // full level-0 tiles already indexed
+}
+
+// NewStaticLogClient builds a StaticLogClient for the static CT log with the
+// given monitoring URL prefix and DER-encoded public key, making requests
+// with the given HTTP client (http.DefaultClient if nil).
+func NewStaticLogClient(uri string, logKeyDER []byte, hc *http.Client) *StaticLogClient {
+	return NewStaticLogClientWithFetcher(uri, NewHTTPTileFetcher(uri, hc), logKeyDER)
+}
+
+// NewStaticLogClientWithFetcher builds a StaticLogClient that retrieves the
+// static CT log's resources with the given TileFetcher (e.g. from a local
+// mirror); uri is only used to identify the log.
+func NewStaticLogClientWithFetcher(uri string, fetcher TileFetcher, logKeyDER []byte) *StaticLogClient {
+	return &StaticLogClient{
+		uri:     strings.TrimRight(uri, "/"),
+		fetcher: &cachingTileFetcher{fetcher: fetcher, tiles: make(map[string][]byte)},
+		keyDER:  logKeyDER,
+		maxScan: maxScannedTiles,
+		indices: make(map[[sha256.Size]byte]uint64),
+		scanned: make(map[uint64]bool),
+	}
+}
+
+// BaseURI returns the monitoring URL prefix of the log.
+func (c *StaticLogClient) BaseURI() string {
+	return c.uri
+}
+
+// GetSTH retrieves the log's current checkpoint and returns the STH that it
+// carries.  The STH's signature is not verified.
+func (c *StaticLogClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	data, err := c.fetcher.Fetch(ctx, "checkpoint")
+	if err != nil {
+		return nil, err
+	}
+	sth, _, err := ct.STHFromCheckpoint(data, c.keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %v", err)
+	}
+	c.mu.Lock()
+	if sth.TreeSize > c.published {
+		c.published = sth.TreeSize
+	}
+	c.mu.Unlock()
+	return sth, nil
+}
+
+// reader returns a tileHashReader for the given tree size, which reads the
+// tiles published for the latest checkpoint, fetching the checkpoint if the
+// tree size is larger than that of any seen so far.
+func (c *StaticLogClient) reader(ctx context.Context, treeSize uint64) (*tileHashReader, error) {
+	c.mu.Lock()
+	published := c.published
+	c.mu.Unlock()
+	if published < treeSize {
+		sth, err := c.GetSTH(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if sth.TreeSize < treeSize {
+			return nil, fmt.Errorf("tree size %d exceeds checkpoint tree size %d", treeSize, sth.TreeSize)
+		}
+		published = sth.TreeSize
+	}
+	return newTileHashReaderAt(c.fetcher, treeSize, published), nil
+}
+
+// GetSTHConsistency computes a consistency proof between the given tree sizes
+// of the log from its tiles.
+func (c *StaticLogClient) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	if first > second {
+		return nil, fmt.Errorf("first tree size %d exceeds second tree size %d", first, second)
+	}
+	if first == 0 || first == second {
+		return nil, nil
+	}
+	r, err := c.reader(ctx, second)
+	if err != nil {
+		return nil, err
+	}
+	return r.consistencyProof(ctx, first, 0, second, true)
+}
+
+// GetProofByHash computes an inclusion proof for the leaf with the given hash
+// in the tree of the given size from the log's tiles.  If the leaf is not
+// found in the tree, the error is a RspError with status 404, as for a log
+// that serves get-proof-by-hash.
+func (c *StaticLogClient) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	if len(hash) != sha256.Size {
+		return nil, fmt.Errorf("leaf hash has length %d, want %d", len(hash), sha256.Size)
+	}
+	var leafHash [sha256.Size]byte
+	copy(leafHash[:], hash)
+	r, err := c.reader(ctx, treeSize)
+	if err != nil {
+		return nil, err
+	}
+	index, err := c.findLeaf(ctx, r, leafHash)
+	if err != nil {
+		return nil, err
+	}
+	return proofByIndex(ctx, r, index)
+}
+
+// GetProofByIndex computes an inclusion proof for the leaf with the given
+// index in the tree of the given size from the log's tiles.
+func (c *StaticLogClient) GetProofByIndex(ctx context.Context, index, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	r, err := c.reader(ctx, treeSize)
+	if err != nil {
+		return nil, err
+	}
+	return proofByIndex(ctx, r, index)
+}
+
+func proofByIndex(ctx context.Context, r *tileHashReader, index uint64) (*ct.GetProofByHashResponse, error) {
+	if index >= r.treeSize {
+		return nil, fmt.Errorf("leaf index %d out of range for tree size %d", index, r.treeSize)
+	}
+	proof, err := r.inclusionProof(ctx, index, 0, r.treeSize)
+	if err != nil {
+		return nil, err
+	}
+	return &ct.GetProofByHashResponse{LeafIndex: int64(index), AuditPath: proof}, nil
+}
+
+// findLeaf returns the index of the leaf with the given hash in the reader's
+// tree, scanning up to c.maxScan of the tree's level-0 tiles that are not
+// already indexed, from the most recent.  The leaf is only reported as not
+// found (with a 404) if the whole tree was searched.
+func (c *StaticLogClient) findLeaf(ctx context.Context, r *tileHashReader, leafHash [sha256.Size]byte) (uint64, error) {
+	treeSize := r.treeSize
+	c.mu.Lock()
+	index, ok := c.indices[leafHash]
+	c.mu.Unlock()
+	if ok && index < treeSize {
+		return index, nil
+	}
+
+	var scans uint64
+	for tile := (treeSize + TileWidth - 1) / TileWidth; tile > 0; tile-- {
+		c.mu.Lock()
+		done := c.scanned[tile-1]
+		c.mu.Unlock()
+		if done {
+			continue
+		}
+		if scans == c.maxScan {
+			return 0, fmt.Errorf("leaf hash %x not found in the latest %d unindexed tiles of tree of size %d; its leaf index is needed to find it", leafHash, scans, treeSize)
+		}
+		scans++
+		hashes, err := r.tile(ctx, 0, tile-1)
+		if err != nil {
+			return 0, err
+		}
+		// Once the index is full, tiles are still searched but no longer
+		// indexed, so that the leaves already indexed stay so.
+		found := false
+		c.mu.Lock()
+		record := len(c.indices)+len(hashes) <= maxIndexedLeaves
+		for i, h := range hashes {
+			var key [sha256.Size]byte
+			copy(key[:], h)
+			if record {
+				c.indices[key] = (tile-1)*TileWidth + uint64(i)
+			}
+			if key == leafHash {
+				index, found = (tile-1)*TileWidth+uint64(i), true
+			}
+		}
+		if record && len(hashes) == TileWidth {
+			c.scanned[tile-1] = true
+		}
+		c.mu.Unlock()
+		if found {
+			return index, nil
+		}
+	}
+	return 0, RspError{
+		Err:        fmt.Errorf("leaf hash %x not found in tree of size %d", leafHash, treeSize),
+		StatusCode: http.StatusNotFound,
+	}
+}
+
+// cachingTileFetcher is a TileFetcher that caches the tiles it fetches (but
+// not other resources, such as the checkpoint).  The contents of a tile path
+// never change, as a partial tile has a different path from the full tile.
+type cachingTileFetcher struct {
+	fetcher TileFetcher
+
+	mu    sync.Mutex
+	tiles map[string][]byte
+}
+
+func (f *cachingTileFetcher) Fetch(ctx context.Context, path string) ([]byte, error) {
+	if !strings.HasPrefix(path, "tile/") {
+		return f.fetcher.Fetch(ctx, path)
+	}
+	f.mu.Lock()
+	data, ok := f.tiles[path]
+	f.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	data, err := f.fetcher.Fetch(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.tiles) >= maxCachedTiles {
+		f.tiles = make(map[string][]byte)
+	}
+	f.tiles[path] = data
+	return data, nil
+}
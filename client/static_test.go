@@ -0,0 +1,271 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// countingFetcher is a TileFetcher that counts the fetches of each path.
+type countingFetcher struct {
+	TileFetcher
+	mu    sync.Mutex
+	count map[string]int
+}
+
+func (f *countingFetcher) Fetch(ctx context.Context, path string) ([]byte, error) {
+	f.mu.Lock()
+	f.count[path]++
+	f.mu.Unlock()
+	return f.TileFetcher.Fetch(ctx, path)
+}
+
+func (f *countingFetcher) total() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.count {
+		n += c
+	}
+	return n
+}
+
+// staticLog is a synthetic static CT log held in a temporary directory.
+type staticLog struct {
+	dir        string
+	tree       *merkle.InMemoryMerkleTree
+	leafHashes [][]byte
+	key        *ecdsa.PrivateKey
+	keyDER     []byte
+	verifier   *ct.SignatureVerifier
+}
+
+// newStaticLog writes the tiles of a tree of the given size, and a checkpoint
+// for it, to a new temporary directory.
+func newStaticLog(t *testing.T, size int) *staticLog {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	verifier, err := ct.NewSignatureVerifier(key.Public())
+	if err != nil {
+		t.Fatalf("failed to build verifier: %v", err)
+	}
+	sl := &staticLog{
+		dir:      dir,
+		tree:     merkle.NewInMemoryMerkleTree(rfc6962.DefaultHasher),
+		key:      key,
+		keyDER:   keyDER,
+		verifier: verifier,
+	}
+	for i := 0; i < size; i++ {
+		_, entry := sl.tree.AddLeaf([]byte(fmt.Sprintf("static-leaf-%d", i)))
+		sl.leafHashes = append(sl.leafHashes, entry.Hash())
+	}
+	writeTiles(t, dir, sl.leafHashes)
+
+	sth := ct.SignedTreeHead{
+		Version:   ct.V1,
+		TreeSize:  uint64(size),
+		Timestamp: uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+	}
+	copy(sth.SHA256RootHash[:], sl.tree.CurrentRoot().Hash())
+	data, err := ct.SerializeSTHSignatureInput(sth)
+	if err != nil {
+		t.Fatalf("failed to serialize STH: %v", err)
+	}
+	sig, err := tls.CreateSignature(*key, tls.SHA256, data)
+	if err != nil {
+		t.Fatalf("failed to sign STH: %v", err)
+	}
+	sth.TreeHeadSignature = ct.DigitallySigned(sig)
+	checkpoint, err := sth.ToCheckpoint("static.example.com/log", keyDER)
+	if err != nil {
+		t.Fatalf("failed to build checkpoint: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "checkpoint"), checkpoint, 0644); err != nil {
+		t.Fatalf("failed to write checkpoint: %v", err)
+	}
+	return sl
+}
+
+func TestStaticLogClient(t *testing.T) {
+	ctx := context.Background()
+	const size = 600
+	sl := newStaticLog(t, size)
+	defer os.RemoveAll(sl.dir)
+	server := httptest.NewServer(http.FileServer(http.Dir(sl.dir)))
+	defer server.Close()
+
+	fetcher := &countingFetcher{TileFetcher: NewFileTileFetcher(sl.dir), count: make(map[string]int)}
+	for _, c := range []struct {
+		desc   string
+		client *StaticLogClient
+	}{
+		{desc: "file", client: NewStaticLogClientWithFetcher("https://static.example.com/log", fetcher, sl.keyDER)},
+		{desc: "http", client: NewStaticLogClient(server.URL, sl.keyDER, nil)},
+	} {
+		t.Run(c.desc, func(t *testing.T) {
+			var lc CheckLogClient = c.client
+			sth, err := lc.GetSTH(ctx)
+			if err != nil {
+				t.Fatalf("GetSTH()=nil,%v; want _,nil", err)
+			}
+			if sth.TreeSize != size {
+				t.Errorf("GetSTH().TreeSize=%d; want %d", sth.TreeSize, size)
+			}
+			if err := sl.verifier.VerifySTHSignature(*sth); err != nil {
+				t.Errorf("VerifySTHSignature(GetSTH())=%v; want nil", err)
+			}
+
+			verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+			for _, treeSize := range []uint64{300, size} {
+				root := sl.tree.RootAtSnapshot(int64(treeSize)).Hash()
+				for _, index := range []uint64{treeSize - 1, 0, 255, 256, treeSize / 2} {
+					rsp, err := lc.GetProofByHash(ctx, sl.leafHashes[index], treeSize)
+					if err != nil {
+						t.Fatalf("GetProofByHash(leaf %d, size %d)=nil,%v; want _,nil", index, treeSize, err)
+					}
+					if rsp.LeafIndex != int64(index) {
+						t.Errorf("GetProofByHash(leaf %d, size %d).LeafIndex=%d; want %d", index, treeSize, rsp.LeafIndex, index)
+					}
+					if err := verifier.VerifyInclusionProof(rsp.LeafIndex, int64(treeSize), rsp.AuditPath, root, sl.leafHashes[index]); err != nil {
+						t.Errorf("VerifyInclusionProof(leaf %d, size %d)=%v; want nil", index, treeSize, err)
+					}
+				}
+			}
+
+			_, err = lc.GetProofByHash(ctx, sl.leafHashes[400], 300)
+			var rspErr RspError
+			if !errors.As(err, &rspErr) || rspErr.StatusCode != http.StatusNotFound {
+				t.Errorf("GetProofByHash(leaf beyond tree size)=_,%v; want RspError with status 404", err)
+			}
+			if _, err := lc.GetProofByHash(ctx, []byte{1, 2, 3}, size); err == nil {
+				t.Error("GetProofByHash(short hash)=_,nil; want _,non-nil")
+			}
+
+			proof, err := lc.GetSTHConsistency(ctx, 300, size)
+			if err != nil {
+				t.Fatalf("GetSTHConsistency()=nil,%v; want _,nil", err)
+			}
+			if err := verifier.VerifyConsistencyProof(300, size, sl.tree.RootAtSnapshot(300).Hash(), sl.tree.RootAtSnapshot(size).Hash(), proof); err != nil {
+				t.Errorf("VerifyConsistencyProof()=%v; want nil", err)
+			}
+		})
+	}
+
+	// Each tile is only fetched once by a client.
+	for path, count := range fetcher.count {
+		if path != "checkpoint" && count > 1 {
+			t.Errorf("tile %s fetched %d times by one client; want 1", path, count)
+		}
+	}
+
+	// Once a leaf's tile has been scanned, its index is remembered and the
+	// tiles for its proof are cached, so repeating the proof needs no fetches.
+	lc := NewStaticLogClientWithFetcher("https://static.example.com/log", fetcher, sl.keyDER)
+	if _, err := lc.GetProofByHash(ctx, sl.leafHashes[10], size); err != nil {
+		t.Fatalf("GetProofByHash()=nil,%v; want _,nil", err)
+	}
+	before := fetcher.total()
+	if _, err := lc.GetProofByHash(ctx, sl.leafHashes[10], size); err != nil {
+		t.Fatalf("GetProofByHash()=nil,%v; want _,nil", err)
+	}
+	if got := fetcher.total() - before; got != 0 {
+		t.Errorf("repeated GetProofByHash() made %d fetches; want 0", got)
+	}
+}
+
+func TestStaticLogClientBoundedScan(t *testing.T) {
+	ctx := context.Background()
+	const size = 600 // two full tiles and a partial one
+	sl := newStaticLog(t, size)
+	defer os.RemoveAll(sl.dir)
+	lc := NewStaticLogClientWithFetcher("https://static.example.com/log", NewFileTileFetcher(sl.dir), sl.keyDER)
+	lc.maxScan = 2
+
+	// A leaf beyond the latest two tiles is not reported as missing, and can
+	// be found by index.
+	_, err := lc.GetProofByHash(ctx, sl.leafHashes[10], size)
+	var rspErr RspError
+	if err == nil || (errors.As(err, &rspErr) && rspErr.StatusCode == http.StatusNotFound) {
+		t.Errorf("GetProofByHash(leaf 10)=_,%v; want non-404 error", err)
+	}
+	if rsp, err := lc.GetProofByIndex(ctx, 10, size); err != nil || rsp.LeafIndex != 10 {
+		t.Errorf("GetProofByIndex(10)=%+v,%v; want index 10", rsp, err)
+	}
+	// Leaves in the latest two tiles are found.
+	if rsp, err := lc.GetProofByHash(ctx, sl.leafHashes[300], size); err != nil || rsp.LeafIndex != 300 {
+		t.Errorf("GetProofByHash(leaf 300)=%+v,%v; want index 300", rsp, err)
+	}
+	// The indexed full tile is skipped, so a further tile is scanned.
+	if rsp, err := lc.GetProofByHash(ctx, sl.leafHashes[10], size); err != nil || rsp.LeafIndex != 10 {
+		t.Errorf("GetProofByHash(leaf 10, after indexing)=%+v,%v; want index 10", rsp, err)
+	}
+	// A leaf absent from a tree that fits in the scan is reported as missing.
+	_, err = lc.GetProofByHash(ctx, sl.leafHashes[400], 300)
+	if !errors.As(err, &rspErr) || rspErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetProofByHash(leaf beyond tree size)=_,%v; want RspError with status 404", err)
+	}
+}
+
+func TestStaticLogClientBadCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	sl := newStaticLog(t, 10)
+	defer os.RemoveAll(sl.dir)
+	other := newStaticLog(t, 1)
+	defer os.RemoveAll(other.dir)
+
+	// The checkpoint is not signed by the key given to the client.
+	lc := NewStaticLogClientWithFetcher("https://static.example.com/log", NewFileTileFetcher(sl.dir), other.keyDER)
+	if _, err := lc.GetSTH(ctx); err == nil {
+		t.Error("GetSTH(wrong key)=_,nil; want _,non-nil")
+	}
+	if err := os.Remove(filepath.Join(sl.dir, "checkpoint")); err != nil {
+		t.Fatalf("failed to remove checkpoint: %v", err)
+	}
+	lc = NewStaticLogClientWithFetcher("https://static.example.com/log", NewFileTileFetcher(sl.dir), sl.keyDER)
+	if _, err := lc.GetSTH(ctx); err == nil {
+		t.Error("GetSTH(no checkpoint)=_,nil; want _,non-nil")
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/trillian/merkle/rfc6962"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Static (tiled) CT logs publish the hashes of their Merkle tree as tiles,
+// each of which holds up to TileWidth consecutive hashes from a single level
+// of the tree.  Tile level L holds the hashes at Merkle tree level
+// L*TileHeight, so the hashes at intermediate levels are recomputed from the
+// tile below them.
+const (
+	// TileHeight is the number of Merkle tree levels covered by each tile.
+	TileHeight = 8
+	// TileWidth is the number of hashes held by a full tile.
+	TileWidth = 1 << TileHeight
+)
+
+// TileFetcher retrieves the resources published by a static CT log, given
+// their path relative to the log's prefix (e.g. "checkpoint" or
+// "tile/0/x001/234").
+type TileFetcher interface {
+	Fetch(ctx context.Context, path string) ([]byte, error)
+}
+
+// HTTPTileFetcher retrieves static CT log resources over HTTP.
+type HTTPTileFetcher struct {
+	prefix     string
+	httpClient *http.Client
+}
+
+// NewHTTPTileFetcher builds a TileFetcher for the static CT log with the given
+// URL prefix, using the given HTTP client (http.DefaultClient if nil).
+func NewHTTPTileFetcher(prefix string, hc *http.Client) *HTTPTileFetcher {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &HTTPTileFetcher{prefix: strings.TrimRight(prefix, "/"), httpClient: hc}
+}
+
+// Fetch retrieves the resource at the given path.
+func (f *HTTPTileFetcher) Fetch(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, f.prefix+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := ctxhttp.Do(ctx, f.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, RspError{Err: fmt.Errorf("failed to read response body: %v", err), StatusCode: rsp.StatusCode, Body: body}
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, RspError{Err: fmt.Errorf("got HTTP Status %q", rsp.Status), StatusCode: rsp.StatusCode, Body: body}
+	}
+	return body, nil
+}
+
+// FileTileFetcher retrieves static CT log resources from a local directory
+// that is laid out in the same way as the log's HTTP paths, e.g. a mirror.
+type FileTileFetcher struct {
+	dir string
+}
+
+// NewFileTileFetcher builds a TileFetcher that reads from the given directory.
+func NewFileTileFetcher(dir string) *FileTileFetcher {
+	return &FileTileFetcher{dir: dir}
+}
+
+// Fetch reads the resource at the given path.  A missing file is reported in
+// the same way as an HTTP 404 response, so callers can treat both fetchers
+// alike.
+func (f *FileTileFetcher) Fetch(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(f.dir, filepath.FromSlash(path)))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, os.ErrNotExist) {
+			statusCode = http.StatusNotFound
+		}
+		return nil, RspError{Err: err, StatusCode: statusCode}
+	}
+	return data, nil
+}
+
+// TilePath returns the path of the tile at the given tile level and index,
+// holding width hashes; a width less than TileWidth indicates a partial tile.
+func TilePath(level, index uint64, width int) string {
+	p := path.Join("tile", fmt.Sprint(level), tileIndexPath(index))
+	if width < TileWidth {
+		p += fmt.Sprintf(".p/%d", width)
+	}
+	return p
+}
+
+// tileIndexPath encodes a tile index as a sequence of 3-digit path elements,
+// all but the last of which are prefixed with "x".
+func tileIndexPath(index uint64) string {
+	elems := []string{fmt.Sprintf("%03d", index%1000)}
+	for index >= 1000 {
+		index /= 1000
+		elems = append([]string{fmt.Sprintf("x%03d", index%1000)}, elems...)
+	}
+	return strings.Join(elems, "/")
+}
+
+// tileHashReader reads Merkle tree hashes for a particular tree size from the
+// tiles of a static CT log, caching the tiles that it has fetched.  The tiles
+// are those published for a tree size at least as large, as a log need not
+// keep the partial tiles of earlier tree sizes.
+type tileHashReader struct {
+	fetcher       TileFetcher
+	treeSize      uint64
+	publishedSize uint64
+	tiles         map[string][][]byte
+}
+
+func newTileHashReader(fetcher TileFetcher, treeSize uint64) *tileHashReader {
+	return newTileHashReaderAt(fetcher, treeSize, treeSize)
+}
+
+// newTileHashReaderAt builds a tileHashReader for the given tree size that
+// reads the tiles published for the given (not smaller) tree size.
+func newTileHashReaderAt(fetcher TileFetcher, treeSize, publishedSize uint64) *tileHashReader {
+	if publishedSize < treeSize {
+		publishedSize = treeSize
+	}
+	return &tileHashReader{fetcher: fetcher, treeSize: treeSize, publishedSize: publishedSize, tiles: make(map[string][][]byte)}
+}
+
+// tileWidth returns the number of hashes in the tile at the given tile level
+// and index for the given tree size.
+func tileWidth(treeSize, level, index uint64) int {
+	count := treeSize >> (level * TileHeight)
+	if index == count/TileWidth {
+		return int(count % TileWidth)
+	}
+	return TileWidth
+}
+
+// tile returns the hashes held in the tile at the given tile level and index.
+func (r *tileHashReader) tile(ctx context.Context, level, index uint64) ([][]byte, error) {
+	width, published := tileWidth(r.treeSize, level, index), tileWidth(r.publishedSize, level, index)
+	p := TilePath(level, index, published)
+	if hashes, ok := r.tiles[p]; ok {
+		return hashes[:width], nil
+	}
+	data, err := r.fetcher.Fetch(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tile %s: %v", p, err)
+	}
+	if len(data) != published*sha256.Size {
+		return nil, fmt.Errorf("tile %s has %d bytes, want %d", p, len(data), published*sha256.Size)
+	}
+	hashes := make([][]byte, published)
+	for i := range hashes {
+		hashes[i] = data[i*sha256.Size : (i+1)*sha256.Size]
+	}
+	r.tiles[p] = hashes
+	return hashes[:width], nil
+}
+
+// nodeHash returns the hash of the complete subtree at the given Merkle tree
+// level and index, i.e. the subtree covering leaves [index<<level,
+// (index+1)<<level).
+func (r *tileHashReader) nodeHash(ctx context.Context, level, index uint64) ([]byte, error) {
+	tileLevel, subLevel := level/TileHeight, level%TileHeight
+	first := index << subLevel
+	hashes, err := r.tile(ctx, tileLevel, first/TileWidth)
+	if err != nil {
+		return nil, err
+	}
+	offset := first % TileWidth
+	if end := offset + 1<<subLevel; end > uint64(len(hashes)) {
+		return nil, fmt.Errorf("tile for level %d index %d is too short", level, index)
+	}
+	nodes := append([][]byte(nil), hashes[offset:offset+1<<subLevel]...)
+	for len(nodes) > 1 {
+		for i := 0; i < len(nodes)/2; i++ {
+			nodes[i] = rfc6962.DefaultHasher.HashChildren(nodes[2*i], nodes[2*i+1])
+		}
+		nodes = nodes[:len(nodes)/2]
+	}
+	return nodes[0], nil
+}
+
+// subtreeHash returns the Merkle tree hash of the leaves [start, end), where
+// start is a multiple of the largest power of two not exceeding end-start.
+func (r *tileHashReader) subtreeHash(ctx context.Context, start, end uint64) ([]byte, error) {
+	size := end - start
+	if size&(size-1) == 0 {
+		level := uint64(0)
+		for 1<<level < size {
+			level++
+		}
+		return r.nodeHash(ctx, level, start>>level)
+	}
+	k := largestPowerOfTwoBelow(size)
+	left, err := r.subtreeHash(ctx, start, start+k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := r.subtreeHash(ctx, start+k, end)
+	if err != nil {
+		return nil, err
+	}
+	return rfc6962.DefaultHasher.HashChildren(left, right), nil
+}
+
+// inclusionProof returns the RFC 6962 audit path for the leaf at index within
+// the leaves [start, end).
+func (r *tileHashReader) inclusionProof(ctx context.Context, index, start, end uint64) ([][]byte, error) {
+	if end-start <= 1 {
+		return nil, nil
+	}
+	k := largestPowerOfTwoBelow(end - start)
+	var proof [][]byte
+	var sibling []byte
+	var err error
+	if index < start+k {
+		if proof, err = r.inclusionProof(ctx, index, start, start+k); err != nil {
+			return nil, err
+		}
+		sibling, err = r.subtreeHash(ctx, start+k, end)
+	} else {
+		if proof, err = r.inclusionProof(ctx, index, start+k, end); err != nil {
+			return nil, err
+		}
+		sibling, err = r.subtreeHash(ctx, start, start+k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, sibling), nil
+}
+
+// consistencyProof returns the RFC 6962 SUBPROOF(m, D[start:end], complete)
+// for the leaves [start, end), where m is the number of those leaves that are
+// in the earlier tree.
+func (r *tileHashReader) consistencyProof(ctx context.Context, m, start, end uint64, complete bool) ([][]byte, error) {
+	if m == end-start {
+		if complete {
+			return nil, nil
+		}
+		hash, err := r.subtreeHash(ctx, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{hash}, nil
+	}
+	k := largestPowerOfTwoBelow(end - start)
+	var proof [][]byte
+	var sibling []byte
+	var err error
+	if m <= k {
+		if proof, err = r.consistencyProof(ctx, m, start, start+k, complete); err != nil {
+			return nil, err
+		}
+		sibling, err = r.subtreeHash(ctx, start+k, end)
+	} else {
+		if proof, err = r.consistencyProof(ctx, m-k, start+k, end, false); err != nil {
+			return nil, err
+		}
+		sibling, err = r.subtreeHash(ctx, start, start+k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, sibling), nil
+}
+
+// largestPowerOfTwoBelow returns the largest power of two strictly less than
+// n, for n > 1.
+func largestPowerOfTwoBelow(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// TileRootHash computes the root hash of the static CT log's Merkle tree at
+// the given size from its tiles.
+func TileRootHash(ctx context.Context, fetcher TileFetcher, treeSize uint64) ([]byte, error) {
+	if treeSize == 0 {
+		return rfc6962.DefaultHasher.EmptyRoot(), nil
+	}
+	return newTileHashReader(fetcher, treeSize).subtreeHash(ctx, 0, treeSize)
+}
+
+// TileInclusionProof computes the inclusion proof for the leaf at the given
+// index in the static CT log's Merkle tree at the given size, from its tiles.
+func TileInclusionProof(ctx context.Context, fetcher TileFetcher, index, treeSize uint64) ([][]byte, error) {
+	if index >= treeSize {
+		return nil, fmt.Errorf("leaf index %d out of range for tree size %d", index, treeSize)
+	}
+	return newTileHashReader(fetcher, treeSize).inclusionProof(ctx, index, 0, treeSize)
+}
+
+// TileConsistencyProof computes the consistency proof between the given tree
+// sizes of the static CT log's Merkle tree, from its tiles.
+func TileConsistencyProof(ctx context.Context, fetcher TileFetcher, first, second uint64) ([][]byte, error) {
+	if first > second {
+		return nil, fmt.Errorf("first tree size %d exceeds second tree size %d", first, second)
+	}
+	if first == 0 || first == second {
+		return nil, nil
+	}
+	return newTileHashReader(fetcher, second).consistencyProof(ctx, first, 0, second, true)
+}
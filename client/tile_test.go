@@ -0,0 +1,197 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+func TestTilePath(t *testing.T) {
+	tests := []struct {
+		level, index uint64
+		width        int
+		want         string
+	}{
+		{level: 0, index: 0, width: TileWidth, want: "tile/0/000"},
+		{level: 0, index: 1234067, width: TileWidth, want: "tile/0/x001/x234/067"},
+		{level: 1, index: 3, width: 17, want: "tile/1/003.p/17"},
+		{level: 2, index: 1000, width: TileWidth, want: "tile/2/x001/000"},
+	}
+	for _, test := range tests {
+		if got := TilePath(test.level, test.index, test.width); got != test.want {
+			t.Errorf("TilePath(%d, %d, %d)=%q; want %q", test.level, test.index, test.width, got, test.want)
+		}
+	}
+}
+
+// writeTiles writes the tiles for a tree of the given leaf hashes to dir.
+func writeTiles(t *testing.T, dir string, leafHashes [][]byte) {
+	t.Helper()
+	hashes := leafHashes
+	for level := uint64(0); len(hashes) > 0; level++ {
+		for index := 0; index*TileWidth < len(hashes); index++ {
+			end := (index + 1) * TileWidth
+			if end > len(hashes) {
+				end = len(hashes)
+			}
+			p := filepath.Join(dir, filepath.FromSlash(TilePath(level, uint64(index), end-index*TileWidth)))
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatalf("failed to create tile directory: %v", err)
+			}
+			if err := ioutil.WriteFile(p, bytes.Join(hashes[index*TileWidth:end], nil), 0644); err != nil {
+				t.Fatalf("failed to write tile: %v", err)
+			}
+		}
+		// Hash up to the first level of the next tile.
+		var next [][]byte
+		for i := 0; i+TileWidth <= len(hashes); i += TileWidth {
+			nodes := append([][]byte(nil), hashes[i:i+TileWidth]...)
+			for len(nodes) > 1 {
+				for j := 0; j < len(nodes)/2; j++ {
+					nodes[j] = rfc6962.DefaultHasher.HashChildren(nodes[2*j], nodes[2*j+1])
+				}
+				nodes = nodes[:len(nodes)/2]
+			}
+			next = append(next, nodes[0])
+		}
+		hashes = next
+	}
+}
+
+func TestTileInclusionProof(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "tiles")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const maxSize = 1000
+	tree := merkle.NewInMemoryMerkleTree(rfc6962.DefaultHasher)
+	var leafHashes [][]byte
+	for i := 0; i < maxSize; i++ {
+		_, entry := tree.AddLeaf([]byte(fmt.Sprintf("leaf-%d", i)))
+		leafHashes = append(leafHashes, entry.Hash())
+	}
+	sizes := []uint64{1, 7, 256, 257, 513, maxSize}
+	for _, size := range sizes {
+		writeTiles(t, dir, leafHashes[:size])
+	}
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	for _, fetcher := range []struct {
+		desc    string
+		fetcher TileFetcher
+	}{
+		{desc: "file", fetcher: NewFileTileFetcher(dir)},
+		{desc: "http", fetcher: NewHTTPTileFetcher(server.URL+"/", nil)},
+	} {
+		t.Run(fetcher.desc, func(t *testing.T) {
+			for _, size := range sizes {
+				root, err := TileRootHash(ctx, fetcher.fetcher, size)
+				if err != nil {
+					t.Fatalf("TileRootHash(%d)=_,%v; want _,nil", size, err)
+				}
+				if want := tree.RootAtSnapshot(int64(size)).Hash(); !bytes.Equal(root, want) {
+					t.Fatalf("TileRootHash(%d)=%x; want %x", size, root, want)
+				}
+				for _, index := range []uint64{0, size / 2, size - 1} {
+					proof, err := TileInclusionProof(ctx, fetcher.fetcher, index, size)
+					if err != nil {
+						t.Fatalf("TileInclusionProof(%d, %d)=_,%v; want _,nil", index, size, err)
+					}
+					if err := verifier.VerifyInclusionProof(int64(index), int64(size), proof, root, leafHashes[index]); err != nil {
+						t.Errorf("VerifyInclusionProof(%d, %d)=%v; want nil", index, size, err)
+					}
+				}
+			}
+			if _, err := TileInclusionProof(ctx, fetcher.fetcher, 0, maxSize+1); err == nil {
+				t.Error("TileInclusionProof(missing tiles)=_,nil; want _,non-nil")
+			}
+			if _, err := TileInclusionProof(ctx, fetcher.fetcher, maxSize, maxSize); err == nil {
+				t.Error("TileInclusionProof(index out of range)=_,nil; want _,non-nil")
+			}
+		})
+	}
+}
+
+func TestTileConsistencyProof(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "tiles")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const maxSize = 600
+	tree := merkle.NewInMemoryMerkleTree(rfc6962.DefaultHasher)
+	var leafHashes [][]byte
+	for i := 0; i < maxSize; i++ {
+		_, entry := tree.AddLeaf([]byte(fmt.Sprintf("leaf-%d", i)))
+		leafHashes = append(leafHashes, entry.Hash())
+	}
+	sizes := []uint64{1, 3, 256, 300, 512, maxSize}
+	for _, size := range sizes {
+		writeTiles(t, dir, leafHashes[:size])
+	}
+	fetcher := NewFileTileFetcher(dir)
+
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	for _, second := range sizes {
+		for _, first := range []uint64{0, 1, 2, 5, 128, 255, 256, 257, 299, 511, second} {
+			if first > second {
+				continue
+			}
+			proof, err := TileConsistencyProof(ctx, fetcher, first, second)
+			if err != nil {
+				t.Fatalf("TileConsistencyProof(%d, %d)=_,%v; want _,nil", first, second, err)
+			}
+			var want [][]byte
+			if first > 0 {
+				for _, node := range tree.SnapshotConsistency(int64(first), int64(second)) {
+					want = append(want, node.Value.Hash())
+				}
+			}
+			if !reflect.DeepEqual(proof, want) {
+				t.Errorf("TileConsistencyProof(%d, %d)=%x; want %x", first, second, proof, want)
+			}
+			if first == 0 {
+				continue
+			}
+			root1, root2 := tree.RootAtSnapshot(int64(first)).Hash(), tree.RootAtSnapshot(int64(second)).Hash()
+			if err := verifier.VerifyConsistencyProof(int64(first), int64(second), root1, root2, proof); err != nil {
+				t.Errorf("VerifyConsistencyProof(%d, %d)=%v; want nil", first, second, err)
+			}
+		}
+	}
+	if _, err := TileConsistencyProof(ctx, fetcher, 2, 1); err == nil {
+		t.Error("TileConsistencyProof(2, 1)=_,nil; want _,non-nil")
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/compact"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// entriesBatchSize is the number of entries requested from a log at a time.
+const entriesBatchSize = 1000
+
+// RawEntriesClient is implemented by log clients that can retrieve the raw
+// entries in a log, such as client.LogClient.
+type RawEntriesClient interface {
+	GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error)
+}
+
+// ComputeRootFromEntries independently calculates the root hash of the log's
+// Merkle tree at the given size, by retrieving every entry in the tree and
+// hashing them as described in RFC 6962 s2.1.  The tree is built incrementally
+// as entries arrive, so memory use does not grow with the tree size, but all of
+// the entries are downloaded: this is a full audit of the log, and is only
+// practical for small logs.  The log's client must implement RawEntriesClient.
+//
+// The result can be compared against the root hash in an STH for the same size.
+func ComputeRootFromEntries(ctx context.Context, li *LogInfo, treeSize uint64) ([sha256.Size]byte, error) {
+	return ComputeRootFromEntriesConcurrently(ctx, li, treeSize, 1)
+}
+
+// ComputeRootFromEntriesConcurrently calculates the root hash of the log's
+// Merkle tree at the given size in the same way as ComputeRootFromEntries, but
+// retrieves up to the given number of ranges of entries concurrently.  Ranges
+// are added to the tree in index order as they arrive; to bound memory use,
+// retrieval does not get more than 2*concurrency ranges ahead of the tree.
+func ComputeRootFromEntriesConcurrently(ctx context.Context, li *LogInfo, treeSize uint64, concurrency int) ([sha256.Size]byte, error) {
+	var root [sha256.Size]byte
+	ec, ok := li.Client.(RawEntriesClient)
+	if !ok {
+		return root, fmt.Errorf("client for %q log cannot retrieve entries", li.Description)
+	}
+	if concurrency < 1 {
+		return root, fmt.Errorf("invalid concurrency %d", concurrency)
+	}
+	if treeSize == 0 {
+		copy(root[:], rfc6962.DefaultHasher.EmptyRoot())
+		return root, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type chunk struct {
+		index  uint64
+		hashes [][]byte
+		err    error
+	}
+	chunks := (treeSize + entriesBatchSize - 1) / entriesBatchSize
+	results := make(chan chunk)
+	// window holds a token for each chunk that has been dispatched but not yet
+	// added to the tree, and workers holds a token for each chunk in flight.
+	window := make(chan struct{}, 2*concurrency)
+	workers := make(chan struct{}, concurrency)
+	go func() {
+		for i := uint64(0); i < chunks; i++ {
+			for _, tokens := range []chan struct{}{window, workers} {
+				select {
+				case tokens <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			go func(i uint64) {
+				defer func() { <-workers }()
+				start := i * entriesBatchSize
+				end := start + entriesBatchSize
+				if end > treeSize {
+					end = treeSize
+				}
+				hashes, err := leafHashes(ctx, li, ec, start, end)
+				select {
+				case results <- chunk{index: i, hashes: hashes, err: err}:
+				case <-ctx.Done():
+				}
+			}(i)
+		}
+	}()
+
+	factory := compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+	cr := factory.NewEmptyRange(0)
+	pending := make(map[uint64][][]byte)
+	for next := uint64(0); next < chunks; {
+		select {
+		case c := <-results:
+			if c.err != nil {
+				return root, c.err
+			}
+			pending[c.index] = c.hashes
+		case <-ctx.Done():
+			return root, ctx.Err()
+		}
+		for hashes, ok := pending[next]; ok; hashes, ok = pending[next] {
+			for _, hash := range hashes {
+				if err := cr.Append(hash, nil); err != nil {
+					return root, fmt.Errorf("failed to add entry %d to tree: %v", cr.End(), err)
+				}
+			}
+			delete(pending, next)
+			next++
+			<-window
+		}
+	}
+
+	hash, err := cr.GetRootHash(nil)
+	if err != nil {
+		return root, fmt.Errorf("failed to compute root hash: %v", err)
+	}
+	copy(root[:], hash)
+	return root, nil
+}
+
+// leafHashes retrieves the entries [start, end) from the log, returning their
+// leaf hashes.
+func leafHashes(ctx context.Context, li *LogInfo, ec RawEntriesClient, start, end uint64) ([][]byte, error) {
+	hashes := make([][]byte, 0, end-start)
+	for next := start; next < end; {
+		rsp, err := ec.GetRawEntries(ctx, int64(next), int64(end-1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get entries [%d, %d] from %q log: %v", next, end-1, li.Description, err)
+		}
+		if len(rsp.Entries) == 0 {
+			return nil, fmt.Errorf("no entries returned from %q log for [%d, %d]", li.Description, next, end-1)
+		}
+		if uint64(len(rsp.Entries)) > end-next {
+			return nil, fmt.Errorf("%q log returned %d entries for [%d, %d]", li.Description, len(rsp.Entries), next, end-1)
+		}
+		for _, entry := range rsp.Entries {
+			hashes = append(hashes, rfc6962.DefaultHasher.HashLeaf(entry.LeafInput))
+		}
+		next += uint64(len(rsp.Entries))
+	}
+	return hashes, nil
+}
+
+// RootAtSize returns the root hash of the log's Merkle tree at the given
+// (historical) size, which must not exceed the log's current tree size.  The
+// root is derived from a consistency proof between the given size and the
+// log's current (verified) STH, so it is only returned if it is consistent with
+// that STH.
+//
+// If the given size is a power of two, a consistency proof does not include
+// the older root (as RFC 6962 assumes the verifier already knows it), so it is
+// instead taken from the inclusion proof for the entry that immediately follows
+// the older tree; this requires the log's client to implement RawEntriesClient.
+func RootAtSize(ctx context.Context, li *LogInfo, size uint64) ([sha256.Size]byte, error) {
+	var root [sha256.Size]byte
+	sth, err := li.GetVerifiedSTH(ctx)
+	if err != nil {
+		return root, err
+	}
+
+	switch {
+	case size > sth.TreeSize:
+		return root, fmt.Errorf("size %d is beyond current tree size %d of %q log", size, sth.TreeSize, li.Description)
+	case size == sth.TreeSize:
+		return sth.SHA256RootHash, nil
+	case size == 0:
+		copy(root[:], rfc6962.DefaultHasher.EmptyRoot())
+		return root, nil
+	}
+
+	var hash []byte
+	if size&(size-1) == 0 {
+		hash, err = rootFromInclusionProof(ctx, li, size, sth)
+	} else {
+		var proof [][]byte
+		proof, err = li.Client.GetSTHConsistency(ctx, size, sth.TreeSize)
+		if err != nil {
+			return root, fmt.Errorf("failed to get consistency proof from %q log between sizes %d and %d: %v", li.Description, size, sth.TreeSize, err)
+		}
+		hash, err = rootFromConsistencyProof(size, sth.TreeSize, proof, sth.SHA256RootHash[:])
+	}
+	if err != nil {
+		return root, fmt.Errorf("failed to derive root of %q log at size %d from STH at size %d: %v", li.Description, size, sth.TreeSize, err)
+	}
+	copy(root[:], hash)
+	return root, nil
+}
+
+// rootFromConsistencyProof calculates the root hash of a tree of size1 from a
+// consistency proof between it and a tree of size2 with the given root, using
+// the verification algorithm of RFC 9162 s2.1.4.2.  The proof must be for a
+// size1 that is not a power of two, so that it starts with a hash in the older
+// tree.
+func rootFromConsistencyProof(size1, size2 uint64, proof [][]byte, root2 []byte) ([]byte, error) {
+	if len(proof) == 0 {
+		return nil, fmt.Errorf("empty consistency proof")
+	}
+	fn, sn := size1-1, size2-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return nil, fmt.Errorf("consistency proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = rfc6962.DefaultHasher.HashChildren(c, fr)
+			sr = rfc6962.DefaultHasher.HashChildren(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = rfc6962.DefaultHasher.HashChildren(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return nil, fmt.Errorf("consistency proof too short")
+	}
+	if !bytes.Equal(sr, root2) {
+		return nil, fmt.Errorf("consistency proof gives root %x at size %d, want %x", sr, size2, root2)
+	}
+	return fr, nil
+}
+
+// rootFromInclusionProof extracts the root hash of the tree of the given size,
+// which must be a power of two, from the (verified) inclusion proof for the
+// entry at index size in the tree described by the STH.  The older tree is a
+// complete subtree, so is the sibling of one of the nodes on that entry's path
+// to the root.
+func rootFromInclusionProof(ctx context.Context, li *LogInfo, size uint64, sth *ct.SignedTreeHead) ([]byte, error) {
+	ec, ok := li.Client.(RawEntriesClient)
+	if !ok {
+		return nil, fmt.Errorf("client for %q log cannot retrieve entries", li.Description)
+	}
+	rsp, err := ec.GetRawEntries(ctx, int64(size), int64(size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entry %d: %v", size, err)
+	}
+	if len(rsp.Entries) == 0 {
+		return nil, fmt.Errorf("no entry returned for index %d", size)
+	}
+	leafHash := rfc6962.DefaultHasher.HashLeaf(rsp.Entries[0].LeafInput)
+	proof, err := li.Client.GetProofByHash(ctx, leafHash, sth.TreeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetProofByHash(entry %d,size=%d): %v", size, sth.TreeSize, err)
+	}
+	if proof.LeafIndex != int64(size) {
+		return nil, fmt.Errorf("inclusion proof is for index %d, want %d", proof.LeafIndex, size)
+	}
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyInclusionProof(proof.LeafIndex, int64(sth.TreeSize), proof.AuditPath, sth.SHA256RootHash[:], leafHash); err != nil {
+		return nil, fmt.Errorf("failed to verify inclusion proof at size %d: %v", sth.TreeSize, err)
+	}
+	// The path ends with the roots of the right-hand subtrees split off at
+	// each level above the subtree whose left half is the older tree.
+	above := 0
+	for n := sth.TreeSize; ; above++ {
+		k := uint64(1)
+		for k<<1 < n {
+			k <<= 1
+		}
+		if k <= size {
+			break
+		}
+		n = k
+	}
+	if above >= len(proof.AuditPath) {
+		return nil, fmt.Errorf("inclusion proof too short")
+	}
+	return proof.AuditPath[len(proof.AuditPath)-1-above], nil
+}
+
+// ErrSizeMismatch indicates that the entries a log serves do not match the
+// tree size in its STH.
+var ErrSizeMismatch = errors.New("log entries do not match STH tree size")
+
+// AuditSizeConsistency checks that the entries served by the log agree with the
+// tree size of its current STH: the last entry in the tree should be
+// available, and no entry at or beyond the tree size should be.  An error
+// wrapping ErrSizeMismatch, describing the anomalies, is returned if not.  The
+// log's client must implement RawEntriesClient.
+// A failure to get the last entry is returned as a plain error, as it need not
+// be the log's fault, whereas any failure to get the entry beyond the tree
+// size is taken as a refusal to serve it.  If that entry is served, the STH is
+// fetched again, so that a log that has grown in the meantime is not flagged.
+func (li *LogInfo) AuditSizeConsistency(ctx context.Context) error {
+	ec, ok := li.Client.(RawEntriesClient)
+	if !ok {
+		return fmt.Errorf("client for %q log cannot retrieve entries", li.Description)
+	}
+	sth, err := li.getCheckedSTH(ctx)
+	if err != nil {
+		return err
+	}
+
+	var anomalies []string
+	if sth.TreeSize > 0 {
+		last := int64(sth.TreeSize) - 1
+		rsp, err := ec.GetRawEntries(ctx, last, last)
+		if err != nil {
+			return fmt.Errorf("failed to get last entry %d of %q log: %v", last, li.Description, err)
+		}
+		if len(rsp.Entries) != 1 {
+			anomalies = append(anomalies, fmt.Sprintf("got %d entries for last entry %d, want 1", len(rsp.Entries), last))
+		}
+	}
+	next := int64(sth.TreeSize)
+	if rsp, err := ec.GetRawEntries(ctx, next, next); err == nil && len(rsp.Entries) > 0 {
+		latest, err := li.getCheckedSTH(ctx)
+		if err != nil {
+			return err
+		}
+		if int64(latest.TreeSize) <= next {
+			anomalies = append(anomalies, fmt.Sprintf("served entry %d beyond tree size (still %d)", next, latest.TreeSize))
+		}
+	}
+	if len(anomalies) > 0 {
+		return fmt.Errorf("%w: %q log at tree size %d: %s", ErrSizeMismatch, li.Description, sth.TreeSize, strings.Join(anomalies, "; "))
+	}
+	return nil
+}
+
+// getCheckedSTH gets the log's current STH from its client and verifies its
+// signature.
+func (li *LogInfo) getCheckedSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	sth, err := li.Client.GetSTH(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
+	}
+	if err := li.VerifySTHSignature(*sth); err != nil {
+		return nil, fmt.Errorf("failed to verify current STH for %q log: %v", li.Description, err)
+	}
+	return sth, nil
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// checkOnly hides all but the client.CheckLogClient methods of a log client.
+type checkOnly struct {
+	client.CheckLogClient
+}
+
+func TestComputeRootFromEntries(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 2345)
+	// Return short batches, as real logs do.
+	fl.maxEntries = 300
+	li := fl.logInfo(t)
+
+	for _, size := range []uint64{0, 1, 7, 1000, 1001, 2345} {
+		got, err := ComputeRootFromEntries(ctx, li, size)
+		if err != nil {
+			t.Errorf("ComputeRootFromEntries(%d)=_,%v; want _,nil", size, err)
+			continue
+		}
+		want := fl.tree.RootAtSnapshot(int64(size)).Hash()
+		if size == 0 {
+			want = rfc6962.DefaultHasher.EmptyRoot()
+		}
+		if !bytes.Equal(got[:], want) {
+			t.Errorf("ComputeRootFromEntries(%d)=%x; want %x", size, got, want)
+		}
+	}
+
+	if _, err := ComputeRootFromEntries(ctx, li, 3000); err == nil {
+		t.Error("ComputeRootFromEntries(beyond log size)=_,nil; want _,non-nil")
+	}
+	noEntries := fl.logInfo(t)
+	noEntries.Client = checkOnly{fl}
+	if _, err := ComputeRootFromEntries(ctx, noEntries, 1); err == nil {
+		t.Error("ComputeRootFromEntries(client without entries)=_,nil; want _,non-nil")
+	}
+}
+
+// jitteryLog delays each GetRawEntries call by a varying amount, so that
+// concurrent requests complete out of order.
+type jitteryLog struct {
+	*fakeLog
+}
+
+func (j jitteryLog) GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error) {
+	time.Sleep(time.Duration((start*7919)%5) * time.Millisecond)
+	return j.fakeLog.GetRawEntries(ctx, start, end)
+}
+
+func TestComputeRootFromEntriesConcurrently(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 12345)
+	fl.maxEntries = 700
+	li := fl.logInfo(t)
+	li.Client = jitteryLog{fl}
+
+	for _, size := range []uint64{1, 999, 12345} {
+		want, err := ComputeRootFromEntries(ctx, li, size)
+		if err != nil {
+			t.Fatalf("ComputeRootFromEntries(%d)=_,%v; want _,nil", size, err)
+		}
+		for _, concurrency := range []int{2, 5, 16} {
+			got, err := ComputeRootFromEntriesConcurrently(ctx, li, size, concurrency)
+			if err != nil {
+				t.Errorf("ComputeRootFromEntriesConcurrently(%d, %d)=_,%v; want _,nil", size, concurrency, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("ComputeRootFromEntriesConcurrently(%d, %d)=%x; want %x", size, concurrency, got, want)
+			}
+		}
+	}
+
+	if _, err := ComputeRootFromEntriesConcurrently(ctx, li, 20000, 4); err == nil {
+		t.Error("ComputeRootFromEntriesConcurrently(beyond log size)=_,nil; want _,non-nil")
+	}
+	if _, err := ComputeRootFromEntriesConcurrently(ctx, li, 10, 0); err == nil {
+		t.Error("ComputeRootFromEntriesConcurrently(concurrency=0)=_,nil; want _,non-nil")
+	}
+}
+
+// badConsistencyLog returns consistency proofs with a corrupted first hash.
+type badConsistencyLog struct {
+	*fakeLog
+}
+
+func (b badConsistencyLog) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	proof, err := b.fakeLog.GetSTHConsistency(ctx, first, second)
+	if err != nil || len(proof) == 0 {
+		return proof, err
+	}
+	proof[0] = append([]byte{}, proof[0]...)
+	proof[0][0] ^= 0xff
+	return proof, nil
+}
+
+func TestRootAtSize(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 21)
+	li := fl.logInfo(t)
+
+	for size := uint64(0); size <= 21; size++ {
+		got, err := RootAtSize(ctx, li, size)
+		if err != nil {
+			t.Errorf("RootAtSize(%d)=_,%v; want _,nil", size, err)
+			continue
+		}
+		want := fl.tree.RootAtSnapshot(int64(size)).Hash()
+		if size == 0 {
+			want = rfc6962.DefaultHasher.EmptyRoot()
+		}
+		if !bytes.Equal(got[:], want) {
+			t.Errorf("RootAtSize(%d)=%x; want %x", size, got, want)
+		}
+	}
+	if got := li.LastSTH(); got == nil || got.TreeSize != 21 {
+		t.Errorf("LastSTH()=%v; want STH at size 21", got)
+	}
+
+	if _, err := RootAtSize(ctx, li, 22); err == nil {
+		t.Error("RootAtSize(beyond log size)=_,nil; want _,non-nil")
+	}
+	noEntries := fl.logInfo(t)
+	noEntries.Client = checkOnly{fl}
+	if _, err := RootAtSize(ctx, noEntries, 13); err != nil {
+		t.Errorf("RootAtSize(13, client without entries)=_,%v; want _,nil", err)
+	}
+	if _, err := RootAtSize(ctx, noEntries, 16); err == nil {
+		t.Error("RootAtSize(16, client without entries)=_,nil; want _,non-nil")
+	}
+	tampered := fl.logInfo(t)
+	tampered.Client = badConsistencyLog{fl}
+	if _, err := RootAtSize(ctx, tampered, 13); err == nil {
+		t.Error("RootAtSize(13, corrupt consistency proof)=_,nil; want _,non-nil")
+	}
+}
+
+// sizeLog serves a different number of entries than the tree size of its
+// STH: the STH is for the first sthSize entries of the underlying log, while
+// only the first entries are served.
+type sizeLog struct {
+	*fakeLog
+	sthSize, entries int64
+}
+
+func (s sizeLog) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	return s.sthAt(uint64(s.sthSize))
+}
+
+func (s sizeLog) GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error) {
+	if start >= s.entries {
+		return nil, errors.New("entry not found")
+	}
+	return s.fakeLog.GetRawEntries(ctx, start, end)
+}
+
+// growingLog serves all of the entries of the underlying log, but reports an
+// STH for a smaller tree the first time it is asked, as for a log that grows
+// between requests.
+type growingLog struct {
+	*fakeLog
+	sths *int
+}
+
+func (g growingLog) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	*g.sths++
+	if *g.sths == 1 {
+		return g.sthAt(8)
+	}
+	return g.fakeLog.GetSTH(ctx)
+}
+
+func TestAuditSizeConsistency(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 10)
+	tests := []struct {
+		desc    string
+		client  client.CheckLogClient
+		wantErr bool
+		want    string
+	}{
+		{desc: "consistent", client: fl},
+		{desc: "consistent-empty", client: sizeLog{fl, 0, 0}},
+		{desc: "serves-beyond-sth", client: sizeLog{fl, 8, 10}, wantErr: true, want: "beyond tree size"},
+		{desc: "missing-last-entry", client: sizeLog{fl, 8, 6}, wantErr: true, want: "last entry 7"},
+		{desc: "grown", client: growingLog{fl, new(int)}},
+		{desc: "no-entries-client", client: checkOnly{fl}, wantErr: true, want: "cannot retrieve entries"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			li := fl.logInfo(t)
+			li.Client = test.client
+			err := li.AuditSizeConsistency(ctx)
+			if !test.wantErr {
+				if err != nil {
+					t.Errorf("AuditSizeConsistency()=%v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("AuditSizeConsistency()=%v; want error containing %q", err, test.want)
+			}
+		})
+	}
+
+	li := fl.logInfo(t)
+	li.Client = sizeLog{fl, 8, 10}
+	if err := li.AuditSizeConsistency(ctx); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("AuditSizeConsistency()=%v; want error wrapping ErrSizeMismatch", err)
+	}
+	// A failure to get the last entry is not taken as a mismatch.
+	li.Client = sizeLog{fl, 8, 6}
+	if err := li.AuditSizeConsistency(ctx); err == nil || errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("AuditSizeConsistency(missing last entry)=%v; want error not wrapping ErrSizeMismatch", err)
+	}
+}
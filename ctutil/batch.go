@@ -0,0 +1,203 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// The batch operations on LogInfoByHash share a common convention for
+// handling failures and cancellation:
+//  - the returned results map is always non-nil, and holds an entry for
+//    every log whose operation completed successfully;
+//  - if the context is cancelled (or its deadline passes) before all of the
+//    operations complete, the returned error wraps the context's error, so
+//    callers can check for it with errors.Is(err, context.Canceled) and still
+//    salvage the partial results;
+//  - otherwise, the returned error (if any) summarizes the logs that failed.
+
+// batchError describes the failure of some of the operations in a batch.
+type batchError map[[sha256.Size]byte]error
+
+func (be batchError) Error() string {
+	var msgs []string
+	for _, err := range be {
+		msgs = append(msgs, err.Error())
+	}
+	sort.Strings(msgs)
+	return fmt.Sprintf("%d log(s) failed: %s", len(be), strings.Join(msgs, "; "))
+}
+
+// runBatch invokes fn concurrently for each of the given keys, and waits for
+// all of the invocations to complete.  The fn function is expected to honour
+// cancellation of the context it is passed.
+func runBatch(ctx context.Context, keys [][sha256.Size]byte, fn func(ctx context.Context, key [sha256.Size]byte) error) error {
+	var mu sync.Mutex
+	failed := make(batchError)
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key [sha256.Size]byte) {
+			defer wg.Done()
+			if err := fn(ctx, key); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				failed[key] = err
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("batch interrupted with %d of %d operation(s) complete: %w", len(keys)-len(failed), len(keys), err)
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// CollectSTHs retrieves the current STH from each of the logs concurrently,
+// recording it as the last known STH for the log.  Returns the STHs that were
+// successfully retrieved, indexed by log key hash.
+func (m LogInfoByHash) CollectSTHs(ctx context.Context) (map[[sha256.Size]byte]*ct.SignedTreeHead, error) {
+	keys := make([][sha256.Size]byte, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	var mu sync.Mutex
+	results := make(map[[sha256.Size]byte]*ct.SignedTreeHead)
+	err := runBatch(ctx, keys, func(ctx context.Context, key [sha256.Size]byte) error {
+		li := m[key]
+		sth, err := li.Client.GetSTH(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
+		}
+		li.SetSTH(sth)
+		mu.Lock()
+		defer mu.Unlock()
+		results[key] = sth
+		return nil
+	})
+	return results, err
+}
+
+// VerifyInclusionForSCTs checks that the given Merkle tree leaf is present in
+// the latest known tree size of each of the logs that issued the given SCTs,
+// adjusting the leaf for the timestamp in each SCT.  Returns the index of the
+// leaf in each log where inclusion was verified, indexed by log key hash.
+// SCTs from logs that are not in the map are reported as failures.
+func (m LogInfoByHash) VerifyInclusionForSCTs(ctx context.Context, leaf ct.MerkleTreeLeaf, scts []ct.SignedCertificateTimestamp) (map[[sha256.Size]byte]int64, error) {
+	sctByKey := make(map[[sha256.Size]byte]ct.SignedCertificateTimestamp)
+	keys := make([][sha256.Size]byte, 0, len(scts))
+	for _, sct := range scts {
+		key := sct.LogID.KeyID
+		if _, ok := sctByKey[key]; ok {
+			continue
+		}
+		sctByKey[key] = sct
+		keys = append(keys, key)
+	}
+
+	var mu sync.Mutex
+	results := make(map[[sha256.Size]byte]int64)
+	err := runBatch(ctx, keys, func(ctx context.Context, key [sha256.Size]byte) error {
+		li, err := m.MustLogForSCT(sctByKey[key])
+		if err != nil {
+			return err
+		}
+		index, err := li.VerifyInclusionLatest(ctx, leaf, sctByKey[key].Timestamp)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		results[key] = index
+		return nil
+	})
+	return results, err
+}
+
+// VerifyInclusionQuorum checks that the given Merkle tree leaf is present in
+// the latest known tree size of at least quorum distinct logs among those that
+// issued the given SCTs, as for VerifyInclusionForSCTs.  The logs are checked
+// concurrently, and nil is returned as soon as quorum of them have verified
+// inclusion, cancelling the remaining checks.  Several SCTs from the same log
+// only count once towards the quorum.  Once so many logs have failed that the
+// quorum cannot be met, the remaining checks are likewise cancelled, and the
+// error summarizes the logs that failed (including SCTs from logs that are
+// not in the map); it wraps the context's error if the context is done first.
+func (m LogInfoByHash) VerifyInclusionQuorum(ctx context.Context, leaf ct.MerkleTreeLeaf, scts []ct.SignedCertificateTimestamp, quorum int) error {
+	if quorum < 1 {
+		return fmt.Errorf("quorum of %d logs is not positive", quorum)
+	}
+	sctByKey := make(map[[sha256.Size]byte]ct.SignedCertificateTimestamp)
+	for _, sct := range scts {
+		if _, ok := sctByKey[sct.LogID.KeyID]; !ok {
+			sctByKey[sct.LogID.KeyID] = sct
+		}
+	}
+	if len(sctByKey) < quorum {
+		return fmt.Errorf("SCTs from %d distinct log(s) cannot meet quorum of %d", len(sctByKey), quorum)
+	}
+
+	type result struct {
+		key [sha256.Size]byte
+		err error
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, len(sctByKey))
+	for key, sct := range sctByKey {
+		go func(key [sha256.Size]byte, sct ct.SignedCertificateTimestamp) {
+			li, err := m.MustLogForSCT(sct)
+			if err == nil {
+				_, err = li.VerifyInclusionLatest(cctx, leaf, sct.Timestamp)
+			}
+			results <- result{key: key, err: err}
+		}(key, sct)
+	}
+
+	verified := 0
+	failed := make(batchError)
+	for range sctByKey {
+		var r result
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return fmt.Errorf("quorum check interrupted with inclusion verified in %d of %d required log(s): %w", verified, quorum, ctx.Err())
+		}
+		if r.err != nil {
+			failed[r.key] = r.err
+		} else {
+			verified++
+		}
+		if verified >= quorum {
+			return nil
+		}
+		if len(sctByKey)-len(failed) < quorum {
+			break
+		}
+	}
+	return fmt.Errorf("inclusion verified in %d of %d required log(s): %w", verified, quorum, failed)
+}
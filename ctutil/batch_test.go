@@ -0,0 +1,234 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// stallingLog is a fakeLog whose GetSTH calls block until their context is
+// done, after first invoking the stall hook.
+type stallingLog struct {
+	*fakeLog
+	stall func()
+}
+
+func (s *stallingLog) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	s.stall()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// notifyingLog is a fakeLog that invokes a callback after each GetSTH call.
+type notifyingLog struct {
+	*fakeLog
+	done func()
+}
+
+func (n *notifyingLog) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	defer n.done()
+	return n.fakeLog.GetSTH(ctx)
+}
+
+func TestCollectSTHs(t *testing.T) {
+	ctx := context.Background()
+	m := make(LogInfoByHash)
+	for i := 0; i < 3; i++ {
+		fl := newFakeLog(t, fmt.Sprintf("https://log%d.example.com", i))
+		fl.addLeaves(t, i+1)
+		li := fl.logInfo(t)
+		m[sha256.Sum256(li.PublicKey)] = li
+	}
+
+	got, err := m.CollectSTHs(ctx)
+	if err != nil {
+		t.Fatalf("CollectSTHs()=_,%v; want _,nil", err)
+	}
+	if len(got) != len(m) {
+		t.Errorf("CollectSTHs() returned %d STHs; want %d", len(got), len(m))
+	}
+	for key, li := range m {
+		if got[key] == nil || li.LastSTH() != got[key] {
+			t.Errorf("CollectSTHs() did not record STH for %q", li.Description)
+		}
+	}
+}
+
+func TestCollectSTHsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := make(LogInfoByHash)
+	var fast sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		fl := newFakeLog(t, fmt.Sprintf("https://log%d.example.com", i))
+		fl.addLeaves(t, 1)
+		li := fl.logInfo(t)
+		m[sha256.Sum256(li.PublicKey)] = li
+		fast.Add(1)
+		li.Client = &notifyingLog{fakeLog: fl, done: fast.Done}
+	}
+	// Cancel the batch once all of the other logs have responded.
+	fl := newFakeLog(t, "https://slow.example.com")
+	slow := fl.logInfo(t)
+	slow.Client = &stallingLog{fakeLog: fl, stall: func() {
+		fast.Wait()
+		cancel()
+	}}
+	slowKey := sha256.Sum256(slow.PublicKey)
+	m[slowKey] = slow
+
+	got, err := m.CollectSTHs(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CollectSTHs()=_,%v; want error wrapping %v", err, context.Canceled)
+	}
+	if got == nil {
+		t.Fatal("CollectSTHs() returned nil results")
+	}
+	if len(got) != len(m)-1 {
+		t.Errorf("CollectSTHs() returned %d STHs; want %d", len(got), len(m)-1)
+	}
+	if _, ok := got[slowKey]; ok {
+		t.Error("CollectSTHs() returned STH for cancelled log")
+	}
+}
+
+func TestVerifyInclusionForSCTs(t *testing.T) {
+	ctx := context.Background()
+	leaf := testLeaf(1)
+	m := make(LogInfoByHash)
+	var scts []ct.SignedCertificateTimestamp
+	want := make(map[[sha256.Size]byte]int64)
+	for i := 0; i < 3; i++ {
+		fl := newFakeLog(t, fmt.Sprintf("https://log%d.example.com", i))
+		fl.addLeaves(t, i)
+		timestamp := uint64(1000 + i)
+		index := fl.addLeaf(t, stamped(leaf, timestamp))
+		li := fl.logInfo(t)
+		key := sha256.Sum256(li.PublicKey)
+		m[key] = li
+		want[key] = index
+		scts = append(scts, fl.signSCT(t, leaf, timestamp))
+	}
+	unknown := newFakeLog(t, "https://unknown.example.com")
+	scts = append(scts, unknown.signSCT(t, leaf, 1))
+
+	got, err := m.VerifyInclusionForSCTs(ctx, leaf, scts)
+	if err == nil {
+		t.Error("VerifyInclusionForSCTs()=_,nil; want error for unknown log")
+	}
+	if len(got) != len(want) {
+		t.Errorf("VerifyInclusionForSCTs() returned %d results; want %d", len(got), len(want))
+	}
+	for key, index := range want {
+		if got[key] != index {
+			t.Errorf("VerifyInclusionForSCTs()[%x]=%d; want %d", key, got[key], index)
+		}
+	}
+	if leaf.TimestampedEntry.Timestamp != 0 {
+		t.Errorf("VerifyInclusionForSCTs() modified leaf timestamp to %d", leaf.TimestampedEntry.Timestamp)
+	}
+}
+
+// blockingLog is a fakeLog whose GetSTH calls block until their context is
+// done, closing the done channel when they return.
+type blockingLog struct {
+	*fakeLog
+	done chan struct{}
+}
+
+func (b *blockingLog) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	<-ctx.Done()
+	close(b.done)
+	return nil, ctx.Err()
+}
+
+func TestVerifyInclusionQuorum(t *testing.T) {
+	ctx := context.Background()
+	leaf := testLeaf(1)
+	m := make(LogInfoByHash)
+	var good []ct.SignedCertificateTimestamp
+	for i := 0; i < 3; i++ {
+		fl := newFakeLog(t, fmt.Sprintf("https://log%d.example.com", i))
+		fl.addLeaves(t, i)
+		timestamp := uint64(1000 + i)
+		fl.addLeaf(t, stamped(leaf, timestamp))
+		li := fl.logInfo(t)
+		m[sha256.Sum256(li.PublicKey)] = li
+		good = append(good, fl.signSCT(t, leaf, timestamp))
+	}
+	unknown := newFakeLog(t, "https://unknown.example.com").signSCT(t, leaf, 1)
+	fl := newFakeLog(t, "https://blocking.example.com")
+	fl.addLeaf(t, stamped(leaf, 2000))
+	blocking := &blockingLog{fakeLog: fl, done: make(chan struct{})}
+	li := fl.logInfo(t)
+	li.Client = blocking
+	m[sha256.Sum256(li.PublicKey)] = li
+	blocked := fl.signSCT(t, leaf, 2000)
+
+	// Once the quorum is met, the outstanding checks are cancelled.
+	scts := append([]ct.SignedCertificateTimestamp{blocked, unknown}, good...)
+	if err := m.VerifyInclusionQuorum(ctx, leaf, scts, 3); err != nil {
+		t.Errorf("VerifyInclusionQuorum(quorum 3)=%v; want nil", err)
+	}
+	select {
+	case <-blocking.done:
+	case <-time.After(5 * time.Second):
+		t.Error("VerifyInclusionQuorum() did not cancel outstanding check")
+	}
+
+	tests := []struct {
+		desc    string
+		scts    []ct.SignedCertificateTimestamp
+		quorum  int
+		wantErr string
+	}{
+		{desc: "one", scts: good[:1], quorum: 1},
+		{desc: "failures", scts: append([]ct.SignedCertificateTimestamp{unknown}, good...), quorum: 4, wantErr: "unknown log"},
+		{desc: "same-log", scts: []ct.SignedCertificateTimestamp{good[0], good[0], good[0]}, quorum: 2, wantErr: "1 distinct log"},
+		{desc: "zero", scts: good, quorum: 0, wantErr: "not positive"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := m.VerifyInclusionQuorum(ctx, leaf, test.scts, test.quorum)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifyInclusionQuorum()=%v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("VerifyInclusionQuorum()=%v; want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+
+	// A quorum that needs a stalled log fails when the context is done.
+	blocking.done = make(chan struct{})
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := m.VerifyInclusionQuorum(cctx, leaf, append([]ct.SignedCertificateTimestamp{blocked}, good...), 4); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("VerifyInclusionQuorum(stalled)=%v; want error wrapping %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// ParseCheckpoint parses a signed checkpoint published by a static CT log,
+// returning the STH that it carries once its signature has been verified with
+// the given verifier.  The checkpoint's origin line must be the given origin.
+// The STH is taken from the log's RFC 6962 signature line (see
+// ct.STHFromCheckpoint), so can be used wherever an STH from get-sth is.
+func ParseCheckpoint(data []byte, origin string, verifier *ct.SignatureVerifier) (*ct.SignedTreeHead, error) {
+	if verifier == nil {
+		return nil, errors.New("no signature verifier for checkpoint")
+	}
+	if !bytes.HasPrefix(data, []byte(origin+"\n")) {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i]
+		}
+		return nil, fmt.Errorf("checkpoint origin %q does not match expected origin %q", line, origin)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(verifier.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log public key: %v", err)
+	}
+	sth, _, err := ct.STHFromCheckpoint(data, keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %v", err)
+	}
+	if err := verifier.VerifySTHSignature(*sth); err != nil {
+		return nil, fmt.Errorf("failed to verify checkpoint signature: %v", err)
+	}
+	return sth, nil
+}
+
+// CrossCheckSTHCheckpoint checks that an STH retrieved over the RFC 6962
+// get-sth interface agrees with a checkpoint published by the same log, i.e.
+// that both describe the same tree size and root hash.  A disagreement means
+// the log is presenting inconsistent views over its two interfaces.
+//
+// Note that this does not verify the signatures on either the STH or the
+// checkpoint; callers should do so before relying on the result.
+func CrossCheckSTHCheckpoint(sth *ct.SignedTreeHead, checkpoint *ct.Checkpoint) error {
+	if sth == nil || checkpoint == nil {
+		return errors.New("STH and checkpoint are both required")
+	}
+	if sth.TreeSize != checkpoint.TreeSize {
+		return fmt.Errorf("STH tree size %d does not match checkpoint tree size %d", sth.TreeSize, checkpoint.TreeSize)
+	}
+	if sth.SHA256RootHash != checkpoint.SHA256RootHash {
+		return fmt.Errorf("STH root hash %s does not match checkpoint root hash %s at tree size %d",
+			sth.SHA256RootHash.Base64String(), checkpoint.SHA256RootHash.Base64String(), sth.TreeSize)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+func TestCrossCheckSTHCheckpoint(t *testing.T) {
+	root := ct.SHA256Hash{0x01, 0x02}
+	otherRoot := ct.SHA256Hash{0x03, 0x04}
+	sth := &ct.SignedTreeHead{TreeSize: 10, SHA256RootHash: root}
+
+	tests := []struct {
+		desc    string
+		cp      *ct.Checkpoint
+		wantErr bool
+	}{
+		{desc: "agree", cp: &ct.Checkpoint{Origin: "example.com/log", TreeSize: 10, SHA256RootHash: root}},
+		{desc: "size mismatch", cp: &ct.Checkpoint{Origin: "example.com/log", TreeSize: 11, SHA256RootHash: root}, wantErr: true},
+		{desc: "root mismatch", cp: &ct.Checkpoint{Origin: "example.com/log", TreeSize: 10, SHA256RootHash: otherRoot}, wantErr: true},
+		{desc: "missing checkpoint", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := CrossCheckSTHCheckpoint(sth, test.cp)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("CrossCheckSTHCheckpoint()=%v; want error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCheckpoint(t *testing.T) {
+	const origin = "log.example.com/static"
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 5)
+	sth, err := fl.GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("GetSTH()=nil,%v; want _,nil", err)
+	}
+	checkpoint := func(sth ct.SignedTreeHead) []byte {
+		t.Helper()
+		data, err := sth.ToCheckpoint(origin, fl.keyDER(t))
+		if err != nil {
+			t.Fatalf("ToCheckpoint()=nil,%v; want _,nil", err)
+		}
+		return data
+	}
+	tampered := *sth
+	tampered.SHA256RootHash[0] ^= 0x01
+	verifier := fl.logInfo(t).Verifier
+	other := newFakeLog(t, "https://other.example.com").logInfo(t).Verifier
+
+	tests := []struct {
+		desc     string
+		data     []byte
+		origin   string
+		verifier *ct.SignatureVerifier
+		wantErr  string
+	}{
+		{desc: "valid", data: checkpoint(*sth), origin: origin, verifier: verifier},
+		{desc: "wrong origin", data: checkpoint(*sth), origin: "other.example.com/static", verifier: verifier, wantErr: "origin"},
+		{desc: "origin prefix", data: checkpoint(*sth), origin: "log.example.com", verifier: verifier, wantErr: "origin"},
+		{desc: "wrong key", data: checkpoint(*sth), origin: origin, verifier: other, wantErr: "no signature"},
+		{desc: "bad signature", data: checkpoint(tampered), origin: origin, verifier: verifier, wantErr: "verify"},
+		{desc: "unsigned", data: []byte(origin + "\n5\n" + sth.SHA256RootHash.Base64String() + "\n"), origin: origin, verifier: verifier, wantErr: "parse"},
+		{desc: "no verifier", data: checkpoint(*sth), origin: origin, wantErr: "verifier"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := ParseCheckpoint(test.data, test.origin, test.verifier)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("ParseCheckpoint()=%v,%v; want nil,error containing %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCheckpoint()=nil,%v; want _,nil", err)
+			}
+			if got.TreeSize != sth.TreeSize || got.SHA256RootHash != sth.SHA256RootHash || got.Timestamp != sth.Timestamp {
+				t.Errorf("ParseCheckpoint()=%+v; want STH with size %d, root %x, timestamp %d", got, sth.TreeSize, sth.SHA256RootHash, sth.Timestamp)
+			}
+		})
+	}
+}
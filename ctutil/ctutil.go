@@ -24,6 +24,7 @@ import (
 	"fmt"
 
 	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
 	"github.com/google/certificate-transparency-go/tls"
 	"github.com/google/certificate-transparency-go/x509"
 )
@@ -81,6 +82,19 @@ func LeafHash(chain []*x509.Certificate, sct *ct.SignedCertificateTimestamp, emb
 	return ct.LeafHashForLeaf(leaf)
 }
 
+// LeafHashForSCT calculates the RFC 6962 leaf hash of the given Merkle tree
+// leaf, adjusted for the timestamp in the SCT (as for LogInfo.VerifySCTSignature),
+// so that the timestamp in the leaf itself need not be set.  This is the hash
+// to give to a log's get-proof-by-hash entrypoint (e.g. with
+// client.CheckLogClient.GetProofByHash) to check the SCT's inclusion.
+func LeafHashForSCT(sct ct.SignedCertificateTimestamp, leaf ct.MerkleTreeLeaf) ([sha256.Size]byte, error) {
+	if leaf.TimestampedEntry == nil {
+		return emptyHash, errors.New("leaf has no timestamped entry")
+	}
+	leaf = leafWithTimestamp(leaf, sct.Timestamp)
+	return ct.LeafHashForLeaf(&leaf)
+}
+
 // VerifySCT takes the public key of a Certificate Transparency Log, a
 // certificate chain, and an SCT and verifies whether the SCT is a valid SCT for
 // the certificate at chain[0], signed by the Log that the public key belongs
@@ -97,8 +111,10 @@ func LeafHash(chain []*x509.Certificate, sct *ct.SignedCertificateTimestamp, emb
 //   - Precertificate:
 //       If using this function to verify an SCT for a precertificate then the
 //       issuing certificate must also be provided in chain.  The precertificate
-//       should be at chain[0], and its issuer at chain[1].  For this case, set
-//       embedded to false.
+//       should be at chain[0], and its issuer at chain[1].  If the issuer is
+//       a pre-issuer (i.e. has the Certificate Transparency extended key
+//       usage), the issuer key hash is instead taken from the final issuing
+//       CA, which must be at chain[2].  For this case, set embedded to false.
 //   - X.509 Certificate containing the SCT embedded within it:
 //       If the SCT you wish to verify is embedded within the certificate you
 //       are providing at chain[0], set embedded to true.  VerifySCT will
@@ -132,8 +148,10 @@ func VerifySCT(pubKey crypto.PublicKey, chain []*x509.Certificate, sct *ct.Signe
 //   - Precertificate:
 //       If using this function to verify an SCT for a precertificate then the
 //       issuing certificate must also be provided in chain.  The precertificate
-//       should be at chain[0], and its issuer at chain[1].  For this case, set
-//       embedded to false.
+//       should be at chain[0], and its issuer at chain[1].  If the issuer is
+//       a pre-issuer (i.e. has the Certificate Transparency extended key
+//       usage), the issuer key hash is instead taken from the final issuing
+//       CA, which must be at chain[2].  For this case, set embedded to false.
 //   - X.509 Certificate containing the SCT embedded within it:
 //       If the SCT you wish to verify is embedded within the certificate you
 //       are providing at chain[0], set embedded to true.  VerifySCT will
@@ -191,6 +209,77 @@ func createLeaf(chain []*x509.Certificate, sct *ct.SignedCertificateTimestamp, e
 	return leaf, nil
 }
 
+// PrecertLeafFromChain builds the Merkle tree leaf that a log added for the
+// precertificate corresponding to cert, as needed to check the inclusion of an
+// SCT with the given timestamp (e.g. with LogInfo.VerifyInclusionAt).  cert may
+// be either a precertificate, whose CT poison extension is removed, or a final
+// certificate, whose embedded SCT list extension (if any) is removed; issuer is
+// the certificate that issued cert.
+//
+// If issuer is a precertificate signing certificate (RFC 6962 s3.1), the leaf
+// must instead use the key of the pre-issuer's own issuer, so an error is
+// returned; use PrecertLeafFromFullChain for that case.
+func PrecertLeafFromChain(cert, issuer *x509.Certificate, timestamp uint64) (ct.MerkleTreeLeaf, error) {
+	return PrecertLeafFromFullChain([]*x509.Certificate{cert, issuer}, timestamp)
+}
+
+// PrecertLeafFromFullChain is like PrecertLeafFromChain, but takes the chain
+// of cert (chain[0]) and its issuers.  If chain[1] is a precertificate signing
+// certificate, the leaf's issuer key hash is that of chain[2], and the
+// precertificate's issuer and authority key ID are replaced by those of the
+// precertificate signing certificate, as the log did.
+func PrecertLeafFromFullChain(chain []*x509.Certificate, timestamp uint64) (ct.MerkleTreeLeaf, error) {
+	if len(chain) < 2 || chain[0] == nil || chain[1] == nil {
+		return ct.MerkleTreeLeaf{}, errors.New("missing certificate or issuer")
+	}
+	cert, issuer := chain[0], chain[1]
+	var preIssuer *x509.Certificate
+	if ct.IsPreIssuer(issuer) {
+		if !cert.IsPrecertificate() {
+			return ct.MerkleTreeLeaf{}, fmt.Errorf("issuer %q is a precertificate signing certificate, but the certificate is not a precertificate", issuer.Subject.CommonName)
+		}
+		if len(chain) < 3 || chain[2] == nil {
+			return ct.MerkleTreeLeaf{}, fmt.Errorf("issuer %q is a precertificate signing certificate; its issuer is needed", issuer.Subject.CommonName)
+		}
+		preIssuer, issuer = issuer, chain[2]
+	}
+
+	tbs := cert.RawTBSCertificate
+	var err error
+	if cert.IsPrecertificate() {
+		if tbs, err = x509.BuildPrecertTBS(tbs, preIssuer); err != nil {
+			return ct.MerkleTreeLeaf{}, fmt.Errorf("failed to remove CT poison extension: %v", err)
+		}
+	}
+	if hasExtension(cert, x509.OIDExtensionCTSCT) {
+		if tbs, err = x509.RemoveSCTList(tbs); err != nil {
+			return ct.MerkleTreeLeaf{}, fmt.Errorf("failed to remove SCT List extension: %v", err)
+		}
+	}
+
+	return ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			EntryType: ct.PrecertLogEntryType,
+			Timestamp: timestamp,
+			PrecertEntry: &ct.PreCert{
+				IssuerKeyHash:  sha256.Sum256(issuer.RawSubjectPublicKeyInfo),
+				TBSCertificate: tbs,
+			},
+		},
+	}, nil
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
 // ContainsSCT checks to see whether the given SCT is embedded within the given
 // certificate.
 func ContainsSCT(cert *x509.Certificate, sct *ct.SignedCertificateTimestamp) (bool, error) {
@@ -15,12 +15,19 @@
 package ctutil
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"math/big"
+	"reflect"
 	"testing"
+	"time"
 
 	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
 	"github.com/google/certificate-transparency-go/testdata"
 	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
 	"github.com/google/certificate-transparency-go/x509util"
 )
 
@@ -359,3 +366,183 @@ func TestContainsSCT(t *testing.T) {
 		})
 	}
 }
+
+func TestPrecertLeafFromChain(t *testing.T) {
+	tests := []struct {
+		desc     string
+		chainPEM string
+		sct      []byte
+	}{
+		{
+			desc:     "precert",
+			chainPEM: testdata.TestPreCertPEM + testdata.CACertPEM,
+			sct:      testdata.TestPreCertProof,
+		},
+		{
+			desc:     "cert with embedded SCT",
+			chainPEM: testdata.TestEmbeddedCertPEM + testdata.CACertPEM,
+			sct:      testdata.TestPreCertProof,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			chain, err := x509util.CertificatesFromPEM([]byte(test.chainPEM))
+			if err != nil {
+				t.Fatalf("error parsing certificate chain: %s", err)
+			}
+			var sct ct.SignedCertificateTimestamp
+			if _, err = tls.Unmarshal(test.sct, &sct); err != nil {
+				t.Fatalf("error tls-unmarshalling sct: %s", err)
+			}
+
+			leaf, err := PrecertLeafFromChain(chain[0], chain[1], sct.Timestamp)
+			if err != nil {
+				t.Fatalf("PrecertLeafFromChain()=_,%v; want nil", err)
+			}
+			got, err := ct.LeafHashForLeaf(&leaf)
+			if err != nil {
+				t.Fatalf("LeafHashForLeaf()=_,%v", err)
+			}
+			if gotB64 := base64.StdEncoding.EncodeToString(got[:]); gotB64 != testdata.TestPreCertB64LeafHash {
+				t.Errorf("leaf hash=%s; want %s", gotB64, testdata.TestPreCertB64LeafHash)
+			}
+		})
+	}
+}
+
+func TestPrecertLeafFromChainErrors(t *testing.T) {
+	chain, err := x509util.CertificatesFromPEM([]byte(testdata.TestPreCertPEM + testdata.CACertPEM))
+	if err != nil {
+		t.Fatalf("error parsing certificate chain: %s", err)
+	}
+	preIssuer := *chain[1]
+	preIssuer.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCertificateTransparency}
+
+	tests := []struct {
+		desc         string
+		cert, issuer *x509.Certificate
+	}{
+		{desc: "no cert", issuer: chain[1]},
+		{desc: "no issuer", cert: chain[0]},
+		{desc: "pre-issuer", cert: chain[0], issuer: &preIssuer},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := PrecertLeafFromChain(test.cert, test.issuer, 0); err == nil {
+				t.Error("PrecertLeafFromChain()=_,nil; want error")
+			}
+		})
+	}
+}
+
+func TestPrecertLeafFromFullChain(t *testing.T) {
+	notBefore := time.Now().Add(-time.Hour)
+	template := func(serial int64, cn string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    notBefore,
+			NotAfter:     notBefore.Add(24 * time.Hour),
+		}
+	}
+	caTemplate := template(1, "Test CA")
+	caTemplate.IsCA, caTemplate.BasicConstraintsValid = true, true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	caTemplate.SubjectKeyId = []byte{1, 2, 3}
+	ca, caKey := issueCert(t, caTemplate, nil, nil)
+
+	preIssuerTemplate := template(2, "Test CA Precertificate Signing")
+	preIssuerTemplate.IsCA, preIssuerTemplate.BasicConstraintsValid = true, true
+	preIssuerTemplate.KeyUsage = x509.KeyUsageCertSign
+	preIssuerTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCertificateTransparency}
+	preIssuerTemplate.SubjectKeyId = []byte{4, 5, 6}
+	preIssuer, preIssuerKey := issueCert(t, preIssuerTemplate, ca, caKey)
+
+	precertTemplate := template(3, "www.example.com")
+	precertTemplate.ExtraExtensions = []pkix.Extension{{Id: x509.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}}
+	precert, _ := issueCert(t, precertTemplate, preIssuer, preIssuerKey)
+	chain := []*x509.Certificate{precert, preIssuer, ca}
+
+	const timestamp = 1234
+	leaf, err := PrecertLeafFromFullChain(chain, timestamp)
+	if err != nil {
+		t.Fatalf("PrecertLeafFromFullChain()=_,%v; want _,nil", err)
+	}
+	want, err := ct.MerkleTreeLeafFromChain(chain, ct.PrecertLogEntryType, timestamp)
+	if err != nil {
+		t.Fatalf("MerkleTreeLeafFromChain()=_,%v", err)
+	}
+	if !reflect.DeepEqual(leaf, *want) {
+		t.Errorf("PrecertLeafFromFullChain()=%+v; want %+v", leaf, *want)
+	}
+	if got, want := leaf.TimestampedEntry.PrecertEntry.IssuerKeyHash, sha256.Sum256(ca.RawSubjectPublicKeyInfo); got != want {
+		t.Errorf("PrecertLeafFromFullChain().IssuerKeyHash=%x; want %x (CA key)", got, want)
+	}
+
+	if _, err := PrecertLeafFromFullChain(chain[:2], timestamp); err == nil {
+		t.Error("PrecertLeafFromFullChain(no pre-issuer's issuer)=_,nil; want error")
+	}
+	if _, err := PrecertLeafFromChain(precert, preIssuer, timestamp); err == nil {
+		t.Error("PrecertLeafFromChain(pre-issuer)=_,nil; want error")
+	}
+}
+
+func TestLeafHashForSCT(t *testing.T) {
+	certChain, err := x509util.CertificatesFromPEM([]byte(testdata.TestCertPEM))
+	if err != nil {
+		t.Fatalf("error parsing certificate chain: %s", err)
+	}
+	precertChain, err := x509util.CertificatesFromPEM([]byte(testdata.TestPreCertPEM + testdata.CACertPEM))
+	if err != nil {
+		t.Fatalf("error parsing certificate chain: %s", err)
+	}
+	// The leaves are built without the SCT timestamp, which LeafHashForSCT
+	// fills in.
+	precertLeaf, err := PrecertLeafFromChain(precertChain[0], precertChain[1], 0)
+	if err != nil {
+		t.Fatalf("PrecertLeafFromChain()=_,%v", err)
+	}
+
+	tests := []struct {
+		desc string
+		leaf ct.MerkleTreeLeaf
+		sct  []byte
+		want string
+	}{
+		{
+			desc: "cert",
+			leaf: *ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: certChain[0].Raw}, 0),
+			sct:  testdata.TestCertProof,
+			want: testdata.TestCertB64LeafHash,
+		},
+		{
+			desc: "precert",
+			leaf: precertLeaf,
+			sct:  testdata.TestPreCertProof,
+			want: testdata.TestPreCertB64LeafHash,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var sct ct.SignedCertificateTimestamp
+			if _, err := tls.Unmarshal(test.sct, &sct); err != nil {
+				t.Fatalf("error tls-unmarshalling sct: %s", err)
+			}
+			got, err := LeafHashForSCT(sct, test.leaf)
+			if err != nil {
+				t.Fatalf("LeafHashForSCT()=_,%v; want _,nil", err)
+			}
+			if gotB64 := base64.StdEncoding.EncodeToString(got[:]); gotB64 != test.want {
+				t.Errorf("LeafHashForSCT()=%s; want %s", gotB64, test.want)
+			}
+			if test.leaf.TimestampedEntry.Timestamp != 0 {
+				t.Error("LeafHashForSCT() modified the leaf's timestamp")
+			}
+		})
+	}
+
+	if _, err := LeafHashForSCT(ct.SignedCertificateTimestamp{}, ct.MerkleTreeLeaf{}); err == nil {
+		t.Error("LeafHashForSCT(empty leaf)=_,nil; want _,non-nil")
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/scanner"
+	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/net/publicsuffix"
+)
+
+// DomainCount holds the number of certificates seen for a registered domain.
+type DomainCount struct {
+	Domain string
+	Count  int
+}
+
+// DomainCounts is a scanner.Matcher that tallies the number of certificates
+// (and precertificates) seen during a scan for each registered domain (i.e.
+// eTLD+1) named in their subject common name or DNS subject alternative names.
+// A certificate is counted once for each distinct registered domain it names.
+// It is safe for use by concurrent scanner workers.
+type DomainCounts struct {
+	// Matcher, if set, selects the certificates to count, and provides the
+	// results of matching; otherwise all certificates are counted and match.
+	Matcher scanner.Matcher
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// CertificateMatches tallies the registered domains of the certificate if it
+// matches the underlying Matcher, and returns the result of the match.
+func (d *DomainCounts) CertificateMatches(cert *x509.Certificate) bool {
+	if d.Matcher != nil && !d.Matcher.CertificateMatches(cert) {
+		return false
+	}
+	d.add(cert)
+	return true
+}
+
+// PrecertificateMatches tallies the registered domains of the precertificate
+// if it matches the underlying Matcher, and returns the result of the match.
+func (d *DomainCounts) PrecertificateMatches(precert *ct.Precertificate) bool {
+	if d.Matcher != nil && !d.Matcher.PrecertificateMatches(precert) {
+		return false
+	}
+	d.add(precert.TBSCertificate)
+	return true
+}
+
+func (d *DomainCounts) add(cert *x509.Certificate) {
+	if cert == nil {
+		return
+	}
+	domains := make(map[string]bool)
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		if domain := registeredDomain(name); domain != "" {
+			domains[domain] = true
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.counts == nil {
+		d.counts = make(map[string]int)
+	}
+	for domain := range domains {
+		d.counts[domain]++
+	}
+}
+
+// registeredDomain returns the registered domain (eTLD+1) for a DNS name from
+// a certificate, or an empty string if it does not have one.
+func registeredDomain(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	name = strings.TrimPrefix(name, "*.")
+	if !strings.Contains(name, ".") || net.ParseIP(name) != nil {
+		return ""
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+// Top returns the n registered domains with the most certificates, in
+// decreasing order of count (and then alphabetically).  If n is not positive,
+// all domains are returned.
+func (d *DomainCounts) Top(n int) []DomainCount {
+	d.mu.Lock()
+	result := make([]DomainCount, 0, len(d.counts))
+	for domain, count := range d.counts {
+		result = append(result, DomainCount{Domain: domain, Count: count})
+	}
+	d.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Domain < result[j].Domain
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
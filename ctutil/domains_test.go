@@ -0,0 +1,114 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/scanner"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+func TestDomainCounts(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	notBefore := time.Now().Add(-time.Hour)
+	emptyChain, err := tls.Marshal(ct.CertificateChain{})
+	if err != nil {
+		t.Fatalf("failed to marshal chain: %v", err)
+	}
+	var extra [][]byte
+	for i, entry := range []struct {
+		cn   string
+		sans []string
+	}{
+		{cn: "www.example.com"},
+		{cn: "*.example.com", sans: []string{"example.com", "Mail.Example.COM."}},
+		{cn: "a.example.co.uk"},
+		{cn: "shop", sans: []string{"shop.example.com", "shop.other.org"}},
+		{cn: "b.c.example.co.uk"},
+		{cn: "localhost"},
+		{cn: "10.1.2.3"},
+	} {
+		cert, _ := issueCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 1)),
+			Subject:      pkix.Name{CommonName: entry.cn},
+			DNSNames:     entry.sans,
+			NotBefore:    notBefore,
+			NotAfter:     notBefore.Add(time.Hour),
+		}, nil, nil)
+		fl.addLeaf(t, ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: cert.Raw}, 0))
+		extra = append(extra, emptyChain)
+	}
+	server := serveEntries(t, fl, extra)
+	defer server.Close()
+	lc, err := client.New(server.URL, nil, jsonclient.Options{PublicKeyDER: fl.keyDER(t)})
+	if err != nil {
+		t.Fatalf("client.New()=_,%v", err)
+	}
+
+	tests := []struct {
+		desc    string
+		matcher scanner.Matcher
+		n       int
+		want    []DomainCount
+	}{
+		{
+			desc: "all",
+			want: []DomainCount{{"example.com", 3}, {"example.co.uk", 2}, {"other.org", 1}},
+		},
+		{
+			desc: "top-2",
+			n:    2,
+			want: []DomainCount{{"example.com", 3}, {"example.co.uk", 2}},
+		},
+		{
+			desc:    "filtered",
+			matcher: scanner.MatchSubjectRegex{CertificateSubjectRegex: regexp.MustCompile(`\.co\.uk$`)},
+			want:    []DomainCount{{"example.co.uk", 2}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			counts := &DomainCounts{Matcher: test.matcher}
+			opts := scanner.DefaultScannerOptions()
+			opts.Matcher = counts
+			opts.BatchSize = 2
+			opts.NumWorkers = 4
+			var matched int32
+			found := func(*ct.RawLogEntry) { atomic.AddInt32(&matched, 1) }
+			if err := scanner.NewScanner(lc, *opts).Scan(ctx, found, found); err != nil {
+				t.Fatalf("Scan()=%v; want nil", err)
+			}
+			if got := counts.Top(test.n); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Top(%d)=%v; want %v", test.n, got, test.want)
+			}
+			if test.matcher == nil && int(matched) != len(extra) {
+				t.Errorf("Scan() matched %d entries; want %d", matched, len(extra))
+			}
+		})
+	}
+}
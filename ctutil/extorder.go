@@ -0,0 +1,120 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+// sortTBSExtensions returns a copy of the DER-encoded TBSCertificate with its
+// extensions in ascending OID order, leaving the rest of its contents as is.
+func sortTBSExtensions(tbs []byte) ([]byte, error) {
+	var seq asn1.RawValue
+	if rest, err := asn1.Unmarshal(tbs, &seq); err != nil {
+		return nil, fmt.Errorf("failed to parse TBSCertificate: %v", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after TBSCertificate")
+	}
+	fields, err := rawValues(seq.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TBSCertificate fields: %v", err)
+	}
+
+	var contents []byte
+	found := false
+	for _, field := range fields {
+		if field.Class == asn1.ClassContextSpecific && field.Tag == 3 {
+			sorted, err := sortExtensions(field.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			field.FullBytes, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: sorted})
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal extensions: %v", err)
+			}
+			found = true
+		}
+		contents = append(contents, field.FullBytes...)
+	}
+	if !found {
+		return nil, errors.New("TBSCertificate has no extensions")
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: contents})
+}
+
+// sortExtensions returns the DER encoding of the given (explicitly tagged)
+// Extensions sequence, with the extensions sorted into ascending OID order.
+func sortExtensions(data []byte) ([]byte, error) {
+	var seq asn1.RawValue
+	if rest, err := asn1.Unmarshal(data, &seq); err != nil {
+		return nil, fmt.Errorf("failed to parse extensions: %v", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after extensions")
+	}
+	raws, err := rawValues(seq.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse extensions: %v", err)
+	}
+	oids := make(map[int]asn1.ObjectIdentifier, len(raws))
+	for i, raw := range raws {
+		var ext pkix.Extension
+		if _, err := asn1.Unmarshal(raw.FullBytes, &ext); err != nil {
+			return nil, fmt.Errorf("failed to parse extension %d: %v", i, err)
+		}
+		oids[i] = ext.Id
+	}
+	idx := make([]int, len(raws))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return oidLess(oids[idx[i]], oids[idx[j]])
+	})
+
+	var contents []byte
+	for _, i := range idx {
+		contents = append(contents, raws[i].FullBytes...)
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: contents})
+}
+
+// rawValues splits DER data into its consecutive ASN.1 values.
+func rawValues(data []byte) ([]asn1.RawValue, error) {
+	var values []asn1.RawValue
+	for len(data) > 0 {
+		var v asn1.RawValue
+		var err error
+		if data, err = asn1.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// oidLess orders OIDs by comparing their arcs in turn.
+func oidLess(a, b asn1.ObjectIdentifier) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
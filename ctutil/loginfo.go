@@ -15,10 +15,13 @@
 package ctutil
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +31,7 @@ import (
 	"github.com/google/certificate-transparency-go/dnsclient"
 	"github.com/google/certificate-transparency-go/jsonclient"
 	"github.com/google/certificate-transparency-go/loglist"
+	"github.com/google/certificate-transparency-go/tls"
 	"github.com/google/certificate-transparency-go/x509"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/merkle/rfc6962"
@@ -39,33 +43,180 @@ type LogInfo struct {
 	Description string
 	Client      client.CheckLogClient
 	MMD         time.Duration
-	Verifier    *ct.SignatureVerifier
-	PublicKey   []byte
+	// Verifier is nil for a lazily built LogInfo, so should be accessed via
+	// SignatureVerifier.
+	Verifier  *ct.SignatureVerifier
+	PublicKey []byte
+	// VerifySTHs makes the inclusion checks that fetch the log's current STH
+	// (VerifyInclusion and VerifyInclusionLatest) do so with GetVerifiedSTH,
+	// checking its signature before it is used or recorded.  Otherwise the STH
+	// is used as returned by the log's client, which only checks signatures if
+	// it was given the log's key (as by NewLogInfo, but not NewLazyLogInfo).
+	VerifySTHs bool
+	// NotFoundByMMD controls how a 404 from the log's get-proof-by-hash
+	// entrypoint is reported by the inclusion checks.  If set, such a
+	// response gives ErrNotYetIncluded if the SCT was younger than the log's
+	// MMD at the timestamp of the STH checked against, and ErrMissingInclusion
+	// if it was older.  This only applies when that STH is known: the one
+	// fetched by VerifyInclusion, or the log's last STH for VerifyInclusionAt.
+	NotFoundByMMD bool
+	// TolerateExtensionReordering enables a compatibility mode for SCTs
+	// embedded in a certificate whose precertificate was logged with the same
+	// extensions sorted into ascending OID order, while the final certificate
+	// kept them in another order.  RFC 6962 s3.1 requires the precertificate
+	// to be rebuilt from the final certificate by only removing the SCT list
+	// extension, so such SCTs do not verify; in this mode, an embedded SCT that
+	// fails to verify is retried against a rebuilt precertificate with its
+	// extensions in ascending OID order.
+	//
+	// This is a generic fallback for that one kind of mismatch, not a fix for a
+	// particular CA's documented incident.  Only that direction is covered: if
+	// it was the final certificate whose extensions were sorted, the order used
+	// in the precertificate cannot be recovered from the final certificate, so
+	// its SCTs still fail to verify.  It is off by default, as it accepts
+	// certificates that do not comply with RFC 6962.
+	TolerateExtensionReordering bool
+	// RootsCache, if set, caches the log's accepted roots for AcceptedRoots;
+	// it may be shared with other logs (see LogInfoByHash.SetRootsCache).
+	RootsCache *RootsCache
+	// STHMaxAge, if set, bounds the age (by its timestamp) of the last known
+	// STH used by VerifyInclusionLatest; an older STH is replaced by a newly
+	// fetched one.  If zero, the last known STH is used however old it is.
+	STHMaxAge time.Duration
+	// FutureTimestampTolerance, if set, enables checking in
+	// VerifyInclusionLatest that an SCT's timestamp is plausible before asking
+	// the log for an inclusion proof: a timestamp more than this far ahead of
+	// the local clock, or more than sthTimestampSkew ahead of the STH being
+	// checked against, gives an error wrapping ErrFutureTimestamp.
+	FutureTimestampTolerance time.Duration
 
-	mu      sync.RWMutex
-	lastSTH *ct.SignedTreeHead
+	sthCache  STHCache // see WithSTHCache
+	mu        sync.RWMutex
+	lastSTH   *ct.SignedTreeHead
+	lastFetch time.Time
+
+	verifierOnce sync.Once
+	verifier     *ct.SignatureVerifier // built lazily when Verifier is nil
+	verifierErr  error
+}
+
+// PollsPerMMD is the number of times per MMD that NextPollTime suggests
+// polling a log for a new STH.
+const PollsPerMMD = 4
+
+// LogInfoOption configures a LogInfo built by NewLogInfo or NewLazyLogInfo.
+type LogInfoOption func(*LogInfo)
+
+// WithSTHCache makes a LogInfo hold the log's last known STH (keyed by the
+// hash of its public key) in the given cache, as well as in the LogInfo
+// itself; the cache may be shared with other LogInfo objects, including later
+// ones for the same log (see also LogInfoByHash.SetSTHCache).
+func WithSTHCache(c STHCache) LogInfoOption {
+	return func(li *LogInfo) {
+		li.sthCache = c
+	}
 }
 
 // NewLogInfo builds a LogInfo object based on a log list entry.
-func NewLogInfo(log *loglist.Log, hc *http.Client) (*LogInfo, error) {
-	url := log.URL
-	if !strings.HasPrefix(url, "https://") {
-		url = "https://" + url
+func NewLogInfo(log *loglist.Log, hc *http.Client, opts ...LogInfoOption) (*LogInfo, error) {
+	lc, err := newLogClient(log, hc, jsonclient.Options{PublicKeyDER: log.Key, UserAgent: "ct-go-logclient"})
+	if err != nil {
+		return nil, err
+	}
+	li, err := newLogInfo(log, lc)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(li)
+	}
+	return li, nil
+}
+
+// NewLazyLogInfo builds a LogInfo object based on a log list entry, without
+// parsing the log's public key.  The key is instead parsed when the log's
+// signatures are first verified (see SignatureVerifier), so a log with an
+// unparseable key only causes errors once it is actually used.  As the key is
+// not available to the log's client, the client does not itself verify STH
+// signatures; set VerifySTHs for the inclusion checks to verify them.
+func NewLazyLogInfo(log *loglist.Log, hc *http.Client, opts ...LogInfoOption) (*LogInfo, error) {
+	lc, err := newLogClient(log, hc, jsonclient.Options{UserAgent: "ct-go-logclient"})
+	if err != nil {
+		return nil, err
+	}
+	li := &LogInfo{
+		Description: log.Description,
+		Client:      lc,
+		MMD:         time.Duration(log.MaximumMergeDelay) * time.Second,
+		PublicKey:   log.Key,
+	}
+	for _, opt := range opts {
+		opt(li)
+	}
+	return li, nil
+}
+
+// newPlainLogInfo and newPlainLazyLogInfo build LogInfo objects without
+// options, for use as the factories of LogInfoByHash maps.
+func newPlainLogInfo(log *loglist.Log, hc *http.Client) (*LogInfo, error) {
+	return NewLogInfo(log, hc)
+}
+
+func newPlainLazyLogInfo(log *loglist.Log, hc *http.Client) (*LogInfo, error) {
+	return NewLazyLogInfo(log, hc)
+}
+
+// newLogClient builds a client for the log's URL.  If the log list entry has
+// mirror URLs, the client fails over to them in turn (see client.WithFailover),
+// and the client for the log's own URL is available from client.Primary.  A static
+// (tiled) log, which has a monitoring URL in its log list entry, is instead
+// accessed with a client.StaticLogClient, which does not verify STH signatures.
+func newLogClient(log *loglist.Log, hc *http.Client, opts jsonclient.Options) (client.CheckLogClient, error) {
+	if log.MonitoringURL != "" {
+		return client.NewStaticLogClient(logURL(log.MonitoringURL), log.Key, hc), nil
 	}
-	lc, err := client.New(url, hc, jsonclient.Options{PublicKeyDER: log.Key, UserAgent: "ct-go-logclient"})
+	lc, err := client.New(logURL(log.URL), hc, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client for log %q: %v", log.Description, err)
 	}
-	return newLogInfo(log, lc)
+	if len(log.MirrorURLs) == 0 {
+		return lc, nil
+	}
+	clients := []client.CheckLogClient{lc}
+	for _, url := range log.MirrorURLs {
+		mc, err := client.New(logURL(url), hc, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client for mirror %q of log %q: %v", url, log.Description, err)
+		}
+		clients = append(clients, mc)
+	}
+	return client.WithFailover(clients...), nil
 }
 
-// NewLogInfoOverDNSWrapper builds a LogInfo object that accesses logs via DNS, based on a log list entry.
-// The inert http.Client argument allows this variant to be used interchangeably with NewLogInfo.
+func logURL(url string) string {
+	if !strings.HasPrefix(url, "https://") {
+		return "https://" + url
+	}
+	return url
+}
+
+// NewLogInfoOverDNSWrapper builds a LogInfo object that accesses logs via classic DNS, based on a log
+// list entry.  The inert http.Client argument allows this variant to be used interchangeably with
+// NewLogInfo; see NewLogInfoOverDoHWrapper for a variant that uses it.
 func NewLogInfoOverDNSWrapper(log *loglist.Log, _ *http.Client) (*LogInfo, error) {
 	return NewLogInfoOverDNS(log)
 }
 
-// NewLogInfoOverDNS builds a LogInfo object that accesses logs via DNS, based on a log list entry.
+// NewLogInfoOverDoHWrapper returns a function that builds LogInfo objects as for NewLogInfoOverDoH,
+// making DNS queries to the resolver at the given URL, so that it can be used interchangeably with
+// NewLogInfo.
+func NewLogInfoOverDoHWrapper(resolverURL string) func(*loglist.Log, *http.Client) (*LogInfo, error) {
+	return func(log *loglist.Log, hc *http.Client) (*LogInfo, error) {
+		return NewLogInfoOverDoH(log, resolverURL, hc)
+	}
+}
+
+// NewLogInfoOverDNS builds a LogInfo object that accesses logs via classic DNS, based on a log list entry.
 func NewLogInfoOverDNS(log *loglist.Log) (*LogInfo, error) {
 	if log.DNSAPIEndpoint == "" {
 		return nil, fmt.Errorf("no available DNS endpoint for log %q", log.Description)
@@ -77,14 +228,24 @@ func NewLogInfoOverDNS(log *loglist.Log) (*LogInfo, error) {
 	return newLogInfo(log, dc)
 }
 
-func newLogInfo(log *loglist.Log, lc client.CheckLogClient) (*LogInfo, error) {
-	logKey, err := x509.ParsePKIXPublicKey(log.Key)
+// NewLogInfoOverDoH builds a LogInfo object that accesses logs via DNS-over-HTTPS, based on a log
+// list entry, making queries to the resolver at the given URL using the given http.Client (or
+// http.DefaultClient if nil).
+func NewLogInfoOverDoH(log *loglist.Log, resolverURL string, hc *http.Client) (*LogInfo, error) {
+	if log.DNSAPIEndpoint == "" {
+		return nil, fmt.Errorf("no available DNS endpoint for log %q", log.Description)
+	}
+	dc, err := dnsclient.NewOverHTTPS(log.DNSAPIEndpoint, jsonclient.Options{PublicKeyDER: log.Key}, resolverURL, hc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse public key data for log %q: %v", log.Description, err)
+		return nil, fmt.Errorf("failed to create DNS-over-HTTPS client for log %q: %v", log.Description, err)
 	}
-	verifier, err := ct.NewSignatureVerifier(logKey)
+	return newLogInfo(log, dc)
+}
+
+func newLogInfo(log *loglist.Log, lc client.CheckLogClient) (*LogInfo, error) {
+	verifier, err := newVerifier(log.Description, log.Key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build verifier log %q: %v", log.Description, err)
+		return nil, err
 	}
 	mmd := time.Duration(log.MaximumMergeDelay) * time.Second
 	return &LogInfo{
@@ -96,23 +257,261 @@ func newLogInfo(log *loglist.Log, lc client.CheckLogClient) (*LogInfo, error) {
 	}, nil
 }
 
-// LogInfoByHash holds LogInfo objects index by the SHA-256 hash of the log's public key.
+func newVerifier(description string, keyDER []byte) (*ct.SignatureVerifier, error) {
+	logKey, err := x509.ParsePKIXPublicKey(keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key data for log %q: %v", description, err)
+	}
+	verifier, err := ct.NewSignatureVerifier(logKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build verifier log %q: %v", description, err)
+	}
+	return verifier, nil
+}
+
+// SignatureVerifier returns the verifier for signatures from the log.  If the
+// LogInfo has no Verifier (e.g. as built by NewLazyLogInfo), one is built from
+// the log's public key on first use and kept for later calls, leaving the
+// Verifier field unset; any failure to do so is returned on every call.
+func (li *LogInfo) SignatureVerifier() (*ct.SignatureVerifier, error) {
+	if li.Verifier != nil {
+		return li.Verifier, nil
+	}
+	li.verifierOnce.Do(func() {
+		li.verifier, li.verifierErr = newVerifier(li.Description, li.PublicKey)
+	})
+	return li.verifier, li.verifierErr
+}
+
+// VerifySTHSignature checks the signature in the STH using the log's verifier.
+func (li *LogInfo) VerifySTHSignature(sth ct.SignedTreeHead) error {
+	verifier, err := li.SignatureVerifier()
+	if err != nil {
+		return err
+	}
+	return verifier.VerifySTHSignature(sth)
+}
+
+// GetVerifiedSTH retrieves the log's current STH and checks its signature,
+// returning an error wrapping ErrBadSTHSignature if the signature does not
+// verify.  Only a verified STH is recorded as the last known STH for the log
+// (see SetSTH).
+func (li *LogInfo) GetVerifiedSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	sth, err := li.Client.GetSTH(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
+	}
+	verifier, err := li.SignatureVerifier()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifier.VerifySTHSignature(*sth); err != nil {
+		return nil, fmt.Errorf("failed to verify current STH for %q log: %w: %v", li.Description, ErrBadSTHSignature, err)
+	}
+	li.SetSTH(sth)
+	return sth, nil
+}
+
+// VerifyKeyMatches retrieves the log's current STH and checks that its
+// signature verifies with the log's advertised public key, returning an error
+// wrapping ErrKeyMismatch if it does not.  This detects logs that are signing
+// with a different key than the one in the log list, e.g. during a key
+// rotation that the list does not yet reflect.
+func (li *LogInfo) VerifyKeyMatches(ctx context.Context) error {
+	sth, err := li.Client.GetSTH(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
+	}
+	verifier, err := li.SignatureVerifier()
+	if err != nil {
+		return err
+	}
+	if err := verifier.VerifySTHSignature(*sth); err != nil {
+		return fmt.Errorf("%w: STH at size %d from %q log does not verify with advertised key: %v", ErrKeyMismatch, sth.TreeSize, li.Description, err)
+	}
+	return nil
+}
+
+// LogInfoByHash holds LogInfo objects index by the SHA-256 hash of the log's public key,
+// which is the KeyID of the log ID in the log's SCTs (see LogIDForPublicKey).
 type LogInfoByHash map[[sha256.Size]byte]*LogInfo
 
+// ErrNotYetIncluded indicates that a log has not yet incorporated an entry
+// into its tree, but is still within its MMD for doing so.
+var ErrNotYetIncluded = errors.New("entry not yet included in log")
+
+// ErrMissingInclusion indicates that a log has failed to incorporate an entry
+// into its tree within its MMD.
+var ErrMissingInclusion = errors.New("entry not included in log within MMD")
+
+// ErrSplitView indicates that a log has issued two STHs of the same size with
+// different root hashes, i.e. has presented different views of its tree.
+var ErrSplitView = errors.New("log presented a split view")
+
+// ErrRollback indicates that a log has issued an STH for a smaller tree than
+// an earlier STH, i.e. its tree appears to have shrunk.
+var ErrRollback = errors.New("log tree size went backwards")
+
+// ErrKeyMismatch indicates that a log is signing with a different key than the
+// one it is known by.
+var ErrKeyMismatch = errors.New("log signing key does not match advertised key")
+
+// ErrBadSTHSignature indicates that the signature on an STH served by a log
+// does not verify with the log's key.
+var ErrBadSTHSignature = errors.New("STH signature does not verify")
+
+// ErrUnknownLog indicates that no log with the requested ID is known.
+var ErrUnknownLog = errors.New("unknown log")
+
+// ErrFutureTimestamp is wrapped by errors reporting an SCT timestamp that is
+// implausibly far in the future, indicating a broken clock at the log or the
+// client.
+var ErrFutureTimestamp = errors.New("SCT timestamp in the future")
+
+// LogInfoForSCT returns the LogInfo for the log that issued the given SCT,
+// whose log ID is the SHA-256 hash of the log's public key, and whether there
+// is such a log in the map.
+func (m LogInfoByHash) LogInfoForSCT(sct ct.SignedCertificateTimestamp) (*LogInfo, bool) {
+	li, ok := m[sct.LogID.KeyID]
+	if !ok || li == nil {
+		return nil, false
+	}
+	return li, true
+}
+
+// MustLogForSCT returns the LogInfo for the log that issued the given SCT, or
+// an error wrapping ErrUnknownLog if there is no such log in the map.
+func (m LogInfoByHash) MustLogForSCT(sct ct.SignedCertificateTimestamp) (*LogInfo, error) {
+	li, ok := m.LogInfoForSCT(sct)
+	if !ok {
+		return nil, fmt.Errorf("%w: no log found with key hash %x", ErrUnknownLog, sct.LogID.KeyID)
+	}
+	return li, nil
+}
+
+// VerifyAndLog finds the log in the map that issued the given SCT, checks the
+// signature in the SCT against the given leaf (adjusted for the timestamp in
+// the SCT), and checks that the leaf is present in the log's current tree, as
+// for LogInfo.VerifyInclusion.  Returns the log and the index of the leaf in
+// the log; if the log is not in the map, the error wraps ErrUnknownLog.
+func (m LogInfoByHash) VerifyAndLog(ctx context.Context, sct ct.SignedCertificateTimestamp, leaf ct.MerkleTreeLeaf) (*LogInfo, int64, error) {
+	li, err := m.MustLogForSCT(sct)
+	if err != nil {
+		return nil, -1, err
+	}
+	if err := li.VerifySCTSignature(sct, leaf); err != nil {
+		return li, -1, err
+	}
+	index, err := li.VerifyInclusion(ctx, leaf, sct.Timestamp)
+	if err != nil {
+		return li, -1, err
+	}
+	return li, index, nil
+}
+
+// VerifyAllSignatures checks the signature of each of the SCTs against the
+// given leaf (adjusted for the timestamp of each SCT in turn, without
+// modifying the caller's leaf), using the log in the map that issued it.
+// Returns the outcome for each SCT, in order, as a nil or non-nil error; if
+// any SCT is from a log that is not in the map, its outcome is an error
+// wrapping ErrUnknownLog, and the overall error also wraps ErrUnknownLog.
+func (m LogInfoByHash) VerifyAllSignatures(scts []ct.SignedCertificateTimestamp, leaf ct.MerkleTreeLeaf) ([]error, error) {
+	errs := make([]error, len(scts))
+	unknown := 0
+	for i, sct := range scts {
+		li, err := m.MustLogForSCT(sct)
+		if err != nil {
+			errs[i] = err
+			unknown++
+			continue
+		}
+		errs[i] = li.VerifySCTSignature(sct, leaf)
+	}
+	if unknown > 0 {
+		return errs, fmt.Errorf("%w: %d of %d SCTs are from unknown logs", ErrUnknownLog, unknown, len(scts))
+	}
+	return errs, nil
+}
+
+// LogIDFunc derives the log ID that a log's SCTs carry from the log's
+// DER-encoded public key.
+type LogIDFunc func(keyDER []byte) ([sha256.Size]byte, error)
+
+// SHA256LogID is the LogIDFunc for RFC 6962 logs, whose log ID is the SHA-256
+// hash of their public key.
+func SHA256LogID(keyDER []byte) ([sha256.Size]byte, error) {
+	return LogIDForPublicKey(keyDER).KeyID, nil
+}
+
+// LogIDForPublicKey returns the log ID carried by the SCTs of the RFC 6962 log
+// with the given DER-encoded public key (SubjectPublicKeyInfo), which is the
+// SHA-256 hash of the key.  Its KeyID is the key of the log in a LogInfoByHash
+// built by LogInfoByKeyHash.
+func LogIDForPublicKey(keyDER []byte) ct.LogID {
+	return ct.LogID{KeyID: sha256.Sum256(keyDER)}
+}
+
+// LogIDForLog returns the log ID carried by the SCTs of the given RFC 6962
+// log, as for LogIDForPublicKey.
+func LogIDForLog(log *loglist.Log) ct.LogID {
+	return LogIDForPublicKey(log.Key)
+}
+
+// Key returns the key under which the given LogInfo is held in the map.  This
+// is normally the KeyID of LogIDForPublicKey(li.PublicKey), but may differ for
+// a map built by LogInfoByLogID.  If the LogInfo is not in the map, Key returns
+// the key it would have in a map built by LogInfoByKeyHash.
+func (m LogInfoByHash) Key(li *LogInfo) [sha256.Size]byte {
+	key := LogIDForPublicKey(li.PublicKey).KeyID
+	if m[key] == li {
+		return key
+	}
+	for k, other := range m {
+		if other == li {
+			return k
+		}
+	}
+	return key
+}
+
 // LogInfoByKeyHash builds a map of LogInfo objects indexed by their key hashes.
 func LogInfoByKeyHash(ll *loglist.LogList, hc *http.Client) (LogInfoByHash, error) {
-	return logInfoByKeyHash(ll, hc, NewLogInfo)
+	return logInfoByKeyHash(ll, hc, newPlainLogInfo, SHA256LogID)
+}
+
+// LogInfoByLogID builds a map of LogInfo objects indexed by the log IDs that
+// the given function derives from their public keys, for logs that do not
+// identify themselves by the SHA-256 hash of their key.  The map's methods
+// then match SCTs to logs using those log IDs.
+func LogInfoByLogID(ll *loglist.LogList, hc *http.Client, logID LogIDFunc) (LogInfoByHash, error) {
+	return logInfoByKeyHash(ll, hc, newPlainLogInfo, logID)
+}
+
+// LogInfoByKeyHashLazy builds a map of LogInfo objects indexed by their key
+// hashes, deferring the parsing of each log's public key until it is first
+// needed (see NewLazyLogInfo).
+func LogInfoByKeyHashLazy(ll *loglist.LogList, hc *http.Client) (LogInfoByHash, error) {
+	return logInfoByKeyHash(ll, hc, newPlainLazyLogInfo, SHA256LogID)
 }
 
 // LogInfoByKeyHashOverDNS builds a map of LogInfo objects (for access over DNS) indexed by their key hashes.
 func LogInfoByKeyHashOverDNS(ll *loglist.LogList, hc *http.Client) (LogInfoByHash, error) {
-	return logInfoByKeyHash(ll, hc, NewLogInfoOverDNSWrapper)
+	return logInfoByKeyHash(ll, hc, NewLogInfoOverDNSWrapper, SHA256LogID)
 }
 
-func logInfoByKeyHash(ll *loglist.LogList, hc *http.Client, infoFactory func(*loglist.Log, *http.Client) (*LogInfo, error)) (map[[sha256.Size]byte]*LogInfo, error) {
+// LogInfoByKeyHashOverDoH builds a map of LogInfo objects (for access over DNS-over-HTTPS, via the
+// resolver at the given URL) indexed by their key hashes.
+func LogInfoByKeyHashOverDoH(ll *loglist.LogList, resolverURL string, hc *http.Client) (LogInfoByHash, error) {
+	return logInfoByKeyHash(ll, hc, NewLogInfoOverDoHWrapper(resolverURL), SHA256LogID)
+}
+
+func logInfoByKeyHash(ll *loglist.LogList, hc *http.Client, infoFactory func(*loglist.Log, *http.Client) (*LogInfo, error), logID LogIDFunc) (map[[sha256.Size]byte]*LogInfo, error) {
 	result := make(map[[sha256.Size]byte]*LogInfo)
 	for _, log := range ll.Logs {
-		h := sha256.Sum256(log.Key)
+		h, err := logID(log.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive log ID for %q log: %v", log.Description, err)
+		}
 		li, err := infoFactory(&log, hc)
 		if err != nil {
 			return nil, err
@@ -122,76 +521,762 @@ func logInfoByKeyHash(ll *loglist.LogList, hc *http.Client, infoFactory func(*lo
 	return result, nil
 }
 
-// LastSTH returns the last STH known for the log.
+// LogInfoByKeyHashParallel builds a map of LogInfo objects indexed by their
+// key hashes, as for LogInfoByKeyHash, but builds them using the given number
+// of concurrent workers.  Once a log fails to build, no further logs are
+// started; the error returned is the one for the earliest failing log in the
+// list, as for LogInfoByKeyHash.
+func LogInfoByKeyHashParallel(ll *loglist.LogList, hc *http.Client, workers int) (LogInfoByHash, error) {
+	return logInfoByKeyHashParallel(ll, hc, newPlainLogInfo, SHA256LogID, workers)
+}
+
+func logInfoByKeyHashParallel(ll *loglist.LogList, hc *http.Client, infoFactory func(*loglist.Log, *http.Client) (*LogInfo, error), logID LogIDFunc, workers int) (LogInfoByHash, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers %d", workers)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Logs are handed out in list order, so once a worker fails every earlier
+	// log has been started, and will finish, before the others stop; the
+	// earliest error is therefore the one a sequential build would give.
+	var (
+		mu     sync.Mutex
+		next   int
+		result = make(LogInfoByHash)
+		errs   = make([]error, len(ll.Logs))
+		wg     sync.WaitGroup
+	)
+	build := func(i int) error {
+		log := &ll.Logs[i]
+		h, err := logID(log.Key)
+		if err != nil {
+			return fmt.Errorf("failed to derive log ID for %q log: %v", log.Description, err)
+		}
+		li, err := infoFactory(log, hc)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		result[h] = li
+		return nil
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if next >= len(ll.Logs) || ctx.Err() != nil {
+					mu.Unlock()
+					return
+				}
+				i := next
+				next++
+				mu.Unlock()
+				if errs[i] = build(i); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// LogBuildResult describes the outcome of building a LogInfo object for a
+// single log list entry.
+type LogBuildResult struct {
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	// LogID is the SHA-256 hash of the log's public key.
+	LogID []byte `json:"log_id"`
+	// Error holds the reason that construction failed, or is empty on success.
+	Error string `json:"error,omitempty"`
+}
+
+// BuildReport holds the per-log outcomes of building a LogInfoByHash from a
+// log list, together with the map of those logs that were built successfully.
+type BuildReport struct {
+	Logs  []LogBuildResult `json:"logs"`
+	Built LogInfoByHash    `json:"-"`
+}
+
+// Failed returns the results for those logs that could not be built.
+func (r *BuildReport) Failed() []LogBuildResult {
+	var failed []LogBuildResult
+	for _, lr := range r.Logs {
+		if lr.Error != "" {
+			failed = append(failed, lr)
+		}
+	}
+	return failed
+}
+
+// LogInfoByKeyHashWithReport builds a map of LogInfo objects indexed by their
+// key hashes, in the same way as LogInfoByKeyHash.  Rather than failing on the
+// first malformed entry, it continues through the whole list and returns a
+// report of which logs could (and could not) be built.
+func LogInfoByKeyHashWithReport(ll *loglist.LogList, hc *http.Client) *BuildReport {
+	return logInfoByKeyHashWithReport(ll, hc, newPlainLogInfo)
+}
+
+func logInfoByKeyHashWithReport(ll *loglist.LogList, hc *http.Client, infoFactory func(*loglist.Log, *http.Client) (*LogInfo, error)) *BuildReport {
+	report := BuildReport{Built: make(LogInfoByHash)}
+	for _, log := range ll.Logs {
+		h := sha256.Sum256(log.Key)
+		lr := LogBuildResult{
+			Description: log.Description,
+			URL:         log.URL,
+			LogID:       h[:],
+		}
+		li, err := infoFactory(&log, hc)
+		if err != nil {
+			lr.Error = err.Error()
+		} else {
+			report.Built[h] = li
+		}
+		report.Logs = append(report.Logs, lr)
+	}
+	return &report
+}
+
+// LastSTH returns the last STH known for the log.  If the log has an STH
+// cache (see WithSTHCache), the cached STH is returned instead of the
+// LogInfo's own if it is for a larger tree and its signature verifies.
 func (li *LogInfo) LastSTH() *ct.SignedTreeHead {
 	li.mu.RLock()
-	defer li.mu.RUnlock()
-	return li.lastSTH
+	last := li.lastSTH
+	li.mu.RUnlock()
+	if li.sthCache == nil {
+		return last
+	}
+	sth := li.sthCache.Get(sha256.Sum256(li.PublicKey))
+	if sth == nil || (last != nil && sth.TreeSize <= last.TreeSize) {
+		return last
+	}
+	if err := li.VerifySTHSignature(*sth); err != nil {
+		return last
+	}
+	return sth
 }
 
-// SetSTH sets the last STH known for the log.
+// SetSTH sets the last STH known for the log, recording the current time as
+// the time it was fetched.  The STH is also put in the log's STH cache, if it
+// has one.
 func (li *LogInfo) SetSTH(sth *ct.SignedTreeHead) {
+	if li.sthCache != nil {
+		li.sthCache.Put(sha256.Sum256(li.PublicKey), sth)
+	}
 	li.mu.Lock()
 	defer li.mu.Unlock()
 	li.lastSTH = sth
+	li.lastFetch = time.Now()
+}
+
+// LastFetch returns the time at which the last known STH for the log was set
+// on this LogInfo, or the zero time if there is none.
+func (li *LogInfo) LastFetch() time.Time {
+	li.mu.RLock()
+	defer li.mu.RUnlock()
+	return li.lastFetch
+}
+
+// NextPollTime suggests when the log should next be polled for a new STH:
+// PollsPerMMD times per MMD, timed from when the last STH was fetched, so
+// that logs with a shorter MMD are polled more often.  If no STH has been
+// fetched for the log (or it has no MMD), the log should be polled now.
+func (li *LogInfo) NextPollTime() time.Time {
+	last := li.LastFetch()
+	if last.IsZero() || li.MMD <= 0 {
+		return time.Now()
+	}
+	return last.Add(li.MMD / PollsPerMMD)
+}
+
+// Reconcile checks an STH for the log obtained via gossip against the last
+// known STH for the log.  If the gossiped STH is larger, its signature and its
+// consistency with the last known STH are verified and it becomes the last
+// known STH.  If it is smaller, the last known STH is verified to be consistent
+// with it.  If it is the same size but has a different root hash, an error
+// wrapping ErrSplitView is returned without fetching any proof.  If there is no
+// last known STH, the gossiped STH is adopted once its signature verifies.
+func (li *LogInfo) Reconcile(ctx context.Context, gossiped *ct.SignedTreeHead) error {
+	if err := li.VerifySTHSignature(*gossiped); err != nil {
+		return fmt.Errorf("failed to verify gossiped STH signature for %q log: %v", li.Description, err)
+	}
+	cached := li.LastSTH()
+	switch {
+	case cached == nil:
+		li.SetSTH(gossiped)
+	case gossiped.TreeSize > cached.TreeSize:
+		if err := li.VerifyConsistency(ctx, cached, gossiped); err != nil {
+			return err
+		}
+		li.SetSTH(gossiped)
+	case gossiped.TreeSize < cached.TreeSize:
+		return li.VerifyConsistency(ctx, gossiped, cached)
+	case gossiped.SHA256RootHash != cached.SHA256RootHash:
+		return fmt.Errorf("%w: %q log has root hashes %x and %x at size %d", ErrSplitView, li.Description, cached.SHA256RootHash, gossiped.SHA256RootHash, cached.TreeSize)
+	}
+	return nil
+}
+
+// VerifyConsistency checks that the log has remained append-only between two
+// of its STHs (e.g. the last known STH, see SetSTH, and a newly fetched one),
+// by retrieving a consistency proof between them from the log and verifying
+// it.  The STHs' signatures are not verified.  If the first STH is larger than
+// the second, an error wrapping ErrRollback is returned; if they are the same
+// size but have different root hashes, an error wrapping ErrSplitView is
+// returned.  No proof is fetched in either case, nor if the STHs match.
+func (li *LogInfo) VerifyConsistency(ctx context.Context, first, second *ct.SignedTreeHead) error {
+	if first == nil || second == nil {
+		return fmt.Errorf("two STHs are required to check consistency of %q log", li.Description)
+	}
+	switch {
+	case first.TreeSize > second.TreeSize:
+		return fmt.Errorf("%w: %q log STH at size %d follows one at size %d", ErrRollback, li.Description, second.TreeSize, first.TreeSize)
+	case first.TreeSize == second.TreeSize:
+		if first.SHA256RootHash != second.SHA256RootHash {
+			return fmt.Errorf("%w: %q log has root hashes %x and %x at size %d", ErrSplitView, li.Description, first.SHA256RootHash, second.SHA256RootHash, first.TreeSize)
+		}
+		return nil
+	case first.TreeSize == 0:
+		return nil
+	}
+	proof, err := li.Client.GetSTHConsistency(ctx, first.TreeSize, second.TreeSize)
+	if err != nil {
+		return fmt.Errorf("failed to get consistency proof from %q log between sizes %d and %d: %v", li.Description, first.TreeSize, second.TreeSize, err)
+	}
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyConsistencyProof(int64(first.TreeSize), int64(second.TreeSize), first.SHA256RootHash[:], second.SHA256RootHash[:], proof); err != nil {
+		return fmt.Errorf("%q log STHs at sizes %d and %d are inconsistent: %v", li.Description, first.TreeSize, second.TreeSize, err)
+	}
+	return nil
+}
+
+// leafWithTimestamp returns a copy of the leaf adjusted for the given
+// timestamp.  The leaf's TimestampedEntry is copied rather than modified in
+// place, as it is shared with the caller's copy of the leaf (and so perhaps
+// with concurrent verifications of other SCTs for the same leaf).  The
+// remaining entry contents are only read, so are not copied.
+func leafWithTimestamp(leaf ct.MerkleTreeLeaf, timestamp uint64) ct.MerkleTreeLeaf {
+	if leaf.TimestampedEntry != nil {
+		entry := *leaf.TimestampedEntry
+		entry.Timestamp = timestamp
+		leaf.TimestampedEntry = &entry
+	}
+	return leaf
 }
 
 // VerifySCTSignature checks the signature in the SCT matches the given leaf (adjusted for the
 // timestamp in the SCT) and log.
 func (li *LogInfo) VerifySCTSignature(sct ct.SignedCertificateTimestamp, leaf ct.MerkleTreeLeaf) error {
-	leaf.TimestampedEntry.Timestamp = sct.Timestamp
-	if err := li.Verifier.VerifySCTSignature(sct, ct.LogEntry{Leaf: leaf}); err != nil {
+	leaf = leafWithTimestamp(leaf, sct.Timestamp)
+	verifier, err := li.SignatureVerifier()
+	if err != nil {
+		return err
+	}
+	if err := verifier.VerifySCTSignature(sct, ct.LogEntry{Leaf: leaf}); err != nil {
 		return fmt.Errorf("failed to verify SCT signature from log %q: %v", li.Description, err)
 	}
 	return nil
 }
 
+// VerifySCTAgainstLeafInput checks that the TLS-encoded SCT was issued by the
+// log for the MerkleTreeLeaf held in the raw leaf input (as returned by the
+// log's get-entries entrypoint).  As for VerifySCTSignature, the leaf's
+// timestamp is taken from the SCT.
+func VerifySCTAgainstLeafInput(li *LogInfo, sct, leafInput []byte) error {
+	var s ct.SignedCertificateTimestamp
+	if rest, err := tls.Unmarshal(sct, &s); err != nil {
+		return fmt.Errorf("failed to parse SCT: %v", err)
+	} else if len(rest) > 0 {
+		return fmt.Errorf("trailing data (%d bytes) after SCT", len(rest))
+	}
+	var leaf ct.MerkleTreeLeaf
+	if rest, err := tls.Unmarshal(leafInput, &leaf); err != nil {
+		return fmt.Errorf("failed to parse leaf input: %v", err)
+	} else if len(rest) > 0 {
+		return fmt.Errorf("trailing data (%d bytes) after leaf input", len(rest))
+	}
+	return li.VerifySCTSignature(s, leaf)
+}
+
+// VerifyChainSCTSignature checks the signature in the SCT matches the leaf
+// built from the given chain and the log.  The chain is as for
+// VerifySCTWithVerifier; in particular, for a precertificate signed by a
+// pre-issuer the issuer key hash in the leaf is automatically taken from the
+// pre-issuer's own issuer, which must be at chain[2].
+//
+// If the SCT is embedded and the log has TolerateExtensionReordering set, an
+// SCT that does not verify is retried against the precertificate rebuilt with
+// its extensions in ascending OID order; this only helps where the CA sorted
+// the precertificate's extensions, not the final certificate's.
+func (li *LogInfo) VerifyChainSCTSignature(sct ct.SignedCertificateTimestamp, chain []*x509.Certificate, embedded bool) error {
+	leaf, err := createLeaf(chain, &sct, embedded)
+	if err != nil {
+		return fmt.Errorf("failed to build leaf for SCT from log %q: %v", li.Description, err)
+	}
+	err = li.VerifySCTSignature(sct, *leaf)
+	if err == nil || !embedded || !li.TolerateExtensionReordering {
+		return err
+	}
+	precert := *leaf.TimestampedEntry.PrecertEntry
+	precert.TBSCertificate, err = sortTBSExtensions(precert.TBSCertificate)
+	if err != nil {
+		return fmt.Errorf("failed to reorder precertificate extensions for SCT from log %q: %v", li.Description, err)
+	}
+	entry := *leaf.TimestampedEntry
+	entry.PrecertEntry = &precert
+	leaf.TimestampedEntry = &entry
+	if err := li.VerifySCTSignature(sct, *leaf); err != nil {
+		return fmt.Errorf("SCT from log %q does not verify with either extension order: %v", li.Description, err)
+	}
+	return nil
+}
+
+// VerifySCTAgainstLogURL checks the signature in the SCT matches the given leaf
+// (adjusted for the timestamp in the SCT), for a log that is identified only by
+// its URL and DER-encoded public key rather than by a trusted log list entry.
+// The key must match the log ID in the SCT.  Only the signature is checked, so
+// the log is not contacted; the URL just identifies the log in errors, and the
+// context is only checked for cancellation before verifying.
+func VerifySCTAgainstLogURL(ctx context.Context, url string, keyDER []byte, sct ct.SignedCertificateTimestamp, leaf ct.MerkleTreeLeaf) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if logID := LogIDForPublicKey(keyDER); logID != sct.LogID {
+		return fmt.Errorf("SCT log ID %x does not match key for log at %q (key hash %x)", sct.LogID.KeyID, url, logID.KeyID)
+	}
+	verifier, err := newVerifier(url, keyDER)
+	if err != nil {
+		return err
+	}
+	if err := verifier.VerifySCTSignature(sct, ct.LogEntry{Leaf: leafWithTimestamp(leaf, sct.Timestamp)}); err != nil {
+		return fmt.Errorf("failed to verify SCT signature from log %q: %v", url, err)
+	}
+	return nil
+}
+
 // VerifyInclusionLatest checks that the given Merkle tree leaf, adjusted for the provided timestamp,
-// is present in the latest known tree size of the log.  If no tree size for the log is known, it will
-// be queried.  On success, returns the index of the leaf in the log.
+// is present in the latest known tree size of the log.  If no tree size for the log is known, or the
+// last known STH is older than the log's STHMaxAge, it will be queried.  On success, returns the index
+// of the leaf in the log.
+//
+// If the log has FutureTimestampTolerance set, the timestamp is first checked
+// against the local clock, and a last known STH older than the timestamp is
+// replaced by a newly fetched one, against which the timestamp is then
+// checked; see ErrFutureTimestamp.
 func (li *LogInfo) VerifyInclusionLatest(ctx context.Context, leaf ct.MerkleTreeLeaf, timestamp uint64) (int64, error) {
+	checkTimestamp := li.FutureTimestampTolerance > 0
+	if checkTimestamp {
+		if ahead := time.Until(ct.TimestampToTime(timestamp)); ahead > li.FutureTimestampTolerance {
+			return -1, fmt.Errorf("%w: %q log SCT timestamp %d is %v ahead of local clock", ErrFutureTimestamp, li.Description, timestamp, ahead.Round(time.Millisecond))
+		}
+	}
 	sth := li.LastSTH()
-	if sth == nil {
+	if sth == nil || (li.STHMaxAge > 0 && time.Since(ct.TimestampToTime(sth.Timestamp)) > li.STHMaxAge) || (checkTimestamp && sth.Timestamp < timestamp) {
 		var err error
-		sth, err = li.Client.GetSTH(ctx)
+		sth, err = li.getSTH(ctx)
 		if err != nil {
-			return -1, fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
+			return -1, err
+		}
+	}
+	if checkTimestamp {
+		if ahead := ct.TimestampToTime(timestamp).Sub(ct.TimestampToTime(sth.Timestamp)); ahead > sthTimestampSkew {
+			return -1, fmt.Errorf("%w: %q log SCT timestamp %d is %v ahead of STH at size %d", ErrFutureTimestamp, li.Description, timestamp, ahead, sth.TreeSize)
 		}
-		li.SetSTH(sth)
 	}
-	return li.VerifyInclusionAt(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:])
+	return li.verifyInclusionInSTH(ctx, leaf, timestamp, sth)
+}
+
+// sthTimestampSkew is how far an SCT's timestamp may be ahead of the STH it is
+// checked against when FutureTimestampTolerance is set, allowing for clock
+// differences between the log's frontends and its signer.
+const sthTimestampSkew = 10 * time.Second
+
+// VerifySCTAndInclusion checks the signature in the SCT against the given leaf
+// and log, as for VerifySCTSignature, and then checks that the leaf (adjusted
+// for the timestamp in the SCT) is present in the latest known tree of the log,
+// as for VerifyInclusionLatest.  The log is not queried if the signature does
+// not verify.  The caller's leaf is not modified.  On success, returns the
+// index of the leaf in the log.
+func (li *LogInfo) VerifySCTAndInclusion(ctx context.Context, sct ct.SignedCertificateTimestamp, leaf ct.MerkleTreeLeaf) (int64, error) {
+	leaf = leafWithTimestamp(leaf, sct.Timestamp)
+	if err := li.VerifySCTSignature(sct, leaf); err != nil {
+		return -1, err
+	}
+	return li.VerifyInclusionLatest(ctx, leaf, sct.Timestamp)
 }
 
 // VerifyInclusion checks that the given Merkle tree leaf, adjusted for the provided timestamp,
 // is present in the current tree size of the log.  On success, returns the index of the leaf
 // in the log.
 func (li *LogInfo) VerifyInclusion(ctx context.Context, leaf ct.MerkleTreeLeaf, timestamp uint64) (int64, error) {
+	sth, err := li.getSTH(ctx)
+	if err != nil {
+		return -1, err
+	}
+	return li.verifyInclusionInSTH(ctx, leaf, timestamp, sth)
+}
+
+// getSTH retrieves the log's current STH for the inclusion checks, and records
+// it as the last known STH; if VerifySTHs is set, its signature is checked
+// first, as for GetVerifiedSTH.
+func (li *LogInfo) getSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	if li.VerifySTHs {
+		return li.GetVerifiedSTH(ctx)
+	}
 	sth, err := li.Client.GetSTH(ctx)
 	if err != nil {
-		return -1, fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
+		return nil, fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
 	}
 	li.SetSTH(sth)
-	return li.VerifyInclusionAt(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:])
+	return sth, nil
 }
 
 // VerifyInclusionAt checks that the given Merkle tree leaf, adjusted for the provided timestamp,
 // is present in the given tree size & root hash of the log. On success, returns the index of the
-// leaf in the log.
+// leaf in the log.  If the leaf carries a leaf_index extension (as the leaves of static CT logs do,
+// matching the extensions of their SCTs) and the log's client implements ProofByIndexClient, the
+// proof for that index is tried first.
 func (li *LogInfo) VerifyInclusionAt(ctx context.Context, leaf ct.MerkleTreeLeaf, timestamp, treeSize uint64, rootHash []byte) (int64, error) {
-	leaf.TimestampedEntry.Timestamp = timestamp
+	proof, err := li.VerifyInclusionAtWithProof(ctx, leaf, timestamp, treeSize, rootHash)
+	if err != nil {
+		return -1, err
+	}
+	return proof.LeafIndex, nil
+}
+
+// VerifyInclusionAtWithProof checks that the given Merkle tree leaf, adjusted for the provided
+// timestamp, is present in the given tree size & root hash of the log, as for VerifyInclusionAt.
+// On success, returns the inclusion proof that was verified, so that it can be stored for later
+// checking.
+func (li *LogInfo) VerifyInclusionAtWithProof(ctx context.Context, leaf ct.MerkleTreeLeaf, timestamp, treeSize uint64, rootHash []byte) (ct.InclusionProof, error) {
+	return li.verifyInclusionAt(ctx, leaf, timestamp, treeSize, rootHash, li.treeTimestamp(treeSize, rootHash), 0)
+}
+
+// VerifyInclusionAtWithin checks that the given Merkle tree leaf, adjusted for
+// the provided timestamp, is present in the given tree size & root hash of the
+// log, as for VerifyInclusionAt.  However, a log may not serve an inclusion
+// proof for an entry until shortly after the entry is merged (e.g. while its
+// frontends catch up), so if the log reports that the entry is not found (an
+// HTTP 400 or 404 response), or the request fails transiently (see
+// client.IsTransient), the proof is requested again with exponential backoff
+// until the given duration has passed or the context is done.  Other errors,
+// such as an unparseable response, are returned at once.  A zero
+// duration means the log's MMD, within which the entry must be merged.  On
+// success, returns the index of the leaf in the log.
+func (li *LogInfo) VerifyInclusionAtWithin(ctx context.Context, leaf ct.MerkleTreeLeaf, timestamp, treeSize uint64, rootHash []byte, within time.Duration) (int64, error) {
+	if within <= 0 {
+		within = li.MMD
+	}
+	proof, err := li.verifyInclusionAt(ctx, leaf, timestamp, treeSize, rootHash, li.treeTimestamp(treeSize, rootHash), within)
+	if err != nil {
+		return -1, err
+	}
+	return proof.LeafIndex, nil
+}
+
+// verifyInclusionInSTH checks that the given Merkle tree leaf, adjusted for the
+// provided timestamp, is present in the tree described by the STH, as for
+// VerifyInclusionAt.
+func (li *LogInfo) verifyInclusionInSTH(ctx context.Context, leaf ct.MerkleTreeLeaf, timestamp uint64, sth *ct.SignedTreeHead) (int64, error) {
+	proof, err := li.verifyInclusionAt(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:], sth.Timestamp, 0)
+	if err != nil {
+		return -1, err
+	}
+	return proof.LeafIndex, nil
+}
+
+// treeTimestamp returns the timestamp of the log's last known STH if it
+// describes the tree of the given size and root hash, or zero otherwise.
+func (li *LogInfo) treeTimestamp(treeSize uint64, rootHash []byte) uint64 {
+	if sth := li.LastSTH(); sth != nil && sth.TreeSize == treeSize && bytes.Equal(sth.SHA256RootHash[:], rootHash) {
+		return sth.Timestamp
+	}
+	return 0
+}
+
+// Backoff for retrying get-proof-by-hash requests in VerifyInclusionAtWithin.
+var (
+	proofRetryInitialBackoff = time.Second
+	proofRetryMaxBackoff     = 30 * time.Second
+)
+
+// verifyInclusionAt does the work of the inclusion checks; sthTimestamp is the
+// timestamp of the STH for the tree, if known, or zero.
+func (li *LogInfo) verifyInclusionAt(ctx context.Context, leaf ct.MerkleTreeLeaf, timestamp, treeSize uint64, rootHash []byte, sthTimestamp uint64, within time.Duration) (ct.InclusionProof, error) {
+	leaf = leafWithTimestamp(leaf, timestamp)
 	leafHash, err := ct.LeafHashForLeaf(&leaf)
 	if err != nil {
-		return -1, fmt.Errorf("failed to create leaf hash: %v", err)
+		return ct.InclusionProof{}, fmt.Errorf("failed to create leaf hash: %v", err)
 	}
 
-	rsp, err := li.Client.GetProofByHash(ctx, leafHash[:], treeSize)
+	if proof, ok := li.proofByIndexHint(ctx, leaf, leafHash[:], treeSize, rootHash); ok {
+		return proof, nil
+	}
+
+	rsp, err := li.getProofByHashWithin(ctx, leafHash[:], treeSize, within)
 	if err != nil {
-		return -1, fmt.Errorf("failed to GetProofByHash(sct,size=%d): %v", treeSize, err)
+		if li.NotFoundByMMD && sthTimestamp > 0 && isNotFound(err) {
+			return ct.InclusionProof{}, li.notIncludedError(timestamp, treeSize, sthTimestamp)
+		}
+		return ct.InclusionProof{}, fmt.Errorf("failed to GetProofByHash(sct,size=%d): %v", treeSize, err)
 	}
 
 	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
 	if err := verifier.VerifyInclusionProof(rsp.LeafIndex, int64(treeSize), rsp.AuditPath, rootHash, leafHash[:]); err != nil {
-		return -1, fmt.Errorf("failed to verify inclusion proof at size %d: %v", treeSize, err)
+		return ct.InclusionProof{}, fmt.Errorf("failed to verify inclusion proof at size %d: %v", treeSize, err)
+	}
+	return ct.InclusionProof{
+		LeafIndex: rsp.LeafIndex,
+		TreeSize:  treeSize,
+		RootHash:  append([]byte{}, rootHash...),
+		LeafHash:  leafHash[:],
+		AuditPath: rsp.AuditPath,
+	}, nil
+}
+
+// ProofByIndexClient is implemented by log clients that can provide an
+// inclusion proof for a leaf given its index, such as client.StaticLogClient.
+type ProofByIndexClient interface {
+	GetProofByIndex(ctx context.Context, index, treeSize uint64) (*ct.GetProofByHashResponse, error)
+}
+
+// proofByIndexHint tries to verify the inclusion of the leaf using the leaf
+// index from its leaf_index extension, as included by static CT logs, which
+// saves looking the leaf up by its hash.  It returns false if the leaf has no
+// such hint, the log's client cannot provide proofs by index, or the proof for
+// the hinted index does not verify, in which case the leaf should be looked up
+// by its hash instead.
+func (li *LogInfo) proofByIndexHint(ctx context.Context, leaf ct.MerkleTreeLeaf, leafHash []byte, treeSize uint64, rootHash []byte) (ct.InclusionProof, bool) {
+	pc, ok := li.Client.(ProofByIndexClient)
+	if !ok || leaf.TimestampedEntry == nil {
+		return ct.InclusionProof{}, false
+	}
+	index, ok := leaf.TimestampedEntry.Extensions.LeafIndex()
+	if !ok || index >= treeSize {
+		return ct.InclusionProof{}, false
+	}
+	rsp, err := pc.GetProofByIndex(ctx, index, treeSize)
+	if err != nil {
+		return ct.InclusionProof{}, false
+	}
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyInclusionProof(int64(index), int64(treeSize), rsp.AuditPath, rootHash, leafHash); err != nil {
+		return ct.InclusionProof{}, false
+	}
+	return ct.InclusionProof{
+		LeafIndex: int64(index),
+		TreeSize:  treeSize,
+		RootHash:  append([]byte{}, rootHash...),
+		LeafHash:  leafHash,
+		AuditPath: rsp.AuditPath,
+	}, true
+}
+
+// VerifyInclusionAgainstRoot checks that the given Merkle tree leaf, adjusted
+// for the provided timestamp, is present in the log's tree of the given size,
+// whose root hash is trusted by the caller (e.g. as attested by a witness).
+// No STH is retrieved or signature checked, so the log's public key is not
+// needed.  On success, returns the index of the leaf in the log.
+func VerifyInclusionAgainstRoot(ctx context.Context, li *LogInfo, leaf ct.MerkleTreeLeaf, timestamp, treeSize uint64, trustedRoot []byte) (int64, error) {
+	if len(trustedRoot) != sha256.Size {
+		return -1, fmt.Errorf("trusted root hash has length %d, want %d", len(trustedRoot), sha256.Size)
+	}
+	if treeSize == 0 {
+		return -1, errors.New("no entries in tree of size 0")
+	}
+	return li.VerifyInclusionAt(ctx, leaf, timestamp, treeSize, trustedRoot)
+}
+
+// getProofByHashWithin requests an inclusion proof from the log, retrying for
+// up to the given duration while the log reports that the entry is not found.
+func (li *LogInfo) getProofByHashWithin(ctx context.Context, hash []byte, treeSize uint64, within time.Duration) (*ct.GetProofByHashResponse, error) {
+	deadline := time.Now().Add(within)
+	wait := proofRetryInitialBackoff
+	for {
+		rsp, err := li.Client.GetProofByHash(ctx, hash, treeSize)
+		if err == nil || !(isProofPending(err) || client.IsTransient(err)) {
+			return rsp, err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, err
+		}
+		if wait > remaining {
+			wait = remaining
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if wait *= 2; wait > proofRetryMaxBackoff {
+			wait = proofRetryMaxBackoff
+		}
+	}
+}
+
+// isProofPending indicates whether the error reports an HTTP 400 or 404
+// response, as logs give for an inclusion proof for an entry that is not (yet)
+// in the tree.
+func isProofPending(err error) bool {
+	var rspErr jsonclient.RspError
+	if !errors.As(err, &rspErr) {
+		return false
+	}
+	return rspErr.StatusCode == http.StatusBadRequest || rspErr.StatusCode == http.StatusNotFound
+}
+
+// isNotFound indicates whether the error reports an HTTP 404 response.
+func isNotFound(err error) bool {
+	var rspErr jsonclient.RspError
+	return errors.As(err, &rspErr) && rspErr.StatusCode == http.StatusNotFound
+}
+
+// notIncludedError returns the error for an entry with the given timestamp
+// that is absent from the log's tree at the given size, according to whether
+// the log's MMD for the entry had passed by the timestamp of the tree's STH.
+// The local clock is not used, so a stale STH does not count against the log.
+func (li *LogInfo) notIncludedError(timestamp, treeSize, sthTimestamp uint64) error {
+	age := ct.TimestampToTime(sthTimestamp).Sub(ct.TimestampToTime(timestamp))
+	if age < li.MMD {
+		return fmt.Errorf("%w: %q log at size %d, entry age %v at STH within MMD %v", ErrNotYetIncluded, li.Description, treeSize, age.Round(time.Second), li.MMD)
+	}
+	return fmt.Errorf("%w: %q log at size %d, entry age %v at STH exceeds MMD %v", ErrMissingInclusion, li.Description, treeSize, age.Round(time.Second), li.MMD)
+}
+
+// VerifyInclusionAgainstAny checks that the given Merkle tree leaf, adjusted for
+// the provided timestamp, is present in the log's tree as described by any of
+// the given STHs (e.g. as collected from gossip peers).  The STHs are tried in
+// order of increasing tree size, skipping any whose signature does not verify,
+// so the leaf is checked against the smallest tree that includes it.  Returns
+// that STH and the index of the leaf.
+func VerifyInclusionAgainstAny(ctx context.Context, li *LogInfo, leaf ct.MerkleTreeLeaf, timestamp uint64, sths []*ct.SignedTreeHead) (*ct.SignedTreeHead, int64, error) {
+	sorted := make([]*ct.SignedTreeHead, 0, len(sths))
+	for _, sth := range sths {
+		if sth != nil {
+			sorted = append(sorted, sth)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].TreeSize < sorted[j].TreeSize })
+
+	var errs []string
+	for _, sth := range sorted {
+		if err := li.VerifySTHSignature(*sth); err != nil {
+			errs = append(errs, fmt.Sprintf("size %d: invalid STH signature: %v", sth.TreeSize, err))
+			continue
+		}
+		index, err := li.verifyInclusionInSTH(ctx, leaf, timestamp, sth)
+		if err == nil {
+			return sth, index, nil
+		}
+		errs = append(errs, fmt.Sprintf("size %d: %v", sth.TreeSize, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, -1, fmt.Errorf("failed to verify inclusion in %q log against any of %d STH(s): %s", li.Description, len(sorted), strings.Join(errs, "; "))
+}
+
+// VerifyInclusionProof checks, without contacting the log, that the given
+// inclusion proof shows that the Merkle tree leaf (adjusted for the provided
+// timestamp) is present in the tree described by the STH.  The signature on
+// the STH is checked against the log's key.  This allows proofs supplied by a
+// third party (e.g. as parsed by ct.ParseGetProofByHashResponse) to be checked.
+func (li *LogInfo) VerifyInclusionProof(leaf ct.MerkleTreeLeaf, timestamp uint64, proof *ct.GetProofByHashResponse, sth *ct.SignedTreeHead) error {
+	if proof == nil || sth == nil {
+		return errors.New("missing inclusion proof or STH")
+	}
+	if err := li.VerifySTHSignature(*sth); err != nil {
+		return fmt.Errorf("failed to verify STH signature from log %q: %v", li.Description, err)
+	}
+	leaf = leafWithTimestamp(leaf, timestamp)
+	leafHash, err := ct.LeafHashForLeaf(&leaf)
+	if err != nil {
+		return fmt.Errorf("failed to create leaf hash: %v", err)
+	}
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyInclusionProof(proof.LeafIndex, int64(sth.TreeSize), proof.AuditPath, sth.SHA256RootHash[:], leafHash[:]); err != nil {
+		return fmt.Errorf("failed to verify inclusion proof at size %d: %v", sth.TreeSize, err)
+	}
+	return nil
+}
+
+// EntryAndProofClient is implemented by log clients that can retrieve a log
+// entry together with its inclusion proof, such as client.LogClient.
+type EntryAndProofClient interface {
+	GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error)
+}
+
+// GetEntryAndVerify retrieves the entry at the given index in the log, along
+// with its inclusion proof in a single request, and checks that the proof
+// shows the entry to be at that index in the tree of the given size & root
+// hash.  The response does not include the index, so an entry from any other
+// index fails verification.  On success, returns the parsed entry.  The log's
+// client must implement EntryAndProofClient.
+func (li *LogInfo) GetEntryAndVerify(ctx context.Context, leafIndex, treeSize uint64, rootHash []byte) (*ct.LogEntry, error) {
+	ec, ok := li.Client.(EntryAndProofClient)
+	if !ok {
+		return nil, fmt.Errorf("client for %q log cannot retrieve entries with proofs", li.Description)
+	}
+	if leafIndex >= treeSize {
+		return nil, fmt.Errorf("index %d is beyond tree size %d", leafIndex, treeSize)
+	}
+	rsp, err := ec.GetEntryAndProof(ctx, leafIndex, treeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetEntryAndProof(index=%d,size=%d): %v", leafIndex, treeSize, err)
+	}
+
+	leafHash := rfc6962.DefaultHasher.HashLeaf(rsp.LeafInput)
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyInclusionProof(int64(leafIndex), int64(treeSize), rsp.AuditPath, rootHash, leafHash); err != nil {
+		return nil, fmt.Errorf("entry returned for index %d does not verify at that index in tree of size %d: %v", leafIndex, treeSize, err)
+	}
+
+	entry, err := ct.LogEntryFromLeaf(int64(leafIndex), &ct.LeafEntry{LeafInput: rsp.LeafInput, ExtraData: rsp.ExtraData})
+	if x509.IsFatal(err) {
+		return nil, fmt.Errorf("failed to parse entry %d: %v", leafIndex, err)
+	}
+	return entry, nil
+}
+
+// VerifyInclusionFromMirror checks that the given Merkle tree leaf, adjusted
+// for the provided timestamp, is present in the origin log's tree as described
+// by the given STH, using an inclusion proof fetched from a (read-only) mirror
+// of the log.  The STH must be signed by the origin log, described by li, and
+// the proof is verified against the STH's root hash, so a mirror cannot forge
+// inclusion.  On success, returns the index of the leaf in the log.
+func VerifyInclusionFromMirror(ctx context.Context, li *LogInfo, mirror client.CheckLogClient, originSTH *ct.SignedTreeHead, leaf ct.MerkleTreeLeaf, timestamp uint64) (int64, error) {
+	if originSTH == nil {
+		return -1, errors.New("missing origin STH")
+	}
+	if err := li.VerifySTHSignature(*originSTH); err != nil {
+		return -1, fmt.Errorf("failed to verify origin STH signature from log %q: %v", li.Description, err)
+	}
+	stamped := leafWithTimestamp(leaf, timestamp)
+	leafHash, err := ct.LeafHashForLeaf(&stamped)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create leaf hash: %v", err)
+	}
+	proof, err := mirror.GetProofByHash(ctx, leafHash[:], originSTH.TreeSize)
+	if err != nil {
+		return -1, fmt.Errorf("failed to GetProofByHash(sct,size=%d) from mirror %q: %v", originSTH.TreeSize, mirror.BaseURI(), err)
+	}
+	if err := li.VerifyInclusionProof(leaf, timestamp, proof, originSTH); err != nil {
+		return -1, fmt.Errorf("proof from mirror %q: %v", mirror.BaseURI(), err)
 	}
-	return rsp.LeafIndex, nil
+	return proof.LeafIndex, nil
 }
@@ -0,0 +1,2119 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/loglist"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// fakeLog is an in-memory CT log that implements client.CheckLogClient.
+type fakeLog struct {
+	mu        sync.Mutex
+	uri       string
+	key       *ecdsa.PrivateKey
+	tree      *merkle.InMemoryMerkleTree
+	index     map[[sha256.Size]byte]int64
+	entries   [][]byte
+	timestamp uint64
+	// maxEntries limits the number of entries returned by GetRawEntries, if set.
+	maxEntries int
+	// Errors to return from the corresponding methods, if set.
+	sthErr   error
+	proofErr error
+}
+
+func newFakeLog(t *testing.T, uri string) *fakeLog {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &fakeLog{
+		uri:       uri,
+		key:       key,
+		tree:      merkle.NewInMemoryMerkleTree(rfc6962.DefaultHasher),
+		index:     make(map[[sha256.Size]byte]int64),
+		timestamp: uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+	}
+}
+
+// keyDER returns the DER-encoded public key of the log.
+func (f *fakeLog) keyDER(t *testing.T) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&f.key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return der
+}
+
+// logInfo builds a LogInfo object that accesses the fake log.
+func (f *fakeLog) logInfo(t *testing.T) *LogInfo {
+	t.Helper()
+	verifier, err := ct.NewSignatureVerifier(&f.key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to build verifier: %v", err)
+	}
+	return &LogInfo{
+		Description: f.uri,
+		Client:      f,
+		MMD:         24 * time.Hour,
+		Verifier:    verifier,
+		PublicKey:   f.keyDER(t),
+	}
+}
+
+// addLeaf adds the given leaf to the log, returning its index.
+func (f *fakeLog) addLeaf(t *testing.T, leaf *ct.MerkleTreeLeaf) int64 {
+	t.Helper()
+	data, err := tls.Marshal(*leaf)
+	if err != nil {
+		t.Fatalf("failed to marshal leaf: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	seq, _ := f.tree.AddLeaf(data)
+	index := seq - 1
+	f.entries = append(f.entries, data)
+	f.index[sha256.Sum256(append([]byte{ct.TreeLeafPrefix}, data...))] = index
+	return index
+}
+
+// signSCT issues an SCT from the log for the given leaf, at the given timestamp.
+func (f *fakeLog) signSCT(t *testing.T, leaf ct.MerkleTreeLeaf, timestamp uint64) ct.SignedCertificateTimestamp {
+	t.Helper()
+	leaf = *stamped(leaf, timestamp)
+	sct := ct.SignedCertificateTimestamp{
+		SCTVersion: ct.V1,
+		LogID:      ct.LogID{KeyID: sha256.Sum256(f.keyDER(t))},
+		Timestamp:  timestamp,
+	}
+	data, err := ct.SerializeSCTSignatureInput(sct, ct.LogEntry{Leaf: leaf})
+	if err != nil {
+		t.Fatalf("failed to serialize SCT: %v", err)
+	}
+	sig, err := tls.CreateSignature(*f.key, tls.SHA256, data)
+	if err != nil {
+		t.Fatalf("failed to sign SCT: %v", err)
+	}
+	sct.Signature = ct.DigitallySigned(sig)
+	return sct
+}
+
+// sthAt builds a signed tree head for the given size of the log.
+func (f *fakeLog) sthAt(size uint64) (*ct.SignedTreeHead, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sth := &ct.SignedTreeHead{
+		Version:   ct.V1,
+		TreeSize:  size,
+		Timestamp: f.timestamp,
+	}
+	copy(sth.SHA256RootHash[:], f.tree.RootAtSnapshot(int64(size)).Hash())
+	data, err := ct.SerializeSTHSignatureInput(*sth)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := tls.CreateSignature(*f.key, tls.SHA256, data)
+	if err != nil {
+		return nil, err
+	}
+	sth.TreeHeadSignature = ct.DigitallySigned(sig)
+	return sth, nil
+}
+
+func (f *fakeLog) BaseURI() string {
+	return f.uri
+}
+
+func (f *fakeLog) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	if f.sthErr != nil {
+		return nil, f.sthErr
+	}
+	f.mu.Lock()
+	size := uint64(f.tree.LeafCount())
+	f.mu.Unlock()
+	return f.sthAt(size)
+}
+
+func (f *fakeLog) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var proof [][]byte
+	for _, node := range f.tree.SnapshotConsistency(int64(first), int64(second)) {
+		proof = append(proof, node.Value.Hash())
+	}
+	return proof, nil
+}
+
+func (f *fakeLog) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	if f.proofErr != nil {
+		return nil, f.proofErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var leafHash [sha256.Size]byte
+	copy(leafHash[:], hash)
+	index, ok := f.index[leafHash]
+	if !ok || uint64(index) >= treeSize {
+		return nil, jsonclient.RspError{Err: fmt.Errorf("got HTTP Status %q", "404 Not Found"), StatusCode: http.StatusNotFound}
+	}
+	rsp := &ct.GetProofByHashResponse{LeafIndex: index}
+	for _, node := range f.tree.PathToRootAtSnapshot(index+1, int64(treeSize)) {
+		rsp.AuditPath = append(rsp.AuditPath, node.Value.Hash())
+	}
+	return rsp, nil
+}
+
+func (f *fakeLog) GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if start < 0 || end < start || start >= int64(len(f.entries)) {
+		return nil, jsonclient.RspError{Err: fmt.Errorf("got HTTP Status %q", "400 Bad Request"), StatusCode: http.StatusBadRequest}
+	}
+	if end >= int64(len(f.entries)) {
+		end = int64(len(f.entries)) - 1
+	}
+	if f.maxEntries > 0 && end-start+1 > int64(f.maxEntries) {
+		end = start + int64(f.maxEntries) - 1
+	}
+	var rsp ct.GetEntriesResponse
+	for _, data := range f.entries[start : end+1] {
+		rsp.Entries = append(rsp.Entries, ct.LeafEntry{LeafInput: data})
+	}
+	return &rsp, nil
+}
+
+func (f *fakeLog) GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if index >= treeSize || treeSize > uint64(len(f.entries)) {
+		return nil, jsonclient.RspError{Err: fmt.Errorf("got HTTP Status %q", "400 Bad Request"), StatusCode: http.StatusBadRequest}
+	}
+	// The extra data is an empty (X.509) certificate chain.
+	rsp := &ct.GetEntryAndProofResponse{LeafInput: f.entries[index], ExtraData: []byte{0, 0, 0}}
+	for _, node := range f.tree.PathToRootAtSnapshot(int64(index)+1, int64(treeSize)) {
+		rsp.AuditPath = append(rsp.AuditPath, node.Value.Hash())
+	}
+	return rsp, nil
+}
+
+// testLeaf builds an X.509 Merkle tree leaf with distinct contents for each n.
+func testLeaf(n int) ct.MerkleTreeLeaf {
+	return *ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: []byte(fmt.Sprintf("certificate-%d", n))}, 0)
+}
+
+// stamped returns a copy of the leaf adjusted for the given timestamp.
+func stamped(leaf ct.MerkleTreeLeaf, timestamp uint64) *ct.MerkleTreeLeaf {
+	entry := *leaf.TimestampedEntry
+	entry.Timestamp = timestamp
+	leaf.TimestampedEntry = &entry
+	return &leaf
+}
+
+// addLeaves adds count filler leaves to the log.
+func (f *fakeLog) addLeaves(t *testing.T, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		f.mu.Lock()
+		n := int(f.tree.LeafCount())
+		f.mu.Unlock()
+		f.addLeaf(t, stamped(testLeaf(-1-n), uint64(n)))
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 5)
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	want := fl.addLeaf(t, stamped(leaf, timestamp))
+	fl.addLeaves(t, 1)
+	li := fl.logInfo(t)
+
+	got, err := li.VerifyInclusion(ctx, leaf, timestamp)
+	if err != nil {
+		t.Fatalf("VerifyInclusion()=_,%v; want _,nil", err)
+	}
+	if got != want {
+		t.Errorf("VerifyInclusion()=%d; want %d", got, want)
+	}
+	if _, err := li.VerifyInclusion(ctx, testLeaf(2), timestamp); err == nil {
+		t.Error("VerifyInclusion(absent leaf)=_,nil; want _,non-nil")
+	}
+}
+
+func TestLogInfoByKeyHashWithReport(t *testing.T) {
+	ll := loglist.LogList{
+		Logs: []loglist.Log{
+			{Description: "good log 1", URL: "good1.example.com", Key: newFakeLog(t, "good1").keyDER(t), MaximumMergeDelay: 86400},
+			{Description: "bad log", URL: "bad.example.com", Key: []byte("not a key"), MaximumMergeDelay: 86400},
+			{Description: "good log 2", URL: "good2.example.com", Key: newFakeLog(t, "good2").keyDER(t), MaximumMergeDelay: 86400},
+		},
+	}
+	if _, err := LogInfoByKeyHash(&ll, http.DefaultClient); err == nil {
+		t.Fatal("LogInfoByKeyHash()=_,nil; want _,non-nil")
+	}
+
+	report := LogInfoByKeyHashWithReport(&ll, http.DefaultClient)
+	if got, want := len(report.Logs), len(ll.Logs); got != want {
+		t.Fatalf("len(report.Logs)=%d; want %d", got, want)
+	}
+	for i, lr := range report.Logs {
+		if lr.Description != ll.Logs[i].Description {
+			t.Errorf("report.Logs[%d].Description=%q; want %q", i, lr.Description, ll.Logs[i].Description)
+		}
+		if got, want := lr.Error != "", ll.Logs[i].Description == "bad log"; got != want {
+			t.Errorf("report.Logs[%d].Error=%q; want failure=%v", i, lr.Error, want)
+		}
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].URL != "bad.example.com" {
+		t.Errorf("report.Failed()=%+v; want just bad.example.com", failed)
+	}
+	if got, want := len(report.Built), 2; got != want {
+		t.Errorf("len(report.Built)=%d; want %d", got, want)
+	}
+	for _, log := range ll.Logs {
+		_, ok := report.Built[sha256.Sum256(log.Key)]
+		if want := log.Description != "bad log"; ok != want {
+			t.Errorf("report.Built[%q] present=%v; want %v", log.Description, ok, want)
+		}
+	}
+}
+
+func TestVerifyInclusionProof(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 5)
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	fl.addLeaf(t, stamped(leaf, timestamp))
+	fl.addLeaves(t, 2)
+	li := fl.logInfo(t)
+
+	sth, err := fl.GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetSTH()=_,%v", err)
+	}
+	leafHash, err := ct.LeafHashForLeaf(stamped(leaf, timestamp))
+	if err != nil {
+		t.Fatalf("LeafHashForLeaf()=_,%v", err)
+	}
+	rsp, err := fl.GetProofByHash(ctx, leafHash[:], sth.TreeSize)
+	if err != nil {
+		t.Fatalf("GetProofByHash()=_,%v", err)
+	}
+	data, err := json.Marshal(rsp)
+	if err != nil {
+		t.Fatalf("json.Marshal()=_,%v", err)
+	}
+	proof, err := ct.ParseGetProofByHashResponse(data)
+	if err != nil {
+		t.Fatalf("ParseGetProofByHashResponse()=_,%v", err)
+	}
+
+	if err := li.VerifyInclusionProof(leaf, timestamp, proof, sth); err != nil {
+		t.Errorf("VerifyInclusionProof()=%v; want nil", err)
+	}
+	if err := li.VerifyInclusionProof(leaf, timestamp+1, proof, sth); err == nil {
+		t.Error("VerifyInclusionProof(wrong timestamp)=nil; want non-nil")
+	}
+	badSTH := *sth
+	badSTH.TreeSize++
+	if err := li.VerifyInclusionProof(leaf, timestamp, proof, &badSTH); err == nil {
+		t.Error("VerifyInclusionProof(bad STH signature)=nil; want non-nil")
+	}
+	corrupt := strings.Replace(string(data), `"audit_path":["`, `"audit_path":["%%`, 1)
+	if _, err := ct.ParseGetProofByHashResponse([]byte(corrupt)); err == nil {
+		t.Errorf("ParseGetProofByHashResponse(%s)=_,nil; want _,non-nil", corrupt)
+	}
+}
+
+func TestVerifySCTSignatureSharedLeaf(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	li := fl.logInfo(t)
+	leaf := testLeaf(1)
+	first := fl.signSCT(t, leaf, 1000)
+	second := fl.signSCT(t, leaf, 2000)
+	origTimestamp := leaf.TimestampedEntry.Timestamp
+
+	if err := li.VerifySCTSignature(first, leaf); err != nil {
+		t.Errorf("VerifySCTSignature(first)=%v; want nil", err)
+	}
+	if err := li.VerifySCTSignature(second, leaf); err != nil {
+		t.Errorf("VerifySCTSignature(second)=%v; want nil", err)
+	}
+	if got := leaf.TimestampedEntry.Timestamp; got != origTimestamp {
+		t.Errorf("leaf timestamp after verification=%d; want unchanged %d", got, origTimestamp)
+	}
+
+	// Concurrent verifications of the same leaf must not interfere.
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		for _, sct := range []ct.SignedCertificateTimestamp{first, second} {
+			wg.Add(1)
+			go func(sct ct.SignedCertificateTimestamp) {
+				defer wg.Done()
+				errs <- li.VerifySCTSignature(sct, leaf)
+			}(sct)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent VerifySCTSignature()=%v; want nil", err)
+		}
+	}
+}
+
+func TestMustLogForSCT(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	li := fl.logInfo(t)
+	m := LogInfoByHash{sha256.Sum256(fl.keyDER(t)): li}
+
+	if got, err := m.MustLogForSCT(fl.signSCT(t, testLeaf(1), 1000)); err != nil || got != li {
+		t.Errorf("MustLogForSCT(known)=%v,%v; want %v,nil", got, err, li)
+	}
+
+	unknown := newFakeLog(t, "https://unknown.example.com").signSCT(t, testLeaf(1), 1000)
+	got, err := m.MustLogForSCT(unknown)
+	if err == nil {
+		t.Fatalf("MustLogForSCT(unknown)=%v,nil; want _,non-nil", got)
+	}
+	if !errors.Is(err, ErrUnknownLog) {
+		t.Errorf("MustLogForSCT(unknown)=_,%v; want error wrapping ErrUnknownLog", err)
+	}
+	if want := fmt.Sprintf("%x", unknown.LogID.KeyID); !strings.Contains(err.Error(), want) {
+		t.Errorf("MustLogForSCT(unknown)=_,%q; want error containing %s", err, want)
+	}
+}
+
+func TestLogInfoForSCT(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	li := fl.logInfo(t)
+	m := LogInfoByHash{sha256.Sum256(fl.keyDER(t)): li}
+
+	if got, ok := m.LogInfoForSCT(fl.signSCT(t, testLeaf(1), 1000)); !ok || got != li {
+		t.Errorf("LogInfoForSCT(known)=%v,%t; want %v,true", got, ok, li)
+	}
+	unknown := newFakeLog(t, "https://unknown.example.com").signSCT(t, testLeaf(1), 1000)
+	if got, ok := m.LogInfoForSCT(unknown); ok || got != nil {
+		t.Errorf("LogInfoForSCT(unknown)=%v,%t; want nil,false", got, ok)
+	}
+	if got, ok := LogInfoByHash(nil).LogInfoForSCT(unknown); ok || got != nil {
+		t.Errorf("LogInfoForSCT(nil map)=%v,%t; want nil,false", got, ok)
+	}
+}
+
+func TestLogIDForPublicKey(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	keyDER := fl.keyDER(t)
+	sct := fl.signSCT(t, testLeaf(1), 1000)
+	if got := LogIDForPublicKey(keyDER); got != sct.LogID {
+		t.Errorf("LogIDForPublicKey()=%x; want %x", got.KeyID, sct.LogID.KeyID)
+	}
+	if got := LogIDForLog(&loglist.Log{Key: keyDER}); got != sct.LogID {
+		t.Errorf("LogIDForLog()=%x; want %x", got.KeyID, sct.LogID.KeyID)
+	}
+
+	li := fl.logInfo(t)
+	want := sha256.Sum256(keyDER)
+	if got := (LogInfoByHash{want: li}).Key(li); got != want {
+		t.Errorf("Key()=%x; want %x", got, want)
+	}
+	other := sha256.Sum256([]byte("other log ID"))
+	if got := (LogInfoByHash{other: li}).Key(li); got != other {
+		t.Errorf("Key(other log ID)=%x; want %x", got, other)
+	}
+	if got := (LogInfoByHash{}).Key(li); got != want {
+		t.Errorf("Key(not in map)=%x; want %x", got, want)
+	}
+}
+
+func TestVerifyAndLog(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	wantIndex := fl.addLeaf(t, stamped(leaf, timestamp))
+	fl.addLeaves(t, 2)
+	li := fl.logInfo(t)
+	m := LogInfoByHash{sha256.Sum256(fl.keyDER(t)): li}
+	sct := fl.signSCT(t, leaf, timestamp)
+
+	got, index, err := m.VerifyAndLog(ctx, sct, leaf)
+	if err != nil {
+		t.Fatalf("VerifyAndLog()=_,_,%v; want _,_,nil", err)
+	}
+	if got != li || index != wantIndex {
+		t.Errorf("VerifyAndLog()=%v,%d,nil; want %v,%d,nil", got, index, li, wantIndex)
+	}
+
+	// The SCT signature is checked before inclusion.
+	forged := sct
+	forged.Timestamp++
+	if _, _, err := m.VerifyAndLog(ctx, forged, leaf); err == nil {
+		t.Error("VerifyAndLog(bad signature)=_,_,nil; want _,_,non-nil")
+	} else if msg := err.Error(); strings.Count(msg, "failed to verify SCT signature") != 1 {
+		t.Errorf("VerifyAndLog(bad signature)=_,_,%q; want error naming the failure once", msg)
+	}
+	// A correctly signed SCT for an entry that is not in the log.
+	if _, _, err := m.VerifyAndLog(ctx, fl.signSCT(t, testLeaf(2), timestamp), testLeaf(2)); err == nil {
+		t.Error("VerifyAndLog(not included)=_,_,nil; want _,_,non-nil")
+	}
+	unknown := newFakeLog(t, "https://unknown.example.com").signSCT(t, leaf, timestamp)
+	if got, _, err := m.VerifyAndLog(ctx, unknown, leaf); got != nil || !errors.Is(err, ErrUnknownLog) {
+		t.Errorf("VerifyAndLog(unknown log)=%v,_,%v; want nil,_,error wrapping ErrUnknownLog", got, err)
+	}
+}
+
+func TestVerifySCTAndInclusion(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	wantIndex := fl.addLeaf(t, stamped(leaf, timestamp))
+	fl.addLeaves(t, 2)
+	sct := fl.signSCT(t, leaf, timestamp)
+	wantTimestamp := leaf.TimestampedEntry.Timestamp
+
+	li := fl.logInfo(t)
+	if index, err := li.VerifySCTAndInclusion(ctx, sct, leaf); err != nil || index != wantIndex {
+		t.Errorf("VerifySCTAndInclusion()=%d,%v; want %d,nil", index, err, wantIndex)
+	}
+	if got := leaf.TimestampedEntry.Timestamp; got != wantTimestamp {
+		t.Errorf("VerifySCTAndInclusion() modified leaf timestamp to %d; want %d", got, wantTimestamp)
+	}
+	if _, err := li.VerifySCTAndInclusion(ctx, fl.signSCT(t, testLeaf(2), timestamp), testLeaf(2)); err == nil {
+		t.Error("VerifySCTAndInclusion(not included)=_,nil; want _,non-nil")
+	}
+
+	// The log is not queried for an SCT whose signature does not verify.
+	fl.sthErr = errors.New("get-sth called")
+	li = fl.logInfo(t)
+	forged := sct
+	forged.Timestamp++
+	if _, err := li.VerifySCTAndInclusion(ctx, forged, leaf); err == nil || strings.Contains(err.Error(), "get-sth called") {
+		t.Errorf("VerifySCTAndInclusion(bad signature)=_,%v; want signature error", err)
+	}
+}
+
+func TestVerifyInclusionAgainstAny(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 4)
+	small, err := fl.sthAt(4)
+	if err != nil {
+		t.Fatalf("sthAt(4)=_,%v", err)
+	}
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	wantIndex := fl.addLeaf(t, stamped(leaf, timestamp))
+	fl.addLeaves(t, 3)
+	medium, err := fl.sthAt(6)
+	if err != nil {
+		t.Fatalf("sthAt(6)=_,%v", err)
+	}
+	large, err := fl.sthAt(8)
+	if err != nil {
+		t.Fatalf("sthAt(8)=_,%v", err)
+	}
+	forged := *small
+	forged.TreeSize = 7
+	li := fl.logInfo(t)
+
+	sth, index, err := VerifyInclusionAgainstAny(ctx, li, leaf, timestamp, []*ct.SignedTreeHead{large, small, &forged, medium})
+	if err != nil {
+		t.Fatalf("VerifyInclusionAgainstAny()=_,_,%v; want _,_,nil", err)
+	}
+	if sth != medium {
+		t.Errorf("VerifyInclusionAgainstAny() used STH at size %d; want %d", sth.TreeSize, medium.TreeSize)
+	}
+	if index != wantIndex {
+		t.Errorf("VerifyInclusionAgainstAny()=_,%d,nil; want _,%d,nil", index, wantIndex)
+	}
+
+	// Inclusion only succeeds against the larger STH.
+	if sth, _, err := VerifyInclusionAgainstAny(ctx, li, leaf, timestamp, []*ct.SignedTreeHead{small, large}); err != nil || sth != large {
+		t.Errorf("VerifyInclusionAgainstAny(small, large)=%v,_,%v; want STH at size 8", sth, err)
+	}
+	if _, _, err := VerifyInclusionAgainstAny(ctx, li, leaf, timestamp, []*ct.SignedTreeHead{small, &forged}); err == nil {
+		t.Error("VerifyInclusionAgainstAny(too small)=_,_,nil; want _,_,non-nil")
+	}
+}
+
+func TestVerifyInclusionNotFoundByMMD(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	li := fl.logInfo(t)
+	li.NotFoundByMMD = true
+	now := time.Now()
+	toMillis := func(t time.Time) uint64 { return uint64(t.UnixNano() / int64(time.Millisecond)) }
+
+	for _, test := range []struct {
+		desc      string
+		sthTime   time.Time
+		timestamp time.Time
+		want      error
+	}{
+		{desc: "young", sthTime: now, timestamp: now.Add(-time.Hour), want: ErrNotYetIncluded},
+		{desc: "old", sthTime: now, timestamp: now.Add(-48 * time.Hour), want: ErrMissingInclusion},
+		// The MMD had not passed when a stale STH was issued, however long ago.
+		{desc: "stale-sth", sthTime: now.Add(-48 * time.Hour), timestamp: now.Add(-49 * time.Hour), want: ErrNotYetIncluded},
+		{desc: "stale-sth-old", sthTime: now.Add(-48 * time.Hour), timestamp: now.Add(-96 * time.Hour), want: ErrMissingInclusion},
+	} {
+		fl.timestamp = toMillis(test.sthTime)
+		sth, err := fl.sthAt(3)
+		if err != nil {
+			t.Fatalf("sthAt(3)=_,%v", err)
+		}
+		li.SetSTH(sth)
+		_, err = li.VerifyInclusionAt(ctx, testLeaf(1), toMillis(test.timestamp), sth.TreeSize, sth.SHA256RootHash[:])
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: VerifyInclusionAt()=_,%v; want _,%v", test.desc, err, test.want)
+		}
+		_, err = li.VerifyInclusion(ctx, testLeaf(1), toMillis(test.timestamp))
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: VerifyInclusion()=_,%v; want _,%v", test.desc, err, test.want)
+		}
+	}
+	sth := li.LastSTH()
+
+	// Without a known STH for the tree, a 404 is a plain failure.
+	li.SetSTH(nil)
+	_, err := li.VerifyInclusionAt(ctx, testLeaf(1), toMillis(now.Add(-48*time.Hour)), sth.TreeSize, sth.SHA256RootHash[:])
+	if err == nil || errors.Is(err, ErrNotYetIncluded) || errors.Is(err, ErrMissingInclusion) {
+		t.Errorf("VerifyInclusionAt(unknown STH)=_,%v; want plain error", err)
+	}
+	li.SetSTH(sth)
+
+	// Other failures are reported as before.
+	fl.proofErr = jsonclient.RspError{Err: errors.New("got HTTP Status 500"), StatusCode: http.StatusInternalServerError}
+	_, err = li.VerifyInclusionAt(ctx, testLeaf(1), toMillis(now), sth.TreeSize, sth.SHA256RootHash[:])
+	if err == nil || errors.Is(err, ErrNotYetIncluded) || errors.Is(err, ErrMissingInclusion) {
+		t.Errorf("VerifyInclusionAt(server error)=_,%v; want other error", err)
+	}
+
+	// Without the option, a 404 is a plain failure.
+	fl.proofErr = nil
+	li.NotFoundByMMD = false
+	_, err = li.VerifyInclusionAt(ctx, testLeaf(1), toMillis(now), sth.TreeSize, sth.SHA256RootHash[:])
+	if err == nil || errors.Is(err, ErrNotYetIncluded) {
+		t.Errorf("VerifyInclusionAt(option unset)=_,%v; want plain error", err)
+	}
+}
+
+// issueCert creates a certificate from the template, signed by the parent
+// (or self-signed if parent is nil), returning it and its private key.
+func issueCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate %q: %v", template.Subject.CommonName, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate %q: %v", template.Subject.CommonName, err)
+	}
+	return cert, key
+}
+
+func TestVerifyChainSCTSignaturePreIssuer(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	li := fl.logInfo(t)
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.Add(24 * time.Hour)
+	template := func(serial int64, cn string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+		}
+	}
+
+	caTemplate := template(1, "Test CA")
+	caTemplate.IsCA, caTemplate.BasicConstraintsValid = true, true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	ca, caKey := issueCert(t, caTemplate, nil, nil)
+
+	preIssuerTemplate := template(2, "Test CA Precertificate Signing")
+	preIssuerTemplate.IsCA, preIssuerTemplate.BasicConstraintsValid = true, true
+	preIssuerTemplate.KeyUsage = x509.KeyUsageCertSign
+	preIssuerTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCertificateTransparency}
+	preIssuer, preIssuerKey := issueCert(t, preIssuerTemplate, ca, caKey)
+
+	precertTemplate := template(3, "www.example.com")
+	precertTemplate.ExtraExtensions = []pkix.Extension{{Id: x509.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}}
+	precert, _ := issueCert(t, precertTemplate, ca, caKey)
+	prePrecert, _ := issueCert(t, precertTemplate, preIssuer, preIssuerKey)
+
+	// precertLeaf builds the expected leaf by hand: the issuer key hash is
+	// always that of the final issuing CA.
+	precertLeaf := func(cert, preIssuer *x509.Certificate) ct.MerkleTreeLeaf {
+		tbs, err := x509.BuildPrecertTBS(cert.RawTBSCertificate, preIssuer)
+		if err != nil {
+			t.Fatalf("BuildPrecertTBS()=_,%v", err)
+		}
+		return ct.MerkleTreeLeaf{
+			Version:  ct.V1,
+			LeafType: ct.TimestampedEntryLeafType,
+			TimestampedEntry: &ct.TimestampedEntry{
+				EntryType: ct.PrecertLogEntryType,
+				PrecertEntry: &ct.PreCert{
+					IssuerKeyHash:  sha256.Sum256(ca.RawSubjectPublicKeyInfo),
+					TBSCertificate: tbs,
+				},
+			},
+		}
+	}
+	timestamp := uint64(notBefore.UnixNano() / int64(time.Millisecond))
+
+	tests := []struct {
+		desc  string
+		chain []*x509.Certificate
+		sct   ct.SignedCertificateTimestamp
+	}{
+		{
+			desc:  "normal-issuer",
+			chain: []*x509.Certificate{precert, ca},
+			sct:   fl.signSCT(t, precertLeaf(precert, nil), timestamp),
+		},
+		{
+			desc:  "pre-issuer",
+			chain: []*x509.Certificate{prePrecert, preIssuer, ca},
+			sct:   fl.signSCT(t, precertLeaf(prePrecert, preIssuer), timestamp),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := li.VerifyChainSCTSignature(test.sct, test.chain, false); err != nil {
+				t.Errorf("VerifyChainSCTSignature()=%v; want nil", err)
+			}
+			if err := VerifySCTWithVerifier(li.Verifier, test.chain, &test.sct, false); err != nil {
+				t.Errorf("VerifySCTWithVerifier()=%v; want nil", err)
+			}
+		})
+	}
+
+	// An SCT over the pre-issuer's own key hash does not verify.
+	wrong := precertLeaf(prePrecert, preIssuer)
+	wrong.TimestampedEntry.PrecertEntry.IssuerKeyHash = sha256.Sum256(preIssuer.RawSubjectPublicKeyInfo)
+	sct := fl.signSCT(t, wrong, timestamp)
+	if err := li.VerifyChainSCTSignature(sct, []*x509.Certificate{prePrecert, preIssuer, ca}, false); err == nil {
+		t.Error("VerifyChainSCTSignature(pre-issuer key hash)=nil; want non-nil")
+	}
+	if err := li.VerifyChainSCTSignature(sct, []*x509.Certificate{prePrecert, preIssuer}, false); err == nil {
+		t.Error("VerifyChainSCTSignature(missing final issuer)=nil; want non-nil")
+	}
+}
+
+func TestNextPollTime(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 1)
+	sth, err := fl.sthAt(1)
+	if err != nil {
+		t.Fatalf("sthAt(1)=_,%v", err)
+	}
+	for _, mmd := range []time.Duration{24 * time.Hour, time.Minute} {
+		li := fl.logInfo(t)
+		li.MMD = mmd
+		before := time.Now()
+		if got := li.NextPollTime(); got.Before(before) || got.After(time.Now()) {
+			t.Errorf("MMD %v: NextPollTime()=%v before any fetch; want now", mmd, got)
+		}
+
+		li.SetSTH(sth)
+		last := li.LastFetch()
+		if last.Before(before) {
+			t.Errorf("MMD %v: LastFetch()=%v; want after %v", mmd, last, before)
+		}
+		if got, want := li.NextPollTime(), last.Add(mmd/PollsPerMMD); !got.Equal(want) {
+			t.Errorf("MMD %v: NextPollTime()=%v; want %v", mmd, got, want)
+		}
+	}
+}
+
+func TestVerifySCTAgainstLogURL(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	other := newFakeLog(t, "https://other.example.com")
+	leaf := testLeaf(1)
+	sct := fl.signSCT(t, leaf, 1000)
+
+	tests := []struct {
+		desc    string
+		keyDER  []byte
+		sct     ct.SignedCertificateTimestamp
+		wantErr string
+	}{
+		{desc: "matching", keyDER: fl.keyDER(t), sct: sct},
+		{desc: "mismatched-key", keyDER: other.keyDER(t), sct: sct, wantErr: "does not match key"},
+		{desc: "invalid-key", keyDER: []byte("not a key"), sct: func() ct.SignedCertificateTimestamp {
+			s := sct
+			s.LogID.KeyID = sha256.Sum256([]byte("not a key"))
+			return s
+		}(), wantErr: "failed to parse public key"},
+		{desc: "wrong-leaf", keyDER: fl.keyDER(t), sct: fl.signSCT(t, testLeaf(2), 1000), wantErr: "failed to verify SCT signature"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := VerifySCTAgainstLogURL(ctx, fl.uri, test.keyDER, test.sct, leaf)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifySCTAgainstLogURL()=%v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("VerifySCTAgainstLogURL()=%v; want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := VerifySCTAgainstLogURL(cctx, fl.uri, fl.keyDER(t), sct, leaf); !errors.Is(err, context.Canceled) {
+		t.Errorf("VerifySCTAgainstLogURL(cancelled)=%v; want %v", err, context.Canceled)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 4)
+	small, err := fl.sthAt(4)
+	if err != nil {
+		t.Fatalf("sthAt(4)=_,%v", err)
+	}
+	fl.addLeaves(t, 4)
+	cached, err := fl.sthAt(8)
+	if err != nil {
+		t.Fatalf("sthAt(8)=_,%v", err)
+	}
+	fl.addLeaves(t, 4)
+	large, err := fl.sthAt(12)
+	if err != nil {
+		t.Fatalf("sthAt(12)=_,%v", err)
+	}
+	sameRoot := *cached
+	sameRoot.Timestamp++
+	sameRoot.TreeHeadSignature = mustResign(t, fl, &sameRoot)
+	split := *cached
+	split.SHA256RootHash[0] ^= 0xff
+	split.TreeHeadSignature = mustResign(t, fl, &split)
+	// A small STH from a different tree, signed by the log.
+	forkLog := newFakeLog(t, "https://fork.example.com")
+	for i := 0; i < 4; i++ {
+		forkLog.addLeaf(t, stamped(testLeaf(i), 0))
+	}
+	fork, err := forkLog.sthAt(4)
+	if err != nil {
+		t.Fatalf("sthAt(4)=_,%v", err)
+	}
+	fork.TreeHeadSignature = mustResign(t, fl, fork)
+	forged := *large
+	forged.TreeSize++
+
+	tests := []struct {
+		desc      string
+		gossiped  *ct.SignedTreeHead
+		wantErr   bool
+		wantSplit bool
+		wantLast  *ct.SignedTreeHead
+	}{
+		{desc: "larger", gossiped: large, wantLast: large},
+		{desc: "smaller", gossiped: small, wantLast: cached},
+		{desc: "smaller-inconsistent", gossiped: fork, wantErr: true, wantLast: cached},
+		{desc: "equal-same-root", gossiped: &sameRoot, wantLast: cached},
+		{desc: "equal-different-root", gossiped: &split, wantErr: true, wantSplit: true, wantLast: cached},
+		{desc: "bad-signature", gossiped: &forged, wantErr: true, wantLast: cached},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			li := fl.logInfo(t)
+			li.SetSTH(cached)
+			err := li.Reconcile(ctx, test.gossiped)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("Reconcile()=%v; want error %v", err, test.wantErr)
+			}
+			if got := errors.Is(err, ErrSplitView); got != test.wantSplit {
+				t.Errorf("Reconcile()=%v; want split view %v", err, test.wantSplit)
+			}
+			if got := li.LastSTH(); got != test.wantLast {
+				t.Errorf("LastSTH() has size %d; want %d", got.TreeSize, test.wantLast.TreeSize)
+			}
+		})
+	}
+
+	li := fl.logInfo(t)
+	if err := li.Reconcile(ctx, small); err != nil || li.LastSTH() != small {
+		t.Errorf("Reconcile(no cached STH)=%v; want nil with STH adopted", err)
+	}
+}
+
+func TestNewLogInfoP192(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(x509.Secp192r1(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(P192)=nil,%v; want _,nil", err)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey(P192)=nil,%v; want _,nil", err)
+	}
+	log := loglist.Log{Description: "p192 log", URL: "p192.example.com", Key: keyDER, MaximumMergeDelay: 86400}
+	li, err := NewLogInfo(&log, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewLogInfo(P192)=nil,%v; want _,nil", err)
+	}
+	pub, ok := li.Verifier.PubKey.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("NewLogInfo(P192).Verifier.PubKey=%T; want *ecdsa.PublicKey", li.Verifier.PubKey)
+	}
+	if pub.Curve != x509.Secp192r1() {
+		t.Errorf("NewLogInfo(P192).Verifier.PubKey.Curve=%v; want P-192", pub.Curve.Params().Name)
+	}
+}
+
+func TestVerifySCTSignatureP192(t *testing.T) {
+	f := newFakeLog(t, "https://p192.example.com")
+	key, err := ecdsa.GenerateKey(x509.Secp192r1(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(P192)=nil,%v; want _,nil", err)
+	}
+	f.key = key
+	log := loglist.Log{Description: "p192 log", URL: "p192.example.com", Key: f.keyDER(t), MaximumMergeDelay: 86400}
+	li, err := NewLogInfo(&log, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewLogInfo(P192)=nil,%v; want _,nil", err)
+	}
+
+	leaf := testLeaf(1)
+	sct := f.signSCT(t, leaf, 1000)
+	if err := li.VerifySCTSignature(sct, leaf); err != nil {
+		t.Errorf("VerifySCTSignature(P192)=%v; want nil", err)
+	}
+	sct.Timestamp++
+	if err := li.VerifySCTSignature(sct, leaf); err == nil {
+		t.Error("VerifySCTSignature(P192, modified timestamp)=nil; want error")
+	}
+}
+
+func TestLogInfoByKeyHashLazy(t *testing.T) {
+	good := newFakeLog(t, "https://good.example.com")
+	ll := loglist.LogList{
+		Logs: []loglist.Log{
+			{Description: "good log", URL: "good.example.com", Key: good.keyDER(t), MaximumMergeDelay: 86400},
+			{Description: "bad log", URL: "bad.example.com", Key: []byte("not a key"), MaximumMergeDelay: 86400},
+		},
+	}
+	logs, err := LogInfoByKeyHashLazy(&ll, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("LogInfoByKeyHashLazy()=_,%v; want _,nil", err)
+	}
+	if got, want := len(logs), len(ll.Logs); got != want {
+		t.Fatalf("len(LogInfoByKeyHashLazy())=%d; want %d", got, want)
+	}
+	for _, li := range logs {
+		if li.Verifier != nil {
+			t.Errorf("%q: Verifier built at construction; want nil", li.Description)
+		}
+		if li.MMD != 24*time.Hour {
+			t.Errorf("%q: MMD=%v; want 24h", li.Description, li.MMD)
+		}
+	}
+
+	leaf := testLeaf(1)
+	sct := good.signSCT(t, leaf, 1000)
+	goodInfo := logs[sha256.Sum256(good.keyDER(t))]
+	// The verifier is built once, however many callers need it, without
+	// writing the exported Verifier field that callers may read.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := goodInfo.VerifySCTSignature(sct, leaf); err != nil {
+				t.Errorf("VerifySCTSignature(good log)=%v; want nil", err)
+			}
+			if goodInfo.Verifier != nil {
+				t.Error("Verifier set after first use; want nil")
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := goodInfo.SignatureVerifier(); v == nil || err != nil {
+		t.Errorf("SignatureVerifier()=%v,%v; want non-nil,nil", v, err)
+	}
+
+	badInfo := logs[sha256.Sum256([]byte("not a key"))]
+	for i := 0; i < 2; i++ {
+		err := badInfo.VerifySCTSignature(sct, leaf)
+		if err == nil || !strings.Contains(err.Error(), "failed to parse public key") {
+			t.Errorf("VerifySCTSignature(bad log) attempt %d=%v; want key parse error", i, err)
+		}
+	}
+}
+
+func TestVerifyInclusionFromMirror(t *testing.T) {
+	ctx := context.Background()
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	origin := newFakeLog(t, "https://log.example.com")
+	mirror := newFakeLog(t, "https://mirror.example.com")
+	forger := newFakeLog(t, "https://forger.example.com")
+	for i := 0; i < 5; i++ {
+		l := stamped(testLeaf(10+i), 0)
+		if i == 2 {
+			l = stamped(leaf, timestamp)
+		}
+		origin.addLeaf(t, l)
+		mirror.addLeaf(t, l)
+	}
+	// The forger's tree includes the leaf, but differs from the origin log.
+	for i := 0; i < 5; i++ {
+		l := stamped(testLeaf(20+i), 0)
+		if i == 2 {
+			l = stamped(leaf, timestamp)
+		}
+		forger.addLeaf(t, l)
+	}
+	sth, err := origin.sthAt(5)
+	if err != nil {
+		t.Fatalf("sthAt(5)=_,%v", err)
+	}
+	li := origin.logInfo(t)
+
+	index, err := VerifyInclusionFromMirror(ctx, li, mirror, sth, leaf, timestamp)
+	if err != nil {
+		t.Fatalf("VerifyInclusionFromMirror(honest mirror)=_,%v; want _,nil", err)
+	}
+	if index != 2 {
+		t.Errorf("VerifyInclusionFromMirror(honest mirror)=%d,nil; want 2,nil", index)
+	}
+
+	if _, err := VerifyInclusionFromMirror(ctx, li, forger, sth, leaf, timestamp); err == nil {
+		t.Error("VerifyInclusionFromMirror(forging mirror)=_,nil; want _,non-nil")
+	}
+	// The forger's own signed root does not help, as it is not the origin's.
+	forgedSTH, err := forger.sthAt(5)
+	if err != nil {
+		t.Fatalf("sthAt(5)=_,%v", err)
+	}
+	if _, err := VerifyInclusionFromMirror(ctx, li, forger, forgedSTH, leaf, timestamp); err == nil {
+		t.Error("VerifyInclusionFromMirror(forger's STH)=_,nil; want _,non-nil")
+	}
+}
+
+func TestVerifyKeyMatches(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	li := fl.logInfo(t)
+	if err := li.VerifyKeyMatches(ctx); err != nil {
+		t.Errorf("VerifyKeyMatches()=%v; want nil", err)
+	}
+
+	// The log has rotated to a new key that its LogInfo does not know about.
+	rotated := newFakeLog(t, "https://log.example.com")
+	fl.key = rotated.key
+	err := li.VerifyKeyMatches(ctx)
+	if !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("VerifyKeyMatches(rotated key)=%v; want error wrapping ErrKeyMismatch", err)
+	}
+
+	fl.sthErr = errors.New("unavailable")
+	err = li.VerifyKeyMatches(ctx)
+	if err == nil || errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("VerifyKeyMatches(unavailable)=%v; want non-mismatch error", err)
+	}
+}
+
+func TestGetVerifiedSTH(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	li := fl.logInfo(t)
+	sth, err := li.GetVerifiedSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetVerifiedSTH()=nil,%v; want _,nil", err)
+	}
+	if sth.TreeSize != 3 {
+		t.Errorf("GetVerifiedSTH().TreeSize=%d; want 3", sth.TreeSize)
+	}
+	if got := li.LastSTH(); got != sth {
+		t.Errorf("LastSTH()=%v; want %v", got, sth)
+	}
+
+	// The log now serves STHs that are not signed by its key.
+	fl.addLeaves(t, 2)
+	fl.key = newFakeLog(t, "https://other.example.com").key
+	sth, err = li.GetVerifiedSTH(ctx)
+	if !errors.Is(err, ErrBadSTHSignature) {
+		t.Errorf("GetVerifiedSTH(forged)=%v,%v; want nil,error wrapping ErrBadSTHSignature", sth, err)
+	}
+	if got := li.LastSTH(); got.TreeSize != 3 {
+		t.Errorf("LastSTH().TreeSize=%d after forged STH; want 3", got.TreeSize)
+	}
+	// The inclusion checks only verify the STH when asked to.
+	if _, err := li.VerifyInclusion(ctx, testLeaf(0), 0); errors.Is(err, ErrBadSTHSignature) {
+		t.Errorf("VerifyInclusion(forged STH)=_,%v; want error not wrapping ErrBadSTHSignature", err)
+	}
+	li.VerifySTHs = true
+	if _, err := li.VerifyInclusion(ctx, testLeaf(0), 0); !errors.Is(err, ErrBadSTHSignature) {
+		t.Errorf("VerifyInclusion(forged STH, VerifySTHs)=_,%v; want error wrapping ErrBadSTHSignature", err)
+	}
+	li.SetSTH(nil)
+	if _, err := li.VerifyInclusionLatest(ctx, testLeaf(0), 0); !errors.Is(err, ErrBadSTHSignature) {
+		t.Errorf("VerifyInclusionLatest(forged STH, VerifySTHs)=_,%v; want error wrapping ErrBadSTHSignature", err)
+	}
+
+	fl.sthErr = errors.New("unavailable")
+	if _, err := li.GetVerifiedSTH(ctx); err == nil || errors.Is(err, ErrBadSTHSignature) {
+		t.Errorf("GetVerifiedSTH(unavailable)=_,%v; want error not wrapping ErrBadSTHSignature", err)
+	}
+}
+
+func TestLogInfoByLogID(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	ll := loglist.LogList{
+		Logs: []loglist.Log{
+			{Description: "custom log", URL: "log.example.com", Key: fl.keyDER(t), MaximumMergeDelay: 86400},
+		},
+	}
+	truncatedSHA512 := func(keyDER []byte) ([sha256.Size]byte, error) {
+		return sha512.Sum512_256(keyDER), nil
+	}
+	logs, err := LogInfoByLogID(&ll, http.DefaultClient, truncatedSHA512)
+	if err != nil {
+		t.Fatalf("LogInfoByLogID()=_,%v; want _,nil", err)
+	}
+
+	leaf := testLeaf(1)
+	sct := fl.signSCT(t, leaf, 1000)
+	if _, err := logs.MustLogForSCT(sct); !errors.Is(err, ErrUnknownLog) {
+		t.Errorf("MustLogForSCT(SHA-256 log ID)=_,%v; want error wrapping ErrUnknownLog", err)
+	}
+	// The log ID is not covered by the SCT signature, so the log can issue
+	// SCTs that identify it by its custom log ID.
+	sct.LogID.KeyID = sha512.Sum512_256(fl.keyDER(t))
+	li, err := logs.MustLogForSCT(sct)
+	if err != nil {
+		t.Fatalf("MustLogForSCT(custom log ID)=_,%v; want _,nil", err)
+	}
+	if err := li.VerifySCTSignature(sct, leaf); err != nil {
+		t.Errorf("VerifySCTSignature(custom log ID)=%v; want nil", err)
+	}
+
+	failing := func([]byte) ([sha256.Size]byte, error) {
+		return [sha256.Size]byte{}, errors.New("unsupported key")
+	}
+	if _, err := LogInfoByLogID(&ll, http.DefaultClient, failing); err == nil {
+		t.Error("LogInfoByLogID(failing derivation)=_,nil; want _,non-nil")
+	}
+}
+
+func TestVerifyInclusionAgainstRoot(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	leaf := testLeaf(1)
+	fl.addLeaves(t, 3)
+	wantIndex := fl.addLeaf(t, stamped(leaf, 1000))
+	fl.addLeaves(t, 4)
+
+	// The log's key is not available, so no STH signature can be verified.
+	li := &LogInfo{Description: fl.uri, Client: fl, MMD: 24 * time.Hour, PublicKey: []byte("unknown key")}
+	root := fl.tree.RootAtSnapshot(6).Hash()
+	index, err := VerifyInclusionAgainstRoot(ctx, li, leaf, 1000, 6, root)
+	if err != nil {
+		t.Fatalf("VerifyInclusionAgainstRoot()=_,%v; want _,nil", err)
+	}
+	if index != wantIndex {
+		t.Errorf("VerifyInclusionAgainstRoot()=%d,nil; want %d,nil", index, wantIndex)
+	}
+
+	wrongRoot := fl.tree.RootAtSnapshot(7).Hash()
+	for _, test := range []struct {
+		desc     string
+		treeSize uint64
+		root     []byte
+	}{
+		{desc: "mismatched-root", treeSize: 6, root: wrongRoot},
+		{desc: "short-root", treeSize: 6, root: root[:16]},
+		{desc: "empty-tree", treeSize: 0, root: root},
+		{desc: "not-yet-included", treeSize: 3, root: fl.tree.RootAtSnapshot(3).Hash()},
+	} {
+		if _, err := VerifyInclusionAgainstRoot(ctx, li, leaf, 1000, test.treeSize, test.root); err == nil {
+			t.Errorf("%s: VerifyInclusionAgainstRoot()=_,nil; want _,non-nil", test.desc)
+		}
+	}
+}
+
+func TestVerifyChainSCTSignatureReorderedExtensions(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	li := fl.logInfo(t)
+	notBefore := time.Now().Add(-time.Hour)
+	ca, caKey := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	// The extensions are deliberately out of OID order.
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(24 * time.Hour),
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 5}, Value: asn1.NullBytes},
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: asn1.NullBytes},
+		},
+	}
+	issue := func() *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+		return cert
+	}
+	embed := func(sct ct.SignedCertificateTimestamp) *x509.Certificate {
+		val, err := tls.Marshal(sct)
+		if err != nil {
+			t.Fatalf("failed to marshal SCT: %v", err)
+		}
+		template.SCTList = x509.SignedCertificateTimestampList{SCTList: []x509.SerializedSCT{{Val: val}}}
+		defer func() { template.SCTList = x509.SignedCertificateTimestampList{} }()
+		return issue()
+	}
+	leafFor := func(tbs []byte) ct.MerkleTreeLeaf {
+		return ct.MerkleTreeLeaf{
+			Version:  ct.V1,
+			LeafType: ct.TimestampedEntryLeafType,
+			TimestampedEntry: &ct.TimestampedEntry{
+				EntryType: ct.PrecertLogEntryType,
+				PrecertEntry: &ct.PreCert{
+					IssuerKeyHash:  sha256.Sum256(ca.RawSubjectPublicKeyInfo),
+					TBSCertificate: tbs,
+				},
+			},
+		}
+	}
+	timestamp := uint64(notBefore.UnixNano() / int64(time.Millisecond))
+
+	// The precertificate as logged had its extensions sorted.
+	tbs := issue().RawTBSCertificate
+	sorted, err := sortTBSExtensions(tbs)
+	if err != nil {
+		t.Fatalf("sortTBSExtensions()=_,%v", err)
+	}
+	if bytes.Equal(sorted, tbs) {
+		t.Fatal("sortTBSExtensions() left extensions in place; want reordered")
+	}
+	reordered := fl.signSCT(t, leafFor(sorted), timestamp)
+	compliant := fl.signSCT(t, leafFor(tbs), timestamp)
+	bogus := fl.signSCT(t, testLeaf(1), timestamp)
+
+	tests := []struct {
+		desc     string
+		sct      ct.SignedCertificateTimestamp
+		tolerate bool
+		// finalSorted issues the final certificate with its extensions
+		// sorted, rather than in the order of the unsorted precertificate.
+		finalSorted bool
+		wantErr     bool
+	}{
+		{desc: "reordered", sct: reordered, wantErr: true},
+		{desc: "reordered-tolerated", sct: reordered, tolerate: true},
+		{desc: "compliant", sct: compliant},
+		{desc: "compliant-tolerated", sct: compliant, tolerate: true},
+		{desc: "bogus-tolerated", sct: bogus, tolerate: true, wantErr: true},
+		// Only a sorted precertificate is tolerated, not a sorted final
+		// certificate.
+		{desc: "final-sorted-tolerated", sct: compliant, tolerate: true, finalSorted: true, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			exts := template.ExtraExtensions
+			if test.finalSorted {
+				template.ExtraExtensions = []pkix.Extension{exts[1], exts[0]}
+			}
+			chain := []*x509.Certificate{embed(test.sct), ca}
+			template.ExtraExtensions = exts
+			li.TolerateExtensionReordering = test.tolerate
+			err := li.VerifyChainSCTSignature(test.sct, chain, true)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("VerifyChainSCTSignature()=%v; want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 12)
+	sth := func(size uint64) *ct.SignedTreeHead {
+		t.Helper()
+		s, err := fl.sthAt(size)
+		if err != nil {
+			t.Fatalf("sthAt(%d)=_,%v", size, err)
+		}
+		return s
+	}
+	empty, small, large := sth(0), sth(5), sth(12)
+	split := *small
+	split.SHA256RootHash[0] ^= 0xff
+
+	tests := []struct {
+		desc          string
+		first, second *ct.SignedTreeHead
+		client        client.CheckLogClient
+		wantErr       bool
+		wantRollback  bool
+		wantSplit     bool
+	}{
+		{desc: "consistent", first: small, second: large},
+		{desc: "from-empty", first: empty, second: large},
+		{desc: "same", first: small, second: small},
+		{desc: "bad-proof", first: small, second: large, client: badConsistencyLog{fl}, wantErr: true},
+		{desc: "rollback", first: large, second: small, wantErr: true, wantRollback: true},
+		{desc: "split-view", first: small, second: &split, wantErr: true, wantSplit: true},
+		{desc: "missing", first: nil, second: large, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			li := fl.logInfo(t)
+			if test.client != nil {
+				li.Client = test.client
+			}
+			err := li.VerifyConsistency(ctx, test.first, test.second)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("VerifyConsistency()=%v; want error %v", err, test.wantErr)
+			}
+			if got := errors.Is(err, ErrRollback); got != test.wantRollback {
+				t.Errorf("VerifyConsistency()=%v; want rollback %v", err, test.wantRollback)
+			}
+			if got := errors.Is(err, ErrSplitView); got != test.wantSplit {
+				t.Errorf("VerifyConsistency()=%v; want split view %v", err, test.wantSplit)
+			}
+			if err != nil && !strings.Contains(err.Error(), li.Description) {
+				t.Errorf("VerifyConsistency()=%v; want error naming %q", err, li.Description)
+			}
+		})
+	}
+}
+
+func TestVerifySCTAgainstLeafInput(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	li := fl.logInfo(t)
+	const timestamp = 1500000000000
+	fl.addLeaves(t, 2)
+	index := fl.addLeaf(t, stamped(testLeaf(5), timestamp))
+	rsp, err := fl.GetRawEntries(ctx, index, index)
+	if err != nil {
+		t.Fatalf("GetRawEntries()=_,%v", err)
+	}
+	leafInput := rsp.Entries[0].LeafInput
+	marshal := func(sct ct.SignedCertificateTimestamp) []byte {
+		data, err := tls.Marshal(sct)
+		if err != nil {
+			t.Fatalf("failed to marshal SCT: %v", err)
+		}
+		return data
+	}
+	sct := marshal(fl.signSCT(t, testLeaf(5), timestamp))
+	other := marshal(fl.signSCT(t, testLeaf(6), timestamp))
+
+	tests := []struct {
+		desc      string
+		sct       []byte
+		leafInput []byte
+		wantErr   string
+	}{
+		{desc: "valid", sct: sct, leafInput: leafInput},
+		{desc: "other-leaf", sct: other, leafInput: leafInput, wantErr: "failed to verify"},
+		{desc: "bad-sct", sct: sct[:10], leafInput: leafInput, wantErr: "failed to parse SCT"},
+		{desc: "sct-trailing-data", sct: append(append([]byte{}, sct...), 0), leafInput: leafInput, wantErr: "after SCT"},
+		{desc: "bad-leaf-input", sct: sct, leafInput: leafInput[:10], wantErr: "failed to parse leaf input"},
+		{desc: "leaf-input-trailing-data", sct: sct, leafInput: append(append([]byte{}, leafInput...), 0), wantErr: "after leaf input"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := VerifySCTAgainstLeafInput(li, test.sct, test.leafInput)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifySCTAgainstLeafInput()=%v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("VerifySCTAgainstLeafInput()=%v; want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyInclusionLatestMaxAge(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	now := fl.timestamp
+	fl.addLeaves(t, 2)
+	fl.timestamp = now - uint64(2*time.Hour/time.Millisecond)
+	old, err := fl.sthAt(2)
+	if err != nil {
+		t.Fatalf("sthAt(2)=_,%v", err)
+	}
+	fl.timestamp = now
+	const timestamp = 1500000000000
+	index := fl.addLeaf(t, stamped(testLeaf(5), timestamp))
+
+	tests := []struct {
+		desc     string
+		maxAge   time.Duration
+		wantErr  bool
+		wantSize uint64
+	}{
+		{desc: "no-max-age", wantErr: true, wantSize: 2},
+		{desc: "fresh-enough", maxAge: 3 * time.Hour, wantErr: true, wantSize: 2},
+		{desc: "stale", maxAge: time.Hour, wantSize: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			li := fl.logInfo(t)
+			li.STHMaxAge = test.maxAge
+			li.SetSTH(old)
+			got, err := li.VerifyInclusionLatest(ctx, testLeaf(5), timestamp)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("VerifyInclusionLatest()=%d,%v; want error %v", got, err, test.wantErr)
+			} else if err == nil && got != index {
+				t.Errorf("VerifyInclusionLatest()=%d,nil; want %d", got, index)
+			}
+			if sth := li.LastSTH(); sth.TreeSize != test.wantSize {
+				t.Errorf("LastSTH().TreeSize=%d; want %d", sth.TreeSize, test.wantSize)
+			}
+		})
+	}
+}
+
+func TestVerifyInclusionLatestFutureTimestamp(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ms := func(d time.Duration) uint64 {
+		return uint64(now.Add(d).UnixNano() / int64(time.Millisecond))
+	}
+	tests := []struct {
+		desc       string
+		tolerance  time.Duration
+		sthAge     time.Duration
+		leafAge    time.Duration
+		cachedAge  time.Duration // if non-zero, age of a last known STH
+		wantErr    bool
+		wantFuture bool
+	}{
+		{desc: "disabled", sthAge: time.Hour, leafAge: -time.Hour},
+		{desc: "plausible", tolerance: time.Minute, leafAge: time.Minute},
+		{desc: "within-tolerance", tolerance: time.Minute, leafAge: -30 * time.Second, sthAge: -30 * time.Second},
+		{desc: "ahead-of-clock", tolerance: time.Minute, leafAge: -time.Hour, sthAge: -time.Hour, wantErr: true, wantFuture: true},
+		{desc: "ahead-of-sth", tolerance: time.Minute, sthAge: time.Hour, wantErr: true, wantFuture: true},
+		{desc: "ahead-of-cached-sth", tolerance: time.Minute, leafAge: time.Minute, cachedAge: time.Hour},
+		{desc: "ahead-of-cached-sth-disabled", leafAge: time.Minute, cachedAge: time.Hour, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			fl := newFakeLog(t, "https://log.example.com")
+			li := fl.logInfo(t)
+			li.FutureTimestampTolerance = test.tolerance
+			if test.cachedAge != 0 {
+				fl.timestamp = ms(-test.cachedAge)
+				fl.addLeaves(t, 1)
+				cached, err := fl.sthAt(1)
+				if err != nil {
+					t.Fatalf("sthAt(1)=_,%v", err)
+				}
+				li.SetSTH(cached)
+			}
+			fl.timestamp = ms(-test.sthAge)
+			timestamp := ms(-test.leafAge)
+			index := fl.addLeaf(t, stamped(testLeaf(1), timestamp))
+
+			got, err := li.VerifyInclusionLatest(ctx, testLeaf(1), timestamp)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("VerifyInclusionLatest()=%d,%v; want error %v", got, err, test.wantErr)
+			}
+			if gotFuture := errors.Is(err, ErrFutureTimestamp); gotFuture != test.wantFuture {
+				t.Errorf("VerifyInclusionLatest()=%d,%v; want error wrapping ErrFutureTimestamp %v", got, err, test.wantFuture)
+			}
+			if err == nil && got != index {
+				t.Errorf("VerifyInclusionLatest()=%d,nil; want %d", got, index)
+			}
+		})
+	}
+}
+
+func TestVerifyAllSignatures(t *testing.T) {
+	fl1 := newFakeLog(t, "https://log1.example.com")
+	fl2 := newFakeLog(t, "https://log2.example.com")
+	unknown := newFakeLog(t, "https://unknown.example.com")
+	m := LogInfoByHash{
+		sha256.Sum256(fl1.keyDER(t)): fl1.logInfo(t),
+		sha256.Sum256(fl2.keyDER(t)): fl2.logInfo(t),
+	}
+	leaf := testLeaf(1)
+	origTimestamp := leaf.TimestampedEntry.Timestamp
+	corrupt := fl2.signSCT(t, leaf, 3000)
+	corrupt.Signature.Signature = append([]byte{}, corrupt.Signature.Signature...)
+	corrupt.Signature.Signature[len(corrupt.Signature.Signature)-1] ^= 0xff
+
+	tests := []struct {
+		desc        string
+		scts        []ct.SignedCertificateTimestamp
+		wantErrs    []bool
+		wantUnknown bool
+	}{
+		{desc: "none"},
+		{
+			desc:     "all-valid",
+			scts:     []ct.SignedCertificateTimestamp{fl1.signSCT(t, leaf, 1000), fl2.signSCT(t, leaf, 2000)},
+			wantErrs: []bool{false, false},
+		},
+		{
+			desc:     "one-invalid",
+			scts:     []ct.SignedCertificateTimestamp{fl1.signSCT(t, leaf, 1000), corrupt},
+			wantErrs: []bool{false, true},
+		},
+		{
+			desc:        "unknown-log",
+			scts:        []ct.SignedCertificateTimestamp{unknown.signSCT(t, leaf, 1000), fl2.signSCT(t, leaf, 2000)},
+			wantErrs:    []bool{true, false},
+			wantUnknown: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			errs, err := m.VerifyAllSignatures(test.scts, leaf)
+			if got := errors.Is(err, ErrUnknownLog); got != test.wantUnknown || (err != nil) != test.wantUnknown {
+				t.Errorf("VerifyAllSignatures()=_,%v; want unknown log error %v", err, test.wantUnknown)
+			}
+			if len(errs) != len(test.wantErrs) {
+				t.Fatalf("VerifyAllSignatures() gave %d errors; want %d", len(errs), len(test.wantErrs))
+			}
+			for i, err := range errs {
+				if gotErr := err != nil; gotErr != test.wantErrs[i] {
+					t.Errorf("VerifyAllSignatures()[%d]=%v; want error %v", i, err, test.wantErrs[i])
+				}
+			}
+			if got := leaf.TimestampedEntry.Timestamp; got != origTimestamp {
+				t.Errorf("leaf timestamp after verification=%d; want unchanged %d", got, origTimestamp)
+			}
+		})
+	}
+}
+
+func TestLogInfoByKeyHashParallel(t *testing.T) {
+	var good loglist.LogList
+	for i := 0; i < 20; i++ {
+		uri := fmt.Sprintf("log%d.example.com", i)
+		good.Logs = append(good.Logs, loglist.Log{Description: uri, URL: uri, Key: newFakeLog(t, uri).keyDER(t), MaximumMergeDelay: 86400})
+	}
+	bad := loglist.LogList{Logs: append([]loglist.Log{}, good.Logs...)}
+	bad.Logs[5].Key = []byte("not a key")
+	bad.Logs[12].Key = []byte("not a key either")
+	_, wantErr := LogInfoByKeyHash(&bad, http.DefaultClient)
+	if wantErr == nil {
+		t.Fatal("LogInfoByKeyHash(bad)=_,nil; want _,non-nil")
+	}
+
+	for _, workers := range []int{1, 4, 32} {
+		t.Run(fmt.Sprintf("workers-%d", workers), func(t *testing.T) {
+			logs, err := LogInfoByKeyHashParallel(&good, http.DefaultClient, workers)
+			if err != nil {
+				t.Fatalf("LogInfoByKeyHashParallel(good)=_,%v; want _,nil", err)
+			}
+			if got, want := len(logs), len(good.Logs); got != want {
+				t.Errorf("len(LogInfoByKeyHashParallel(good))=%d; want %d", got, want)
+			}
+			for _, log := range good.Logs {
+				if li, ok := logs[sha256.Sum256(log.Key)]; !ok || li.Description != log.Description {
+					t.Errorf("LogInfoByKeyHashParallel(good)[%q]=%v; want log", log.Description, li)
+				}
+			}
+
+			// The error for the earliest bad log is always the one reported.
+			for i := 0; i < 10; i++ {
+				logs, err := LogInfoByKeyHashParallel(&bad, http.DefaultClient, workers)
+				if err == nil || err.Error() != wantErr.Error() {
+					t.Fatalf("LogInfoByKeyHashParallel(bad)=%v,%v; want nil,%v", logs, err, wantErr)
+				}
+			}
+		})
+	}
+
+	if _, err := LogInfoByKeyHashParallel(&good, http.DefaultClient, 0); err == nil {
+		t.Error("LogInfoByKeyHashParallel(workers=0)=_,nil; want _,non-nil")
+	}
+}
+
+func TestVerifyInclusionAtWithProof(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 5)
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	index := fl.addLeaf(t, stamped(leaf, timestamp))
+	fl.addLeaves(t, 2)
+	li := fl.logInfo(t)
+	sth, err := fl.GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetSTH()=_,%v", err)
+	}
+	leafHash, err := ct.LeafHashForLeaf(stamped(leaf, timestamp))
+	if err != nil {
+		t.Fatalf("LeafHashForLeaf()=_,%v", err)
+	}
+
+	proof, err := li.VerifyInclusionAtWithProof(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:])
+	if err != nil {
+		t.Fatalf("VerifyInclusionAtWithProof()=_,%v; want _,nil", err)
+	}
+	if proof.LeafIndex != index || proof.TreeSize != sth.TreeSize || !bytes.Equal(proof.RootHash, sth.SHA256RootHash[:]) || !bytes.Equal(proof.LeafHash, leafHash[:]) {
+		t.Errorf("VerifyInclusionAtWithProof()=%+v; want index %d, size %d, root %x, leaf hash %x", proof, index, sth.TreeSize, sth.SHA256RootHash, leafHash)
+	}
+
+	// The stored proof can be checked again offline.
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("json.Marshal()=_,%v", err)
+	}
+	var stored ct.InclusionProof
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("json.Unmarshal()=%v", err)
+	}
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyInclusionProof(stored.LeafIndex, int64(stored.TreeSize), stored.AuditPath, stored.RootHash, stored.LeafHash); err != nil {
+		t.Errorf("VerifyInclusionProof(stored proof)=%v; want nil", err)
+	}
+
+	badRoot := sth.SHA256RootHash
+	badRoot[0] ^= 0xff
+	if got, err := li.VerifyInclusionAtWithProof(ctx, leaf, timestamp, sth.TreeSize, badRoot[:]); err == nil {
+		t.Errorf("VerifyInclusionAtWithProof(bad root)=%+v,nil; want _,non-nil", got)
+	}
+	if got, err := li.VerifyInclusionAt(ctx, leaf, timestamp, sth.TreeSize, badRoot[:]); err == nil || got != -1 {
+		t.Errorf("VerifyInclusionAt(bad root)=%d,%v; want -1,non-nil", got, err)
+	}
+}
+
+// indexedLog is a fakeLog that also serves inclusion proofs by leaf index, as
+// a static CT log's client does.
+type indexedLog struct {
+	*fakeLog
+	calls int
+}
+
+func (l *indexedLog) GetProofByIndex(ctx context.Context, index, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	l.calls++
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if index >= treeSize || treeSize > uint64(l.tree.LeafCount()) {
+		return nil, jsonclient.RspError{Err: fmt.Errorf("got HTTP Status %q", "400 Bad Request"), StatusCode: http.StatusBadRequest}
+	}
+	rsp := &ct.GetProofByHashResponse{LeafIndex: int64(index)}
+	for _, node := range l.tree.PathToRootAtSnapshot(int64(index)+1, int64(treeSize)) {
+		rsp.AuditPath = append(rsp.AuditPath, node.Value.Hash())
+	}
+	return rsp, nil
+}
+
+// withLeafIndex returns a copy of the leaf with a leaf_index extension for the
+// given index.
+func withLeafIndex(leaf ct.MerkleTreeLeaf, index uint64) ct.MerkleTreeLeaf {
+	entry := *leaf.TimestampedEntry
+	entry.Extensions = ct.CTExtensions{ct.LeafIndexExtensionType, 0x00, 0x05, byte(index >> 32), byte(index >> 24), byte(index >> 16), byte(index >> 8), byte(index)}
+	leaf.TimestampedEntry = &entry
+	return leaf
+}
+
+func TestVerifyInclusionAtLeafIndexHint(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 5)
+	timestamp := uint64(1000)
+	leaf := withLeafIndex(testLeaf(1), 5)
+	index := fl.addLeaf(t, stamped(leaf, timestamp))
+	// A leaf whose extension gives the wrong index.
+	misleading := withLeafIndex(testLeaf(2), 2)
+	misIndex := fl.addLeaf(t, stamped(misleading, timestamp))
+	fl.addLeaves(t, 2)
+	sth, err := fl.GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetSTH()=_,%v", err)
+	}
+	il := &indexedLog{fakeLog: fl}
+	li := fl.logInfo(t)
+	li.Client = il
+
+	// The hinted index is used without looking the leaf up by hash.
+	fl.proofErr = errors.New("get-proof-by-hash unavailable")
+	if got, err := li.VerifyInclusionAt(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:]); err != nil || got != index {
+		t.Errorf("VerifyInclusionAt(hinted leaf)=%d,%v; want %d,nil", got, err, index)
+	}
+	if il.calls != 1 {
+		t.Errorf("VerifyInclusionAt(hinted leaf) made %d GetProofByIndex calls; want 1", il.calls)
+	}
+	if _, err := li.VerifyInclusionAt(ctx, misleading, timestamp, sth.TreeSize, sth.SHA256RootHash[:]); err == nil {
+		t.Error("VerifyInclusionAt(misleading hint, no get-proof-by-hash)=_,nil; want _,non-nil")
+	}
+
+	// A wrong hint falls back to looking the leaf up by hash.
+	fl.proofErr = nil
+	if got, err := li.VerifyInclusionAt(ctx, misleading, timestamp, sth.TreeSize, sth.SHA256RootHash[:]); err != nil || got != misIndex {
+		t.Errorf("VerifyInclusionAt(misleading hint)=%d,%v; want %d,nil", got, err, misIndex)
+	}
+
+	// A client without proofs by index ignores the hint.
+	li.Client = fl
+	il.calls = 0
+	if got, err := li.VerifyInclusionAt(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:]); err != nil || got != index {
+		t.Errorf("VerifyInclusionAt(hinted leaf, no index proofs)=%d,%v; want %d,nil", got, err, index)
+	}
+	if il.calls != 0 {
+		t.Errorf("VerifyInclusionAt(no index proofs) made %d GetProofByIndex calls; want 0", il.calls)
+	}
+}
+
+// laggingLog is a fakeLog whose first few get-proof-by-hash requests fail as
+// though the entry had not yet been merged.
+type laggingLog struct {
+	*fakeLog
+	pending int
+	calls   int
+	// err is returned by the failing requests, if set, in place of an HTTP 400
+	// response.
+	err error
+}
+
+func (l *laggingLog) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	l.calls++
+	if l.calls <= l.pending {
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, jsonclient.RspError{Err: fmt.Errorf("got HTTP Status %q", "400 Bad Request"), StatusCode: http.StatusBadRequest}
+	}
+	return l.fakeLog.GetProofByHash(ctx, hash, treeSize)
+}
+
+func TestVerifyInclusionAtWithin(t *testing.T) {
+	defer func(backoff time.Duration) { proofRetryInitialBackoff = backoff }(proofRetryInitialBackoff)
+	proofRetryInitialBackoff = time.Millisecond
+
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	leaf := testLeaf(1)
+	timestamp := uint64(1000)
+	wantIndex := fl.addLeaf(t, stamped(leaf, timestamp))
+	sth, err := fl.GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetSTH()=_,%v", err)
+	}
+
+	unavailable := jsonclient.RspError{Err: errors.New("got HTTP Status 503"), StatusCode: http.StatusServiceUnavailable}
+	forbidden := jsonclient.RspError{Err: errors.New("got HTTP Status 403"), StatusCode: http.StatusForbidden}
+	malformed := jsonclient.RspError{Err: &jsonclient.ParseError{Err: errors.New("bad JSON")}, StatusCode: http.StatusOK}
+	tests := []struct {
+		desc      string
+		pending   int
+		err       error
+		within    time.Duration
+		mmd       time.Duration
+		wantErr   bool
+		wantCalls int
+	}{
+		{desc: "immediate", pending: 0, within: time.Minute, wantCalls: 1},
+		{desc: "lagging", pending: 3, within: time.Minute, wantCalls: 4},
+		{desc: "lagging-mmd", pending: 3, mmd: time.Minute, wantCalls: 4},
+		{desc: "too-slow", pending: 1000, within: 20 * time.Millisecond, wantErr: true},
+		{desc: "no-wait", pending: 1, wantErr: true, wantCalls: 1},
+		{desc: "transient", pending: 2, err: unavailable, within: time.Minute, wantCalls: 3},
+		{desc: "rejected", pending: 2, err: forbidden, within: time.Minute, wantErr: true, wantCalls: 1},
+		{desc: "malformed", pending: 2, err: malformed, within: time.Minute, wantErr: true, wantCalls: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ll := &laggingLog{fakeLog: fl, pending: test.pending, err: test.err}
+			li := fl.logInfo(t)
+			li.Client = ll
+			li.MMD = test.mmd
+
+			index, err := li.VerifyInclusionAtWithin(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:], test.within)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("VerifyInclusionAtWithin()=%d,%v; want err? %t", index, err, test.wantErr)
+			}
+			if !test.wantErr && index != wantIndex {
+				t.Errorf("VerifyInclusionAtWithin()=%d,nil; want %d,nil", index, wantIndex)
+			}
+			if test.wantCalls > 0 && ll.calls != test.wantCalls {
+				t.Errorf("VerifyInclusionAtWithin() made %d request(s); want %d", ll.calls, test.wantCalls)
+			}
+		})
+	}
+
+	// Other failures are not retried.
+	fl.proofErr = forbidden
+	li := fl.logInfo(t)
+	if _, err := li.VerifyInclusionAtWithin(ctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:], time.Hour); err == nil {
+		t.Error("VerifyInclusionAtWithin(rejected)=_,nil; want _,non-nil")
+	}
+	fl.proofErr = nil
+
+	// Waiting stops when the context is done.
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	li.Client = &laggingLog{fakeLog: fl, pending: 1000}
+	if _, err := li.VerifyInclusionAtWithin(cctx, leaf, timestamp, sth.TreeSize, sth.SHA256RootHash[:], time.Hour); err == nil {
+		t.Error("VerifyInclusionAtWithin(cancelled)=_,nil; want _,non-nil")
+	}
+}
+
+// shiftedLog is a fakeLog that returns the entry and proof for the following
+// index from get-entry-and-proof.
+type shiftedLog struct {
+	*fakeLog
+}
+
+func (l shiftedLog) GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error) {
+	return l.fakeLog.GetEntryAndProof(ctx, index+1, treeSize)
+}
+
+func TestGetEntryAndVerify(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	notBefore := time.Now().Add(-time.Hour)
+	var certs []*x509.Certificate
+	for i := 0; i < 5; i++ {
+		cert, _ := issueCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 1)),
+			Subject:      pkix.Name{CommonName: fmt.Sprintf("www%d.example.com", i)},
+			NotBefore:    notBefore,
+			NotAfter:     notBefore.Add(24 * time.Hour),
+		}, nil, nil)
+		certs = append(certs, cert)
+		fl.addLeaf(t, ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: cert.Raw}, uint64(i)))
+	}
+	sth, err := fl.GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetSTH()=_,%v", err)
+	}
+	li := fl.logInfo(t)
+
+	for i, cert := range certs {
+		entry, err := li.GetEntryAndVerify(ctx, uint64(i), sth.TreeSize, sth.SHA256RootHash[:])
+		if err != nil {
+			t.Fatalf("GetEntryAndVerify(%d)=_,%v; want _,nil", i, err)
+		}
+		if entry.Index != int64(i) || entry.X509Cert == nil || !entry.X509Cert.Equal(cert) {
+			t.Errorf("GetEntryAndVerify(%d)=entry %d for %v; want entry %d for %q", i, entry.Index, entry.X509Cert, i, cert.Subject.CommonName)
+		}
+	}
+
+	badRoot := sth.SHA256RootHash
+	badRoot[0] ^= 0xff
+	shifted := fl.logInfo(t)
+	shifted.Client = shiftedLog{fl}
+	tests := []struct {
+		desc     string
+		li       *LogInfo
+		index    uint64
+		treeSize uint64
+		rootHash []byte
+	}{
+		{desc: "bad-root", li: li, index: 1, treeSize: sth.TreeSize, rootHash: badRoot[:]},
+		{desc: "beyond-size", li: li, index: sth.TreeSize, treeSize: sth.TreeSize, rootHash: sth.SHA256RootHash[:]},
+		{desc: "wrong-index", li: shifted, index: 1, treeSize: sth.TreeSize, rootHash: sth.SHA256RootHash[:]},
+		{desc: "no-entries", li: &LogInfo{Description: "check-only", Client: checkOnly{fl}}, index: 1, treeSize: sth.TreeSize, rootHash: sth.SHA256RootHash[:]},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if entry, err := test.li.GetEntryAndVerify(ctx, test.index, test.treeSize, test.rootHash); err == nil {
+				t.Errorf("GetEntryAndVerify()=%+v,nil; want _,non-nil", entry)
+			}
+		})
+	}
+}
+
+// serveSTH returns a TLS server that serves the fake log's current STH, or the
+// given status if it is not http.StatusOK.
+func serveSTH(t *testing.T, fl *fakeLog, status int) *httptest.Server {
+	t.Helper()
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		sth, err := fl.GetSTH(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sig, err := tls.Marshal(sth.TreeHeadSignature)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(ct.GetSTHResponse{
+			TreeSize:          sth.TreeSize,
+			Timestamp:         sth.Timestamp,
+			SHA256RootHash:    sth.SHA256RootHash[:],
+			TreeHeadSignature: sig,
+		})
+	}))
+}
+
+func TestNewLogInfoMirrors(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	primary := serveSTH(t, fl, http.StatusServiceUnavailable)
+	defer primary.Close()
+	mirror := serveSTH(t, fl, http.StatusOK)
+	defer mirror.Close()
+	// A mirror serving STHs signed with a different key.
+	impostor := newFakeLog(t, "https://impostor.example.com")
+	impostor.addLeaves(t, 3)
+	forger := serveSTH(t, impostor, http.StatusOK)
+	defer forger.Close()
+
+	// The test servers share a certificate, so any of their clients will do.
+	hc := primary.Client()
+	for _, build := range []struct {
+		name string
+		fn   func(*loglist.Log, *http.Client) (*LogInfo, error)
+	}{
+		{name: "NewLogInfo", fn: newPlainLogInfo},
+		{name: "NewLazyLogInfo", fn: newPlainLazyLogInfo},
+	} {
+		t.Run(build.name, func(t *testing.T) {
+			log := loglist.Log{Description: "mirrored log", URL: primary.URL, MirrorURLs: []string{mirror.URL}, Key: fl.keyDER(t)}
+			li, err := build.fn(&log, hc)
+			if err != nil {
+				t.Fatalf("%s()=nil,%v; want _,nil", build.name, err)
+			}
+			if got := li.Client.BaseURI(); got != primary.URL {
+				t.Errorf("BaseURI()=%q; want %q", got, primary.URL)
+			}
+			// The optional client interfaces are still available.
+			if _, ok := li.Client.(client.AddLogClient); !ok {
+				t.Errorf("%s().Client=%T; want client.AddLogClient", build.name, li.Client)
+			}
+			if _, ok := li.Client.(RawEntriesClient); !ok {
+				t.Errorf("%s().Client=%T; want RawEntriesClient", build.name, li.Client)
+			}
+			if _, ok := li.Client.(EntryAndProofClient); !ok {
+				t.Errorf("%s().Client=%T; want EntryAndProofClient", build.name, li.Client)
+			}
+			if _, ok := client.Primary(li.Client).(*client.LogClient); !ok {
+				t.Errorf("client.Primary(%s().Client)=%T; want *client.LogClient", build.name, client.Primary(li.Client))
+			}
+			sth, err := li.GetVerifiedSTH(ctx)
+			if err != nil {
+				t.Fatalf("GetVerifiedSTH()=nil,%v; want _,nil", err)
+			}
+			if sth.TreeSize != 3 {
+				t.Errorf("GetVerifiedSTH().TreeSize=%d; want 3", sth.TreeSize)
+			}
+
+			log.MirrorURLs = []string{forger.URL}
+			li, err = build.fn(&log, hc)
+			if err != nil {
+				t.Fatalf("%s()=nil,%v; want _,nil", build.name, err)
+			}
+			if sth, err := li.GetVerifiedSTH(ctx); err == nil {
+				t.Errorf("GetVerifiedSTH(forged mirror)=%+v,nil; want _,non-nil", sth)
+			}
+		})
+	}
+
+	// Without mirrors, the client is a plain LogClient.
+	li, err := NewLogInfo(&loglist.Log{Description: "plain log", URL: primary.URL, Key: fl.keyDER(t)}, hc)
+	if err != nil {
+		t.Fatalf("NewLogInfo()=nil,%v; want _,nil", err)
+	}
+	if _, ok := li.Client.(*client.LogClient); !ok {
+		t.Errorf("NewLogInfo().Client=%T; want *client.LogClient", li.Client)
+	}
+}
+
+// serveStatic serves the fake log as a static (tiled) CT log, which must have
+// fewer leaves than fit in a single tile.
+func serveStatic(t *testing.T, fl *fakeLog) *httptest.Server {
+	t.Helper()
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sth, err := fl.GetSTH(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		switch r.URL.Path {
+		case "/checkpoint":
+			checkpoint, err := sth.ToCheckpoint("log.example.com/static", fl.keyDER(t))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Write(checkpoint)
+		case "/" + client.TilePath(0, 0, int(sth.TreeSize)):
+			fl.mu.Lock()
+			defer fl.mu.Unlock()
+			for i := int64(1); i <= int64(sth.TreeSize); i++ {
+				w.Write(fl.tree.LeafHash(i))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestNewLogInfoStatic(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	index := fl.addLeaf(t, stamped(testLeaf(7), 1234))
+	fl.addLeaves(t, 2)
+	server := serveStatic(t, fl)
+	defer server.Close()
+
+	log := loglist.Log{Description: "static log", URL: "https://submit.invalid", MonitoringURL: server.URL, Key: fl.keyDER(t)}
+	li, err := NewLogInfo(&log, server.Client())
+	if err != nil {
+		t.Fatalf("NewLogInfo()=nil,%v; want _,nil", err)
+	}
+	if _, ok := li.Client.(*client.StaticLogClient); !ok {
+		t.Errorf("NewLogInfo().Client=%T; want *client.StaticLogClient", li.Client)
+	}
+	if got := li.Client.BaseURI(); got != server.URL {
+		t.Errorf("BaseURI()=%q; want %q", got, server.URL)
+	}
+	sth, err := li.GetVerifiedSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetVerifiedSTH()=nil,%v; want _,nil", err)
+	}
+	if sth.TreeSize != 6 {
+		t.Errorf("GetVerifiedSTH().TreeSize=%d; want 6", sth.TreeSize)
+	}
+	if got, err := li.VerifyInclusionLatest(ctx, testLeaf(7), 1234); err != nil || got != index {
+		t.Errorf("VerifyInclusionLatest()=%d,%v; want %d,nil", got, err, index)
+	}
+	if _, err := li.VerifyInclusionLatest(ctx, testLeaf(8), 1234); err == nil {
+		t.Error("VerifyInclusionLatest(missing leaf)=_,nil; want _,non-nil")
+	}
+}
+
+func TestNewLogInfoOverDoHWrapper(t *testing.T) {
+	var queries []string
+	resolver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("dns"))
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer resolver.Close()
+
+	fl := newFakeLog(t, "https://log.example.com")
+	log := loglist.Log{Description: "DNS log", DNSAPIEndpoint: "log.example.com", Key: fl.keyDER(t)}
+	lf := NewLogInfoOverDoHWrapper(resolver.URL)
+	li, err := lf(&log, resolver.Client())
+	if err != nil {
+		t.Fatalf("NewLogInfoOverDoHWrapper()()=nil,%v; want _,nil", err)
+	}
+	if _, err := li.Client.GetSTH(context.Background()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("GetSTH()=_,%v; want error from DNS-over-HTTPS resolver", err)
+	}
+	if len(queries) != 1 || queries[0] == "" {
+		t.Errorf("GetSTH() made DNS-over-HTTPS queries %q; want 1", queries)
+	}
+
+	m, err := LogInfoByKeyHashOverDoH(&loglist.LogList{Logs: []loglist.Log{log}}, resolver.URL, resolver.Client())
+	if err != nil {
+		t.Fatalf("LogInfoByKeyHashOverDoH()=nil,%v; want _,nil", err)
+	}
+	li, ok := m[sha256.Sum256(log.Key)]
+	if !ok {
+		t.Fatalf("LogInfoByKeyHashOverDoH()=%v; want entry for log", m)
+	}
+	if _, err := li.Client.GetSTH(context.Background()); err == nil {
+		t.Error("GetSTH()=_,nil; want error from DNS-over-HTTPS resolver")
+	}
+	if len(queries) != 2 {
+		t.Errorf("GetSTH() made %d DNS-over-HTTPS queries in total; want 2", len(queries))
+	}
+
+	log.DNSAPIEndpoint = ""
+	if _, err := lf(&log, resolver.Client()); err == nil {
+		t.Error("NewLogInfoOverDoHWrapper()(no DNS endpoint)=_,nil; want _,non-nil")
+	}
+}
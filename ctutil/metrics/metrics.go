@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exports the observations of ctutil Monitors as metrics,
+// e.g. for scraping by Prometheus.  It is kept separate from ctutil so that
+// users of the core package do not depend on a metrics implementation.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/ctutil"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/prometheus"
+)
+
+// Exporter maintains per-log metrics describing the STHs and findings of
+// ctutil Monitors.  All metrics are labelled with the log's description.
+type Exporter struct {
+	treeSize            monitoring.Gauge
+	sthTimestamp        monitoring.Gauge
+	sthAge              monitoring.Gauge
+	consistencyFailures monitoring.Counter
+	mmdViolations       monitoring.Counter
+	findings            monitoring.Counter
+	pollErrors          monitoring.Counter
+}
+
+// NewExporter creates the monitoring metrics using the given factory.  As the
+// metrics are registered on creation, this should only be called once for a
+// given factory.
+func NewExporter(mf monitoring.MetricFactory) *Exporter {
+	return &Exporter{
+		treeSize:            mf.NewGauge("log_tree_size", "Tree size of the last good STH from the log", "log"),
+		sthTimestamp:        mf.NewGauge("log_sth_timestamp", "Timestamp (ms since epoch) of the last good STH from the log", "log"),
+		sthAge:              mf.NewGauge("log_sth_age_seconds", "Age of the last good STH from the log when last observed", "log"),
+		consistencyFailures: mf.NewCounter("log_consistency_failures", "Number of STHs from the log that were inconsistent with earlier STHs", "log"),
+		mmdViolations:       mf.NewCounter("log_mmd_violations", "Number of observations of the log with its last good STH older than its MMD", "log"),
+		findings:            mf.NewCounter("log_findings", "Number of findings of log misbehaviour", "log", "kind"),
+		pollErrors:          mf.NewCounter("log_poll_errors", "Number of failures to check the log's STH", "log"),
+	}
+}
+
+// NewPrometheusExporter creates an Exporter whose metrics are registered with
+// Prometheus, with names prefixed by the given prefix.
+func NewPrometheusExporter(prefix string) *Exporter {
+	return NewExporter(prometheus.MetricFactory{Prefix: prefix})
+}
+
+// Record updates the metrics for the monitor's log with its latest good STH
+// and the given findings from the monitor.
+func (e *Exporter) Record(m *ctutil.Monitor, findings []ctutil.Finding) {
+	label := m.Log.Description
+	for _, f := range findings {
+		e.findings.Inc(label, f.Kind.String())
+		if f.Kind == ctutil.ConsistencyFailure {
+			e.consistencyFailures.Inc(label)
+		}
+	}
+	if sth := m.Last(); sth != nil {
+		e.recordSTH(label, m.Log.MMD, sth)
+	}
+}
+
+func (e *Exporter) recordSTH(label string, mmd time.Duration, sth *ct.SignedTreeHead) {
+	age := time.Since(time.Unix(0, int64(sth.Timestamp)*int64(time.Millisecond)))
+	e.treeSize.Set(float64(sth.TreeSize), label)
+	e.sthTimestamp.Set(float64(sth.Timestamp), label)
+	e.sthAge.Set(age.Seconds(), label)
+	if mmd > 0 && age > mmd {
+		e.mmdViolations.Inc(label)
+	}
+}
+
+// Poll polls the monitor's log and records the results.
+func (e *Exporter) Poll(ctx context.Context, m *ctutil.Monitor) ([]ctutil.Finding, error) {
+	findings, err := m.Poll(ctx)
+	if err != nil {
+		e.pollErrors.Inc(m.Log.Description)
+		return nil, err
+	}
+	e.Record(m, findings)
+	return findings, nil
+}
+
+// Handler returns a handler for Monitor.Follow that records the results for
+// each STH, then passes them on to next (if non-nil).
+func (e *Exporter) Handler(m *ctutil.Monitor, next func(sth *ct.SignedTreeHead, findings []ctutil.Finding, err error)) func(sth *ct.SignedTreeHead, findings []ctutil.Finding, err error) {
+	return func(sth *ct.SignedTreeHead, findings []ctutil.Finding, err error) {
+		if err != nil {
+			e.pollErrors.Inc(m.Log.Description)
+		} else {
+			e.Record(m, findings)
+		}
+		if next != nil {
+			next(sth, findings, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/ctutil"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scriptedLog is a client.CheckLogClient that returns a fixed sequence of
+// STHs, signed on demand.
+type scriptedLog struct {
+	key  *ecdsa.PrivateKey
+	sths []ct.SignedTreeHead
+}
+
+func (s *scriptedLog) BaseURI() string {
+	return "https://log.example.com"
+}
+
+func (s *scriptedLog) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	if len(s.sths) == 0 {
+		return nil, errors.New("no more STHs")
+	}
+	sth := s.sths[0]
+	s.sths = s.sths[1:]
+	sth.Version = ct.V1
+	data, err := ct.SerializeSTHSignatureInput(sth)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := tls.CreateSignature(*s.key, tls.SHA256, data)
+	if err != nil {
+		return nil, err
+	}
+	sth.TreeHeadSignature = ct.DigitallySigned(sig)
+	return &sth, nil
+}
+
+func (s *scriptedLog) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *scriptedLog) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+// scrape returns the value of the named metric with the given log label from
+// the default Prometheus registry.
+func scrape(t *testing.T, name, log string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather()=_,%v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != "log" || label.GetValue() != log {
+					continue
+				}
+				if g := metric.GetGauge(); g != nil {
+					return g.GetValue()
+				}
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	t.Fatalf("metric %s{log=%q} not found", name, log)
+	return 0
+}
+
+func TestExporterPoll(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	verifier, err := ct.NewSignatureVerifier(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to build verifier: %v", err)
+	}
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	stale := now - uint64(48*time.Hour/time.Millisecond)
+	log := &scriptedLog{
+		key: key,
+		sths: []ct.SignedTreeHead{
+			{TreeSize: 10, Timestamp: now, SHA256RootHash: ct.SHA256Hash{0x01}},
+			// Same size, different root: inconsistent.
+			{TreeSize: 10, Timestamp: now, SHA256RootHash: ct.SHA256Hash{0x02}},
+			// Consistent, but older than the MMD.
+			{TreeSize: 10, Timestamp: stale, SHA256RootHash: ct.SHA256Hash{0x01}},
+		},
+	}
+	li := &ctutil.LogInfo{Description: "test log", Client: log, MMD: 24 * time.Hour, Verifier: verifier}
+	m := ctutil.NewMonitor(li)
+	e := NewPrometheusExporter("ctutil_metrics_test_")
+
+	for i := 0; i < 3; i++ {
+		if _, err := e.Poll(ctx, m); err != nil {
+			t.Fatalf("Poll(%d)=_,%v; want _,nil", i, err)
+		}
+	}
+	if _, err := e.Poll(ctx, m); err == nil {
+		t.Fatal("Poll(exhausted)=_,nil; want _,non-nil")
+	}
+
+	for _, test := range []struct {
+		name string
+		want float64
+	}{
+		{name: "ctutil_metrics_test_log_tree_size", want: 10},
+		{name: "ctutil_metrics_test_log_sth_timestamp", want: float64(stale)},
+		{name: "ctutil_metrics_test_log_consistency_failures", want: 1},
+		{name: "ctutil_metrics_test_log_mmd_violations", want: 1},
+		{name: "ctutil_metrics_test_log_poll_errors", want: 1},
+	} {
+		if got := scrape(t, test.name, li.Description); got != test.want {
+			t.Errorf("%s=%v; want %v", test.name, got, test.want)
+		}
+	}
+	if got := scrape(t, "ctutil_metrics_test_log_sth_age_seconds", li.Description); got < (47 * time.Hour).Seconds() {
+		t.Errorf("log_sth_age_seconds=%v; want at least 47h", got)
+	}
+}
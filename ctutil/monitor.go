@@ -0,0 +1,218 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// DefaultMaxEntriesPerSecond is the default limit on the plausible rate of
+// growth of a log.  It is deliberately far above the rate of any real log, so
+// that only grossly implausible growth is flagged.
+const DefaultMaxEntriesPerSecond = 100000
+
+// FindingKind indicates the type of misbehaviour observed by a Monitor.
+type FindingKind int
+
+// FindingKind values.
+const (
+	// InvalidSTHSignature indicates an STH whose signature did not verify.
+	InvalidSTHSignature FindingKind = iota + 1
+	// ConsistencyFailure indicates an STH that is not consistent with the
+	// previous STH from the log.
+	ConsistencyFailure
+	// ImplausibleGrowth indicates that the log's tree grew faster between two
+	// consecutive STHs than is plausible, even though the STHs are consistent.
+	ImplausibleGrowth
+)
+
+func (k FindingKind) String() string {
+	switch k {
+	case InvalidSTHSignature:
+		return "InvalidSTHSignature"
+	case ConsistencyFailure:
+		return "ConsistencyFailure"
+	case ImplausibleGrowth:
+		return "ImplausibleGrowth"
+	default:
+		return fmt.Sprintf("FindingKind(%d)", int(k))
+	}
+}
+
+// Finding describes an instance of log misbehaviour observed by a Monitor.
+type Finding struct {
+	Kind FindingKind
+	// Prev is the last good STH seen before the finding (if any), and STH is
+	// the STH that prompted it.
+	Prev, STH *ct.SignedTreeHead
+	Detail    string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%v: %s", f.Kind, f.Detail)
+}
+
+// Monitor follows the STHs published by a single log, checking that each new
+// STH is correctly signed, consistent with the previous one, and plausible.
+type Monitor struct {
+	Log *LogInfo
+	// MaxEntriesPerSecond is the maximum plausible rate of growth of the log;
+	// if zero, DefaultMaxEntriesPerSecond is used.
+	MaxEntriesPerSecond float64
+
+	mu   sync.Mutex
+	last *ct.SignedTreeHead
+	// verifier, if set, replaces the log's own verifier for STH signatures
+	// after a key rotation.
+	verifier *ct.SignatureVerifier
+}
+
+// NewMonitor builds a Monitor for the given log.
+func NewMonitor(li *LogInfo) *Monitor {
+	return &Monitor{Log: li}
+}
+
+// Last returns the most recent good STH seen by the monitor.
+func (m *Monitor) Last() *ct.SignedTreeHead {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// RotateKey switches the monitor to verifying the signatures of subsequent STHs
+// with the given DER-encoded public key, for a log that has rotated its
+// signing key.  The log's tree continues across the rotation, so the next STH
+// must still be consistent with the last good STH signed by the previous key.
+// The monitor's LogInfo is left unchanged.
+func (m *Monitor) RotateKey(newKeyDER []byte) error {
+	verifier, err := newVerifier(m.Log.Description, newKeyDER)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.verifier = verifier
+	return nil
+}
+
+// Poll retrieves the log's current STH and checks it with ProcessSTH.
+func (m *Monitor) Poll(ctx context.Context) ([]Finding, error) {
+	sth, err := m.Log.Client.GetSTH(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current STH for %q log: %v", m.Log.Description, err)
+	}
+	return m.ProcessSTH(ctx, sth)
+}
+
+// ProcessSTH checks a newly observed STH for the log against the previous good
+// STH, returning any misbehaviour found.  An STH with an invalid signature or
+// that is inconsistent with the previous STH is rejected; otherwise it becomes
+// the monitor's latest good STH, and is recorded as the log's last known STH.
+// A non-nil error indicates that the checks could not be completed.
+func (m *Monitor) ProcessSTH(ctx context.Context, sth *ct.SignedTreeHead) ([]Finding, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.last
+	finding := func(kind FindingKind, format string, args ...interface{}) []Finding {
+		return []Finding{{Kind: kind, Prev: prev, STH: sth, Detail: fmt.Sprintf(format, args...)}}
+	}
+
+	if err := m.verifySTHSignature(sth); err != nil {
+		return finding(InvalidSTHSignature, "STH at size %d from log %q: %v", sth.TreeSize, m.Log.Description, err), nil
+	}
+
+	var findings []Finding
+	if prev != nil {
+		if sth.TreeSize < prev.TreeSize {
+			return finding(ConsistencyFailure, "log %q shrank from size %d to %d", m.Log.Description, prev.TreeSize, sth.TreeSize), nil
+		}
+		if err := m.checkConsistency(ctx, prev, sth); err != nil {
+			if _, ok := err.(consistencyError); ok {
+				return finding(ConsistencyFailure, "%v", err), nil
+			}
+			return nil, err
+		}
+		if detail := m.checkGrowth(prev, sth); detail != "" {
+			findings = finding(ImplausibleGrowth, "%s", detail)
+		}
+	}
+
+	m.last = sth
+	m.Log.SetSTH(sth)
+	return findings, nil
+}
+
+// verifySTHSignature checks the signature on the STH with the log's current
+// key.  The caller must hold m.mu.
+func (m *Monitor) verifySTHSignature(sth *ct.SignedTreeHead) error {
+	if m.verifier != nil {
+		return m.verifier.VerifySTHSignature(*sth)
+	}
+	return m.Log.VerifySTHSignature(*sth)
+}
+
+// consistencyError indicates that a consistency proof between two STHs was
+// obtained but did not verify.
+type consistencyError struct {
+	error
+}
+
+func (m *Monitor) checkConsistency(ctx context.Context, prev, sth *ct.SignedTreeHead) error {
+	if prev.TreeSize == sth.TreeSize {
+		if prev.SHA256RootHash != sth.SHA256RootHash {
+			return consistencyError{fmt.Errorf("log %q has two root hashes at size %d: %x and %x", m.Log.Description, sth.TreeSize, prev.SHA256RootHash, sth.SHA256RootHash)}
+		}
+		return nil
+	}
+	if prev.TreeSize == 0 {
+		return nil
+	}
+	proof, err := m.Log.Client.GetSTHConsistency(ctx, prev.TreeSize, sth.TreeSize)
+	if err != nil {
+		return fmt.Errorf("failed to get consistency proof from %q log between sizes %d and %d: %v", m.Log.Description, prev.TreeSize, sth.TreeSize, err)
+	}
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyConsistencyProof(int64(prev.TreeSize), int64(sth.TreeSize), prev.SHA256RootHash[:], sth.SHA256RootHash[:], proof); err != nil {
+		return consistencyError{fmt.Errorf("log %q STHs at sizes %d (root %x) and %d (root %x) are inconsistent: %v", m.Log.Description, prev.TreeSize, prev.SHA256RootHash, sth.TreeSize, sth.SHA256RootHash, err)}
+	}
+	return nil
+}
+
+// checkGrowth returns a description of the log's growth between the two STHs
+// if it exceeds the plausible rate, or an empty string otherwise.
+func (m *Monitor) checkGrowth(prev, sth *ct.SignedTreeHead) string {
+	if sth.TreeSize <= prev.TreeSize {
+		return ""
+	}
+	maxRate := m.MaxEntriesPerSecond
+	if maxRate <= 0 {
+		maxRate = DefaultMaxEntriesPerSecond
+	}
+	growth := float64(sth.TreeSize - prev.TreeSize)
+	if sth.Timestamp <= prev.Timestamp {
+		return fmt.Sprintf("log %q grew by %.0f entries with no increase in STH timestamp (%d to %d)", m.Log.Description, growth, prev.Timestamp, sth.Timestamp)
+	}
+	elapsed := float64(sth.Timestamp-prev.Timestamp) / 1000
+	if rate := growth / elapsed; rate > maxRate {
+		return fmt.Sprintf("log %q grew by %.0f entries in %.3fs (%.0f entries/s, limit %.0f)", m.Log.Description, growth, elapsed, rate, maxRate)
+	}
+	return ""
+}
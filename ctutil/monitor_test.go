@@ -0,0 +1,198 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+func TestMonitorPoll(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	m := NewMonitor(fl.logInfo(t))
+	m.MaxEntriesPerSecond = 10
+
+	// Each step adds entries to the log, advances its STH timestamp by the
+	// given number of milliseconds, then polls.
+	steps := []struct {
+		desc    string
+		add     int
+		elapsed uint64
+		want    []FindingKind
+	}{
+		{desc: "initial", add: 5, elapsed: 0},
+		{desc: "plausible", add: 10, elapsed: 2000},
+		{desc: "unchanged", add: 0, elapsed: 1000},
+		{desc: "implausible-jump", add: 100, elapsed: 500, want: []FindingKind{ImplausibleGrowth}},
+		{desc: "no-time-passed", add: 1, elapsed: 0, want: []FindingKind{ImplausibleGrowth}},
+		{desc: "plausible-again", add: 3, elapsed: 1000},
+	}
+	for _, step := range steps {
+		fl.addLeaves(t, step.add)
+		fl.timestamp += step.elapsed
+		findings, err := m.Poll(ctx)
+		if err != nil {
+			t.Fatalf("%s: Poll()=_,%v; want _,nil", step.desc, err)
+		}
+		if len(findings) != len(step.want) {
+			t.Errorf("%s: Poll()=%v; want kinds %v", step.desc, findings, step.want)
+			continue
+		}
+		for i, f := range findings {
+			if f.Kind != step.want[i] {
+				t.Errorf("%s: Poll()[%d].Kind=%v; want %v", step.desc, i, f.Kind, step.want[i])
+			}
+		}
+		// Implausible growth is flagged but the (consistent) STH is accepted.
+		if got, want := m.Last().TreeSize, uint64(fl.tree.LeafCount()); got != want {
+			t.Errorf("%s: Last().TreeSize=%d; want %d", step.desc, got, want)
+		}
+	}
+}
+
+func TestMonitorDefaultThreshold(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	m := NewMonitor(fl.logInfo(t))
+	fl.addLeaves(t, 1)
+	if _, err := m.Poll(ctx); err != nil {
+		t.Fatalf("Poll()=_,%v; want _,nil", err)
+	}
+	fl.addLeaves(t, 50)
+	fl.timestamp++
+	findings, err := m.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Poll()=_,%v; want _,nil", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("Poll()=%v; want no findings", findings)
+	}
+}
+
+func TestMonitorProcessSTHRejects(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	m := NewMonitor(fl.logInfo(t))
+	fl.addLeaves(t, 4)
+	good, err := fl.sthAt(4)
+	if err != nil {
+		t.Fatalf("sthAt(4)=_,%v", err)
+	}
+	if findings, err := m.ProcessSTH(ctx, good); err != nil || len(findings) != 0 {
+		t.Fatalf("ProcessSTH(good)=%v,%v; want nil,nil", findings, err)
+	}
+
+	forged := *good
+	forged.TreeSize = 10
+	fl.addLeaves(t, 6)
+	// A different tree of the same size, signed by the log.
+	other := newFakeLog(t, "https://other.example.com")
+	for i := 0; i < 10; i++ {
+		other.addLeaf(t, stamped(testLeaf(i), 0))
+	}
+	fork, err := other.sthAt(10)
+	if err != nil {
+		t.Fatalf("sthAt(10)=_,%v", err)
+	}
+	fork.TreeHeadSignature = mustResign(t, fl, fork)
+
+	for _, test := range []struct {
+		desc string
+		sth  *ct.SignedTreeHead
+		want FindingKind
+	}{
+		{desc: "bad-signature", sth: &forged, want: InvalidSTHSignature},
+		{desc: "inconsistent", sth: fork, want: ConsistencyFailure},
+	} {
+		findings, err := m.ProcessSTH(ctx, test.sth)
+		if err != nil {
+			t.Errorf("%s: ProcessSTH()=_,%v; want _,nil", test.desc, err)
+			continue
+		}
+		if len(findings) != 1 || findings[0].Kind != test.want {
+			t.Errorf("%s: ProcessSTH()=%v; want single %v", test.desc, findings, test.want)
+		}
+		if got := m.Last(); got != good {
+			t.Errorf("%s: Last()=%v; want unchanged %v", test.desc, got, good)
+		}
+	}
+}
+
+// mustResign returns a signature over the STH from the given log's key.
+func mustResign(t *testing.T, f *fakeLog, sth *ct.SignedTreeHead) ct.DigitallySigned {
+	t.Helper()
+	data, err := ct.SerializeSTHSignatureInput(*sth)
+	if err != nil {
+		t.Fatalf("failed to serialize STH: %v", err)
+	}
+	sig, err := tls.CreateSignature(*f.key, tls.SHA256, data)
+	if err != nil {
+		t.Fatalf("failed to sign STH: %v", err)
+	}
+	return ct.DigitallySigned(sig)
+}
+
+func TestMonitorRotateKey(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	m := NewMonitor(fl.logInfo(t))
+	fl.addLeaves(t, 4)
+	if findings, err := m.Poll(ctx); err != nil || len(findings) != 0 {
+		t.Fatalf("Poll()=%v,%v; want nil,nil", findings, err)
+	}
+
+	// The log rotates to a new key, and carries on growing its tree.
+	rotated := newFakeLog(t, "https://log.example.com")
+	oldKey := fl.key
+	fl.key = rotated.key
+	fl.addLeaves(t, 3)
+	fl.timestamp += 1000
+	findings, err := m.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Poll(before RotateKey)=_,%v; want _,nil", err)
+	}
+	if len(findings) != 1 || findings[0].Kind != InvalidSTHSignature {
+		t.Errorf("Poll(before RotateKey)=%v; want single %v", findings, InvalidSTHSignature)
+	}
+
+	if err := m.RotateKey([]byte("not a key")); err == nil {
+		t.Error("RotateKey(invalid key)=nil; want non-nil")
+	}
+	if err := m.RotateKey(rotated.keyDER(t)); err != nil {
+		t.Fatalf("RotateKey()=%v; want nil", err)
+	}
+	if findings, err := m.Poll(ctx); err != nil || len(findings) != 0 {
+		t.Errorf("Poll(after RotateKey)=%v,%v; want nil,nil", findings, err)
+	}
+	if got, want := m.Last().TreeSize, uint64(7); got != want {
+		t.Errorf("Last().TreeSize=%d; want %d", got, want)
+	}
+
+	// STHs signed by the old key are no longer accepted.
+	fl.key = oldKey
+	fl.addLeaves(t, 1)
+	fl.timestamp += 1000
+	findings, err = m.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Poll(old key)=_,%v; want _,nil", err)
+	}
+	if len(findings) != 1 || findings[0].Kind != InvalidSTHSignature {
+		t.Errorf("Poll(old key)=%v; want single %v", findings, InvalidSTHSignature)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509util"
+)
+
+// SCTVerdict holds the outcome of verifying a single SCT.
+type SCTVerdict struct {
+	SCT ct.SignedCertificateTimestamp
+	// Log is the log that issued the SCT, or nil if it is not a known log.
+	Log *LogInfo
+	// Err is nil if the SCT verified, and otherwise describes why it did not.
+	Err error
+}
+
+// Valid indicates whether the SCT verified.
+func (v SCTVerdict) Valid() bool {
+	return v.Err == nil
+}
+
+// VerifyPEMCertSCTs verifies the SCTs embedded in a PEM-encoded certificate,
+// given its PEM-encoded issuer, against the known logs.  The issuer data may
+// hold a full chain, in which case only its first certificate is used.
+// Returns a verdict for each of the embedded SCTs, in order; an SCT from a log
+// that is not in the map has a verdict with an error wrapping ErrUnknownLog.
+// An error is only returned if the certificates or SCTs cannot be parsed.
+func VerifyPEMCertSCTs(leafPEM, issuerPEM []byte, m LogInfoByHash) ([]SCTVerdict, error) {
+	leaf, err := firstCertFromPEM(leafPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	issuer, err := firstCertFromPEM(issuerPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer: %v", err)
+	}
+	scts, err := SCTsFromCertificate(leaf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse embedded SCTs: %v", err)
+	}
+
+	chain := []*x509.Certificate{leaf, issuer}
+	verdicts := make([]SCTVerdict, 0, len(scts))
+	for _, sct := range scts {
+		v := SCTVerdict{SCT: sct}
+		v.Log, v.Err = m.MustLogForSCT(sct)
+		if v.Err == nil {
+			v.Err = v.Log.VerifyChainSCTSignature(sct, chain, true)
+		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts, nil
+}
+
+// firstCertFromPEM parses the first certificate held in the PEM data.
+func firstCertFromPEM(data []byte) (*x509.Certificate, error) {
+	certs, err := x509util.CertificatesFromPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in PEM data")
+	}
+	return certs[0], nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/testdata"
+)
+
+func TestVerifyPEMCertSCTs(t *testing.T) {
+	der, err := base64.StdEncoding.DecodeString(testdata.LogPublicKeyB64)
+	if err != nil {
+		t.Fatalf("failed to decode log key: %v", err)
+	}
+	pk, err := ct.PublicKeyFromB64(testdata.LogPublicKeyB64)
+	if err != nil {
+		t.Fatalf("failed to parse log key: %v", err)
+	}
+	verifier, err := ct.NewSignatureVerifier(pk)
+	if err != nil {
+		t.Fatalf("failed to build verifier: %v", err)
+	}
+	li := &LogInfo{Description: "test log", Verifier: verifier, PublicKey: der}
+	known := LogInfoByHash{sha256.Sum256(der): li}
+
+	tests := []struct {
+		desc      string
+		leaf      string
+		issuer    string
+		m         LogInfoByHash
+		wantErr   bool
+		want      []bool
+		wantLog   bool
+		wantCause error
+	}{
+		{
+			desc:    "valid",
+			leaf:    testdata.TestEmbeddedCertPEM,
+			issuer:  testdata.CACertPEM,
+			m:       known,
+			want:    []bool{true},
+			wantLog: true,
+		},
+		{
+			desc:    "issuer-chain",
+			leaf:    testdata.TestEmbeddedCertPEM,
+			issuer:  testdata.CACertPEM + "\n" + testdata.TestCertPEM,
+			m:       known,
+			want:    []bool{true},
+			wantLog: true,
+		},
+		{
+			desc:    "wrong-issuer",
+			leaf:    testdata.TestEmbeddedCertPEM,
+			issuer:  testdata.TestCertPEM,
+			m:       known,
+			want:    []bool{false},
+			wantLog: true,
+		},
+		{
+			desc:      "unknown-log",
+			leaf:      testdata.TestEmbeddedCertPEM,
+			issuer:    testdata.CACertPEM,
+			m:         LogInfoByHash{},
+			want:      []bool{false},
+			wantCause: ErrUnknownLog,
+		},
+		{
+			desc:   "no-scts",
+			leaf:   testdata.TestCertPEM,
+			issuer: testdata.CACertPEM,
+			m:      known,
+		},
+		{
+			desc:    "bad-leaf",
+			leaf:    "not PEM",
+			issuer:  testdata.CACertPEM,
+			m:       known,
+			wantErr: true,
+		},
+		{
+			desc:    "missing-issuer",
+			leaf:    testdata.TestEmbeddedCertPEM,
+			m:       known,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			verdicts, err := VerifyPEMCertSCTs([]byte(test.leaf), []byte(test.issuer), test.m)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("VerifyPEMCertSCTs()=_,%v; want error? %t", err, test.wantErr)
+			}
+			if len(verdicts) != len(test.want) {
+				t.Fatalf("VerifyPEMCertSCTs()=%v; want %d verdict(s)", verdicts, len(test.want))
+			}
+			for i, v := range verdicts {
+				if got := v.Valid(); got != test.want[i] {
+					t.Errorf("VerifyPEMCertSCTs()[%d].Valid()=%t (err %v); want %t", i, got, v.Err, test.want[i])
+				}
+				if got := v.Log != nil; got != test.wantLog {
+					t.Errorf("VerifyPEMCertSCTs()[%d].Log=%v; want log? %t", i, v.Log, test.wantLog)
+				}
+				if test.wantCause != nil && !errors.Is(v.Err, test.wantCause) {
+					t.Errorf("VerifyPEMCertSCTs()[%d].Err=%v; want error wrapping %v", i, v.Err, test.wantCause)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist"
+	"github.com/google/certificate-transparency-go/loglist3"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// LogPredicate reports whether SCTs issued by a log should be counted when
+// evaluating a Policy.
+type LogPredicate func(li *LogInfo) bool
+
+// OperatorAllowList returns a LogPredicate that only accepts logs run by one
+// of the allowed operators, according to the given map of log key hash to
+// operator name.
+func OperatorAllowList(operators map[[sha256.Size]byte]string, allowed ...string) LogPredicate {
+	ok := make(map[string]bool)
+	for _, op := range allowed {
+		ok[op] = true
+	}
+	return func(li *LogInfo) bool {
+		return ok[operators[sha256.Sum256(li.PublicKey)]]
+	}
+}
+
+// SCTPredatesDistrust indicates whether the SCT was issued strictly before the
+// given distrust date of its log.  A zero distrust date means that the log is
+// not distrusted.
+func SCTPredatesDistrust(sct ct.SignedCertificateTimestamp, distrust time.Time) bool {
+	if distrust.IsZero() {
+		return true
+	}
+	return ct.TimestampToTime(sct.Timestamp).Before(distrust)
+}
+
+// DistrustDates returns the dates from which the retired logs in the given v3
+// log list are distrusted, indexed by log key hash.
+func DistrustDates(ll *loglist3.LogList) map[[sha256.Size]byte]time.Time {
+	dates := make(map[[sha256.Size]byte]time.Time)
+	for _, op := range ll.Operators {
+		for _, log := range op.Logs {
+			if log.State == nil || log.State.Retired == nil {
+				continue
+			}
+			dates[sha256.Sum256(log.Key)] = log.State.Retired.Timestamp
+		}
+	}
+	return dates
+}
+
+// TemporalIntervals returns the temporal intervals of the sharded logs in the
+// given v3 log list, indexed by log key hash.
+func TemporalIntervals(ll *loglist3.LogList) map[[sha256.Size]byte]loglist3.TemporalInterval {
+	intervals := make(map[[sha256.Size]byte]loglist3.TemporalInterval)
+	for _, op := range ll.Operators {
+		for _, log := range op.Logs {
+			if log.TemporalInterval == nil {
+				continue
+			}
+			intervals[sha256.Sum256(log.Key)] = *log.TemporalInterval
+		}
+	}
+	return intervals
+}
+
+// Policy describes the requirements that the SCTs for a certificate must meet.
+type Policy struct {
+	// Name is a human-readable label for the policy, e.g. "Chrome".
+	Name string
+	// MinSCTs is the number of SCTs, from distinct logs, that is required.
+	MinSCTs int
+	// MinOperators is the number of distinct log operators that the counted
+	// SCTs must come from.
+	MinOperators int
+	// Operators maps log key hashes to operator names.  Logs that are absent
+	// from the map are treated as each having a distinct operator.
+	Operators map[[sha256.Size]byte]string
+	// Accept, if set, restricts the logs whose SCTs are counted; for example,
+	// to exclude logs from operators that are being phased out.
+	Accept LogPredicate
+	// Distrusted maps log key hashes to the dates from which those logs are
+	// distrusted (e.g. as returned by DistrustDates).  SCTs from such a log
+	// only count if they predate the log's distrust date.
+	Distrusted map[[sha256.Size]byte]time.Time
+	// TemporalIntervals maps the key hashes of sharded logs to the range of
+	// certificate expiry dates that each accepts (e.g. as returned by
+	// TemporalIntervals).  When evaluating the SCTs for a certificate, SCTs
+	// from a shard that does not cover the certificate's expiry do not count.
+	TemporalIntervals map[[sha256.Size]byte]loglist3.TemporalInterval
+	// RejectDuplicateLogs makes a set of SCTs that includes more than one SCT
+	// from the same log fail the policy.  Such SCTs only count once anyway,
+	// but usually indicate a mistake by the CA.
+	RejectDuplicateLogs bool
+}
+
+// DistinctLogCount returns the number of distinct logs (by log ID) that issued
+// the given SCTs.
+func DistinctLogCount(scts []ct.SignedCertificateTimestamp) int {
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, sct := range scts {
+		seen[sct.LogID.KeyID] = true
+	}
+	return len(seen)
+}
+
+// PolicyResult holds the outcome of evaluating a set of SCTs against a Policy.
+type PolicyResult struct {
+	// Policy is the name of the policy that was evaluated.
+	Policy string
+	// Logs holds the key hashes of the distinct logs whose SCTs were counted.
+	Logs [][sha256.Size]byte
+	// Operators holds the names of the distinct operators of those logs.
+	Operators []string
+	// Excluded describes why each of the SCTs that were not counted was
+	// excluded.
+	Excluded []string
+	// Failures describes each of the policy requirements that was not met.
+	Failures []string
+}
+
+// Compliant indicates whether all of the policy requirements were met.
+func (r *PolicyResult) Compliant() bool {
+	return len(r.Failures) == 0
+}
+
+// Evaluate checks the given SCTs against the policy, using the map of logs to
+// identify the issuer of each SCT.  SCTs from logs that are unknown or that are
+// not accepted by the policy do not count, nor do SCTs issued after their log
+// was distrusted.  Note that Evaluate does not check
+// SCT signatures; callers should verify SCTs before evaluating them.
+func (p Policy) Evaluate(scts []ct.SignedCertificateTimestamp, m LogInfoByHash) *PolicyResult {
+	return p.evaluate(scts, m, nil)
+}
+
+// EvaluateCert checks the SCTs for the given certificate against the policy, as
+// for Evaluate.  In addition, SCTs from sharded logs only count if the log's
+// temporal interval covers the certificate's expiry date.
+func (p Policy) EvaluateCert(cert *x509.Certificate, scts []ct.SignedCertificateTimestamp, m LogInfoByHash) *PolicyResult {
+	return p.evaluate(scts, m, cert)
+}
+
+func (p Policy) evaluate(scts []ct.SignedCertificateTimestamp, m LogInfoByHash, cert *x509.Certificate) *PolicyResult {
+	result := PolicyResult{Policy: p.Name}
+	seenLog := make(map[[sha256.Size]byte]bool)
+	seenOp := make(map[string]bool)
+	var duplicated []string
+	reported := make(map[[sha256.Size]byte]bool)
+	for i, sct := range scts {
+		key := sct.LogID.KeyID
+		li, err := m.MustLogForSCT(sct)
+		if err != nil {
+			result.Excluded = append(result.Excluded, fmt.Sprintf("SCT %d: %v", i, err))
+			continue
+		}
+		if p.Accept != nil && !p.Accept(li) {
+			result.Excluded = append(result.Excluded, fmt.Sprintf("SCT %d: log %q not accepted by policy", i, li.Description))
+			continue
+		}
+		if !SCTPredatesDistrust(sct, p.Distrusted[key]) {
+			result.Excluded = append(result.Excluded, fmt.Sprintf("SCT %d: issued after log %q was distrusted", i, li.Description))
+			continue
+		}
+		if interval, ok := p.TemporalIntervals[key]; ok && cert != nil && !intervalCovers(interval, cert.NotAfter) {
+			result.Excluded = append(result.Excluded, fmt.Sprintf("SCT %d: log %q shard [%v, %v) does not cover certificate expiry %v", i, li.Description, interval.StartInclusive, interval.EndExclusive, cert.NotAfter))
+			continue
+		}
+		if seenLog[key] {
+			result.Excluded = append(result.Excluded, fmt.Sprintf("SCT %d: duplicate SCT from log %q", i, li.Description))
+			if !reported[key] {
+				reported[key] = true
+				duplicated = append(duplicated, fmt.Sprintf("%q", li.Description))
+			}
+			continue
+		}
+		seenLog[key] = true
+		result.Logs = append(result.Logs, key)
+
+		op, ok := p.Operators[key]
+		if !ok {
+			op = li.Description
+		}
+		if !seenOp[op] {
+			seenOp[op] = true
+			result.Operators = append(result.Operators, op)
+		}
+	}
+	sort.Strings(result.Operators)
+
+	if got := len(result.Logs); got < p.MinSCTs {
+		result.Failures = append(result.Failures, fmt.Sprintf("got SCTs from %d distinct log(s), need %d", got, p.MinSCTs))
+	}
+	if got := len(result.Operators); got < p.MinOperators {
+		result.Failures = append(result.Failures, fmt.Sprintf("got SCTs from %d distinct operator(s), need %d", got, p.MinOperators))
+	}
+	if p.RejectDuplicateLogs && len(duplicated) > 0 {
+		result.Failures = append(result.Failures, fmt.Sprintf("got multiple SCTs from the same log(s), which count once: %s", strings.Join(duplicated, ", ")))
+	}
+	return &result
+}
+
+// ComplianceBadge summarizes the outcome of evaluating a certificate's SCTs
+// against a Policy.
+type ComplianceBadge struct {
+	Policy string `json:"policy"`
+	// SCTs is the number of SCTs that counted towards the policy, and
+	// Excluded the number that did not.
+	SCTs     int `json:"scts"`
+	Excluded int `json:"excluded"`
+	// Operators is the number of distinct operators of the counted SCTs' logs.
+	Operators int  `json:"operators"`
+	Pass      bool `json:"pass"`
+}
+
+// String returns a concise description of the badge, such as
+// "CT: 3 SCTs, 2 operators, PASS (Chrome)".
+func (b ComplianceBadge) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "CT: %s", plural(b.SCTs, "SCT"))
+	if b.Excluded > 0 {
+		fmt.Fprintf(&buf, " (%d excluded)", b.Excluded)
+	}
+	fmt.Fprintf(&buf, ", %s, ", plural(b.Operators, "operator"))
+	if b.Pass {
+		buf.WriteString("PASS")
+	} else {
+		buf.WriteString("FAIL")
+	}
+	if b.Policy != "" {
+		fmt.Fprintf(&buf, " (%s)", b.Policy)
+	}
+	return buf.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Compliance evaluates the SCTs for the given certificate against the policy
+// (as for Policy.EvaluateCert) and summarizes the outcome.
+func Compliance(cert *x509.Certificate, scts []ct.SignedCertificateTimestamp, m LogInfoByHash, policy Policy) ComplianceBadge {
+	result := policy.EvaluateCert(cert, scts, m)
+	return ComplianceBadge{
+		Policy:    result.Policy,
+		SCTs:      len(result.Logs),
+		Excluded:  len(result.Excluded),
+		Operators: len(result.Operators),
+		Pass:      result.Compliant(),
+	}
+}
+
+// ComplianceSummary evaluates the SCTs for the given certificate against the
+// policy, returning a one-line summary suitable for command-line tools and
+// logs; see ComplianceBadge.String.
+func ComplianceSummary(cert *x509.Certificate, scts []ct.SignedCertificateTimestamp, m LogInfoByHash, policy Policy) string {
+	return Compliance(cert, scts, m, policy).String()
+}
+
+// intervalCovers indicates whether the given time falls within the interval.
+func intervalCovers(interval loglist3.TemporalInterval, when time.Time) bool {
+	return !when.Before(interval.StartInclusive) && when.Before(interval.EndExclusive)
+}
+
+// ErrPolicyNotMet is wrapped by the errors returned by CheckSCTPolicy when the
+// SCTs do not meet the policy.
+var ErrPolicyNotMet = errors.New("SCTs do not meet CT policy")
+
+// averageMonth is the mean length of a calendar month.
+const averageMonth = 365.25 * 24 * time.Hour / 12
+
+// ChromeMinSCTs returns the number of SCTs from distinct logs that Chrome's CT
+// policy requires to be embedded in a certificate with the given lifetime: 2
+// for less than 15 months, 3 for up to 27 months, 4 for up to 39 months, and 5
+// beyond that.
+func ChromeMinSCTs(certLifetime time.Duration) int {
+	switch months := int(certLifetime / averageMonth); {
+	case months < 15:
+		return 2
+	case months <= 27:
+		return 3
+	case months <= 39:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// CheckSCTPolicy checks offline whether the given SCTs, embedded in a
+// certificate with the given lifetime, meet Chrome's CT policy according to
+// the given log list: there must be SCTs from at least ChromeMinSCTs distinct
+// logs, run by at least two distinct operators, including at least one
+// Google-operated and one non-Google-operated log.  SCTs from logs that are
+// not in the list do not count, nor do SCTs from disqualified logs issued
+// after their disqualification.  If the policy is not met, the error wraps
+// ErrPolicyNotMet and describes each requirement that failed.  Note that
+// CheckSCTPolicy does not check SCT signatures.
+func CheckSCTPolicy(scts []ct.SignedCertificateTimestamp, ll *loglist.LogList, certLifetime time.Duration) error {
+	names := ll.OperatorIDSet()
+	m := make(LogInfoByHash)
+	policy := Policy{
+		Name:         "Chrome",
+		MinSCTs:      ChromeMinSCTs(certLifetime),
+		MinOperators: 2,
+		Operators:    make(map[[sha256.Size]byte]string),
+		Distrusted:   make(map[[sha256.Size]byte]time.Time),
+	}
+	google := make(map[[sha256.Size]byte]bool)
+	for i := range ll.Logs {
+		log := &ll.Logs[i]
+		key := sha256.Sum256(log.Key)
+		m[key] = &LogInfo{Description: log.Description, PublicKey: log.Key}
+		var ops []string
+		for _, id := range log.OperatedBy {
+			ops = append(ops, names[id])
+		}
+		if len(ops) > 0 {
+			sort.Strings(ops)
+			policy.Operators[key] = strings.Join(ops, ", ")
+		}
+		if log.DisqualifiedAt > 0 {
+			policy.Distrusted[key] = time.Unix(int64(log.DisqualifiedAt), 0)
+		}
+		google[key] = log.GoogleOperated()
+	}
+
+	result := policy.Evaluate(scts, m)
+	failures := result.Failures
+	var haveGoogle, haveOther bool
+	for _, key := range result.Logs {
+		if google[key] {
+			haveGoogle = true
+		} else {
+			haveOther = true
+		}
+	}
+	if !haveGoogle {
+		failures = append(failures, "got no SCT from a Google-operated log, need 1")
+	}
+	if !haveOther {
+		failures = append(failures, "got no SCT from a non-Google-operated log, need 1")
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	msg := strings.Join(failures, "; ")
+	if len(result.Excluded) > 0 {
+		msg += fmt.Sprintf(" (excluded %s)", strings.Join(result.Excluded, "; "))
+	}
+	return fmt.Errorf("%w for certificate lifetime of %d month(s): %s", ErrPolicyNotMet, int(certLifetime/averageMonth), msg)
+}
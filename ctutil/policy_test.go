@@ -0,0 +1,490 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist"
+	"github.com/google/certificate-transparency-go/loglist3"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	leaf := testLeaf(1)
+	logs := make(LogInfoByHash)
+	operators := make(map[[sha256.Size]byte]string)
+	var scts []ct.SignedCertificateTimestamp
+	for _, l := range []struct {
+		uri, operator string
+	}{
+		{"https://a1.example.com", "A"},
+		{"https://a2.example.com", "A"},
+		{"https://b1.example.com", "B"},
+		{"https://legacy.example.com", "Legacy"},
+	} {
+		fl := newFakeLog(t, l.uri)
+		key := sha256.Sum256(fl.keyDER(t))
+		logs[key] = fl.logInfo(t)
+		operators[key] = l.operator
+		scts = append(scts, fl.signSCT(t, leaf, 1000))
+	}
+	unknown := newFakeLog(t, "https://unknown.example.com").signSCT(t, leaf, 1000)
+
+	tests := []struct {
+		desc          string
+		policy        Policy
+		scts          []ct.SignedCertificateTimestamp
+		wantLogs      int
+		wantOperators []string
+		wantExcluded  int
+		wantCompliant bool
+	}{
+		{
+			desc:          "all-logs",
+			policy:        Policy{MinSCTs: 3, MinOperators: 2, Operators: operators},
+			scts:          scts,
+			wantLogs:      4,
+			wantOperators: []string{"A", "B", "Legacy"},
+			wantCompliant: true,
+		},
+		{
+			desc: "legacy-log-filtered",
+			policy: Policy{
+				MinSCTs:      3,
+				MinOperators: 3,
+				Operators:    operators,
+				Accept:       OperatorAllowList(operators, "A", "B"),
+			},
+			scts:          scts,
+			wantLogs:      3,
+			wantOperators: []string{"A", "B"},
+			wantExcluded:  1,
+		},
+		{
+			desc: "filtered-log-does-not-count",
+			policy: Policy{
+				MinSCTs:   2,
+				Operators: operators,
+				Accept:    OperatorAllowList(operators, "A", "B"),
+			},
+			scts:          []ct.SignedCertificateTimestamp{scts[0], scts[3]},
+			wantLogs:      1,
+			wantOperators: []string{"A"},
+			wantExcluded:  1,
+		},
+		{
+			desc: "description-predicate",
+			policy: Policy{
+				MinSCTs: 2,
+				Accept:  func(li *LogInfo) bool { return li.Description != "https://legacy.example.com" },
+			},
+			scts:          scts,
+			wantLogs:      3,
+			wantOperators: []string{"https://a1.example.com", "https://a2.example.com", "https://b1.example.com"},
+			wantExcluded:  1,
+			wantCompliant: true,
+		},
+		{
+			desc:          "unknown-and-duplicate",
+			policy:        Policy{MinSCTs: 2, Operators: operators},
+			scts:          []ct.SignedCertificateTimestamp{scts[0], scts[0], unknown},
+			wantLogs:      1,
+			wantOperators: []string{"A"},
+			wantExcluded:  2,
+		},
+		{
+			desc:          "duplicate-allowed",
+			policy:        Policy{MinSCTs: 2, Operators: operators},
+			scts:          []ct.SignedCertificateTimestamp{scts[0], scts[2], scts[0]},
+			wantLogs:      2,
+			wantOperators: []string{"A", "B"},
+			wantExcluded:  1,
+			wantCompliant: true,
+		},
+		{
+			desc:          "duplicate-rejected",
+			policy:        Policy{MinSCTs: 2, Operators: operators, RejectDuplicateLogs: true},
+			scts:          []ct.SignedCertificateTimestamp{scts[0], scts[2], scts[0]},
+			wantLogs:      2,
+			wantOperators: []string{"A", "B"},
+			wantExcluded:  1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := test.policy.Evaluate(test.scts, logs)
+			if len(got.Logs) != test.wantLogs {
+				t.Errorf("Evaluate().Logs=%x; want %d entries", got.Logs, test.wantLogs)
+			}
+			if len(got.Operators) != len(test.wantOperators) {
+				t.Errorf("Evaluate().Operators=%v; want %v", got.Operators, test.wantOperators)
+			} else {
+				for i, op := range got.Operators {
+					if op != test.wantOperators[i] {
+						t.Errorf("Evaluate().Operators=%v; want %v", got.Operators, test.wantOperators)
+						break
+					}
+				}
+			}
+			if len(got.Excluded) != test.wantExcluded {
+				t.Errorf("Evaluate().Excluded=%v; want %d entries", got.Excluded, test.wantExcluded)
+			}
+			if got.Compliant() != test.wantCompliant {
+				t.Errorf("Evaluate().Compliant()=%v (failures: %v); want %v", got.Compliant(), got.Failures, test.wantCompliant)
+			}
+		})
+	}
+}
+
+func TestDistinctLogCount(t *testing.T) {
+	leaf := testLeaf(1)
+	a := newFakeLog(t, "https://a.example.com")
+	b := newFakeLog(t, "https://b.example.com")
+	a1, a2 := a.signSCT(t, leaf, 1000), a.signSCT(t, leaf, 2000)
+	b1 := b.signSCT(t, leaf, 1000)
+
+	for _, test := range []struct {
+		desc string
+		scts []ct.SignedCertificateTimestamp
+		want int
+	}{
+		{desc: "none", want: 0},
+		{desc: "distinct", scts: []ct.SignedCertificateTimestamp{a1, b1}, want: 2},
+		{desc: "same-log", scts: []ct.SignedCertificateTimestamp{a1, a2}, want: 1},
+		{desc: "mixed", scts: []ct.SignedCertificateTimestamp{a1, b1, a2}, want: 2},
+	} {
+		if got := DistinctLogCount(test.scts); got != test.want {
+			t.Errorf("%s: DistinctLogCount()=%d; want %d", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestSCTPredatesDistrust(t *testing.T) {
+	distrust := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	ms := func(when time.Time) uint64 {
+		return uint64(when.UnixNano() / int64(time.Millisecond))
+	}
+	tests := []struct {
+		desc     string
+		when     time.Time
+		distrust time.Time
+		want     bool
+	}{
+		{desc: "before", when: distrust.Add(-time.Millisecond), distrust: distrust, want: true},
+		{desc: "at", when: distrust, distrust: distrust},
+		{desc: "after", when: distrust.Add(time.Hour), distrust: distrust},
+		{desc: "not-distrusted", when: distrust.Add(time.Hour), want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			sct := ct.SignedCertificateTimestamp{Timestamp: ms(test.when)}
+			if got := SCTPredatesDistrust(sct, test.distrust); got != test.want {
+				t.Errorf("SCTPredatesDistrust(%v, %v)=%v; want %v", test.when, test.distrust, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPolicyEvaluateDistrusted(t *testing.T) {
+	distrust := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	before := uint64(distrust.Add(-24*time.Hour).UnixNano() / int64(time.Millisecond))
+	after := uint64(distrust.Add(24*time.Hour).UnixNano() / int64(time.Millisecond))
+
+	leaf := testLeaf(1)
+	retired := newFakeLog(t, "https://retired.example.com")
+	usable := newFakeLog(t, "https://usable.example.com")
+	ll := loglist3.LogList{
+		Operators: []*loglist3.Operator{
+			{
+				Name: "Operator",
+				Logs: []*loglist3.Log{
+					{
+						Description: "retired",
+						Key:         retired.keyDER(t),
+						State:       &loglist3.LogStates{Retired: &loglist3.LogState{Timestamp: distrust}},
+					},
+					{
+						Description: "usable",
+						Key:         usable.keyDER(t),
+						State:       &loglist3.LogStates{Usable: &loglist3.LogState{Timestamp: distrust}},
+					},
+				},
+			},
+		},
+	}
+	logs := LogInfoByHash{
+		sha256.Sum256(retired.keyDER(t)): retired.logInfo(t),
+		sha256.Sum256(usable.keyDER(t)):  usable.logInfo(t),
+	}
+	dates := DistrustDates(&ll)
+	if len(dates) != 1 {
+		t.Fatalf("DistrustDates()=%v; want 1 entry", dates)
+	}
+	policy := Policy{MinSCTs: 2, Distrusted: dates}
+
+	tests := []struct {
+		desc          string
+		timestamp     uint64
+		wantCompliant bool
+	}{
+		{desc: "before-distrust", timestamp: before, wantCompliant: true},
+		{desc: "after-distrust", timestamp: after},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			scts := []ct.SignedCertificateTimestamp{
+				retired.signSCT(t, leaf, test.timestamp),
+				usable.signSCT(t, leaf, test.timestamp),
+			}
+			got := policy.Evaluate(scts, logs)
+			if got.Compliant() != test.wantCompliant {
+				t.Errorf("Evaluate().Compliant()=%v (excluded: %v); want %v", got.Compliant(), got.Excluded, test.wantCompliant)
+			}
+		})
+	}
+}
+
+func TestPolicyEvaluateCertTemporalShards(t *testing.T) {
+	year := func(y int) time.Time { return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC) }
+	leaf := testLeaf(1)
+	shard2020 := newFakeLog(t, "https://shard2020.example.com")
+	shard2021 := newFakeLog(t, "https://shard2021.example.com")
+	unsharded := newFakeLog(t, "https://unsharded.example.com")
+	ll := loglist3.LogList{
+		Operators: []*loglist3.Operator{
+			{
+				Name: "Operator",
+				Logs: []*loglist3.Log{
+					{Description: "2020", Key: shard2020.keyDER(t), TemporalInterval: &loglist3.TemporalInterval{StartInclusive: year(2020), EndExclusive: year(2021)}},
+					{Description: "2021", Key: shard2021.keyDER(t), TemporalInterval: &loglist3.TemporalInterval{StartInclusive: year(2021), EndExclusive: year(2022)}},
+					{Description: "unsharded", Key: unsharded.keyDER(t)},
+				},
+			},
+		},
+	}
+	logs := make(LogInfoByHash)
+	for _, fl := range []*fakeLog{shard2020, shard2021, unsharded} {
+		logs[sha256.Sum256(fl.keyDER(t))] = fl.logInfo(t)
+	}
+	intervals := TemporalIntervals(&ll)
+	if len(intervals) != 2 {
+		t.Fatalf("TemporalIntervals()=%v; want 2 entries", intervals)
+	}
+	policy := Policy{MinSCTs: 2, TemporalIntervals: intervals}
+
+	tests := []struct {
+		desc          string
+		notAfter      time.Time
+		scts          []*fakeLog
+		wantLogs      int
+		wantCompliant bool
+	}{
+		{desc: "matching-shard", notAfter: year(2020).Add(time.Hour), scts: []*fakeLog{shard2020, unsharded}, wantLogs: 2, wantCompliant: true},
+		{desc: "wrong-shard", notAfter: year(2020).Add(time.Hour), scts: []*fakeLog{shard2021, unsharded}, wantLogs: 1},
+		{desc: "end-exclusive", notAfter: year(2021), scts: []*fakeLog{shard2020, shard2021}, wantLogs: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var scts []ct.SignedCertificateTimestamp
+			for _, fl := range test.scts {
+				scts = append(scts, fl.signSCT(t, leaf, 1000))
+			}
+			cert := &x509.Certificate{NotAfter: test.notAfter}
+			got := policy.EvaluateCert(cert, scts, logs)
+			if len(got.Logs) != test.wantLogs {
+				t.Errorf("EvaluateCert().Logs=%x (excluded: %v); want %d entries", got.Logs, got.Excluded, test.wantLogs)
+			}
+			if got.Compliant() != test.wantCompliant {
+				t.Errorf("EvaluateCert().Compliant()=%v; want %v", got.Compliant(), test.wantCompliant)
+			}
+			// Without a certificate, shards cannot be checked.
+			if got := policy.Evaluate(scts, logs); len(got.Logs) != len(test.scts) {
+				t.Errorf("Evaluate().Logs=%x; want %d entries", got.Logs, len(test.scts))
+			}
+		})
+	}
+}
+
+func TestComplianceSummary(t *testing.T) {
+	leaf := testLeaf(1)
+	logs := make(LogInfoByHash)
+	operators := make(map[[sha256.Size]byte]string)
+	var scts []ct.SignedCertificateTimestamp
+	for _, l := range []struct {
+		uri, operator string
+	}{
+		{"https://a1.example.com", "A"},
+		{"https://a2.example.com", "A"},
+		{"https://b1.example.com", "B"},
+	} {
+		fl := newFakeLog(t, l.uri)
+		key := sha256.Sum256(fl.keyDER(t))
+		logs[key] = fl.logInfo(t)
+		operators[key] = l.operator
+		scts = append(scts, fl.signSCT(t, leaf, 1000))
+	}
+	unknown := newFakeLog(t, "https://unknown.example.com").signSCT(t, leaf, 1000)
+	cert := &x509.Certificate{NotAfter: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}
+	policy := Policy{Name: "Chrome", MinSCTs: 3, MinOperators: 2, Operators: operators}
+
+	tests := []struct {
+		desc   string
+		policy Policy
+		scts   []ct.SignedCertificateTimestamp
+		want   ComplianceBadge
+		str    string
+	}{
+		{
+			desc:   "pass",
+			policy: policy,
+			scts:   scts,
+			want:   ComplianceBadge{Policy: "Chrome", SCTs: 3, Operators: 2, Pass: true},
+			str:    "CT: 3 SCTs, 2 operators, PASS (Chrome)",
+		},
+		{
+			desc:   "fail-with-excluded",
+			policy: policy,
+			scts:   []ct.SignedCertificateTimestamp{scts[0], scts[1], unknown},
+			want:   ComplianceBadge{Policy: "Chrome", SCTs: 2, Excluded: 1, Operators: 1},
+			str:    "CT: 2 SCTs (1 excluded), 1 operator, FAIL (Chrome)",
+		},
+		{
+			desc:   "unnamed-policy",
+			policy: Policy{MinSCTs: 1},
+			scts:   scts[:1],
+			want:   ComplianceBadge{SCTs: 1, Operators: 1, Pass: true},
+			str:    "CT: 1 SCT, 1 operator, PASS",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := Compliance(cert, test.scts, logs, test.policy); got != test.want {
+				t.Errorf("Compliance()=%+v; want %+v", got, test.want)
+			}
+			if got := ComplianceSummary(cert, test.scts, logs, test.policy); got != test.str {
+				t.Errorf("ComplianceSummary()=%q; want %q", got, test.str)
+			}
+		})
+	}
+}
+
+func TestChromeMinSCTs(t *testing.T) {
+	for _, test := range []struct {
+		months float64
+		want   int
+	}{
+		{months: 3, want: 2},
+		{months: 14.9, want: 2},
+		{months: 15, want: 3},
+		{months: 27.5, want: 3},
+		{months: 28, want: 4},
+		{months: 39.5, want: 4},
+		{months: 40, want: 5},
+	} {
+		lifetime := time.Duration(test.months * float64(averageMonth))
+		if got := ChromeMinSCTs(lifetime); got != test.want {
+			t.Errorf("ChromeMinSCTs(%.1f months)=%d; want %d", test.months, got, test.want)
+		}
+	}
+}
+
+func TestCheckSCTPolicy(t *testing.T) {
+	leaf := testLeaf(1)
+	now := time.Now()
+	ll := &loglist.LogList{Operators: []loglist.Operator{{ID: 0, Name: "Google"}, {ID: 1, Name: "Other"}, {ID: 2, Name: "Gone"}}}
+	sct := make(map[string]ct.SignedCertificateTimestamp)
+	for _, l := range []struct {
+		name         string
+		operator     int
+		disqualified time.Time
+	}{
+		{name: "Google 'Argon'", operator: 0},
+		{name: "Google 'Xenon'", operator: 0},
+		{name: "Other Log", operator: 1},
+		{name: "Gone Log", operator: 2, disqualified: now.Add(-24 * time.Hour)},
+	} {
+		fl := newFakeLog(t, "https://"+l.name)
+		log := loglist.Log{Description: l.name, Key: fl.keyDER(t), OperatedBy: []int{l.operator}}
+		if !l.disqualified.IsZero() {
+			log.DisqualifiedAt = int(l.disqualified.Unix())
+		}
+		ll.Logs = append(ll.Logs, log)
+		sct[l.name] = fl.signSCT(t, leaf, uint64(now.UnixNano()/int64(time.Millisecond)))
+	}
+	sct["unknown"] = newFakeLog(t, "https://unknown.example.com").signSCT(t, leaf, 1)
+
+	year := 12 * averageMonth
+	tests := []struct {
+		desc     string
+		scts     []string
+		lifetime time.Duration
+		wantErrs []string
+	}{
+		{desc: "compliant", scts: []string{"Google 'Argon'", "Other Log"}, lifetime: year},
+		{desc: "unknown-ignored", scts: []string{"unknown", "Google 'Argon'", "Other Log"}, lifetime: year},
+		{
+			desc:     "one-operator",
+			scts:     []string{"Google 'Argon'", "Google 'Xenon'"},
+			lifetime: year,
+			wantErrs: []string{"1 distinct operator(s), need 2", "no SCT from a non-Google-operated log"},
+		},
+		{
+			desc:     "long-lived",
+			scts:     []string{"Google 'Argon'", "Google 'Xenon'", "Other Log"},
+			lifetime: 3 * year,
+			wantErrs: []string{"lifetime of 36 month(s)", "3 distinct log(s), need 4"},
+		},
+		{
+			desc:     "disqualified",
+			scts:     []string{"Google 'Argon'", "Gone Log"},
+			lifetime: year,
+			wantErrs: []string{"1 distinct log(s), need 2", "no SCT from a non-Google-operated log", "distrusted"},
+		},
+		{
+			desc:     "none",
+			lifetime: year,
+			wantErrs: []string{"0 distinct log(s), need 2", "no SCT from a Google-operated log"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var scts []ct.SignedCertificateTimestamp
+			for _, name := range test.scts {
+				scts = append(scts, sct[name])
+			}
+			err := CheckSCTPolicy(scts, ll, test.lifetime)
+			if len(test.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("CheckSCTPolicy()=%v; want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrPolicyNotMet) {
+				t.Fatalf("CheckSCTPolicy()=%v; want error wrapping ErrPolicyNotMet", err)
+			}
+			for _, want := range test.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("CheckSCTPolicy()=%v; want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// ErrCertRevoked is wrapped by the error returned by VerifyChainNotRevoked
+// when a certificate in the chain is listed in its issuer's CRL.
+var ErrCertRevoked = errors.New("certificate revoked")
+
+// maxCRLSize bounds the size of a CRL that VerifyChainNotRevoked will download.
+const maxCRLSize = 64 << 20
+
+// VerifyChainNotRevoked checks that none of the certificates in the chain has
+// been revoked, e.g. before submitting the chain to a log.  For each
+// certificate that has CRL distribution points, the CRL from each is
+// downloaded using the given client (or http.DefaultClient if nil), and the
+// certificate's serial number looked up in it; each CRL is only downloaded once
+// per call.  The CRL's signature is checked against the key of the
+// certificate's issuer, which must be at the next position in the chain, and
+// the CRL must not be past its next update time.
+//
+// If a certificate is revoked, the error identifies its position in the chain
+// and wraps ErrCertRevoked.  Failure to retrieve or check a CRL (including
+// for want of the certificate's issuer) is also an error, as the certificate's
+// status is then unknown.
+func VerifyChainNotRevoked(ctx context.Context, chain []*x509.Certificate, hc *http.Client) error {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	crls := make(map[string]*pkix.CertificateList)
+	now := time.Now()
+	for i, cert := range chain {
+		for _, url := range cert.CRLDistributionPoints {
+			if i+1 >= len(chain) {
+				return fmt.Errorf("chain[%d] (%q): no issuer in chain to check CRL at %q", i, cert.Subject.CommonName, url)
+			}
+			issuer := chain[i+1]
+			crl, ok := crls[url]
+			if !ok {
+				var err error
+				if crl, err = fetchCRL(ctx, hc, url); err != nil {
+					return fmt.Errorf("chain[%d] (%q): %v", i, cert.Subject.CommonName, err)
+				}
+				crls[url] = crl
+			}
+			if err := issuer.CheckCRLSignature(crl); err != nil {
+				return fmt.Errorf("chain[%d] (%q): CRL at %q not signed by issuer %q: %v", i, cert.Subject.CommonName, url, issuer.Subject.CommonName, err)
+			}
+			if crl.HasExpired(now) {
+				return fmt.Errorf("chain[%d] (%q): CRL at %q is stale, next update was due at %v", i, cert.Subject.CommonName, url, crl.TBSCertList.NextUpdate)
+			}
+			for _, revoked := range crl.TBSCertList.RevokedCertificates {
+				if revoked.SerialNumber != nil && revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return fmt.Errorf("%w: chain[%d] (%q, serial %v) listed in CRL at %q as revoked at %v", ErrCertRevoked, i, cert.Subject.CommonName, cert.SerialNumber, url, revoked.RevocationTime)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fetchCRL downloads and parses the CRL at the given URL.
+func fetchCRL(ctx context.Context, hc *http.Client, url string) (*pkix.CertificateList, error) {
+	rsp, err := ctxhttp.Get(ctx, hc, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CRL from %q: %v", url, err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get CRL from %q: got HTTP status %q", url, rsp.Status)
+	}
+	data, err := ioutil.ReadAll(&io.LimitedReader{R: rsp.Body, N: maxCRLSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRL from %q: %v", url, err)
+	}
+	crl, err := x509.ParseCRL(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CRL from %q: %v", url, err)
+	}
+	return crl, nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+func TestVerifyChainNotRevoked(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// crls maps request paths to the CRLs served for them.
+	var mu sync.Mutex
+	crls := make(map[string][]byte)
+	fetches := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches[r.URL.Path]++
+		crl, ok := crls[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(crl)
+	}))
+	defer ts.Close()
+	createCRLUntil := func(path string, issuer *x509.Certificate, key *ecdsa.PrivateKey, nextUpdate time.Time, revoked ...*big.Int) {
+		t.Helper()
+		var entries []pkix.RevokedCertificate
+		for _, serial := range revoked {
+			entries = append(entries, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: now.Add(-time.Minute)})
+		}
+		crl, err := issuer.CreateCRL(rand.Reader, key, entries, now.Add(-2*time.Hour), nextUpdate)
+		if err != nil {
+			t.Fatalf("CreateCRL()=_,%v", err)
+		}
+		mu.Lock()
+		crls[path] = crl
+		mu.Unlock()
+	}
+	createCRL := func(path string, issuer *x509.Certificate, key *ecdsa.PrivateKey, revoked ...*big.Int) {
+		t.Helper()
+		createCRLUntil(path, issuer, key, now.Add(time.Hour), revoked...)
+	}
+
+	template := func(serial int64, cn, crlPath string) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(24 * time.Hour),
+		}
+		if crlPath != "" {
+			tmpl.CRLDistributionPoints = []string{ts.URL + crlPath}
+		}
+		return tmpl
+	}
+	caTemplate := func(serial int64, cn, crlPath string) *x509.Certificate {
+		tmpl := template(serial, cn, crlPath)
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		return tmpl
+	}
+	root, rootKey := issueCert(t, caTemplate(1, "Test Root", ""), nil, nil)
+	inter, interKey := issueCert(t, caTemplate(2, "Test Intermediate", "/root.crl"), root, rootKey)
+	good, _ := issueCert(t, template(3, "good.example.com", "/inter.crl"), inter, interKey)
+	bad, _ := issueCert(t, template(4, "bad.example.com", "/inter.crl"), inter, interKey)
+	noCDP, _ := issueCert(t, template(5, "no-cdp.example.com", ""), inter, interKey)
+	missing, _ := issueCert(t, template(6, "missing.example.com", "/missing.crl"), inter, interKey)
+	forged, _ := issueCert(t, template(7, "forged.example.com", "/forged.crl"), inter, interKey)
+	dupTemplate := template(8, "dup.example.com", "/inter.crl")
+	dupTemplate.CRLDistributionPoints = append(dupTemplate.CRLDistributionPoints, dupTemplate.CRLDistributionPoints...)
+	dup, _ := issueCert(t, dupTemplate, inter, interKey)
+	stale, _ := issueCert(t, template(9, "stale.example.com", "/stale.crl"), inter, interKey)
+
+	createCRL("/root.crl", root, rootKey)
+	createCRL("/inter.crl", inter, interKey, bad.SerialNumber)
+	createCRL("/forged.crl", root, rootKey)
+	createCRLUntil("/stale.crl", inter, interKey, now.Add(-time.Hour))
+
+	tests := []struct {
+		desc        string
+		chain       []*x509.Certificate
+		wantRevoked bool
+		wantErr     string
+	}{
+		{desc: "good", chain: []*x509.Certificate{good, inter, root}},
+		{desc: "no-cdp", chain: []*x509.Certificate{noCDP, inter, root}},
+		{desc: "no-cdp-leaf-only", chain: []*x509.Certificate{noCDP}},
+		{desc: "leaf-only", chain: []*x509.Certificate{good}, wantErr: "no issuer in chain"},
+		{desc: "stale-crl", chain: []*x509.Certificate{stale, inter, root}, wantErr: "stale"},
+		{desc: "revoked", chain: []*x509.Certificate{bad, inter, root}, wantRevoked: true, wantErr: "chain[0]"},
+		{desc: "missing-crl", chain: []*x509.Certificate{missing, inter, root}, wantErr: "404"},
+		{desc: "crl-from-wrong-issuer", chain: []*x509.Certificate{forged, inter, root}, wantErr: "not signed by issuer"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := VerifyChainNotRevoked(ctx, test.chain, ts.Client())
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifyChainNotRevoked()=%v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("VerifyChainNotRevoked()=%v; want error containing %q", err, test.wantErr)
+			}
+			if gotRevoked := errors.Is(err, ErrCertRevoked); gotRevoked != test.wantRevoked {
+				t.Errorf("VerifyChainNotRevoked()=%v; want error wrapping ErrCertRevoked: %t", err, test.wantRevoked)
+			}
+		})
+	}
+
+	// Each CRL is only fetched once per call.
+	mu.Lock()
+	fetches = make(map[string]int)
+	mu.Unlock()
+	if err := VerifyChainNotRevoked(ctx, []*x509.Certificate{dup, inter, root}, ts.Client()); err != nil {
+		t.Fatalf("VerifyChainNotRevoked(repeated CRL)=%v; want nil", err)
+	}
+	mu.Lock()
+	if got := fetches["/inter.crl"]; got != 1 {
+		t.Errorf("VerifyChainNotRevoked(repeated CRL) fetched CRL %d times; want 1", got)
+	}
+	mu.Unlock()
+
+	// A revoked intermediate is identified by its position in the chain.
+	createCRL("/root.crl", root, rootKey, inter.SerialNumber)
+	err := VerifyChainNotRevoked(ctx, []*x509.Certificate{good, inter, root}, ts.Client())
+	if !errors.Is(err, ErrCertRevoked) || !strings.Contains(err.Error(), "chain[1]") {
+		t.Errorf("VerifyChainNotRevoked(revoked intermediate)=%v; want error for chain[1] wrapping ErrCertRevoked", err)
+	}
+}
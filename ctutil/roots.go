@@ -0,0 +1,143 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+)
+
+// RootsCache is a cache of the accepted roots of logs, keyed by log URL, which
+// can be shared between the LogInfo objects of many logs (e.g. the temporal
+// shards of an operator's logs) to avoid repeated get-roots requests.  Cached
+// roots expire after the cache's TTL; failed fetches are not cached.
+// Concurrent fetches of the roots for the same URL are collapsed into one.
+// It is safe for concurrent use.
+type RootsCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*rootsEntry
+}
+
+// rootsEntry holds the result of a get-roots fetch, which is only available
+// once done is closed.
+type rootsEntry struct {
+	done    chan struct{}
+	roots   []ct.ASN1Cert
+	err     error
+	fetched time.Time
+	// abandoned indicates that the fetch failed once the context of the
+	// caller that made it was done, so its error says nothing about the log.
+	abandoned bool
+}
+
+// NewRootsCache creates an empty RootsCache whose entries expire after the
+// given TTL.
+func NewRootsCache(ttl time.Duration) *RootsCache {
+	return &RootsCache{ttl: ttl, now: time.Now, entries: make(map[string]*rootsEntry)}
+}
+
+// Get returns the cached roots for the log URL, calling fetch to get them if
+// there are none, or if they have expired.  If a fetch for the URL is already
+// in progress, Get waits for its result rather than starting another; if that
+// fetch fails because the context of the caller that started it is done, Get
+// makes its own fetch instead.  The returned slice is shared, so must not be
+// modified.
+func (c *RootsCache) Get(ctx context.Context, uri string, fetch func(context.Context) ([]ct.ASN1Cert, error)) ([]ct.ASN1Cert, error) {
+	for {
+		c.mu.Lock()
+		e, ok := c.entries[uri]
+		if ok {
+			select {
+			case <-e.done:
+				if e.err != nil || c.now().Sub(e.fetched) >= c.ttl {
+					ok = false
+				}
+			default:
+			}
+		}
+		if !ok {
+			e = &rootsEntry{done: make(chan struct{})}
+			c.entries[uri] = e
+			c.mu.Unlock()
+			e.roots, e.err = fetch(ctx)
+			e.fetched = c.now()
+			e.abandoned = e.err != nil && ctx.Err() != nil
+			close(e.done)
+			if e.err != nil {
+				c.mu.Lock()
+				if c.entries[uri] == e {
+					delete(c.entries, uri)
+				}
+				c.mu.Unlock()
+			}
+			return e.roots, e.err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-e.done:
+			if e.abandoned && ctx.Err() == nil {
+				continue
+			}
+			return e.roots, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Invalidate drops any cached roots for the log URL.
+func (c *RootsCache) Invalidate(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, uri)
+}
+
+// AcceptedRoots returns the roots accepted by the log, using the log's
+// RootsCache if it has one.  Returns an error if the log's client cannot
+// retrieve roots (i.e. does not implement client.AddLogClient).
+func (li *LogInfo) AcceptedRoots(ctx context.Context) ([]ct.ASN1Cert, error) {
+	lc, ok := li.Client.(client.AddLogClient)
+	if !ok {
+		return nil, fmt.Errorf("client for %q log cannot get accepted roots", li.Description)
+	}
+	fetch := func(ctx context.Context) ([]ct.ASN1Cert, error) {
+		roots, err := lc.GetAcceptedRoots(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get accepted roots from %q log: %v", li.Description, err)
+		}
+		return roots, nil
+	}
+	if li.RootsCache == nil {
+		return fetch(ctx)
+	}
+	return li.RootsCache.Get(ctx, li.Client.BaseURI(), fetch)
+}
+
+// SetRootsCache sets the RootsCache used by all of the logs in the map, so
+// that logs sharing a URL share their roots; a nil cache disables caching.
+func (m LogInfoByHash) SetRootsCache(c *RootsCache) {
+	for _, li := range m {
+		li.RootsCache = c
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// rootsLog is a fake log that counts its get-roots requests, optionally
+// blocking them until release is closed.
+type rootsLog struct {
+	*submitLog
+	calls   int32
+	called  chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func newRootsLog(t *testing.T, uri string) *rootsLog {
+	return &rootsLog{
+		submitLog: &submitLog{fakeLog: newFakeLog(t, uri), t: t},
+		called:    make(chan struct{}, 100),
+	}
+}
+
+func (r *rootsLog) GetAcceptedRoots(ctx context.Context) ([]ct.ASN1Cert, error) {
+	atomic.AddInt32(&r.calls, 1)
+	r.called <- struct{}{}
+	if r.release != nil {
+		<-r.release
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return []ct.ASN1Cert{{Data: []byte("root")}}, nil
+}
+
+func TestAcceptedRootsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	rl := newRootsLog(t, "https://log.example.com")
+	rl.release = make(chan struct{})
+	// Two shards of the same log share the cache.
+	m := LogInfoByHash{{1}: rl.logInfo(t), {2}: rl.logInfo(t)}
+	m[[32]byte{1}].Client, m[[32]byte{2}].Client = rl, rl
+	m.SetRootsCache(NewRootsCache(time.Hour))
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	get := func(li *LogInfo) {
+		defer wg.Done()
+		roots, err := li.AcceptedRoots(ctx)
+		if err == nil && len(roots) != 1 {
+			err = errors.New("wrong number of roots")
+		}
+		errs <- err
+	}
+	wg.Add(1)
+	go get(m[[32]byte{1}])
+	<-rl.called
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go get(m[[32]byte{byte(i%2 + 1)}])
+	}
+	close(rl.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("AcceptedRoots()=_,%v; want nil", err)
+		}
+	}
+	if got := atomic.LoadInt32(&rl.calls); got != 1 {
+		t.Errorf("GetAcceptedRoots() called %d times; want 1", got)
+	}
+}
+
+func TestRootsCacheLeaderCancelled(t *testing.T) {
+	c := NewRootsCache(time.Hour)
+	var calls int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) ([]ct.ASN1Cert, error) {
+		atomic.AddInt32(&calls, 1)
+		started <- struct{}{}
+		select {
+		case <-release:
+			return []ct.ASN1Cert{{Data: []byte("root")}}, nil
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to get roots: %v", ctx.Err())
+		}
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.Get(leaderCtx, "https://log.example.com", fetch)
+		leaderErr <- err
+	}()
+	<-started
+	type result struct {
+		roots []ct.ASN1Cert
+		err   error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		roots, err := c.Get(context.Background(), "https://log.example.com", fetch)
+		waiter <- result{roots, err}
+	}()
+	// Give the waiter time to start waiting for the leader's fetch.
+	time.Sleep(10 * time.Millisecond)
+
+	// The leader gives up, but the waiter's context is still live, so it
+	// fetches the roots itself rather than sharing the leader's error.
+	cancel()
+	if err := <-leaderErr; err == nil {
+		t.Error("Get(cancelled)=_,nil; want _,non-nil")
+	}
+	select {
+	case <-started:
+	case got := <-waiter:
+		t.Fatalf("Get(waiter)=%v,%v without fetching; want own fetch", got.roots, got.err)
+	}
+	close(release)
+	if got := <-waiter; got.err != nil || len(got.roots) != 1 {
+		t.Errorf("Get(waiter)=%v,%v; want 1 root,nil", got.roots, got.err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("fetch called %d times; want 2", got)
+	}
+}
+
+func TestRootsCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	rl := newRootsLog(t, "https://log.example.com")
+	li := rl.logInfo(t)
+	li.Client = rl
+	now := time.Now()
+	li.RootsCache = NewRootsCache(time.Minute)
+	li.RootsCache.now = func() time.Time { return now }
+
+	tests := []struct {
+		desc      string
+		advance   time.Duration
+		err       error
+		invalid   bool
+		wantCalls int32
+		wantErr   bool
+	}{
+		{desc: "first", wantCalls: 1},
+		{desc: "cached", advance: 30 * time.Second, wantCalls: 1},
+		{desc: "expired", advance: 30 * time.Second, wantCalls: 2},
+		{desc: "invalidated", invalid: true, err: errors.New("unavailable"), wantCalls: 3, wantErr: true},
+		{desc: "error not cached", wantCalls: 4},
+		{desc: "cached again", wantCalls: 4},
+	}
+	for _, test := range tests {
+		now = now.Add(test.advance)
+		rl.err = test.err
+		if test.invalid {
+			li.RootsCache.Invalidate(rl.uri)
+		}
+		_, err := li.AcceptedRoots(ctx)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: AcceptedRoots()=_,%v; want error %v", test.desc, err, test.wantErr)
+		}
+		if got := atomic.LoadInt32(&rl.calls); got != test.wantCalls {
+			t.Errorf("%s: GetAcceptedRoots() called %d times; want %d", test.desc, got, test.wantCalls)
+		}
+	}
+
+	// With caching disabled, every call fetches the roots.
+	LogInfoByHash{{1}: li}.SetRootsCache(nil)
+	for i := 0; i < 2; i++ {
+		if _, err := li.AcceptedRoots(ctx); err != nil {
+			t.Errorf("AcceptedRoots(uncached)=_,%v; want nil", err)
+		}
+	}
+	if got, want := atomic.LoadInt32(&rl.calls), int32(6); got != want {
+		t.Errorf("GetAcceptedRoots() called %d times; want %d", got, want)
+	}
+
+	// A client that cannot get roots gives an error.
+	li.Client = rl.fakeLog
+	if _, err := li.AcceptedRoots(ctx); err == nil {
+		t.Error("AcceptedRoots(no roots client)=_,nil; want error")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/scanner"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// FindCertsForDomain scans the entries in the index range [start, end) of the
+// log, returning the certificates whose subject common name or DNS subject
+// alternative names cover the given domain (as for scanner.MatchDomain, so
+// wildcards are supported).  For precertificate entries, the returned
+// certificate is the precertificate's TBSCertificate.  Certificates are
+// returned in log order.  The range is truncated to the log's current tree
+// size.  The log's client must be a *client.LogClient (or fail over between
+// them, in which case the primary is used).
+func FindCertsForDomain(ctx context.Context, li *LogInfo, domain string, start, end int64) ([]*x509.Certificate, error) {
+	lc, ok := client.Primary(li.Client).(*client.LogClient)
+	if !ok {
+		return nil, fmt.Errorf("cannot scan %q log: client of type %T does not support get-entries", li.Description, li.Client)
+	}
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("invalid entry range [%d, %d)", start, end)
+	}
+
+	opts := scanner.DefaultScannerOptions()
+	opts.StartIndex = start
+	opts.EndIndex = end
+	opts.Matcher = scanner.MatchDomain{Domain: domain}
+
+	type match struct {
+		index int64
+		cert  *x509.Certificate
+	}
+	var mu sync.Mutex
+	var matches []match
+	var parseErr error
+	found := func(rle *ct.RawLogEntry) {
+		entry, err := rle.ToLogEntry()
+		mu.Lock()
+		defer mu.Unlock()
+		if x509.IsFatal(err) {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("failed to parse entry %d: %v", rle.Index, err)
+			}
+			return
+		}
+		cert := entry.X509Cert
+		if entry.Precert != nil {
+			cert = entry.Precert.TBSCertificate
+		}
+		matches = append(matches, match{index: rle.Index, cert: cert})
+	}
+
+	if err := scanner.NewScanner(lc, *opts).Scan(ctx, found, found); err != nil {
+		return nil, fmt.Errorf("failed to scan %q log: %v", li.Description, err)
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].index < matches[j].index })
+	certs := make([]*x509.Certificate, 0, len(matches))
+	for _, m := range matches {
+		certs = append(certs, m.cert)
+	}
+	return certs, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+// serveEntries serves the get-sth and get-entries entrypoints for the fake
+// log, with the given extra data for each entry.
+func serveEntries(t *testing.T, fl *fakeLog, extra [][]byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp interface{}
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			sth, err := fl.GetSTH(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			sig, err := tls.Marshal(sth.TreeHeadSignature)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			rsp = ct.GetSTHResponse{TreeSize: sth.TreeSize, Timestamp: sth.Timestamp, SHA256RootHash: sth.SHA256RootHash[:], TreeHeadSignature: sig}
+		case "/ct/v1/get-entries":
+			start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+			end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+			entries, err := fl.GetRawEntries(r.Context(), start, end)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for i := range entries.Entries {
+				entries.Entries[i].ExtraData = extra[start+int64(i)]
+			}
+			rsp = entries
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(rsp); err != nil {
+			t.Errorf("failed to encode response: %v", err)
+		}
+	}))
+}
+
+func TestFindCertsForDomain(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	notBefore := time.Now().Add(-time.Hour)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca, caKey := issueCert(t, caTemplate, nil, nil)
+
+	emptyChain, err := tls.Marshal(ct.CertificateChain{})
+	if err != nil {
+		t.Fatalf("failed to marshal chain: %v", err)
+	}
+	var extra [][]byte
+	for i, entry := range []struct {
+		cn      string
+		sans    []string
+		precert bool
+	}{
+		{cn: "www.example.com"},
+		{cn: "other.org"},
+		{cn: "*.example.com", precert: true},
+		{cn: "deep", sans: []string{"a.b.example.com"}},
+		{cn: "shop", sans: []string{"shop.example.org", "WWW.EXAMPLE.COM"}},
+	} {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 100)),
+			Subject:      pkix.Name{CommonName: entry.cn},
+			DNSNames:     entry.sans,
+			NotBefore:    notBefore,
+			NotAfter:     notBefore.Add(time.Hour),
+		}
+		if !entry.precert {
+			cert, _ := issueCert(t, template, ca, caKey)
+			fl.addLeaf(t, ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: cert.Raw}, 0))
+			extra = append(extra, emptyChain)
+			continue
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: x509.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}}
+		precert, _ := issueCert(t, template, ca, caKey)
+		tbs, err := x509.BuildPrecertTBS(precert.RawTBSCertificate, nil)
+		if err != nil {
+			t.Fatalf("BuildPrecertTBS()=_,%v", err)
+		}
+		fl.addLeaf(t, &ct.MerkleTreeLeaf{
+			Version:  ct.V1,
+			LeafType: ct.TimestampedEntryLeafType,
+			TimestampedEntry: &ct.TimestampedEntry{
+				EntryType:    ct.PrecertLogEntryType,
+				PrecertEntry: &ct.PreCert{IssuerKeyHash: sha256.Sum256(ca.RawSubjectPublicKeyInfo), TBSCertificate: tbs},
+			},
+		})
+		data, err := tls.Marshal(ct.PrecertChainEntry{PreCertificate: ct.ASN1Cert{Data: precert.Raw}})
+		if err != nil {
+			t.Fatalf("failed to marshal precert chain: %v", err)
+		}
+		extra = append(extra, data)
+	}
+
+	server := serveEntries(t, fl, extra)
+	defer server.Close()
+	lc, err := client.New(server.URL, nil, jsonclient.Options{PublicKeyDER: fl.keyDER(t)})
+	if err != nil {
+		t.Fatalf("client.New()=_,%v", err)
+	}
+	li := fl.logInfo(t)
+	li.Client = lc
+
+	tests := []struct {
+		desc       string
+		domain     string
+		start, end int64
+		want       []string
+	}{
+		{desc: "all", domain: "www.example.com", start: 0, end: 5, want: []string{"www.example.com", "*.example.com", "shop"}},
+		{desc: "sub-range", domain: "www.example.com", start: 1, end: 4, want: []string{"*.example.com"}},
+		{desc: "beyond-tree", domain: "www.example.com", start: 3, end: 100, want: []string{"shop"}},
+		{desc: "wildcard-query", domain: "*.b.example.com", start: 0, end: 5, want: []string{"deep"}},
+		{desc: "no-match", domain: "example.net", start: 0, end: 5},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			certs, err := FindCertsForDomain(ctx, li, test.domain, test.start, test.end)
+			if err != nil {
+				t.Fatalf("FindCertsForDomain()=_,%v; want _,nil", err)
+			}
+			var got []string
+			for _, cert := range certs {
+				got = append(got, cert.Subject.CommonName)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("FindCertsForDomain()=%q; want %q", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("FindCertsForDomain()=%q; want %q", got, test.want)
+					break
+				}
+			}
+		})
+	}
+
+	if _, err := FindCertsForDomain(ctx, li, "www.example.com", 3, 3); err == nil {
+		t.Error("FindCertsForDomain(empty range)=_,nil; want _,non-nil")
+	}
+	if _, err := FindCertsForDomain(ctx, fl.logInfo(t), "www.example.com", 0, 5); err == nil {
+		t.Error("FindCertsForDomain(non-LogClient)=_,nil; want _,non-nil")
+	}
+}
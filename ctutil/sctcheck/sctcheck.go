@@ -44,6 +44,7 @@ var (
 	deadline       = flag.Duration("deadline", 30*time.Second, "Timeout deadline for HTTP requests")
 	checkInclusion = flag.Bool("check_inclusion", true, "Whether to check SCT inclusion in issuing CT log")
 	useDNS         = flag.Bool("dns", false, "Use DNS access points for inclusion checking")
+	dohResolver    = flag.String("doh_resolver", "", "If set with --dns, URL of a DNS-over-HTTPS resolver to use rather than classic DNS")
 )
 
 type logInfoFactory func(*loglist.Log, *http.Client) (*ctutil.LogInfo, error)
@@ -62,9 +63,14 @@ func main() {
 		glog.Exitf("Failed to parse log list: %v", err)
 	}
 
-	lf := ctutil.NewLogInfo
+	var lf logInfoFactory = func(log *loglist.Log, hc *http.Client) (*ctutil.LogInfo, error) {
+		return ctutil.NewLogInfo(log, hc)
+	}
 	if *useDNS {
 		lf = ctutil.NewLogInfoOverDNSWrapper
+		if *dohResolver != "" {
+			lf = ctutil.NewLogInfoOverDoHWrapper(*dohResolver)
+		}
 	}
 
 	totalInvalid := 0
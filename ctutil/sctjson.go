@@ -0,0 +1,63 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+// SCTsFromJSONArray parses a JSON array of SCTs, each in the form of an
+// add-chain response (RFC 6962 s4.1), i.e. an object with sct_version, id,
+// timestamp, extensions and signature fields, the latter three base64-encoded.
+func SCTsFromJSONArray(data []byte) ([]ct.SignedCertificateTimestamp, error) {
+	var rsps []ct.AddChainResponse
+	if err := json.Unmarshal(data, &rsps); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON SCT array: %v", err)
+	}
+	scts := make([]ct.SignedCertificateTimestamp, 0, len(rsps))
+	for i, rsp := range rsps {
+		sct, err := rsp.ToSignedCertificateTimestamp()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert SCT %d: %v", i, err)
+		}
+		scts = append(scts, *sct)
+	}
+	return scts, nil
+}
+
+// SCTsToJSONArray encodes the given SCTs as a JSON array in the form accepted
+// by SCTsFromJSONArray.
+func SCTsToJSONArray(scts []ct.SignedCertificateTimestamp) ([]byte, error) {
+	rsps := make([]ct.AddChainResponse, 0, len(scts))
+	for i, sct := range scts {
+		sig, err := tls.Marshal(sct.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to tls.Marshal signature of SCT %d: %v", i, err)
+		}
+		rsps = append(rsps, ct.AddChainResponse{
+			SCTVersion: sct.SCTVersion,
+			ID:         sct.LogID.KeyID[:],
+			Timestamp:  sct.Timestamp,
+			Extensions: base64.StdEncoding.EncodeToString(sct.Extensions),
+			Signature:  sig,
+		})
+	}
+	return json.Marshal(rsps)
+}
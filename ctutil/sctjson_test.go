@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/testdata"
+)
+
+func TestSCTsJSONArrayRoundTrip(t *testing.T) {
+	scts := []ct.SignedCertificateTimestamp{
+		mustParseSCT(t, testdata.TestCertProof),
+		mustParseSCT(t, testdata.TestPreCertProof),
+	}
+	scts[1].Extensions = ct.CTExtensions{0x01, 0x02}
+	data, err := SCTsToJSONArray(scts)
+	if err != nil {
+		t.Fatalf("SCTsToJSONArray()=_,%v; want _,nil", err)
+	}
+	got, err := SCTsFromJSONArray(data)
+	if err != nil {
+		t.Fatalf("SCTsFromJSONArray(%s)=_,%v; want _,nil", data, err)
+	}
+	if !reflect.DeepEqual(got, scts) {
+		t.Errorf("SCTsFromJSONArray(SCTsToJSONArray(scts))=%v; want %v", got, scts)
+	}
+
+	empty, err := SCTsFromJSONArray([]byte("[]"))
+	if err != nil || len(empty) != 0 {
+		t.Errorf("SCTsFromJSONArray([])=%v,%v; want [],nil", empty, err)
+	}
+}
+
+func TestSCTsFromJSONArrayErrors(t *testing.T) {
+	const (
+		id  = `"3xwuwRUAlFJHqWFoMl3cXHlZ6PfG04j8AC4LvT9012Q="`
+		sig = `"BAMARzBFAiEA4M1l6EPyz0S/gWETekpQQeTKtC/3pGFQhX4fJvbj7XcCIGOpuZvQ6b+eU8p3KlJ0vKNdWsdUD/cIwBwB56ZZoLEi"`
+	)
+	tests := []struct {
+		desc    string
+		data    string
+		wantErr string
+	}{
+		{
+			desc:    "not-array",
+			data:    `{"sct_version":0}`,
+			wantErr: "failed to parse",
+		},
+		{
+			desc:    "malformed-base64-id",
+			data:    `[{"sct_version":0,"id":"!!!","timestamp":1,"extensions":"","signature":` + sig + `}]`,
+			wantErr: "failed to parse",
+		},
+		{
+			desc:    "malformed-base64-extensions",
+			data:    `[{"sct_version":0,"id":` + id + `,"timestamp":1,"extensions":"!!!","signature":` + sig + `}]`,
+			wantErr: "SCT 0",
+		},
+		{
+			desc:    "short-id",
+			data:    `[{"sct_version":0,"id":"AAAA","timestamp":1,"extensions":"","signature":` + sig + `}]`,
+			wantErr: "id is invalid length",
+		},
+		{
+			desc:    "bad-signature",
+			data:    `[{"sct_version":0,"id":` + id + `,"timestamp":1,"extensions":"","signature":"AAAA"}]`,
+			wantErr: "SCT 0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := SCTsFromJSONArray([]byte(test.data))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("SCTsFromJSONArray()=%v,%v; want _,err containing %q", got, err, test.wantErr)
+			}
+		})
+	}
+}
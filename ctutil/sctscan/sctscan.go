@@ -38,6 +38,7 @@ var (
 	logURI        = flag.String("log_uri", "https://ct.googleapis.com/pilot", "CT log base URI")
 	logList       = flag.String("log_list", loglist.AllLogListURL, "Location of master CT log list (URL or filename)")
 	useDNS        = flag.Bool("dns", true, "Use DNS access points for inclusion checking")
+	dohResolver   = flag.String("doh_resolver", "", "If set, URL of a DNS-over-HTTPS resolver to use for DNS access points rather than classic DNS")
 	inclusion     = flag.Bool("inclusion", false, "Whether to do inclusion checking")
 	deadline      = flag.Duration("deadline", 30*time.Second, "Timeout deadline for HTTP requests")
 	batchSize     = flag.Int("batch_size", 1000, "Max number of entries to request at per call to get-entries")
@@ -78,7 +79,11 @@ func main() {
 	var logsByHash ctutil.LogInfoByHash
 	if *useDNS {
 		glog.Warning("Performing validations via DNS")
-		logsByHash, err = ctutil.LogInfoByKeyHashOverDNS(ll, hc)
+		if *dohResolver != "" {
+			logsByHash, err = ctutil.LogInfoByKeyHashOverDoH(ll, *dohResolver, hc)
+		} else {
+			logsByHash, err = ctutil.LogInfoByKeyHashOverDNS(ll, hc)
+		}
 	} else {
 		glog.Warning("Performing validations via direct log queries")
 		logsByHash, err = ctutil.LogInfoByKeyHash(ll, hc)
@@ -0,0 +1,186 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	gotls "crypto/tls"
+	gox509 "crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/crypto/ocsp"
+)
+
+// oidExtensionOCSPSCT is the OID of the OCSP single response extension that
+// holds an SCT list, from RFC 6962 s3.3.
+var oidExtensionOCSPSCT = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+
+// AllSCTs gathers the SCTs presented for a certificate from all of the
+// delivery mechanisms described in RFC 6962 s3.3: embedded in the certificate
+// itself, in a stapled OCSP response, and in the TLS signed_certificate_timestamp
+// extension.  Any of cert, ocspDER and cs may be nil, in which case that source
+// is skipped; if ocspDER is nil but cs holds a stapled OCSP response, that
+// response is used.  If issuer is non-nil, it is used to check the signature
+// on the OCSP response.
+//
+// The returned SCTs are the union of those from each of the sources, with
+// duplicates (e.g. an SCT that is both embedded and stapled) removed.
+func AllSCTs(cert, issuer *x509.Certificate, ocspDER []byte, cs *gotls.ConnectionState) ([]ct.SignedCertificateTimestamp, error) {
+	var all []ct.SignedCertificateTimestamp
+	if cert != nil {
+		scts, err := SCTsFromCertificate(cert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get SCTs from certificate: %v", err)
+		}
+		all = append(all, scts...)
+	}
+
+	if len(ocspDER) == 0 && cs != nil {
+		ocspDER = cs.OCSPResponse
+	}
+	if len(ocspDER) > 0 {
+		scts, err := sctsFromOCSPResponse(ocspDER, issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get SCTs from OCSP response: %v", err)
+		}
+		all = append(all, scts...)
+	}
+
+	if cs != nil {
+		for i, data := range cs.SignedCertificateTimestamps {
+			sct, err := parseSCT(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse SCT %d from TLS extension: %v", i, err)
+			}
+			all = append(all, *sct)
+		}
+	}
+
+	return dedupSCTs(all)
+}
+
+// dedupSCTs removes duplicate SCTs from the given list, preserving the order
+// in which SCTs first appear.
+func dedupSCTs(scts []ct.SignedCertificateTimestamp) ([]ct.SignedCertificateTimestamp, error) {
+	seen := make(map[string]bool)
+	var result []ct.SignedCertificateTimestamp
+	for _, sct := range scts {
+		data, err := tls.Marshal(sct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to tls.Marshal SCT: %v", err)
+		}
+		if seen[string(data)] {
+			continue
+		}
+		seen[string(data)] = true
+		result = append(result, sct)
+	}
+	return result, nil
+}
+
+// parseSCT decodes a single TLS-encoded SCT.
+func parseSCT(data []byte) (*ct.SignedCertificateTimestamp, error) {
+	var sct ct.SignedCertificateTimestamp
+	if rest, err := tls.Unmarshal(data, &sct); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data (%d bytes) after SCT", len(rest))
+	}
+	return &sct, nil
+}
+
+// parseSCTList decodes each of the SCTs in a TLS-encoded SCT list.
+func parseSCTList(sctList *x509.SignedCertificateTimestampList) ([]ct.SignedCertificateTimestamp, error) {
+	scts := make([]ct.SignedCertificateTimestamp, 0, len(sctList.SCTList))
+	for i, serialized := range sctList.SCTList {
+		sct, err := parseSCT(serialized.Val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SCT %d: %v", i, err)
+		}
+		scts = append(scts, *sct)
+	}
+	return scts, nil
+}
+
+// parseSCTListExtension decodes the SCTs held in the value of an X.509 or
+// OCSP SCT list extension, which is an ASN.1 OCTET STRING that wraps a
+// TLS-encoded SCT list.
+func parseSCTListExtension(value []byte) ([]ct.SignedCertificateTimestamp, error) {
+	var rawSCTList []byte
+	if rest, err := asn1.Unmarshal(value, &rawSCTList); err != nil {
+		return nil, fmt.Errorf("failed to asn1.Unmarshal SCT list extension: %v", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after ASN1-encoded SCT list")
+	}
+	var sctList x509.SignedCertificateTimestampList
+	if rest, err := tls.Unmarshal(rawSCTList, &sctList); err != nil {
+		return nil, fmt.Errorf("failed to tls.Unmarshal SCT list: %v", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after TLS-encoded SCT list")
+	}
+	return parseSCTList(&sctList)
+}
+
+// SCTsFromCertificate returns the SCTs embedded in the SCT list extension
+// (RFC 6962 s3.3) of the given certificate, ready to be checked with
+// LogInfo.VerifyChainSCTSignature.  If the certificate has no such extension,
+// an empty slice is returned; if the extension is malformed, the error
+// describes the problem.
+func SCTsFromCertificate(cert *x509.Certificate) ([]ct.SignedCertificateTimestamp, error) {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(x509.OIDExtensionCTSCT) {
+			return parseSCTListExtension(ext.Value)
+		}
+	}
+	return []ct.SignedCertificateTimestamp{}, nil
+}
+
+// SCTsFromOCSPResponse returns the SCTs held in the SCT list extension (RFC
+// 6962 s3.3) of the single response in the given DER-encoded OCSP response,
+// as stapled by a TLS server.  If the response has no such extension, an
+// empty slice is returned.  The response's signature is only checked against
+// a responder certificate that it carries, if any; use AllSCTs to check it
+// against the certificate's issuer.
+func SCTsFromOCSPResponse(der []byte) ([]ct.SignedCertificateTimestamp, error) {
+	return sctsFromOCSPResponse(der, nil)
+}
+
+// sctsFromOCSPResponse returns the SCTs held in the given DER-encoded OCSP
+// response, as for SCTsFromOCSPResponse, checking the response's signature
+// against the given issuer if it is non-nil.
+func sctsFromOCSPResponse(der []byte, issuer *x509.Certificate) ([]ct.SignedCertificateTimestamp, error) {
+	var goIssuer *gox509.Certificate
+	if issuer != nil {
+		var err error
+		goIssuer, err = gox509.ParseCertificate(issuer.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse issuer certificate: %v", err)
+		}
+	}
+	rsp, err := ocsp.ParseResponse(der, goIssuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP response: %v", err)
+	}
+	for _, ext := range rsp.Extensions {
+		if ext.Id.Equal(oidExtensionOCSPSCT) {
+			return parseSCTListExtension(ext.Value)
+		}
+	}
+	return []ct.SignedCertificateTimestamp{}, nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	gotls "crypto/tls"
+	gox509 "crypto/x509"
+	gopkix "crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/testdata"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+	"github.com/google/certificate-transparency-go/x509util"
+	"golang.org/x/crypto/ocsp"
+)
+
+func mustParseSCT(t *testing.T, data []byte) ct.SignedCertificateTimestamp {
+	t.Helper()
+	var sct ct.SignedCertificateTimestamp
+	if _, err := tls.Unmarshal(data, &sct); err != nil {
+		t.Fatalf("error tls-unmarshalling sct: %s", err)
+	}
+	return sct
+}
+
+func TestAllSCTs(t *testing.T) {
+	chain, err := x509util.CertificatesFromPEM([]byte(testdata.TestEmbeddedCertPEM + testdata.CACertPEM))
+	if err != nil {
+		t.Fatalf("error parsing certificate chain: %s", err)
+	}
+	embedded := mustParseSCT(t, testdata.TestPreCertProof)
+	other := mustParseSCT(t, testdata.TestCertProof)
+
+	tests := []struct {
+		desc string
+		cs   *gotls.ConnectionState
+		want []ct.SignedCertificateTimestamp
+	}{
+		{
+			desc: "embedded only",
+			want: []ct.SignedCertificateTimestamp{embedded},
+		},
+		{
+			desc: "embedded and TLS",
+			cs:   &gotls.ConnectionState{SignedCertificateTimestamps: [][]byte{testdata.TestCertProof}},
+			want: []ct.SignedCertificateTimestamp{embedded, other},
+		},
+		{
+			desc: "duplicate across sources",
+			cs:   &gotls.ConnectionState{SignedCertificateTimestamps: [][]byte{testdata.TestPreCertProof, testdata.TestCertProof}},
+			want: []ct.SignedCertificateTimestamp{embedded, other},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := AllSCTs(chain[0], chain[1], nil, test.cs)
+			if err != nil {
+				t.Fatalf("AllSCTs()=_,%v; want _,nil", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("AllSCTs()=%v; want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSCTsFromCertificate(t *testing.T) {
+	chain, err := x509util.CertificatesFromPEM([]byte(testdata.TestEmbeddedCertPEM + testdata.CACertPEM))
+	if err != nil {
+		t.Fatalf("error parsing certificate chain: %s", err)
+	}
+	got, err := SCTsFromCertificate(chain[0])
+	if err != nil {
+		t.Fatalf("SCTsFromCertificate(embedded)=_,%v; want _,nil", err)
+	}
+	if want := []ct.SignedCertificateTimestamp{mustParseSCT(t, testdata.TestPreCertProof)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SCTsFromCertificate(embedded)=%v; want %v", got, want)
+	}
+
+	got, err = SCTsFromCertificate(chain[1])
+	if err != nil || got == nil || len(got) != 0 {
+		t.Errorf("SCTsFromCertificate(no extension)=%v,%v; want [],nil", got, err)
+	}
+}
+
+func TestSCTsFromCertificateMalformed(t *testing.T) {
+	octets := func(data []byte) []byte {
+		t.Helper()
+		der, err := asn1.Marshal(data)
+		if err != nil {
+			t.Fatalf("asn1.Marshal()=_,%v; want _,nil", err)
+		}
+		return der
+	}
+	tests := []struct {
+		desc  string
+		value []byte
+		want  string
+	}{
+		{desc: "not octet string", value: []byte{0x02, 0x01, 0x00}, want: "failed to asn1.Unmarshal"},
+		{desc: "trailing ASN.1", value: append(octets([]byte{0x00, 0x00}), 0x00), want: "trailing data after ASN1-encoded"},
+		{desc: "truncated list", value: octets([]byte{0x00, 0x05, 0x00}), want: "failed to tls.Unmarshal"},
+		{desc: "trailing TLS", value: octets([]byte{0x00, 0x03, 0x00, 0x01, 0xff, 0x00}), want: "trailing data after TLS-encoded"},
+		{desc: "bad SCT", value: octets([]byte{0x00, 0x03, 0x00, 0x01, 0x00}), want: "failed to parse SCT 0"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cert := &x509.Certificate{Extensions: []pkix.Extension{{Id: x509.OIDExtensionCTSCT, Value: test.value}}}
+			got, err := SCTsFromCertificate(cert)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("SCTsFromCertificate()=%v,%v; want nil,err containing %q", got, err, test.want)
+			}
+		})
+	}
+}
+
+// ocspResponse builds a DER-encoded OCSP response signed by a new responder,
+// with the given single response extensions, returning it along with the
+// responder's certificate.
+func ocspResponse(t *testing.T, exts []gopkix.Extension) ([]byte, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &gox509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      gopkix.Name{CommonName: "OCSP responder"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := gox509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	responder, err := gox509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	der, err := ocsp.CreateResponse(responder, responder, ocsp.Response{
+		Status:          ocsp.Good,
+		SerialNumber:    big.NewInt(2),
+		ThisUpdate:      time.Now(),
+		ExtraExtensions: exts,
+	}, key)
+	if err != nil {
+		t.Fatalf("failed to create OCSP response: %v", err)
+	}
+	issuer, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return der, issuer
+}
+
+func TestSCTsFromOCSPResponse(t *testing.T) {
+	list, err := tls.Marshal(x509.SignedCertificateTimestampList{SCTList: []x509.SerializedSCT{{Val: testdata.TestCertProof}}})
+	if err != nil {
+		t.Fatalf("tls.Marshal()=_,%v; want _,nil", err)
+	}
+	value, err := asn1.Marshal(list)
+	if err != nil {
+		t.Fatalf("asn1.Marshal()=_,%v; want _,nil", err)
+	}
+	der, issuer := ocspResponse(t, []gopkix.Extension{{Id: oidExtensionOCSPSCT, Value: value}})
+	want := []ct.SignedCertificateTimestamp{mustParseSCT(t, testdata.TestCertProof)}
+	got, err := SCTsFromOCSPResponse(der)
+	if err != nil {
+		t.Fatalf("SCTsFromOCSPResponse()=_,%v; want _,nil", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SCTsFromOCSPResponse()=%v; want %v", got, want)
+	}
+	if got, err := AllSCTs(nil, issuer, der, nil); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("AllSCTs(OCSP only)=%v,%v; want %v,nil", got, err, want)
+	}
+
+	der, _ = ocspResponse(t, nil)
+	got, err = SCTsFromOCSPResponse(der)
+	if err != nil || got == nil || len(got) != 0 {
+		t.Errorf("SCTsFromOCSPResponse(no extension)=%v,%v; want [],nil", got, err)
+	}
+
+	der, _ = ocspResponse(t, []gopkix.Extension{{Id: oidExtensionOCSPSCT, Value: []byte{0x02, 0x01, 0x00}}})
+	if _, err := SCTsFromOCSPResponse(der); err == nil {
+		t.Error("SCTsFromOCSPResponse(malformed extension)=_,nil; want _,non-nil")
+	}
+	if _, err := SCTsFromOCSPResponse([]byte{0x30, 0x00}); err == nil {
+		t.Error("SCTsFromOCSPResponse(malformed response)=_,nil; want _,non-nil")
+	}
+}
+
+func TestAllSCTsNoSources(t *testing.T) {
+	got, err := AllSCTs(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("AllSCTs(nil...)=_,%v; want _,nil", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("AllSCTs(nil...)=%v; want empty", got)
+	}
+}
+
+func TestAllSCTsMalformedTLS(t *testing.T) {
+	cs := &gotls.ConnectionState{SignedCertificateTimestamps: [][]byte{{0x00, 0x01}}}
+	if _, err := AllSCTs(nil, nil, nil, cs); err == nil {
+		t.Error("AllSCTs(malformed)=_,nil; want _,non-nil")
+	}
+}
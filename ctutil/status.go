@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LogStatus summarizes the health of a log at a point in time.
+type LogStatus struct {
+	Description string    `json:"description"`
+	CheckedAt   time.Time `json:"checked_at"`
+	// Reachable indicates whether the log returned an STH.
+	Reachable bool `json:"reachable"`
+	// TreeSize and Timestamp describe the STH returned by the log, if any.
+	TreeSize  uint64    `json:"tree_size"`
+	Timestamp time.Time `json:"timestamp"`
+	// SignatureValid indicates whether the STH's signature verified.
+	SignatureValid bool `json:"signature_valid"`
+	// Age is the time since the STH was issued; the STH is Stale if this
+	// exceeds the log's MMD.
+	Age   time.Duration `json:"age"`
+	Stale bool          `json:"stale"`
+	// Error describes the last failure encountered, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Healthy indicates whether the log was reachable and returned a fresh STH
+// with a valid signature.
+func (s *LogStatus) Healthy() bool {
+	return s.Reachable && s.SignatureValid && !s.Stale && s.Error == ""
+}
+
+// Status retrieves the log's current STH and summarizes the log's health.
+// Failures are recorded in the returned LogStatus rather than returned as
+// errors.  If the STH's signature verifies, it is recorded as the log's last
+// known STH.
+func (li *LogInfo) Status(ctx context.Context) *LogStatus {
+	status := &LogStatus{Description: li.Description, CheckedAt: time.Now()}
+	sth, err := li.Client.GetSTH(ctx)
+	if err != nil {
+		status.Error = fmt.Sprintf("failed to get current STH: %v", err)
+		return status
+	}
+	status.Reachable = true
+	status.TreeSize = sth.TreeSize
+	status.Timestamp = time.Unix(0, int64(sth.Timestamp)*int64(time.Millisecond))
+	status.Age = status.CheckedAt.Sub(status.Timestamp)
+	status.Stale = status.Age > li.MMD
+
+	if err := li.VerifySTHSignature(*sth); err != nil {
+		status.Error = fmt.Sprintf("failed to verify STH signature: %v", err)
+		return status
+	}
+	status.SignatureValid = true
+	li.SetSTH(sth)
+	if status.Stale {
+		status.Error = fmt.Sprintf("STH is %v old, exceeding MMD %v", status.Age.Round(time.Second), li.MMD)
+	}
+	return status
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLogInfoStatus(t *testing.T) {
+	ctx := context.Background()
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	tests := []struct {
+		desc      string
+		timestamp uint64
+		sthErr    error
+		want      LogStatus
+		healthy   bool
+	}{
+		{
+			desc:      "healthy",
+			timestamp: now,
+			want:      LogStatus{Reachable: true, TreeSize: 5, SignatureValid: true},
+			healthy:   true,
+		},
+		{
+			desc:      "stale",
+			timestamp: now - uint64(48*time.Hour/time.Millisecond),
+			want:      LogStatus{Reachable: true, TreeSize: 5, SignatureValid: true, Stale: true},
+		},
+		{
+			desc:   "unreachable",
+			sthErr: errors.New("connection refused"),
+			want:   LogStatus{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			fl := newFakeLog(t, "https://log.example.com")
+			fl.addLeaves(t, 5)
+			fl.timestamp = test.timestamp
+			fl.sthErr = test.sthErr
+			li := fl.logInfo(t)
+
+			got := li.Status(ctx)
+			if got.Description != li.Description {
+				t.Errorf("Status().Description=%q; want %q", got.Description, li.Description)
+			}
+			if got.Reachable != test.want.Reachable || got.TreeSize != test.want.TreeSize || got.SignatureValid != test.want.SignatureValid || got.Stale != test.want.Stale {
+				t.Errorf("Status()=%+v; want %+v", got, test.want)
+			}
+			if got.Healthy() != test.healthy {
+				t.Errorf("Status().Healthy()=%v; want %v (status %+v)", got.Healthy(), test.healthy, got)
+			}
+			if gotErr := got.Error != ""; gotErr == test.healthy {
+				t.Errorf("Status().Error=%q; want error %v", got.Error, !test.healthy)
+			}
+			if got.SignatureValid && li.LastSTH() == nil {
+				t.Error("LastSTH()=nil after valid status; want recorded STH")
+			}
+		})
+	}
+}
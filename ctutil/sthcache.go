@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// STHCache holds the last known STH of logs, keyed by the SHA-256 hash of
+// their public key, so that STHs can outlive the LogInfo objects that fetched
+// them (e.g. across rebuilds of a LogInfoByHash map each monitoring cycle) and
+// be shared between them.  Implementations must be safe for concurrent use.
+type STHCache interface {
+	// Get returns the cached STH for the log, or nil if there is none.
+	Get(keyHash [sha256.Size]byte) *ct.SignedTreeHead
+	// Put records an STH for the log.
+	Put(keyHash [sha256.Size]byte, sth *ct.SignedTreeHead)
+}
+
+// MemorySTHCache is an in-memory STHCache.  Put only replaces a log's cached
+// STH with one that has a later timestamp, so that an STH that is fetched
+// concurrently with a newer one cannot overwrite it.
+type MemorySTHCache struct {
+	mu   sync.RWMutex
+	sths map[[sha256.Size]byte]*ct.SignedTreeHead
+}
+
+// NewMemorySTHCache creates an empty MemorySTHCache.
+func NewMemorySTHCache() *MemorySTHCache {
+	return &MemorySTHCache{sths: make(map[[sha256.Size]byte]*ct.SignedTreeHead)}
+}
+
+// Get returns the cached STH for the log, or nil if there is none.
+func (c *MemorySTHCache) Get(keyHash [sha256.Size]byte) *ct.SignedTreeHead {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sths[keyHash]
+}
+
+// Put records an STH for the log, unless the cached STH is newer.
+func (c *MemorySTHCache) Put(keyHash [sha256.Size]byte, sth *ct.SignedTreeHead) {
+	if sth == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cur := c.sths[keyHash]; cur != nil && cur.Timestamp > sth.Timestamp {
+		return
+	}
+	c.sths[keyHash] = sth
+}
+
+// SetSTHCache sets the STHCache used by all of the logs in the map, as for
+// WithSTHCache; a nil cache means each log only keeps its own last known STH.
+func (m LogInfoByHash) SetSTHCache(c STHCache) {
+	for _, li := range m {
+		li.sthCache = c
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist"
+)
+
+func TestMemorySTHCache(t *testing.T) {
+	c := NewMemorySTHCache()
+	key1, key2 := sha256.Sum256([]byte("key1")), sha256.Sum256([]byte("key2"))
+	if got := c.Get(key1); got != nil {
+		t.Errorf("Get(empty)=%v; want nil", got)
+	}
+	older := &ct.SignedTreeHead{TreeSize: 10, Timestamp: 1000}
+	newer := &ct.SignedTreeHead{TreeSize: 20, Timestamp: 2000}
+	c.Put(key1, older)
+	c.Put(key1, newer)
+	c.Put(key1, older)
+	c.Put(key1, nil)
+	if got := c.Get(key1); got != newer {
+		t.Errorf("Get(key1)=%v; want %v", got, newer)
+	}
+	if got := c.Get(key2); got != nil {
+		t.Errorf("Get(key2)=%v; want nil", got)
+	}
+}
+
+func TestSTHCacheSharedAcrossRebuilds(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	index := fl.addLeaf(t, stamped(testLeaf(7), 1234))
+	cache := NewMemorySTHCache()
+
+	build := func() LogInfoByHash {
+		m := LogInfoByHash{sha256.Sum256(fl.keyDER(t)): fl.logInfo(t)}
+		m.SetSTHCache(cache)
+		return m
+	}
+	m := build()
+	li := m[sha256.Sum256(fl.keyDER(t))]
+	sth, err := li.GetVerifiedSTH(ctx)
+	if err != nil {
+		t.Fatalf("GetVerifiedSTH()=nil,%v; want _,nil", err)
+	}
+
+	// A rebuilt map picks up the cached STH, so does not need to fetch one.
+	fl.sthErr = errors.New("get-sth unavailable")
+	li = build()[sha256.Sum256(fl.keyDER(t))]
+	if got := li.LastSTH(); got != sth {
+		t.Errorf("LastSTH()=%v; want %v", got, sth)
+	}
+	if got, err := li.VerifyInclusionLatest(ctx, testLeaf(7), 1234); err != nil || got != index {
+		t.Errorf("VerifyInclusionLatest()=%d,%v; want %d,nil", got, err, index)
+	}
+
+	// Without a cache, the rebuilt LogInfo knows no STH.
+	li = fl.logInfo(t)
+	if got := li.LastSTH(); got != nil {
+		t.Errorf("LastSTH(no cache)=%v; want nil", got)
+	}
+	if _, err := li.VerifyInclusionLatest(ctx, testLeaf(7), 1234); err == nil {
+		t.Error("VerifyInclusionLatest(no cache)=_,nil; want _,non-nil")
+	}
+
+	// A LogInfo's own STH is used if the cache has none.
+	other := newFakeLog(t, "https://other.example.com")
+	li = other.logInfo(t)
+	own := &ct.SignedTreeHead{TreeSize: 1}
+	li.SetSTH(own)
+	WithSTHCache(cache)(li)
+	if got := li.LastSTH(); got != own {
+		t.Errorf("LastSTH(not cached)=%v; want %v", got, own)
+	}
+}
+
+func TestLastSTHWithCache(t *testing.T) {
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 5)
+	small, err := fl.sthAt(2)
+	if err != nil {
+		t.Fatalf("sthAt(2)=_,%v", err)
+	}
+	large, err := fl.sthAt(5)
+	if err != nil {
+		t.Fatalf("sthAt(5)=_,%v", err)
+	}
+	forged := *large
+	forged.TreeSize = 9
+	keyHash := sha256.Sum256(fl.keyDER(t))
+
+	for _, test := range []struct {
+		desc   string
+		own    *ct.SignedTreeHead
+		cached *ct.SignedTreeHead
+		want   *ct.SignedTreeHead
+	}{
+		{desc: "none", want: nil},
+		{desc: "own-only", own: small, want: small},
+		{desc: "cached-only", cached: large, want: large},
+		{desc: "cached-larger", own: small, cached: large, want: large},
+		{desc: "own-larger", own: large, cached: small, want: large},
+		{desc: "cached-forged", own: small, cached: &forged, want: small},
+	} {
+		cache := NewMemorySTHCache()
+		li := fl.logInfo(t)
+		li.SetSTH(test.own)
+		WithSTHCache(cache)(li)
+		cache.Put(keyHash, test.cached)
+		if got := li.LastSTH(); got != test.want {
+			t.Errorf("%s: LastSTH()=%v; want %v", test.desc, got, test.want)
+		}
+	}
+	// The cache can be given when building a LogInfo.
+	cache := NewMemorySTHCache()
+	cache.Put(keyHash, large)
+	log := loglist.Log{Description: "cached log", URL: "https://log.example.com", Key: fl.keyDER(t)}
+	for _, build := range []struct {
+		name string
+		fn   func(*loglist.Log, *http.Client, ...LogInfoOption) (*LogInfo, error)
+	}{
+		{name: "NewLogInfo", fn: NewLogInfo},
+		{name: "NewLazyLogInfo", fn: NewLazyLogInfo},
+	} {
+		li, err := build.fn(&log, http.DefaultClient, WithSTHCache(cache))
+		if err != nil {
+			t.Fatalf("%s()=nil,%v; want _,nil", build.name, err)
+		}
+		if got := li.LastSTH(); got != large {
+			t.Errorf("%s(WithSTHCache).LastSTH()=%v; want %v", build.name, got, large)
+		}
+	}
+}
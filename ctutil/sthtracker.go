@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// STHTracker maintains an unbroken chain of consistency-checked STHs for a
+// single log, from the first STH that it sees.  Each Update only advances the
+// tracked STH once the new STH's signature and its consistency with the
+// tracked STH have been verified; unlike a Monitor, it does not classify the
+// failures.  It is safe for concurrent use.
+type STHTracker struct {
+	mu          sync.Mutex
+	first, last *ct.SignedTreeHead
+}
+
+// NewSTHTracker builds an STHTracker that starts from the given STH, which the
+// caller trusts (e.g. one persisted by an earlier run), or from the first STH
+// fetched by Update if start is nil.
+func NewSTHTracker(start *ct.SignedTreeHead) *STHTracker {
+	return &STHTracker{first: start, last: start}
+}
+
+// First returns the STH at the start of the tracked chain, or nil if there is
+// none yet.
+func (t *STHTracker) First() *ct.SignedTreeHead {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.first
+}
+
+// Last returns the most recent STH in the tracked chain, or nil if there is
+// none yet.
+func (t *STHTracker) Last() *ct.SignedTreeHead {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+// Update fetches the log's current STH, verifies its signature and its
+// consistency with the last tracked STH (see LogInfo.VerifyConsistency), and
+// only then makes it the last tracked STH, also recording it as the log's last
+// known STH.  On failure the last tracked STH is retained; if the new STH is
+// not consistent with it, the error gives the tree sizes and root hashes of
+// both, and wraps any ErrRollback or ErrSplitView.
+func (t *STHTracker) Update(ctx context.Context, li *LogInfo) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sth, err := li.Client.GetSTH(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current STH for %q log: %v", li.Description, err)
+	}
+	if err := li.VerifySTHSignature(*sth); err != nil {
+		return fmt.Errorf("failed to verify STH signature for %q log at size %d: %v", li.Description, sth.TreeSize, err)
+	}
+	if prev := t.last; prev != nil {
+		if err := li.VerifyConsistency(ctx, prev, sth); err != nil {
+			return fmt.Errorf("%q log STH at size %d (root %x) does not extend tracked STH at size %d (root %x): %w",
+				li.Description, sth.TreeSize, sth.SHA256RootHash, prev.TreeSize, prev.SHA256RootHash, err)
+		}
+	}
+	if t.first == nil {
+		t.first = sth
+	}
+	t.last = sth
+	li.SetSTH(sth)
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSTHTracker(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	li := fl.logInfo(t)
+	tracker := NewSTHTracker(nil)
+
+	if err := tracker.Update(ctx, li); err != nil {
+		t.Fatalf("Update(first)=%v; want nil", err)
+	}
+	first := tracker.First()
+	if first == nil || first.TreeSize != 3 || tracker.Last() != first {
+		t.Fatalf("after first Update(), First()=%v, Last()=%v; want both at size 3", first, tracker.Last())
+	}
+	fl.addLeaves(t, 4)
+	if err := tracker.Update(ctx, li); err != nil {
+		t.Fatalf("Update(grown)=%v; want nil", err)
+	}
+	good := tracker.Last()
+	if good.TreeSize != 7 || tracker.First() != first {
+		t.Errorf("after Update(grown), Last().TreeSize=%d, First()=%v; want 7, %v", good.TreeSize, tracker.First(), first)
+	}
+	if li.LastSTH() != good {
+		t.Errorf("LastSTH()=%v; want tracked STH %v", li.LastSTH(), good)
+	}
+
+	// A fork of the log, signed with the same key, that rewrites its history.
+	fork := newFakeLog(t, "https://log.example.com")
+	fork.key = fl.key
+	fork.addLeaf(t, stamped(testLeaf(99), 1))
+	fork.addLeaves(t, 8)
+	// A shorter fork, which appears to roll the log back.
+	short := newFakeLog(t, "https://log.example.com")
+	short.key = fl.key
+	short.addLeaves(t, 2)
+	other := newFakeLog(t, "https://other.example.com")
+	other.addLeaves(t, 10)
+
+	tests := []struct {
+		desc    string
+		log     *fakeLog
+		sthErr  error
+		wantErr []string
+		wantIs  error
+	}{
+		{desc: "fetch-error", log: fl, sthErr: errors.New("unavailable"), wantErr: []string{"unavailable"}},
+		{desc: "bad-signature", log: other, wantErr: []string{"signature"}},
+		{
+			desc:    "inconsistent",
+			log:     fork,
+			wantErr: []string{"size 9", fmt.Sprintf("root %x", good.SHA256RootHash), "size 7", "inconsistent"},
+		},
+		{desc: "rollback", log: short, wantErr: []string{"size 2", "size 7"}, wantIs: ErrRollback},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			test.log.sthErr = test.sthErr
+			defer func() { test.log.sthErr = nil }()
+			li.Client = test.log
+			err := tracker.Update(ctx, li)
+			if err == nil {
+				t.Fatal("Update()=nil; want error")
+			}
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Update()=%v; want error containing %q", err, want)
+				}
+			}
+			if test.wantIs != nil && !errors.Is(err, test.wantIs) {
+				t.Errorf("Update()=%v; want error wrapping %v", err, test.wantIs)
+			}
+			if got := tracker.Last(); got != good {
+				t.Errorf("Last()=%v after failed Update(); want last good STH %v", got, good)
+			}
+		})
+	}
+
+	// The chain continues once the log serves a consistent STH again.
+	li.Client = fl
+	fl.addLeaves(t, 1)
+	if err := tracker.Update(ctx, li); err != nil {
+		t.Errorf("Update(recovered)=%v; want nil", err)
+	}
+	if got := tracker.Last().TreeSize; got != 8 {
+		t.Errorf("Last().TreeSize=%d; want 8", got)
+	}
+
+	// A tracker can start from a trusted STH.
+	resumed := NewSTHTracker(good)
+	li.Client = fork
+	if err := resumed.Update(ctx, li); err == nil {
+		t.Error("Update(fork from resumed STH)=nil; want error")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+)
+
+// STHStreamer is a source of the STHs published by a log.
+type STHStreamer interface {
+	// StreamSTHs returns a channel on which the log's STHs are delivered as
+	// they become available.  The channel is closed once the context is done.
+	StreamSTHs(ctx context.Context) (<-chan *ct.SignedTreeHead, error)
+}
+
+// NewSTHStreamer returns an STHStreamer for the given log that only delivers
+// STHs with valid signatures.  If the log's client can push STHs itself (i.e.
+// implements STHStreamer) then that is used; otherwise the log is polled at the
+// given interval.
+func NewSTHStreamer(li *LogInfo, interval time.Duration) STHStreamer {
+	return newSTHStreamer(li, interval, li.VerifySTHSignature)
+}
+
+// NewSTHStreamer returns an STHStreamer for the monitor's log, as for the
+// NewSTHStreamer function, except that STH signatures are checked with the
+// monitor's current key, so that the stream carries on across a RotateKey.
+func (m *Monitor) NewSTHStreamer(interval time.Duration) STHStreamer {
+	return newSTHStreamer(m.Log, interval, func(sth ct.SignedTreeHead) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.verifySTHSignature(&sth)
+	})
+}
+
+func newSTHStreamer(li *LogInfo, interval time.Duration, verify func(ct.SignedTreeHead) error) STHStreamer {
+	if push, ok := client.Primary(li.Client).(STHStreamer); ok {
+		return &verifyingStreamer{source: push, verify: verify}
+	}
+	return &PollingSTHStreamer{Log: li, Interval: interval, Verify: verify}
+}
+
+// PollingSTHStreamer is an STHStreamer that polls a log's get-sth entrypoint.
+type PollingSTHStreamer struct {
+	Log      *LogInfo
+	Interval time.Duration
+	// Verify, if set, checks the signature of each STH in place of
+	// Log.VerifySTHSignature.
+	Verify func(ct.SignedTreeHead) error
+	// OnError, if set, is invoked for each failure to retrieve or verify an
+	// STH; such failures do not stop the stream.
+	OnError func(error)
+}
+
+// StreamSTHs polls the log immediately and then at each interval, delivering
+// each new STH whose signature verifies.
+func (p *PollingSTHStreamer) StreamSTHs(ctx context.Context) (<-chan *ct.SignedTreeHead, error) {
+	if p.Interval <= 0 {
+		return nil, fmt.Errorf("invalid polling interval %v", p.Interval)
+	}
+	out := make(chan *ct.SignedTreeHead)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		var last *ct.SignedTreeHead
+		for {
+			if sth := p.poll(ctx); sth != nil && (last == nil || !sameSTH(sth, last)) {
+				select {
+				case out <- sth:
+					last = sth
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (p *PollingSTHStreamer) poll(ctx context.Context) *ct.SignedTreeHead {
+	sth, err := p.Log.Client.GetSTH(ctx)
+	if err == nil {
+		verify := p.Verify
+		if verify == nil {
+			verify = p.Log.VerifySTHSignature
+		}
+		err = verify(*sth)
+	}
+	if err != nil {
+		if p.OnError != nil && ctx.Err() == nil {
+			p.OnError(fmt.Errorf("failed to get verified STH for %q log: %v", p.Log.Description, err))
+		}
+		return nil
+	}
+	return sth
+}
+
+// sameSTH indicates whether the two STHs describe the same tree head.
+func sameSTH(a, b *ct.SignedTreeHead) bool {
+	return a.TreeSize == b.TreeSize && a.Timestamp == b.Timestamp && a.SHA256RootHash == b.SHA256RootHash
+}
+
+// verifyingStreamer wraps a push source of STHs, dropping any STHs whose
+// signatures do not verify.
+type verifyingStreamer struct {
+	source STHStreamer
+	verify func(ct.SignedTreeHead) error
+}
+
+func (v *verifyingStreamer) StreamSTHs(ctx context.Context) (<-chan *ct.SignedTreeHead, error) {
+	in, err := v.source.StreamSTHs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *ct.SignedTreeHead)
+	go func() {
+		defer close(out)
+		for sth := range in {
+			if err := v.verify(*sth); err != nil {
+				continue
+			}
+			select {
+			case out <- sth:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Follow processes each of the STHs delivered by the streamer, until the
+// stream ends, passing the results of ProcessSTH to the handler.  To follow
+// the log across a key rotation, use a streamer from m.NewSTHStreamer.  Returns
+// the context's error if the stream ended because the context was done.
+func (m *Monitor) Follow(ctx context.Context, s STHStreamer, handle func(sth *ct.SignedTreeHead, findings []Finding, err error)) error {
+	sths, err := s.StreamSTHs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to stream STHs for %q log: %v", m.Log.Description, err)
+	}
+	for sth := range sths {
+		findings, err := m.ProcessSTH(ctx, sth)
+		if handle != nil {
+			handle(sth, findings, err)
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// pushLog is a fake log that pushes a fixed sequence of STHs.
+type pushLog struct {
+	*fakeLog
+	sths []*ct.SignedTreeHead
+}
+
+func (p *pushLog) StreamSTHs(ctx context.Context) (<-chan *ct.SignedTreeHead, error) {
+	out := make(chan *ct.SignedTreeHead)
+	go func() {
+		defer close(out)
+		for _, sth := range p.sths {
+			select {
+			case out <- sth:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// chanLog is a fake log that pushes the STHs sent on a channel, until it is
+// closed.
+type chanLog struct {
+	*fakeLog
+	sths chan *ct.SignedTreeHead
+}
+
+func (c *chanLog) StreamSTHs(ctx context.Context) (<-chan *ct.SignedTreeHead, error) {
+	return c.sths, nil
+}
+
+func TestMonitorFollowPushStreamer(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	var sths []*ct.SignedTreeHead
+	for _, size := range []uint64{2, 5, 9} {
+		fl.addLeaves(t, int(size)-int(fl.tree.LeafCount()))
+		fl.timestamp += 1000
+		sth, err := fl.sthAt(size)
+		if err != nil {
+			t.Fatalf("sthAt(%d)=_,%v", size, err)
+		}
+		sths = append(sths, sth)
+	}
+	forged := *sths[2]
+	forged.TreeSize = 100
+	push := &pushLog{fakeLog: fl, sths: []*ct.SignedTreeHead{sths[0], sths[1], &forged, sths[2]}}
+
+	li := fl.logInfo(t)
+	li.Client = push
+	streamer := NewSTHStreamer(li, time.Hour)
+	if _, ok := streamer.(*PollingSTHStreamer); ok {
+		t.Fatal("NewSTHStreamer(push client) returned polling streamer")
+	}
+
+	m := NewMonitor(li)
+	var got []uint64
+	err := m.Follow(ctx, streamer, func(sth *ct.SignedTreeHead, findings []Finding, err error) {
+		if err != nil || len(findings) > 0 {
+			t.Errorf("ProcessSTH(size=%d)=%v,%v; want nil,nil", sth.TreeSize, findings, err)
+		}
+		got = append(got, sth.TreeSize)
+	})
+	if err != nil {
+		t.Fatalf("Follow()=%v; want nil", err)
+	}
+	if want := []uint64{2, 5, 9}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Follow() delivered sizes %v; want %v", got, want)
+	}
+	if got, want := m.Last().TreeSize, uint64(9); got != want {
+		t.Errorf("Last().TreeSize=%d; want %d", got, want)
+	}
+}
+
+func TestMonitorFollowKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	fl := newFakeLog(t, "https://log.example.com")
+	rotated := newFakeLog(t, "https://log.example.com")
+	li := fl.logInfo(t)
+	var sths []*ct.SignedTreeHead
+	for _, size := range []uint64{2, 5, 9, 12} {
+		if size == 9 {
+			// The log rotates to a new key, and carries on growing its tree.
+			fl.key = rotated.key
+		}
+		fl.addLeaves(t, int(size)-int(fl.tree.LeafCount()))
+		fl.timestamp += 1000
+		sth, err := fl.sthAt(size)
+		if err != nil {
+			t.Fatalf("sthAt(%d)=_,%v", size, err)
+		}
+		sths = append(sths, sth)
+	}
+	push := &chanLog{fakeLog: fl, sths: make(chan *ct.SignedTreeHead, len(sths))}
+	push.sths <- sths[0]
+	push.sths <- sths[1]
+	li.Client = push
+
+	m := NewMonitor(li)
+	var got []uint64
+	err := m.Follow(ctx, m.NewSTHStreamer(time.Hour), func(sth *ct.SignedTreeHead, findings []Finding, err error) {
+		if err != nil || len(findings) > 0 {
+			t.Errorf("ProcessSTH(size=%d)=%v,%v; want nil,nil", sth.TreeSize, findings, err)
+		}
+		got = append(got, sth.TreeSize)
+		// The rotation is announced once the last STH signed by the old key
+		// has been seen, before the log publishes any STH with the new key.
+		if sth.TreeSize == 5 {
+			if err := m.RotateKey(rotated.keyDER(t)); err != nil {
+				t.Fatalf("RotateKey()=%v; want nil", err)
+			}
+			push.sths <- sths[2]
+			push.sths <- sths[3]
+			close(push.sths)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Follow()=%v; want nil", err)
+	}
+	if want := []uint64{2, 5, 9, 12}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Follow() accepted sizes %v; want %v", got, want)
+	}
+	if got, want := m.Last().TreeSize, uint64(12); got != want {
+		t.Errorf("Last().TreeSize=%d; want %d", got, want)
+	}
+}
+
+func TestPollingSTHStreamer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fl := newFakeLog(t, "https://log.example.com")
+	fl.addLeaves(t, 3)
+	streamer := NewSTHStreamer(fl.logInfo(t), time.Millisecond)
+	if _, ok := streamer.(*PollingSTHStreamer); !ok {
+		t.Fatalf("NewSTHStreamer()=%T; want *PollingSTHStreamer", streamer)
+	}
+	sths, err := streamer.StreamSTHs(ctx)
+	if err != nil {
+		t.Fatalf("StreamSTHs()=_,%v; want _,nil", err)
+	}
+	if sth := <-sths; sth.TreeSize != 3 {
+		t.Errorf("first STH has size %d; want 3", sth.TreeSize)
+	}
+	fl.mu.Lock()
+	fl.timestamp++
+	fl.mu.Unlock()
+	fl.addLeaves(t, 2)
+	// Unchanged STHs are not re-delivered, so the next STH must be newer.
+	if sth := <-sths; sth.TreeSize != 5 {
+		t.Errorf("second STH has size %d; want 5", sth.TreeSize)
+	}
+	cancel()
+	for range sths {
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+)
+
+// ObtainDiverseSCTs submits the given certificate chain to logs run by at
+// least minOperators distinct operators, returning a verified SCT from one log
+// of each operator.  The operators map gives the operator of each log, indexed
+// by log key hash; logs with no known operator, or whose client cannot submit
+// chains (i.e. does not implement client.AddLogClient), are not used.
+//
+// Submissions are made concurrently, one log per operator, to only as many
+// operators as are still needed; if a log fails (or returns an SCT that does
+// not verify), the next log for that operator, or another operator, is tried
+// in a later round.  The healthiest logs are tried first: those with the most
+// recent known STH, followed by those with a stale or no known STH.  Returns an error
+// summarizing the failures if the required diversity could not be reached,
+// along with any SCTs that were obtained.
+func ObtainDiverseSCTs(ctx context.Context, chain []ct.ASN1Cert, m LogInfoByHash, operators map[[sha256.Size]byte]string, minOperators int) ([]*ct.SignedCertificateTimestamp, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("empty chain")
+	}
+	leaf, err := ct.MerkleTreeLeafFromRawChain(chain, ct.X509LogEntryType, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Merkle tree leaf: %v", err)
+	}
+
+	// Queue up the usable logs of each operator, healthiest first.
+	queues := make(map[string][][sha256.Size]byte)
+	for key, li := range m {
+		op, ok := operators[key]
+		if !ok {
+			continue
+		}
+		if _, ok := li.Client.(client.AddLogClient); !ok {
+			continue
+		}
+		queues[op] = append(queues[op], key)
+	}
+	now := time.Now()
+	for _, keys := range queues {
+		sort.Slice(keys, func(i, j int) bool {
+			return healthier(m[keys[i]], m[keys[j]], now)
+		})
+	}
+
+	var (
+		mu     sync.Mutex
+		scts   []*ct.SignedCertificateTimestamp
+		done   = make(map[string]bool)
+		failed = make(batchError)
+	)
+	for len(done) < minOperators {
+		// Choose the operators to try in this round, preferring those whose
+		// next log is healthiest.
+		var pending []string
+		for op, keys := range queues {
+			if !done[op] && len(keys) > 0 {
+				pending = append(pending, op)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		sort.Slice(pending, func(i, j int) bool {
+			return healthier(m[queues[pending[i]][0]], m[queues[pending[j]][0]], now)
+		})
+		if need := minOperators - len(done); len(pending) > need {
+			pending = pending[:need]
+		}
+		round := make(map[[sha256.Size]byte]string)
+		keys := make([][sha256.Size]byte, 0, len(pending))
+		for _, op := range pending {
+			key := queues[op][0]
+			queues[op] = queues[op][1:]
+			round[key] = op
+			keys = append(keys, key)
+		}
+
+		err := runBatch(ctx, keys, func(ctx context.Context, key [sha256.Size]byte) error {
+			li := m[key]
+			sct, err := li.Client.(client.AddLogClient).AddChain(ctx, chain)
+			if err != nil {
+				return fmt.Errorf("failed to submit chain to %q log: %v", li.Description, err)
+			}
+			if sct.LogID.KeyID != key {
+				return fmt.Errorf("SCT from %q log has log ID %x", li.Description, sct.LogID.KeyID)
+			}
+			if err := li.VerifySCTSignature(*sct, *leaf); err != nil {
+				return fmt.Errorf("failed to verify SCT from %q log: %v", li.Description, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			scts = append(scts, sct)
+			done[round[key]] = true
+			return nil
+		})
+		if be, ok := err.(batchError); ok {
+			for key, err := range be {
+				failed[key] = err
+			}
+		} else if err != nil {
+			return scts, err
+		}
+	}
+
+	if len(done) < minOperators {
+		if len(failed) == 0 {
+			return scts, fmt.Errorf("obtained SCTs from %d operator(s), want %d: no further logs available", len(done), minOperators)
+		}
+		return scts, fmt.Errorf("obtained SCTs from %d operator(s), want %d: %v", len(done), minOperators, failed)
+	}
+	return scts, nil
+}
+
+// healthier indicates whether the first log is to be preferred over the second
+// for submissions: a log with a more recent known STH is preferred, and a log
+// with no known STH (or one that is older than its MMD) comes last.  Ties are
+// broken by description, for determinism.
+func healthier(a, b *LogInfo, now time.Time) bool {
+	ageA, okA := sthAge(a, now)
+	ageB, okB := sthAge(b, now)
+	switch {
+	case okA != okB:
+		return okA
+	case okA && ageA != ageB:
+		return ageA < ageB
+	}
+	return a.Description < b.Description
+}
+
+// sthAge returns the age of the last known STH for the log, and whether it is
+// recent enough (within the log's MMD, if known) to indicate a healthy log.
+func sthAge(li *LogInfo, now time.Time) (time.Duration, bool) {
+	sth := li.LastSTH()
+	if sth == nil {
+		return 0, false
+	}
+	age := now.Sub(ct.TimestampToTime(sth.Timestamp))
+	if li.MMD > 0 && age > li.MMD {
+		return age, false
+	}
+	return age, true
+}
+
+// VerifyAddChainSCT checks that an SCT returned by the log in response to an
+// add-chain request is for the chain that was submitted, by verifying its
+// signature over the leaf built from the chain's end-entity certificate.
+func VerifyAddChainSCT(sct *ct.SignedCertificateTimestamp, chain []ct.ASN1Cert, li *LogInfo) error {
+	if sct == nil {
+		return fmt.Errorf("nil SCT")
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("empty chain")
+	}
+	leaf, err := ct.MerkleTreeLeafFromRawChain(chain, ct.X509LogEntryType, sct.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to build Merkle tree leaf: %v", err)
+	}
+	return li.VerifySCTSignature(*sct, *leaf)
+}
+
+// VerifyAddPreChainSCT checks that an SCT returned by the log in response to
+// an add-pre-chain request is for the precertificate that was submitted, by
+// verifying its signature over the precertificate leaf built from the
+// precertificate and its issuer.  The issuer must be the CA that will issue
+// the final certificate; for a precertificate signed by a pre-issuer, use
+// VerifyChainSCTSignature with the full chain instead.
+func VerifyAddPreChainSCT(sct *ct.SignedCertificateTimestamp, precert, issuer ct.ASN1Cert, li *LogInfo) error {
+	if sct == nil {
+		return fmt.Errorf("nil SCT")
+	}
+	leaf, err := ct.MerkleTreeLeafFromRawChain([]ct.ASN1Cert{precert, issuer}, ct.PrecertLogEntryType, sct.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to build precert Merkle tree leaf: %v", err)
+	}
+	return li.VerifySCTSignature(*sct, *leaf)
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+// submitLog is a fake log that also accepts chain submissions.
+type submitLog struct {
+	*fakeLog
+	t *testing.T
+	// err, if set, is returned from AddChain; if corrupt is set, AddChain
+	// returns an SCT with an invalid signature.
+	err     error
+	corrupt bool
+	calls   int
+}
+
+func (s *submitLog) AddChain(ctx context.Context, chain []ct.ASN1Cert) (*ct.SignedCertificateTimestamp, error) {
+	return s.add(chain, ct.X509LogEntryType)
+}
+
+func (s *submitLog) AddPreChain(ctx context.Context, chain []ct.ASN1Cert) (*ct.SignedCertificateTimestamp, error) {
+	return s.add(chain, ct.PrecertLogEntryType)
+}
+
+func (s *submitLog) add(chain []ct.ASN1Cert, etype ct.LogEntryType) (*ct.SignedCertificateTimestamp, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	leaf, err := ct.MerkleTreeLeafFromRawChain(chain, etype, 0)
+	if err != nil {
+		return nil, err
+	}
+	sct := s.signSCT(s.t, *leaf, s.timestamp)
+	if s.corrupt {
+		sct.Signature.Signature[len(sct.Signature.Signature)-1] ^= 0xff
+	}
+	return &sct, nil
+}
+
+func (s *submitLog) GetAcceptedRoots(ctx context.Context) ([]ct.ASN1Cert, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestObtainDiverseSCTs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cert, _ := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "leaf.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+	}, nil, nil)
+	chain := []ct.ASN1Cert{{Data: cert.Raw}}
+
+	logs := make(map[string]*submitLog)
+	m := make(LogInfoByHash)
+	operators := make(map[[sha256.Size]byte]string)
+	addLog := func(name, operator string, sthAge time.Duration) *submitLog {
+		s := &submitLog{fakeLog: newFakeLog(t, name), t: t}
+		li := s.logInfo(t)
+		li.Client = s
+		if sthAge > 0 {
+			s.timestamp = uint64(now.Add(-sthAge).UnixNano() / int64(time.Millisecond))
+			sth, err := s.sthAt(0)
+			if err != nil {
+				t.Fatalf("sthAt(0)=_,%v", err)
+			}
+			li.SetSTH(sth)
+		}
+		key := sha256.Sum256(s.keyDER(t))
+		m[key] = li
+		if operator != "" {
+			operators[key] = operator
+		}
+		logs[name] = s
+		return s
+	}
+	// Operators A and B have the healthiest logs, but the submissions to them
+	// fail, so the next round falls back to C's log and to A's other log.
+	addLog("a1", "A", time.Minute).err = errors.New("internal error")
+	addLog("a2", "A", 0)
+	addLog("b1", "B", 2*time.Minute).corrupt = true
+	addLog("c1", "C", 3*time.Minute)
+	addLog("d1", "D", 0)
+	addLog("d2", "D", 48*time.Hour)
+	addLog("unknown", "", time.Second)
+
+	scts, err := ObtainDiverseSCTs(ctx, chain, m, operators, 2)
+	if err != nil {
+		t.Fatalf("ObtainDiverseSCTs(2)=_,%v; want _,nil", err)
+	}
+	var got []string
+	for _, sct := range scts {
+		got = append(got, m[sct.LogID.KeyID].Description)
+	}
+	sort.Strings(got)
+	if want := []string{"a2", "c1"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("ObtainDiverseSCTs(2) gave SCTs from %v; want %v", got, want)
+	}
+	for name, want := range map[string]int{"a1": 1, "a2": 1, "b1": 1, "c1": 1, "d1": 0, "d2": 0, "unknown": 0} {
+		if got := logs[name].calls; got != want {
+			t.Errorf("ObtainDiverseSCTs(2) made %d submission(s) to %q; want %d", got, name, want)
+		}
+	}
+
+	scts, err = ObtainDiverseSCTs(ctx, chain, m, operators, 4)
+	if err == nil {
+		t.Errorf("ObtainDiverseSCTs(4)=_,nil; want _,non-nil")
+	}
+	if got, want := len(scts), 3; got != want {
+		t.Errorf("ObtainDiverseSCTs(4) gave %d SCTs; want %d", got, want)
+	}
+
+	if _, err := ObtainDiverseSCTs(ctx, nil, m, operators, 1); err == nil {
+		t.Error("ObtainDiverseSCTs(empty chain)=_,nil; want _,non-nil")
+	}
+}
+
+func TestVerifyAddChainSCT(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	chainFor := func(serial int64, cn string) []ct.ASN1Cert {
+		cert, _ := issueCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(24 * time.Hour),
+		}, nil, nil)
+		return []ct.ASN1Cert{{Data: cert.Raw}}
+	}
+	submitted := chainFor(1, "submitted.example.com")
+	other := chainFor(2, "other.example.com")
+
+	s := &submitLog{fakeLog: newFakeLog(t, "https://log.example.com"), t: t}
+	li := s.logInfo(t)
+	sct, err := s.AddChain(ctx, submitted)
+	if err != nil {
+		t.Fatalf("AddChain()=_,%v; want _,nil", err)
+	}
+	replayed, err := s.AddChain(ctx, other)
+	if err != nil {
+		t.Fatalf("AddChain()=_,%v; want _,nil", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		sct     *ct.SignedCertificateTimestamp
+		chain   []ct.ASN1Cert
+		wantErr bool
+	}{
+		{desc: "matching", sct: sct, chain: submitted},
+		{desc: "mismatched", sct: replayed, chain: submitted, wantErr: true},
+		{desc: "nil-sct", chain: submitted, wantErr: true},
+		{desc: "empty-chain", sct: sct, wantErr: true},
+		{desc: "unparseable-chain", sct: sct, chain: []ct.ASN1Cert{{Data: []byte("not a cert")}}, wantErr: true},
+	} {
+		err := VerifyAddChainSCT(test.sct, test.chain, li)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: VerifyAddChainSCT()=%v; want error? %t", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyAddPreChainSCT(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	template := func(serial int64, cn string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(24 * time.Hour),
+		}
+	}
+	caTemplate := template(1, "Test CA")
+	caTemplate.IsCA, caTemplate.BasicConstraintsValid = true, true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	ca, caKey := issueCert(t, caTemplate, nil, nil)
+	otherCA, _ := issueCert(t, caTemplate, nil, nil)
+
+	precertTemplate := template(2, "www.example.com")
+	precertTemplate.ExtraExtensions = []pkix.Extension{{Id: x509.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}}
+	precert, _ := issueCert(t, precertTemplate, ca, caKey)
+	otherPrecertTemplate := template(3, "other.example.com")
+	otherPrecertTemplate.ExtraExtensions = precertTemplate.ExtraExtensions
+	otherPrecert, _ := issueCert(t, otherPrecertTemplate, ca, caKey)
+
+	rawPrecert, rawCA := ct.ASN1Cert{Data: precert.Raw}, ct.ASN1Cert{Data: ca.Raw}
+	s := &submitLog{fakeLog: newFakeLog(t, "https://log.example.com"), t: t}
+	li := s.logInfo(t)
+	sct, err := s.AddPreChain(ctx, []ct.ASN1Cert{rawPrecert, rawCA})
+	if err != nil {
+		t.Fatalf("AddPreChain()=_,%v; want _,nil", err)
+	}
+	replayed, err := s.AddPreChain(ctx, []ct.ASN1Cert{{Data: otherPrecert.Raw}, rawCA})
+	if err != nil {
+		t.Fatalf("AddPreChain()=_,%v; want _,nil", err)
+	}
+	// An SCT for the precertificate as if it were a final certificate.
+	asFinal, err := s.AddChain(ctx, []ct.ASN1Cert{rawPrecert, rawCA})
+	if err != nil {
+		t.Fatalf("AddChain()=_,%v; want _,nil", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		sct     *ct.SignedCertificateTimestamp
+		issuer  ct.ASN1Cert
+		wantErr bool
+	}{
+		{desc: "matching", sct: sct, issuer: rawCA},
+		{desc: "mismatched", sct: replayed, issuer: rawCA, wantErr: true},
+		{desc: "final-cert-sct", sct: asFinal, issuer: rawCA, wantErr: true},
+		{desc: "wrong-issuer", sct: sct, issuer: ct.ASN1Cert{Data: otherCA.Raw}, wantErr: true},
+		{desc: "nil-sct", issuer: rawCA, wantErr: true},
+	} {
+		err := VerifyAddPreChainSCT(test.sct, rawPrecert, test.issuer, li)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: VerifyAddPreChainSCT()=%v; want error? %t", test.desc, err, test.wantErr)
+		}
+	}
+}
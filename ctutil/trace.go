@@ -0,0 +1,128 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// defaultTracePolicy is the policy that ValidationTrace evaluates the verified
+// SCTs against, unless another is given with WithTracePolicy.
+var defaultTracePolicy = Policy{Name: "2 logs, 2 operators", MinSCTs: 2, MinOperators: 2}
+
+// TraceOption configures ValidationTrace.
+type TraceOption func(*traceConfig)
+
+type traceConfig struct {
+	policy Policy
+}
+
+// WithTracePolicy makes ValidationTrace evaluate the verified SCTs against the
+// given policy, rather than requiring SCTs from two logs with two operators.
+func WithTracePolicy(p Policy) TraceOption {
+	return func(c *traceConfig) {
+		c.policy = p
+	}
+}
+
+// ValidationTrace verifies the SCTs embedded in the certificate, issued by the
+// given issuer, against the known logs, and returns a human-readable trace of
+// each step of the validation, for explaining why the certificate does or does
+// not satisfy CT.  Each line of the trace is labelled with the step it
+// describes: for each SCT, its log, the hash of the data it signs, whether its
+// signature verifies, and whether the log has included the certificate (which
+// requires access to the log); then whether the SCTs with valid signatures
+// satisfy the policy (see WithTracePolicy), which gives the overall result.
+//
+// An error is only returned if either certificate is missing, or the
+// certificate's SCTs cannot be parsed.
+func ValidationTrace(ctx context.Context, cert, issuer *x509.Certificate, m LogInfoByHash, opts ...TraceOption) (string, error) {
+	cfg := traceConfig{policy: defaultTracePolicy}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cert == nil || issuer == nil {
+		return "", errors.New("certificate and issuer are both required")
+	}
+	scts, err := SCTsFromCertificate(cert)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse embedded SCTs: %v", err)
+	}
+
+	var b strings.Builder
+	line := func(label, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "%s: %s\n", label, fmt.Sprintf(format, args...))
+	}
+	line("certificate", "%q, serial %v, expires %v", cert.Subject.String(), cert.SerialNumber, cert.NotAfter.UTC().Format(time.RFC3339))
+	line("issuer", "%q", issuer.Subject.String())
+	line("scts", "%d embedded", len(scts))
+
+	chain := []*x509.Certificate{cert, issuer}
+	var verified []ct.SignedCertificateTimestamp
+	for i, sct := range scts {
+		label := fmt.Sprintf("sct[%d]", i)
+		li, err := m.MustLogForSCT(sct)
+		if err != nil {
+			line(label+" log", "UNKNOWN (log ID %x)", sct.LogID.KeyID)
+			continue
+		}
+		line(label+" log", "%q (log ID %x)", li.Description, sct.LogID.KeyID)
+		line(label+" timestamp", "%v", ct.TimestampToTime(sct.Timestamp).UTC().Format(time.RFC3339))
+
+		leaf, err := createLeaf(chain, &sct, true)
+		if err != nil {
+			line(label+" leaf", "FAILED: %v", err)
+			continue
+		}
+		if input, err := ct.SerializeSCTSignatureInput(sct, ct.LogEntry{Leaf: *leaf}); err != nil {
+			line(label+" signing input", "FAILED: %v", err)
+		} else {
+			line(label+" signing input sha256", "%x", sha256.Sum256(input))
+		}
+
+		if err := li.VerifyChainSCTSignature(sct, chain, true); err != nil {
+			line(label+" signature", "FAILED: %v", err)
+			continue
+		}
+		line(label+" signature", "OK")
+		verified = append(verified, sct)
+
+		if index, err := li.VerifyInclusion(ctx, *leaf, sct.Timestamp); err != nil {
+			line(label+" inclusion", "FAILED: %v", err)
+		} else {
+			line(label+" inclusion", "OK at index %d", index)
+		}
+	}
+
+	result := cfg.policy.EvaluateCert(cert, verified, m)
+	verdict := "FAIL"
+	if result.Compliant() {
+		verdict = "PASS"
+	}
+	line("policy", "%q: %s with %d valid SCT(s) from %d log(s), %d operator(s)", result.Policy, verdict, len(verified), len(result.Logs), len(result.Operators))
+	for _, failure := range result.Failures {
+		line("policy failure", "%s", failure)
+	}
+	line("result", "%s", verdict)
+	return b.String(), nil
+}
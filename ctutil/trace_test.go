@@ -0,0 +1,174 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+func TestValidationTrace(t *testing.T) {
+	ctx := context.Background()
+	included := newFakeLog(t, "https://included.example.com")
+	pending := newFakeLog(t, "https://pending.example.com")
+	unknown := newFakeLog(t, "https://unknown.example.com")
+	m := LogInfoByHash{
+		sha256.Sum256(included.keyDER(t)): included.logInfo(t),
+		sha256.Sum256(pending.keyDER(t)):  pending.logInfo(t),
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	ca, caKey := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(24 * time.Hour),
+	}
+	issue := func() *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+		return cert
+	}
+	leaf := ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			EntryType: ct.PrecertLogEntryType,
+			PrecertEntry: &ct.PreCert{
+				IssuerKeyHash:  sha256.Sum256(ca.RawSubjectPublicKeyInfo),
+				TBSCertificate: issue().RawTBSCertificate,
+			},
+		},
+	}
+	timestamp := uint64(notBefore.UnixNano() / int64(time.Millisecond))
+	included.addLeaves(t, 3)
+	included.addLeaf(t, stamped(leaf, timestamp))
+	included.addLeaves(t, 2)
+	corrupt := pending.signSCT(t, leaf, timestamp+1)
+	corrupt.Signature.Signature[len(corrupt.Signature.Signature)-1] ^= 0xff
+
+	embed := func(scts ...ct.SignedCertificateTimestamp) *x509.Certificate {
+		template.SCTList = x509.SignedCertificateTimestampList{}
+		for _, sct := range scts {
+			val, err := tls.Marshal(sct)
+			if err != nil {
+				t.Fatalf("failed to marshal SCT: %v", err)
+			}
+			template.SCTList.SCTList = append(template.SCTList.SCTList, x509.SerializedSCT{Val: val})
+		}
+		return issue()
+	}
+
+	tests := []struct {
+		desc string
+		cert *x509.Certificate
+		opts []TraceOption
+		want []string
+	}{
+		{
+			desc: "pass",
+			cert: embed(included.signSCT(t, leaf, timestamp), pending.signSCT(t, leaf, timestamp)),
+			want: []string{
+				`certificate: "CN=www.example.com", serial 2`,
+				`issuer: "CN=Test CA"`,
+				"scts: 2 embedded",
+				`sct[0] log: "https://included.example.com" (log ID `,
+				"sct[0] signing input sha256: ",
+				"sct[0] signature: OK",
+				"sct[0] inclusion: OK at index 3",
+				`sct[1] log: "https://pending.example.com" (log ID `,
+				"sct[1] signature: OK",
+				"sct[1] inclusion: FAILED: ",
+				`policy: "2 logs, 2 operators": PASS with 2 valid SCT(s) from 2 log(s), 2 operator(s)`,
+				"result: PASS",
+			},
+		},
+		{
+			desc: "fail",
+			cert: embed(included.signSCT(t, leaf, timestamp), corrupt, unknown.signSCT(t, leaf, timestamp)),
+			want: []string{
+				"scts: 3 embedded",
+				"sct[0] signature: OK",
+				"sct[1] signature: FAILED: ",
+				"sct[2] log: UNKNOWN (log ID ",
+				`policy: "2 logs, 2 operators": FAIL with 1 valid SCT(s) from 1 log(s), 1 operator(s)`,
+				"policy failure: got SCTs from 1 distinct log(s), need 2",
+				"result: FAIL",
+			},
+		},
+		{
+			desc: "other-policy",
+			cert: embed(included.signSCT(t, leaf, timestamp), corrupt),
+			opts: []TraceOption{WithTracePolicy(Policy{Name: "1 log", MinSCTs: 1, MinOperators: 1})},
+			want: []string{
+				`policy: "1 log": PASS with 1 valid SCT(s) from 1 log(s), 1 operator(s)`,
+				"result: PASS",
+			},
+		},
+		{
+			desc: "no-scts",
+			cert: embed(),
+			want: []string{"scts: 0 embedded", "result: FAIL"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			trace, err := ValidationTrace(ctx, test.cert, ca, m, test.opts...)
+			if err != nil {
+				t.Fatalf("ValidationTrace()=_,%v; want nil", err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(trace, want) {
+					t.Errorf("ValidationTrace()=\n%s\nwant line containing %q", trace, want)
+				}
+			}
+		})
+	}
+
+	if _, err := ValidationTrace(ctx, nil, ca, m); err == nil {
+		t.Error("ValidationTrace(nil cert)=_,nil; want error")
+	}
+}
@@ -43,7 +43,8 @@ type DNSClient struct {
 
 // New constructs a new DNSClient instance.  The base parameter gives the
 // top-level domain name; opts can be used to provide a custom logger
-// interface and a public key for signature verification.
+// interface, a public key for signature verification and a Limiter to
+// throttle DNS queries.
 func New(base string, opts jsonclient.Options) (*DNSClient, error) {
 	return newWithResolver(base, opts, func(ctx context.Context, name string) ([]string, error) { return net.LookupTXT(name) })
 }
@@ -77,6 +78,15 @@ func newWithResolver(base string, opts jsonclient.Options, resolve func(ctx cont
 			return nil, err
 		}
 	}
+	if limiter := opts.Limiter; limiter != nil {
+		lookup := resolve
+		resolve = func(ctx context.Context, name string) ([]string, error) {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			return lookup(ctx, name)
+		}
+	}
 	if len(base) > 0 && base[len(base)-1] != '.' {
 		base += "."
 	}
@@ -168,8 +178,47 @@ func (c *DNSClient) verifySTHSignature(sth ct.SignedTreeHead) error {
 }
 
 // GetSTHConsistency retrieves the consistency proof between two snapshots.
+// The proof may span several queries, each of which returns the next node
+// hashes in order; as the size of the proof is known in advance, it is an
+// error for the log's zone to be missing any of the records needed.
 func (c *DNSClient) GetSTHConsistency(ctx context.Context, first, second uint64) ([][]byte, error) {
-	return c.getProof(ctx, fmt.Sprintf("%d.%d.sth-consistency.%s", first, second, c.base))
+	if first > second {
+		return nil, fmt.Errorf("invalid consistency range %d > %d", first, second)
+	}
+	want := consistencyProofSize(first, second)
+	if want == 0 {
+		return [][]byte{}, nil
+	}
+	return c.getProof(ctx, fmt.Sprintf("%d.%d.sth-consistency.%s", first, second, c.base), want)
+}
+
+// consistencyProofSize returns the number of node hashes in the consistency
+// proof between trees of the given sizes (RFC 6962 s2.1.2).
+func consistencyProofSize(first, second uint64) int {
+	if first == 0 || first >= second {
+		return 0
+	}
+	size := 0
+	complete := true
+	for first != second {
+		k := uint64(1)
+		for k<<1 < second {
+			k <<= 1
+		}
+		size++
+		if first <= k {
+			second = k
+		} else {
+			first, second = first-k, second-k
+			complete = false
+		}
+	}
+	if !complete {
+		// The root of the older tree is not a node of the newer tree, so
+		// is included in the proof.
+		size++
+	}
+	return size
 }
 
 // GetProofByHash returns an audit path for the hash of an SCT.
@@ -190,7 +239,7 @@ func (c *DNSClient) GetProofByHash(ctx context.Context, hash []byte, treeSize ui
 		return nil, fmt.Errorf("failed to parse result %q", result)
 	}
 
-	proof, err := c.getProof(ctx, fmt.Sprintf("%d.%d.tree.%s", leafIndex, treeSize, c.base))
+	proof, err := c.getProof(ctx, fmt.Sprintf("%d.%d.tree.%s", leafIndex, treeSize, c.base), -1)
 	if err != nil {
 		return nil, err
 	}
@@ -201,9 +250,13 @@ func (c *DNSClient) GetProofByHash(ctx context.Context, hash []byte, treeSize ui
 	}, nil
 }
 
-func (c *DNSClient) getProof(ctx context.Context, base string) ([][]byte, error) {
+// getProof retrieves the node hashes of a proof, starting from the given
+// index, until the given number of hashes have been returned.  If want is
+// negative, the size of the proof is unknown, and the proof is taken to be
+// complete when a lookup fails or returns no data.
+func (c *DNSClient) getProof(ctx context.Context, base string, want int) ([][]byte, error) {
 	var proof [][]byte
-	for index := 0; index <= 255; {
+	for index := 0; index <= 255 && (want < 0 || index < want); {
 		name := fmt.Sprintf("%d.%s", index, base)
 		glog.V(2).Infof("proof: query %s TXT", name)
 		results, err := c.resolve(ctx, name)
@@ -211,6 +264,9 @@ func (c *DNSClient) getProof(ctx context.Context, base string) ([][]byte, error)
 			if index == 0 {
 				return nil, fmt.Errorf("lookup for %q failed: %v", name, err)
 			}
+			if want >= 0 {
+				return nil, fmt.Errorf("lookup for %q failed, with %d of %d proof node(s) retrieved: %v", name, index, want, err)
+			}
 			// Assume that a failure to retrieve any more means the proof is complete.
 			break
 		}
@@ -220,6 +276,9 @@ func (c *DNSClient) getProof(ctx context.Context, base string) ([][]byte, error)
 			return nil, fmt.Errorf("unexpected length of data %d, not multiple of %d: %x", len(result), sha256.Size, result)
 		}
 		if len(result) == 0 {
+			if want >= 0 {
+				return nil, fmt.Errorf("no data for %q, with %d of %d proof node(s) retrieved", name, index, want)
+			}
 			break
 		}
 		for start := 0; start < len(result); start += sha256.Size {
@@ -228,5 +287,8 @@ func (c *DNSClient) getProof(ctx context.Context, base string) ([][]byte, error)
 			index++
 		}
 	}
+	if want >= 0 && len(proof) != want {
+		return nil, fmt.Errorf("got %d proof node(s), want %d", len(proof), want)
+	}
 	return proof, nil
 }
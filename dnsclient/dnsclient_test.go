@@ -27,6 +27,8 @@ import (
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/jsonclient"
 	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
 )
 
 var (
@@ -49,6 +51,43 @@ func testClient(t *testing.T, rsp testRsp) *DNSClient {
 	return dc
 }
 
+type countingLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.calls++
+	return l.err
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := &countingLimiter{}
+	lookups := 0
+	dc, err := newWithResolver("test.example.com", jsonclient.Options{Limiter: limiter}, func(ctx context.Context, name string) ([]string, error) {
+		lookups++
+		return []string{"0"}, nil
+	})
+	if err != nil {
+		t.Fatalf("newWithResolver()=nil,%v; want _,nil", err)
+	}
+	dc.GetSTH(ctx)
+	dc.GetSTHConsistency(ctx, 1, 2)
+	if limiter.calls != lookups || lookups == 0 {
+		t.Errorf("Limiter.Wait() called %d times for %d lookups; want equal and non-zero", limiter.calls, lookups)
+	}
+
+	limiter.err = errors.New("limiter says no")
+	lookups = 0
+	if _, err := dc.GetSTH(ctx); err == nil || !strings.Contains(err.Error(), "limiter says no") {
+		t.Errorf("GetSTH()=_,%v; want error containing %q", err, "limiter says no")
+	}
+	if lookups != 0 {
+		t.Errorf("GetSTH() made %d lookups with limiter failing; want 0", lookups)
+	}
+}
+
 func TestGetSTH(t *testing.T) {
 	ctx := context.Background()
 	var tests = []struct {
@@ -194,91 +233,140 @@ func testMultiClient(t *testing.T, rsps []testRsp) *DNSClient {
 func TestGetSTHConsistency(t *testing.T) {
 	ctx := context.Background()
 	var tests = []struct {
-		name    string
-		rsps    []testRsp
-		want    int
-		wantErr string
+		name          string
+		first, second uint64
+		rsps          []testRsp
+		want          int
+		wantErr       string
 	}{
 		{
 			name:    "NoFirstResponse",
+			first:   100,
+			second:  200,
 			rsps:    []testRsp{{err: errors.New("a test error")}},
 			wantErr: "test error",
 		},
 		{
 			name:    "Not32Multiple",
+			first:   100,
+			second:  200,
 			rsps:    []testRsp{{txt: []string{string(dehex("0102"))}}},
 			wantErr: "not multiple of 32",
 		},
 		{
-			name: "ValidSingle",
+			name:    "InvalidRange",
+			first:   200,
+			second:  100,
+			wantErr: "invalid consistency range",
+		},
+		{
+			name:   "ValidSingle",
+			first:  1,
+			second: 2,
 			rsps: []testRsp{
 				{
-					q:   "0.100.200.sth-consistency.a.b.c.",
+					q:   "0.1.2.sth-consistency.a.b.c.",
 					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
 				},
-				{
-					q:   "1.100.200.sth-consistency.a.b.c.",
-					err: errors.New("finished"),
-				},
 			},
 			want: 1,
 		},
 		{
-			name: "ValidEmpty",
+			name:   "ValidEmpty",
+			first:  200,
+			second: 200,
+			want:   0,
+		},
+		{
+			name:   "ValidEmptyFromZero",
+			first:  0,
+			second: 200,
+			want:   0,
+		},
+		{
+			name:   "ValidMultipleRequests",
+			first:  1,
+			second: 3,
 			rsps: []testRsp{
 				{
-					q:   "0.100.200.sth-consistency.a.b.c.",
-					txt: []string{""},
+					q:   "0.1.3.sth-consistency.a.b.c.",
+					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
 				},
 				{
-					q:   "1.100.200.sth-consistency.a.b.c.",
-					err: errors.New("finished"),
+					q:   "1.1.3.sth-consistency.a.b.c.",
+					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
 				},
 			},
-			want: 0,
+			want: 2,
 		},
 		{
-			name: "ValidMultipleRequests",
+			name:   "ValidMultipleSplit",
+			first:  1,
+			second: 5,
 			rsps: []testRsp{
 				{
-					q:   "0.100.200.sth-consistency.a.b.c.",
-					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
+					q: "0.1.5.sth-consistency.a.b.c.",
+					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+						"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
 				},
 				{
-					q:   "1.100.200.sth-consistency.a.b.c.",
+					q:   "2.1.5.sth-consistency.a.b.c.",
 					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
 				},
-				{
-					q:   "2.100.200.sth-consistency.a.b.c.",
-					err: errors.New("finished"),
-				},
 			},
-			want: 2,
+			want: 3,
 		},
 		{
-			name: "ValidMultipleSplit",
+			name:   "MissingRecord",
+			first:  1,
+			second: 5,
 			rsps: []testRsp{
 				{
-					q: "0.100.200.sth-consistency.a.b.c.",
+					q: "0.1.5.sth-consistency.a.b.c.",
 					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
 						"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
 				},
 				{
-					q:   "2.100.200.sth-consistency.a.b.c.",
+					q:   "2.1.5.sth-consistency.a.b.c.",
+					err: errors.New("no such host"),
+				},
+			},
+			wantErr: "2 of 3 proof node(s) retrieved",
+		},
+		{
+			name:   "MissingData",
+			first:  1,
+			second: 3,
+			rsps: []testRsp{
+				{
+					q:   "0.1.3.sth-consistency.a.b.c.",
 					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
 				},
 				{
-					q:   "3.100.200.sth-consistency.a.b.c.",
-					err: errors.New("finished"),
+					q:   "1.1.3.sth-consistency.a.b.c.",
+					txt: []string{""},
 				},
 			},
-			want: 3,
+			wantErr: "1 of 2 proof node(s) retrieved",
+		},
+		{
+			name:   "TooManyNodes",
+			first:  1,
+			second: 2,
+			rsps: []testRsp{
+				{
+					q: "0.1.2.sth-consistency.a.b.c.",
+					txt: []string{string(dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+						"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))},
+				},
+			},
+			wantErr: "got 2 proof node(s), want 1",
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			dc := testMultiClient(t, test.rsps)
-			proof, err := dc.GetSTHConsistency(ctx, 100, 200)
+			proof, err := dc.GetSTHConsistency(ctx, test.first, test.second)
 			if err != nil {
 				if test.wantErr == "" {
 					t.Fatalf("GetSTHConsistency()=nil,%v; want _, nil", err)
@@ -297,6 +385,22 @@ func TestGetSTHConsistency(t *testing.T) {
 	}
 }
 
+func TestConsistencyProofSize(t *testing.T) {
+	tree := merkle.NewInMemoryMerkleTree(rfc6962.DefaultHasher)
+	for n := int64(1); n <= 40; n++ {
+		tree.AddLeaf([]byte(fmt.Sprintf("leaf-%d", n)))
+		for m := int64(0); m <= n; m++ {
+			want := len(tree.SnapshotConsistency(m, n))
+			if m == 0 {
+				want = 0
+			}
+			if got := consistencyProofSize(uint64(m), uint64(n)); got != want {
+				t.Errorf("consistencyProofSize(%d, %d)=%d; want %d", m, n, got, want)
+			}
+		}
+	}
+}
+
 func TestGetProofByHash(t *testing.T) {
 	ctx := context.Background()
 	hash := dehex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
@@ -0,0 +1,114 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsclient
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dohContentType is the media type of DNS messages sent over HTTPS (RFC 8484).
+const dohContentType = "application/dns-message"
+
+// maxDoHResponseSize bounds the size of a DNS-over-HTTPS response body.
+const maxDoHResponseSize = 64 << 10
+
+// NewOverHTTPS constructs a DNSClient instance that makes its queries using
+// DNS-over-HTTPS (RFC 8484) to the resolver at the given URL (e.g.
+// "https://dns.google/dns-query"), rather than classic DNS.  Every query is
+// made using the given http.Client (or http.DefaultClient if nil), so its
+// timeout and Transport (e.g. for a proxy) apply.
+func NewOverHTTPS(base string, opts jsonclient.Options, resolverURL string, hc *http.Client) (*DNSClient, error) {
+	u, err := url.Parse(resolverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS-over-HTTPS resolver URL %q: %v", resolverURL, err)
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return newWithResolver(base, opts, func(ctx context.Context, name string) ([]string, error) {
+		return lookupTXTOverHTTPS(ctx, hc, u, name)
+	})
+}
+
+// lookupTXTOverHTTPS queries the DNS-over-HTTPS resolver at the given URL for
+// the TXT records of the given name, returning the contents of each record.
+func lookupTXTOverHTTPS(ctx context.Context, hc *http.Client, resolver *url.URL, name string) ([]string, error) {
+	qName, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %v", name, err)
+	}
+	// RFC 8484 s4.1: the ID should be zero, for cacheability.
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qName, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET}},
+	}
+	data, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query for %q: %v", name, err)
+	}
+
+	u := *resolver
+	params := u.Query()
+	params.Set("dns", base64.RawURLEncoding.EncodeToString(data))
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", dohContentType)
+	rsp, err := ctxhttp.Do(ctx, hc, req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got HTTP status %q from DNS-over-HTTPS resolver", rsp.Status)
+	}
+	if contentType := rsp.Header.Get("Content-Type"); contentType != dohContentType {
+		return nil, fmt.Errorf("got Content-Type %q from DNS-over-HTTPS resolver, want %q", contentType, dohContentType)
+	}
+	body, err := ioutil.ReadAll(&io.LimitedReader{R: rsp.Body, N: maxDoHResponseSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DNS-over-HTTPS response: %v", err)
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(body); err != nil {
+		return nil, fmt.Errorf("failed to parse DNS-over-HTTPS response: %v", err)
+	}
+	if msg.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("DNS-over-HTTPS lookup for %q failed: %v", name, msg.RCode)
+	}
+	var results []string
+	for _, rr := range msg.Answers {
+		txt, ok := rr.Body.(*dnsmessage.TXTResource)
+		if !ok || !strings.EqualFold(rr.Header.Name.String(), qName.String()) {
+			continue
+		}
+		results = append(results, strings.Join(txt.TXT, ""))
+	}
+	return results, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsclient
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const validSTHTXT = "224556042.1520614811284.uEM4ZVdEWUXnGqNzFiaWEwEW8keaU8HUdah+X4UQSHo=.BAMARjBEAiBvy2bp3DLPLHjAlTEVTcn5W/QBYUjBz8xJDGsuorhppwIgUPm+sLrKeBuETSMYFsPdwuGzd/u8Y2GNs9kfVDFv1PY="
+
+// dohHandler answers DNS-over-HTTPS queries for TXT records from the given
+// map, which holds the strings of a single record for each name.
+func dohHandler(t *testing.T, records map[string][]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != dohContentType {
+			t.Errorf("request Accept=%q; want %q", got, dohContentType)
+		}
+		data, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(data); err != nil || len(query.Questions) != 1 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		q := query.Questions[0]
+		rsp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: dnsmessage.RCodeNameError},
+			Questions: query.Questions,
+		}
+		if txt, ok := records[q.Name.String()]; ok && q.Type == dnsmessage.TypeTXT {
+			rsp.RCode = dnsmessage.RCodeSuccess
+			rsp.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.TXTResource{TXT: txt},
+			}}
+		}
+		out, err := rsp.Pack()
+		if err != nil {
+			t.Errorf("failed to pack response: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", dohContentType)
+		w.Write(out)
+	}
+}
+
+// countingTransport is an http.RoundTripper that counts the requests it makes.
+type countingTransport struct {
+	calls int
+}
+
+func (rt *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewOverHTTPS(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(dohHandler(t, map[string][]string{
+		"sth.test.example.com.":   {validSTHTXT[:100], validSTHTXT[100:]},
+		"sth.broken.example.com.": {"not-an-sth"},
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		base    string
+		wantErr string
+	}{
+		{base: "test.example.com"},
+		{base: "broken.example.com", wantErr: "failed to parse"},
+		{base: "missing.example.com", wantErr: "RCodeNameError"},
+	}
+	for _, test := range tests {
+		t.Run(test.base, func(t *testing.T) {
+			transport := &countingTransport{}
+			dc, err := NewOverHTTPS(test.base, jsonclient.Options{PublicKeyDER: rocketeerPubKey}, ts.URL+"/dns-query", &http.Client{Transport: transport})
+			if err != nil {
+				t.Fatalf("NewOverHTTPS()=nil,%v; want _,nil", err)
+			}
+			sth, err := dc.GetSTH(ctx)
+			if transport.calls != 1 {
+				t.Errorf("GetSTH() made %d request(s) with custom transport; want 1", transport.calls)
+			}
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("GetSTH()=%+v,%v; want _,error containing %q", sth, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetSTH()=nil,%v; want _,nil", err)
+			}
+			if got, want := sth.TreeSize, uint64(224556042); got != want {
+				t.Errorf("GetSTH().TreeSize=%d; want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestNewOverHTTPSResolverErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		desc    string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{
+			desc:    "http-error",
+			handler: func(w http.ResponseWriter, r *http.Request) { http.Error(w, "oops", http.StatusServiceUnavailable) },
+			wantErr: "503",
+		},
+		{
+			desc: "wrong-content-type",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/dns-json")
+				w.Write([]byte("{}"))
+			},
+			wantErr: "Content-Type",
+		},
+		{
+			desc: "garbage",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", dohContentType)
+				w.Write([]byte{0x01})
+			},
+			wantErr: "failed to parse DNS-over-HTTPS response",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ts := httptest.NewServer(test.handler)
+			defer ts.Close()
+			dc, err := NewOverHTTPS("test.example.com", jsonclient.Options{}, ts.URL, nil)
+			if err != nil {
+				t.Fatalf("NewOverHTTPS()=nil,%v; want _,nil", err)
+			}
+			if _, err := dc.GetSTH(ctx); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("GetSTH()=_,%v; want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+	if _, err := NewOverHTTPS("test.example.com", jsonclient.Options{}, "://bad", nil); err == nil {
+		t.Error("NewOverHTTPS(bad URL)=_,nil; want _,non-nil")
+	}
+}
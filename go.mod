@@ -22,6 +22,7 @@ require (
 	go.etcd.io/etcd v0.0.0-20200513171258-e048e166ab9c
 	golang.org/x/crypto v0.0.0-20200311171314-f7b00557c8c4
 	golang.org/x/net v0.0.0-20200520182314-0ba52f642ac2
+	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1
 	google.golang.org/genproto v0.0.0-20200626011028-ee7919e894b5
 	google.golang.org/grpc v1.29.1
 	google.golang.org/protobuf v1.25.0
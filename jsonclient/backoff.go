@@ -21,12 +21,13 @@ import (
 
 type backoff struct {
 	mu         sync.RWMutex
+	base       time.Duration // initial interval; one second if zero
 	multiplier uint
 	notBefore  time.Time
 }
 
 const (
-	// maximum backoff is 2^(maxMultiplier-1) = 128 seconds
+	// maximum backoff is 2^(maxMultiplier-1) = 128 times the base interval
 	maxMultiplier = 8
 )
 
@@ -51,7 +52,11 @@ func (b *backoff) set(override *time.Duration) time.Duration {
 		if b.multiplier < maxMultiplier {
 			b.multiplier++
 		}
-		wait = time.Second * time.Duration(1<<(b.multiplier-1))
+		base := b.base
+		if base == 0 {
+			base = time.Second
+		}
+		wait = base * time.Duration(1<<(b.multiplier-1))
 	}
 	b.notBefore = time.Now().Add(wait)
 	return wait
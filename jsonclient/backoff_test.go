@@ -109,3 +109,13 @@ func TestBackoffOverride(t *testing.T) {
 		}
 	}
 }
+
+func TestBackoffBase(t *testing.T) {
+	b := backoff{base: 100 * time.Millisecond}
+	for i := uint(0); i < 3; i++ {
+		if got, want := b.set(nil), 100*time.Millisecond*(1<<i); got != want {
+			t.Fatalf("backoff.set(nil)=%v; want %v", got, want)
+		}
+		b.notBefore = time.Time{}
+	}
+}
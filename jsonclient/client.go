@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -34,6 +35,7 @@ import (
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/x509"
 	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/time/rate"
 )
 
 const maxJitter = 250 * time.Millisecond
@@ -60,6 +62,9 @@ type JSONClient struct {
 	logger     Logger                // interface to use for logging warnings and errors
 	backoff    backoffer             // object used to store and calculate backoff information
 	userAgent  string                // If set, this is sent as the UserAgent header.
+	maxRetries int                   // If > 0, the maximum number of retries by PostAndParseWithRetry.
+	limiter    Limiter               // If set, waited on before each request.
+	observer   Observer              // Told about each HTTP round trip.
 }
 
 // Logger is a simple logging interface used to log internal errors and warnings
@@ -68,6 +73,33 @@ type Logger interface {
 	Printf(string, ...interface{})
 }
 
+// Limiter controls the rate of requests made by a client.
+type Limiter interface {
+	// Wait blocks until the next request may be made, or returns an error if
+	// the context is done first.
+	Wait(ctx context.Context) error
+}
+
+// NewRateLimiter returns a token bucket Limiter that allows qps requests per
+// second on average, with bursts of up to burst requests.
+func NewRateLimiter(qps float64, burst int) Limiter {
+	return rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+// Observer is told about the HTTP requests made by a client, e.g. to export
+// per-endpoint latency and status code metrics.
+type Observer interface {
+	// RequestDone is called after each HTTP round trip to the given path
+	// (e.g. "/ct/v1/get-sth"), including retries.  The status is the HTTP
+	// status code of the response, or 0 if no response was received, in
+	// which case err describes the failure.
+	RequestDone(path string, status int, latency time.Duration, err error)
+}
+
+type noopObserver struct{}
+
+func (noopObserver) RequestDone(string, int, time.Duration, error) {}
+
 // Options are the options for creating a new JSONClient.
 type Options struct {
 	// Interface to use for logging warnings and errors, if nil the
@@ -79,6 +111,19 @@ type Options struct {
 	PublicKeyDER []byte
 	// UserAgent, if set, will be sent as the User-Agent header with each request.
 	UserAgent string
+	// MaxRetries, if > 0, limits the number of times that PostAndParseWithRetry
+	// retries a request; otherwise requests are retried until the context
+	// expires.
+	MaxRetries int
+	// BackoffBase is the initial interval that PostAndParseWithRetry waits
+	// before retrying, which doubles with each consecutive failure; if zero,
+	// one second is used.
+	BackoffBase time.Duration
+	// Limiter, if set, is waited on before every request made by the client,
+	// including retries; if nil, requests are not throttled.
+	Limiter Limiter
+	// Observer, if set, is told about every HTTP request made by the client.
+	Observer Observer
 }
 
 // ParsePublicKey parses and returns the public key contained in opts.
@@ -122,8 +167,57 @@ func (e RspError) Error() string {
 	return e.Err.Error()
 }
 
+// Unwrap returns the underlying error, so that errors.Is and errors.As can
+// inspect it (e.g. for a ParseError).
+func (e RspError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is the Err of a RspError for a response that was received but
+// whose body could not be parsed, e.g. malformed JSON.
+type ParseError struct {
+	Err error
+}
+
+// Error formats the ParseError instance, as the underlying error.
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying parsing error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient indicates whether a request that failed with the given error
+// may succeed if retried: that is, if no HTTP response was received because of
+// a network error or timeout, or the response had HTTP status 408, 429 or 5xx.
+// HTTP 4xx rejections, unparseable responses and the cancellation or expiry
+// of the caller's context are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return false
+	}
+	var rspErr RspError
+	if errors.As(err, &rspErr) && rspErr.StatusCode != 0 {
+		switch rspErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return rspErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
 // New constructs a new JSONClient instance, for the given base URI, using the
 // given http.Client object (if provided) and the Options object.
+// Every request is made with the given http.Client, so its Transport can be
+// used to route requests via a proxy or to present client certificates.
 // If opts does not specify a public key, signatures will not be verified.
 func New(uri string, hc *http.Client, opts Options) (*JSONClient, error) {
 	pubkey, err := opts.ParsePublicKey()
@@ -147,13 +241,20 @@ func New(uri string, hc *http.Client, opts Options) (*JSONClient, error) {
 	if logger == nil {
 		logger = &basicLogger{}
 	}
+	observer := opts.Observer
+	if observer == nil {
+		observer = noopObserver{}
+	}
 	return &JSONClient{
 		uri:        strings.TrimRight(uri, "/"),
 		httpClient: hc,
 		Verifier:   verifier,
 		logger:     logger,
-		backoff:    &backoff{},
+		backoff:    &backoff{base: opts.BackoffBase},
 		userAgent:  opts.UserAgent,
+		maxRetries: opts.MaxRetries,
+		limiter:    opts.Limiter,
+		observer:   observer,
 	}, nil
 }
 
@@ -162,11 +263,44 @@ func (c *JSONClient) BaseURI() string {
 	return c.uri
 }
 
+// ErrNotModified is returned by GetAndParseIfModified when the server reports
+// that the resource has not changed.
+var ErrNotModified = errors.New("not modified")
+
+// Validators identify the version of a resource returned by a server, so that
+// it can be requested again only if it has changed.  They can be persisted,
+// e.g. across process restarts, along with the resource itself.
+type Validators struct {
+	ETag         string // the ETag header of the response, if any
+	LastModified string // the Last-Modified header of the response, if any
+}
+
+// ValidatorsFrom returns the validators of the given HTTP response.
+func ValidatorsFrom(rsp *http.Response) Validators {
+	if rsp == nil {
+		return Validators{}
+	}
+	return Validators{ETag: rsp.Header.Get("ETag"), LastModified: rsp.Header.Get("Last-Modified")}
+}
+
 // GetAndParse makes a HTTP GET call to the given path, and attempts to parse
 // the response as a JSON representation of the rsp structure.  Returns the
 // http.Response, the body of the response, and an error (which may be of
 // type RspError if the HTTP response was available).
 func (c *JSONClient) GetAndParse(ctx context.Context, path string, params map[string]string, rsp interface{}) (*http.Response, []byte, error) {
+	return c.getAndParse(ctx, path, params, Validators{}, rsp)
+}
+
+// GetAndParseIfModified is as GetAndParse, but makes a conditional request
+// with the given validators (from an earlier response, see ValidatorsFrom),
+// sending If-None-Match and If-Modified-Since headers.  If the server responds
+// with HTTP status 304 Not Modified, returns the http.Response and
+// ErrNotModified, leaving rsp untouched.
+func (c *JSONClient) GetAndParseIfModified(ctx context.Context, path string, params map[string]string, v Validators, rsp interface{}) (*http.Response, []byte, error) {
+	return c.getAndParse(ctx, path, params, v, rsp)
+}
+
+func (c *JSONClient) getAndParse(ctx context.Context, path string, params map[string]string, v Validators, rsp interface{}) (*http.Response, []byte, error) {
 	if ctx == nil {
 		return nil, nil, errors.New("context.Context required")
 	}
@@ -184,8 +318,17 @@ func (c *JSONClient) GetAndParse(ctx context.Context, path string, params map[st
 	if len(c.userAgent) != 0 {
 		httpReq.Header.Set("User-Agent", c.userAgent)
 	}
+	if v.ETag != "" {
+		httpReq.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		httpReq.Header.Set("If-Modified-Since", v.LastModified)
+	}
 
-	httpRsp, err := ctxhttp.Do(ctx, c.httpClient, httpReq)
+	if err := c.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	httpRsp, err := c.do(ctx, path, httpReq)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -197,12 +340,15 @@ func (c *JSONClient) GetAndParse(ctx context.Context, path string, params map[st
 		return nil, nil, RspError{Err: fmt.Errorf("failed to read response body: %v", err), StatusCode: httpRsp.StatusCode, Body: body}
 	}
 
+	if httpRsp.StatusCode == http.StatusNotModified && v != (Validators{}) {
+		return httpRsp, body, ErrNotModified
+	}
 	if httpRsp.StatusCode != http.StatusOK {
 		return nil, nil, RspError{Err: fmt.Errorf("got HTTP Status %q", httpRsp.Status), StatusCode: httpRsp.StatusCode, Body: body}
 	}
 
 	if err := json.NewDecoder(bytes.NewReader(body)).Decode(rsp); err != nil {
-		return nil, nil, RspError{Err: err, StatusCode: httpRsp.StatusCode, Body: body}
+		return nil, nil, RspError{Err: &ParseError{Err: err}, StatusCode: httpRsp.StatusCode, Body: body}
 	}
 
 	return httpRsp, body, nil
@@ -232,7 +378,10 @@ func (c *JSONClient) PostAndParse(ctx context.Context, path string, req, rsp int
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	httpRsp, err := ctxhttp.Do(ctx, c.httpClient, httpReq)
+	if err := c.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	httpRsp, err := c.do(ctx, path, httpReq)
 
 	// Read all of the body, if there is one, so that the http.Client can do Keep-Alive.
 	var body []byte
@@ -249,12 +398,33 @@ func (c *JSONClient) PostAndParse(ctx context.Context, path string, req, rsp int
 
 	if httpRsp.StatusCode == http.StatusOK {
 		if err = json.Unmarshal(body, &rsp); err != nil {
-			return nil, nil, RspError{StatusCode: httpRsp.StatusCode, Body: body, Err: err}
+			return nil, nil, RspError{StatusCode: httpRsp.StatusCode, Body: body, Err: &ParseError{Err: err}}
 		}
 	}
 	return httpRsp, body, nil
 }
 
+// wait blocks until the client's limiter, if any, allows a request.
+func (c *JSONClient) wait(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.Wait(ctx)
+}
+
+// do performs the HTTP request, reporting the round trip to the client's
+// observer.
+func (c *JSONClient) do(ctx context.Context, path string, httpReq *http.Request) (*http.Response, error) {
+	start := time.Now()
+	httpRsp, err := ctxhttp.Do(ctx, c.httpClient, httpReq)
+	status := 0
+	if httpRsp != nil {
+		status = httpRsp.StatusCode
+	}
+	c.observer.RequestDone(path, status, time.Since(start), err)
+	return httpRsp, err
+}
+
 // waitForBackoff blocks until the defined backoff interval or context has expired, if the returned
 // not before time is in the past it returns immediately.
 func (c *JSONClient) waitForBackoff(ctx context.Context) error {
@@ -271,30 +441,41 @@ func (c *JSONClient) waitForBackoff(ctx context.Context) error {
 	return nil
 }
 
-// PostAndParseWithRetry makes a HTTP POST call, but retries (with backoff) on
-// retriable errors; the caller should set a deadline on the provided context
-// to prevent infinite retries.  Return values are as for PostAndParse.
+// PostAndParseWithRetry makes a HTTP POST call, but retries (with backoff and
+// jitter) on retriable errors, which include HTTP statuses 408, 429 and 503;
+// the backoff for 429 and 503 responses honours any Retry-After header.  Unless
+// Options.MaxRetries was set, the caller should set a deadline on the provided
+// context to prevent infinite retries.  Return values are as for PostAndParse.
 func (c *JSONClient) PostAndParseWithRetry(ctx context.Context, path string, req, rsp interface{}) (*http.Response, []byte, error) {
 	if ctx == nil {
 		return nil, nil, errors.New("context.Context required")
 	}
-	for {
+	for retries := 0; ; retries++ {
 		httpRsp, body, err := c.PostAndParse(ctx, path, req, rsp)
 		if err != nil {
 			// Don't retry context errors.
 			if err == context.Canceled || err == context.DeadlineExceeded {
 				return nil, nil, err
 			}
+			if c.maxRetries > 0 && retries >= c.maxRetries {
+				return nil, nil, err
+			}
 			wait := c.backoff.set(nil)
 			c.logger.Printf("Request to %s failed, backing-off %s: %s", c.uri, wait, err)
 		} else {
+			if httpRsp.StatusCode != http.StatusOK && c.maxRetries > 0 && retries >= c.maxRetries {
+				return nil, nil, RspError{
+					StatusCode: httpRsp.StatusCode,
+					Body:       body,
+					Err:        fmt.Errorf("got HTTP status %q after %d retries", httpRsp.Status, retries)}
+			}
 			switch {
 			case httpRsp.StatusCode == http.StatusOK:
 				return httpRsp, body, nil
 			case httpRsp.StatusCode == http.StatusRequestTimeout:
 				// Request timeout, retry immediately
 				c.logger.Printf("Request to %s timed out, retrying immediately", c.uri)
-			case httpRsp.StatusCode == http.StatusServiceUnavailable:
+			case httpRsp.StatusCode == http.StatusServiceUnavailable, httpRsp.StatusCode == http.StatusTooManyRequests:
 				var backoff *time.Duration
 				// Retry-After may be either a number of seconds as a int or a RFC 1123
 				// date string (RFC 7231 Section 7.1.3)
@@ -18,9 +18,12 @@ import (
 	"context"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
@@ -179,6 +182,16 @@ func MockServer(t *testing.T, failCount int, retryAfter int) *httptest.Server {
 			} else {
 				fmt.Fprintf(w, `{"tree_size": 11, "timestamp": 99}`)
 			}
+		case "/ratelimit":
+			if failCount > 0 {
+				failCount--
+				if retryAfter > 0 {
+					w.Header().Add("Retry-After", strconv.Itoa(retryAfter))
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+			} else {
+				fmt.Fprintf(w, `{"tree_size": 11, "timestamp": 99}`)
+			}
 		case "/retry-rfc1123":
 			if failCount > 0 {
 				failCount--
@@ -457,6 +470,174 @@ func TestPostAndParseWithRetry(t *testing.T) {
 	}
 }
 
+func TestPostAndParseWithRetryLimits(t *testing.T) {
+	tests := []struct {
+		desc            string
+		uri             string
+		request         interface{}
+		maxRetries      int
+		retryAfter      int
+		failCount       int
+		wantErr         string
+		expectedBackoff time.Duration
+	}{
+		{desc: "rate-limited", uri: "/ratelimit", failCount: 2, retryAfter: 5, expectedBackoff: 5 * time.Second},
+		{desc: "rate-limited-within-limit", uri: "/ratelimit", maxRetries: 2, failCount: 2},
+		{desc: "rate-limited-over-limit", uri: "/ratelimit", maxRetries: 2, failCount: 3, wantErr: "429 Too Many Requests\" after 2 retries"},
+		{desc: "unavailable-over-limit", uri: "/retry", maxRetries: 1, failCount: 2, retryAfter: -1, wantErr: "503 Service Unavailable\" after 1 retries"},
+		{desc: "client-error", uri: "/error", request: TestParams{RespCode: 403}, maxRetries: 3, wantErr: "403 Forbidden"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ts := MockServer(t, test.failCount, test.retryAfter)
+			defer ts.Close()
+
+			logClient, err := New(ts.URL, &http.Client{}, Options{MaxRetries: test.maxRetries})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mb := mockBackoff{}
+			logClient.backoff = &mb
+
+			var got TestStruct
+			_, _, err = logClient.PostAndParseWithRetry(context.Background(), test.uri, test.request, &got)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("PostAndParseWithRetry()=%+v,%v; want error %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("PostAndParseWithRetry()=nil,%q; want no error", err.Error())
+			}
+			if test.expectedBackoff > 0 && !fuzzyDurationEquals(test.expectedBackoff, mb.override, time.Second) {
+				t.Errorf("Unexpected backoff override set: got: %s, wanted: %s", mb.override, test.expectedBackoff)
+			}
+		})
+	}
+}
+
+// countingLimiter counts calls to Wait, failing them with err if set.
+type countingLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.calls++
+	return l.err
+}
+
+func TestLimiter(t *testing.T) {
+	ts := MockServer(t, 1, -1)
+	defer ts.Close()
+	limiter := &countingLimiter{}
+	logClient, err := New(ts.URL, &http.Client{}, Options{Limiter: limiter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logClient.backoff = &mockBackoff{}
+	ctx := context.Background()
+
+	var result TestStruct
+	if _, _, err := logClient.GetAndParse(ctx, "/struct/path", nil, &result); err != nil {
+		t.Fatalf("GetAndParse()=_,_,%v; want nil", err)
+	}
+	if _, _, err := logClient.PostAndParse(ctx, "/struct/path", nil, &result); err != nil {
+		t.Fatalf("PostAndParse()=_,_,%v; want nil", err)
+	}
+	// The first attempt fails with a 503, so the request is made twice.
+	if _, _, err := logClient.PostAndParseWithRetry(ctx, "/retry", nil, &result); err != nil {
+		t.Fatalf("PostAndParseWithRetry()=_,_,%v; want nil", err)
+	}
+	if got, want := limiter.calls, 4; got != want {
+		t.Errorf("Limiter.Wait() called %d times; want %d", got, want)
+	}
+
+	limiter.err = errors.New("limiter says no")
+	if _, _, err := logClient.GetAndParse(ctx, "/struct/path", nil, &result); err != limiter.err {
+		t.Errorf("GetAndParse()=_,_,%v; want %v", err, limiter.err)
+	}
+	if _, _, err := logClient.PostAndParse(ctx, "/struct/path", nil, &result); err != limiter.err {
+		t.Errorf("PostAndParse()=_,_,%v; want %v", err, limiter.err)
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(0.001, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != nil {
+		t.Fatalf("Wait()=%v for first request; want nil", err)
+	}
+	if err := limiter.Wait(ctx); err == nil {
+		t.Error("Wait()=nil for request beyond burst; want error")
+	}
+}
+
+type observed struct {
+	path   string
+	status int
+	err    bool
+}
+
+type recordingObserver struct {
+	requests []observed
+}
+
+func (o *recordingObserver) RequestDone(path string, status int, latency time.Duration, err error) {
+	o.requests = append(o.requests, observed{path: path, status: status, err: err != nil})
+}
+
+func TestObserver(t *testing.T) {
+	ts := MockServer(t, 1, -1)
+	defer ts.Close()
+	observer := &recordingObserver{}
+	logClient, err := New(ts.URL, &http.Client{}, Options{Observer: observer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logClient.backoff = &mockBackoff{}
+	ctx := context.Background()
+
+	var result TestStruct
+	if _, _, err := logClient.GetAndParse(ctx, "/struct/path", nil, &result); err != nil {
+		t.Fatalf("GetAndParse()=_,_,%v; want nil", err)
+	}
+	// The first attempt fails with a 503, so the request is made twice.
+	if _, _, err := logClient.PostAndParseWithRetry(ctx, "/retry", nil, &result); err != nil {
+		t.Fatalf("PostAndParseWithRetry()=_,_,%v; want nil", err)
+	}
+	// A request that gets no response is reported with status 0.
+	ts.Close()
+	if _, _, err := logClient.GetAndParse(ctx, "/struct/path", nil, &result); err == nil {
+		t.Fatal("GetAndParse(closed server)=_,_,nil; want error")
+	}
+
+	want := []observed{
+		{path: "/struct/path", status: http.StatusOK},
+		{path: "/retry", status: http.StatusServiceUnavailable},
+		{path: "/retry", status: http.StatusOK},
+		{path: "/struct/path", err: true},
+	}
+	if !reflect.DeepEqual(observer.requests, want) {
+		t.Errorf("observed requests %+v; want %+v", observer.requests, want)
+	}
+}
+
+func TestNoObserver(t *testing.T) {
+	ts := MockServer(t, -1, 0)
+	defer ts.Close()
+	logClient, err := New(ts.URL, &http.Client{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result TestStruct
+	if _, _, err := logClient.GetAndParse(context.Background(), "/struct/path", nil, &result); err != nil {
+		t.Errorf("GetAndParse()=_,_,%v; want nil", err)
+	}
+}
+
 // nolint:staticcheck
 func TestContextRequired(t *testing.T) {
 	ts := MockServer(t, -1, 0)
@@ -505,3 +686,66 @@ func TestCancelledContext(t *testing.T) {
 		t.Errorf("PostAndParseWithRetry() = (_,_,%v), want %q", err, context.Canceled)
 	}
 }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{desc: "nil"},
+		{desc: "other", err: errors.New("oops")},
+		{desc: "canceled", err: context.Canceled},
+		{desc: "deadline", err: fmt.Errorf("request failed: %w", context.DeadlineExceeded)},
+		{desc: "network", err: &url.Error{Op: "Get", URL: "https://ct.example.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, want: true},
+		{desc: "rejected", err: RspError{Err: errors.New("403"), StatusCode: http.StatusForbidden}},
+		{desc: "not-found", err: RspError{Err: errors.New("404"), StatusCode: http.StatusNotFound}},
+		{desc: "timeout-status", err: RspError{Err: errors.New("408"), StatusCode: http.StatusRequestTimeout}, want: true},
+		{desc: "rate-limited", err: RspError{Err: errors.New("429"), StatusCode: http.StatusTooManyRequests}, want: true},
+		{desc: "server-error", err: RspError{Err: errors.New("503"), StatusCode: http.StatusServiceUnavailable}, want: true},
+		{desc: "parse-error", err: RspError{Err: &ParseError{Err: errors.New("bad JSON")}, StatusCode: http.StatusOK}},
+		{desc: "wrapped", err: fmt.Errorf("GetSTH: %w", RspError{Err: errors.New("502"), StatusCode: http.StatusBadGateway}), want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := IsTransient(test.err); got != test.want {
+				t.Errorf("IsTransient(%v)=%v; want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetAndParseErrorTypes(t *testing.T) {
+	ctx := context.Background()
+	ts := MockServer(t, -1, 0)
+	logClient, err := New(ts.URL, &http.Client{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TestStruct
+
+	_, _, err = logClient.GetAndParse(ctx, "/malformed", nil, &got)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || IsTransient(err) {
+		t.Errorf("GetAndParse(malformed)=_,_,%v; want non-transient ParseError", err)
+	}
+	if err == nil || err.Error() != "unexpected EOF" {
+		t.Errorf("GetAndParse(malformed)=_,_,%v; want error %q", err, "unexpected EOF")
+	}
+
+	_, _, err = logClient.GetAndParse(ctx, "/error", map[string]string{"rc": "503"}, &got)
+	var rspErr RspError
+	if !errors.As(err, &rspErr) || rspErr.StatusCode != http.StatusServiceUnavailable || !IsTransient(err) {
+		t.Errorf("GetAndParse(503)=_,_,%v; want transient RspError with status 503", err)
+	}
+	_, _, err = logClient.GetAndParse(ctx, "/error", map[string]string{"rc": "404"}, &got)
+	if !errors.As(err, &rspErr) || rspErr.StatusCode != http.StatusNotFound || IsTransient(err) {
+		t.Errorf("GetAndParse(404)=_,_,%v; want non-transient RspError with status 404", err)
+	}
+
+	// Once the server has gone away, requests fail transiently.
+	ts.Close()
+	if _, _, err = logClient.GetAndParse(ctx, "/struct/path", nil, &got); !IsTransient(err) {
+		t.Errorf("GetAndParse(server closed)=_,_,%v; want transient error", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loglist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// ErrNotModified is returned by Fetch when the log list has not changed since
+// it was last fetched.
+var ErrNotModified = errors.New("log list not modified")
+
+// Fetched is a log list retrieved by Fetch, together with the validators that
+// identify its version, so that it is only downloaded again once it changes.
+// The validators may be persisted (along with the list) and restored, e.g.
+// across process restarts.
+type Fetched struct {
+	List         *LogList
+	ETag         string // the ETag header of the response, if any
+	LastModified string // the Last-Modified header of the response, if any
+}
+
+// Fetch retrieves and parses the log list at the given URL, with the given
+// HTTP client (http.DefaultClient if nil).  If prev holds a previously fetched
+// list, the request is made conditional on the list having changed since, by
+// sending If-None-Match and If-Modified-Since headers; if the server responds
+// with HTTP status 304 Not Modified, prev is returned with ErrNotModified.
+func Fetch(ctx context.Context, hc *http.Client, url string, prev *Fetched) (*Fetched, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	conditional := prev != nil && prev.List != nil
+	if conditional && prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	if conditional && prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
+	rsp, err := ctxhttp.Do(ctx, hc, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch log list: %v", err)
+	}
+	defer rsp.Body.Close()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log list: %v", err)
+	}
+	if rsp.StatusCode == http.StatusNotModified && conditional {
+		return prev, ErrNotModified
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch log list: got HTTP status %q", rsp.Status)
+	}
+	ll, err := NewFromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Fetched{
+		List:         ll,
+		ETag:         rsp.Header.Get("ETag"),
+		LastModified: rsp.Header.Get("Last-Modified"),
+	}, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loglist
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/testdata"
+)
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	etag := `"v1"`
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	body := testdata.SampleLogList
+	var full int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	first, err := Fetch(ctx, nil, ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Fetch()=nil,%v; want _,nil", err)
+	}
+	if got, want := len(first.List.Logs), len(sampleLogList.Logs); got != want {
+		t.Errorf("Fetch().List has %d logs; want %d", got, want)
+	}
+	if first.ETag != etag || first.LastModified != lastModified {
+		t.Errorf("Fetch() validators=%q,%q; want %q,%q", first.ETag, first.LastModified, etag, lastModified)
+	}
+
+	got, err := Fetch(ctx, nil, ts.URL, first)
+	if err != ErrNotModified || got != first {
+		t.Errorf("Fetch(unchanged)=%p,%v; want %p,ErrNotModified", got, err, first)
+	}
+	// Validators restored without a list cannot be used.
+	if _, err := Fetch(ctx, nil, ts.URL, &Fetched{ETag: etag, LastModified: lastModified}); err != nil {
+		t.Errorf("Fetch(validators only)=_,%v; want _,nil", err)
+	}
+
+	etag = `"v2"`
+	got, err = Fetch(ctx, nil, ts.URL, first)
+	if err != nil || got.ETag != etag {
+		t.Errorf("Fetch(changed)=%+v,%v; want ETag %s,nil", got, err, etag)
+	}
+	if full != 3 {
+		t.Errorf("Fetch() made %d full responses; want 3", full)
+	}
+
+	body = "not-json"
+	etag = `"v3"`
+	if _, err := Fetch(ctx, nil, ts.URL, got); !errors.Is(err, ErrMalformedJSON) {
+		t.Errorf("Fetch(malformed)=_,%v; want error wrapping ErrMalformedJSON", err)
+	}
+	if _, err := Fetch(ctx, nil, ts.URL+"/%", nil); err == nil {
+		t.Error("Fetch(bad URL)=_,nil; want _,non-nil")
+	}
+}
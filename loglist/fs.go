@@ -0,0 +1,33 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.16
+// +build go1.16
+
+package loglist
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// ParseFS creates a LogList from the JSON encoded data in the named file of the
+// given filesystem, such as a log list embedded in a binary with embed.FS.
+func ParseFS(fsys fs.FS, name string) (*LogList, error) {
+	llData, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log list: %v", err)
+	}
+	return NewFromJSON(llData)
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.16
+// +build go1.16
+
+package loglist
+
+import (
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"invalid.json": &fstest.MapFile{Data: []byte(`{"logs":[`)},
+	}
+	tests := []struct {
+		desc    string
+		fsys    fs.FS
+		name    string
+		wantErr string
+	}{
+		{desc: "valid", fsys: os.DirFS("testdata"), name: "log_list.json"},
+		{desc: "missing", fsys: os.DirFS("testdata"), name: "missing.json", wantErr: "failed to read log list"},
+		{desc: "invalid", fsys: fsys, name: "invalid.json", wantErr: "failed to parse log list"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := ParseFS(test.fsys, test.name)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("ParseFS()=_,%v; want _,err containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFS()=_,%v; want _,nil", err)
+			}
+			if !reflect.DeepEqual(*got, sampleLogList) {
+				t.Errorf("ParseFS()=%+v; want %+v", *got, sampleLogList)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/certificate-transparency-go/loglist2"
 	"github.com/google/certificate-transparency-go/trillian/ctfe"
 	"github.com/google/certificate-transparency-go/x509"
 )
@@ -40,6 +41,76 @@ func (ll *LogList) ActiveLogs() LogList {
 	return active
 }
 
+// LogStatus indicates the state of a log, as for the v2 and v3 log lists.
+type LogStatus = loglist2.LogStatus
+
+// LogStatus values
+const (
+	UndefinedLogStatus = loglist2.UndefinedLogStatus
+	PendingLogStatus   = loglist2.PendingLogStatus
+	QualifiedLogStatus = loglist2.QualifiedLogStatus
+	UsableLogStatus    = loglist2.UsableLogStatus
+	ReadOnlyLogStatus  = loglist2.ReadOnlyLogStatus
+	RetiredLogStatus   = loglist2.RetiredLogStatus
+	RejectedLogStatus  = loglist2.RejectedLogStatus
+)
+
+// Status returns the state of the log.  This is its State if set; otherwise
+// it is derived from the fields of the v1 list: a log that has been
+// disqualified is retired, a frozen log is read-only, and any other log is
+// usable.
+func (l *Log) Status() LogStatus {
+	switch {
+	case l.State != nil:
+		return l.State.LogStatus()
+	case l.DisqualifiedAt > 0 && time.Until(time.Unix(int64(l.DisqualifiedAt), 0)) <= 0:
+		return RetiredLogStatus
+	case l.FinalSTH != nil:
+		return ReadOnlyLogStatus
+	default:
+		return UsableLogStatus
+	}
+}
+
+// Active creates a new LogList containing only the logs of the original that
+// can currently be submitted to, i.e. those that are usable or qualified (see
+// Status).  Retired, rejected, read-only and pending logs are left out.
+func (ll *LogList) Active() *LogList {
+	return ll.WithStates(UsableLogStatus, QualifiedLogStatus)
+}
+
+// WithStates creates a new LogList containing only the logs of the original
+// whose state (see Status) is one of those given.  All the operators are
+// kept.
+func (ll *LogList) WithStates(states ...LogStatus) *LogList {
+	want := make(map[LogStatus]bool)
+	for _, state := range states {
+		want[state] = true
+	}
+	selected := LogList{Operators: ll.Operators}
+	for _, l := range ll.Logs {
+		if want[l.Status()] {
+			selected.Logs = append(selected.Logs, l)
+		}
+	}
+	return &selected
+}
+
+// LogsForCert returns the logs that accept certificates with the given expiry
+// date: those whose temporal interval contains it (including the start of the
+// interval, but not the end, as for Chrome's CT policy), and those with no
+// temporal interval, which accept certificates of any expiry.  The logs'
+// states are not checked, so the list may first need filtering (see Active).
+func (ll *LogList) LogsForCert(notAfter time.Time) []*Log {
+	var logs []*Log
+	for i, l := range ll.Logs {
+		if ti := l.TemporalInterval; ti == nil || (!notAfter.Before(ti.StartInclusive) && notAfter.Before(ti.EndExclusive)) {
+			logs = append(logs, &ll.Logs[i])
+		}
+	}
+	return logs
+}
+
 // Compatible creates a new LogList containing only the logs of original
 // LogList that are compatible with the provided cert, according to
 // the passed in collection of per-log roots. Logs that are missing from
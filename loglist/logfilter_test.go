@@ -14,8 +14,11 @@
 package loglist
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/google/certificate-transparency-go/loglist2"
 	"github.com/google/certificate-transparency-go/testdata"
 	"github.com/google/certificate-transparency-go/trillian/ctfe"
 	"github.com/google/certificate-transparency-go/x509"
@@ -35,6 +38,55 @@ func subLogList(logURLs map[string]bool) LogList {
 	return ll
 }
 
+func TestWithStates(t *testing.T) {
+	ll := sampleLogList
+	ll.Logs = append(ll.Logs[:len(ll.Logs):len(ll.Logs)],
+		Log{Description: "Pending", State: &loglist2.LogStates{Pending: &loglist2.LogState{}}},
+		Log{Description: "Qualified", State: &loglist2.LogStates{Qualified: &loglist2.LogState{}}},
+		// An explicit state takes precedence over the v1 fields.
+		Log{Description: "Rejected", DisqualifiedAt: 1460678400, State: &loglist2.LogStates{Rejected: &loglist2.LogState{}}},
+	)
+	tests := []struct {
+		desc string
+		got  *LogList
+		want []string
+	}{
+		{
+			desc: "active",
+			got:  ll.Active(),
+			want: []string{"Google 'Icarus' log", "Google 'Rocketeer' log", "Google 'Racketeer' log", "Qualified"},
+		},
+		{
+			desc: "readonly-and-retired",
+			got:  ll.WithStates(ReadOnlyLogStatus, RetiredLogStatus),
+			want: []string{"Google 'Aviator' log", "Bob's Dubious Log"},
+		},
+		{
+			desc: "pending-and-rejected",
+			got:  ll.WithStates(PendingLogStatus, RejectedLogStatus),
+			want: []string{"Pending", "Rejected"},
+		},
+		{
+			desc: "none",
+			got:  ll.WithStates(),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var got []string
+			for _, l := range test.got.Logs {
+				got = append(got, l.Description)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("WithStates()=%v; want %v", got, test.want)
+			}
+			if !reflect.DeepEqual(test.got.Operators, ll.Operators) {
+				t.Errorf("WithStates().Operators=%v; want %v", test.got.Operators, ll.Operators)
+			}
+		})
+	}
+}
+
 func TestActiveLogs(t *testing.T) {
 	tests := []struct {
 		name string
@@ -124,3 +176,46 @@ func TestCompatible(t *testing.T) {
 		})
 	}
 }
+
+func TestLogsForCert(t *testing.T) {
+	shard := func(desc string, year int, operator int) Log {
+		return Log{
+			Description: desc,
+			OperatedBy:  []int{operator},
+			TemporalInterval: &loglist2.TemporalInterval{
+				StartInclusive: time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
+				EndExclusive:   time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		}
+	}
+	ll := &LogList{
+		Operators: []Operator{{ID: 0, Name: "A"}, {ID: 1, Name: "B"}},
+		Logs: []Log{
+			shard("A 2021", 2021, 0),
+			shard("A 2022", 2022, 0),
+			{Description: "A unsharded", OperatedBy: []int{0}},
+			shard("B 2022", 2022, 1),
+		},
+	}
+	tests := []struct {
+		desc     string
+		notAfter time.Time
+		want     []string
+	}{
+		{desc: "mid-year", notAfter: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), want: []string{"A 2021", "A unsharded"}},
+		{desc: "boundary", notAfter: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), want: []string{"A 2022", "A unsharded", "B 2022"}},
+		{desc: "last-second", notAfter: time.Date(2021, 12, 31, 23, 59, 59, 0, time.UTC), want: []string{"A 2021", "A unsharded"}},
+		{desc: "no-shard", notAfter: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), want: []string{"A unsharded"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var got []string
+			for _, l := range ll.LogsForCert(test.notAfter) {
+				got = append(got, l.Description)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("LogsForCert(%v)=%v; want %v", test.notAfter, got, test.want)
+			}
+		})
+	}
+}
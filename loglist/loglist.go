@@ -24,12 +24,15 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"unicode"
 
+	"github.com/google/certificate-transparency-go/loglist2"
 	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
 )
 
 const (
@@ -65,6 +68,18 @@ type Log struct {
 	FinalSTH          *STH   `json:"final_sth,omitempty"`
 	DisqualifiedAt    int    `json:"disqualified_at,omitempty"`
 	DNSAPIEndpoint    string `json:"dns_api_endpoint,omitempty"` // DNS API endpoint for the log
+	// MirrorURLs lists alternative endpoints that serve the same log, which
+	// clients may fail over to if URL is unavailable.
+	MirrorURLs []string `json:"mirror_urls,omitempty"`
+	// MonitoringURL, if set, marks the log as a static (tiled) CT log, and is
+	// the URL prefix from which it serves its checkpoint and tiles.
+	MonitoringURL string `json:"monitoring_url,omitempty"`
+	// State, if set, is the state of the log as given by a v2 or v3 log list
+	// that the list was converted from (see Status).
+	State *loglist2.LogStates `json:"state,omitempty"`
+	// TemporalInterval, if set, indicates that this log only accepts
+	// certificates with a NotAfter date in this time range (see LogsForCert).
+	TemporalInterval *loglist2.TemporalInterval `json:"temporal_interval,omitempty"`
 }
 
 // STH describes a signed tree head from a log.
@@ -81,18 +96,41 @@ func (l *Log) GoogleOperated() bool {
 	return strings.Contains(lowerDesc, "google")
 }
 
-// NewFromJSON creates a LogList from JSON encoded data.
+// ErrMalformedJSON is wrapped by the errors returned when log list data
+// cannot be parsed.
+var ErrMalformedJSON = errors.New("malformed log list JSON")
+
+// ErrBadSignature is wrapped by the errors returned when the signature over
+// log list data does not verify.
+var ErrBadSignature = errors.New("bad log list signature")
+
+// NewFromJSON creates a LogList from JSON encoded data.  If the data cannot be
+// parsed, the error wraps ErrMalformedJSON.
 func NewFromJSON(llData []byte) (*LogList, error) {
 	var ll LogList
 	if err := json.Unmarshal(llData, &ll); err != nil {
-		return nil, fmt.Errorf("failed to parse log list: %v", err)
+		return nil, fmt.Errorf("failed to parse log list: %w: %v", ErrMalformedJSON, err)
 	}
 	return &ll, nil
 }
 
+// ToJSON encodes the LogList as JSON in the log list schema, so that it can be
+// parsed again with NewFromJSON; for example, to write out a list that has been
+// filtered with ActiveLogs.  The output is indented, with fields in schema
+// order, so that the encoding of a given list is stable and diffs well.
+func (ll *LogList) ToJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(ll, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode log list: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
 // NewFromSignedJSON creates a LogList from JSON encoded data, checking a
 // signature along the way. The signature data should be provided as the
-// raw signature data.
+// raw signature data.  If the signature does not verify, the error wraps
+// ErrBadSignature, and the data is not parsed; otherwise, errors are as for
+// NewFromJSON.
 func NewFromSignedJSON(llData, rawSig []byte, pubKey crypto.PublicKey) (*LogList, error) {
 	var sigAlgo tls.SignatureAlgorithm
 	switch pkType := pubKey.(type) {
@@ -111,11 +149,46 @@ func NewFromSignedJSON(llData, rawSig []byte, pubKey crypto.PublicKey) (*LogList
 		Signature: rawSig,
 	}
 	if err := tls.VerifySignature(pubKey, llData, tlsSig); err != nil {
-		return nil, fmt.Errorf("failed to verify signature: %v", err)
+		return nil, fmt.Errorf("failed to verify signature: %w: %v", ErrBadSignature, err)
 	}
 	return NewFromJSON(llData)
 }
 
+// NewFromSignedJSONKeyDER creates a LogList from JSON encoded data, checking
+// a signature along the way as for NewFromSignedJSON, with the RSA or ECDSA
+// public key given as a DER-encoded SubjectPublicKeyInfo.
+func NewFromSignedJSONKeyDER(llData, rawSig, pubKeyDER []byte) (*LogList, error) {
+	pubKey, err := x509.ParsePKIXPublicKey(pubKeyDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	return NewFromSignedJSON(llData, rawSig, pubKey)
+}
+
+// ExpectedSignerKeyHash checks that the SHA-256 hash of the DER-encoded
+// public key matches the expected (pinned) key hash.
+func ExpectedSignerKeyHash(pubKey crypto.PublicKey, want [sha256.Size]byte) error {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal public key: %v", err)
+	}
+	if got := sha256.Sum256(der); got != want {
+		return fmt.Errorf("unexpected signer key hash %x, want %x", got, want)
+	}
+	return nil
+}
+
+// NewFromPinnedSignedJSON creates a LogList from JSON encoded data, checking a
+// signature along the way as for NewFromSignedJSON.  The public key must also
+// match the given key hash, so that a list with a valid signature from any
+// other key is rejected.
+func NewFromPinnedSignedJSON(llData, rawSig []byte, pubKey crypto.PublicKey, keyHash [sha256.Size]byte) (*LogList, error) {
+	if err := ExpectedSignerKeyHash(pubKey, keyHash); err != nil {
+		return nil, err
+	}
+	return NewFromSignedJSON(llData, rawSig, pubKey)
+}
+
 // OperatorIDSet is a helper op, creates set of operators for LogList.
 func (ll *LogList) OperatorIDSet() map[int]string {
 	ops := make(map[int]string)
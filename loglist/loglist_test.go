@@ -15,15 +15,22 @@
 package loglist
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/google/certificate-transparency-go/testdata"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
 )
 
 var sampleLogList = LogList{
@@ -117,6 +124,165 @@ func TestJSONMarshal(t *testing.T) {
 	}
 }
 
+func TestToJSON(t *testing.T) {
+	active := sampleLogList.ActiveLogs()
+	for _, test := range []struct {
+		name string
+		in   LogList
+	}{
+		{name: "Sample", in: sampleLogList},
+		{name: "Active", in: active},
+		{name: "Empty", in: LogList{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.in.ToJSON()
+			if err != nil {
+				t.Fatalf("ToJSON()=nil,%v; want _,nil", err)
+			}
+			got, err := NewFromJSON(data)
+			if err != nil {
+				t.Fatalf("NewFromJSON(ToJSON())=nil,%v; want _,nil", err)
+			}
+			if !reflect.DeepEqual(*got, test.in) {
+				t.Errorf("NewFromJSON(ToJSON())=%+v; want %+v", *got, test.in)
+			}
+			// The encoding is stable.
+			again, err := got.ToJSON()
+			if err != nil {
+				t.Fatalf("ToJSON(round trip)=nil,%v; want _,nil", err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("ToJSON(round trip)=%s; want %s", again, data)
+			}
+		})
+	}
+
+	// The encoding matches the compact form apart from whitespace.
+	data, err := sampleLogList.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON()=nil,%v; want _,nil", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		t.Fatalf("json.Compact()=%v", err)
+	}
+	if got := compact.String(); got != testdata.SampleLogList {
+		t.Errorf("ToJSON() compacted=%q; want %q", got, testdata.SampleLogList)
+	}
+}
+
+func TestNewFromPinnedSignedJSON(t *testing.T) {
+	llData, err := json.Marshal(&sampleLogList)
+	if err != nil {
+		t.Fatalf("json.Marshal()=_,%v", err)
+	}
+	var keys [2]*ecdsa.PrivateKey
+	var keyHashes [2][sha256.Size]byte
+	for i := range keys {
+		if keys[i], err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(&keys[i].PublicKey)
+		if err != nil {
+			t.Fatalf("failed to marshal key: %v", err)
+		}
+		keyHashes[i] = sha256.Sum256(der)
+	}
+	sig, err := tls.CreateSignature(*keys[1], tls.SHA256, llData)
+	if err != nil {
+		t.Fatalf("failed to sign log list: %v", err)
+	}
+
+	tests := []struct {
+		desc    string
+		keyHash [sha256.Size]byte
+		wantErr string
+	}{
+		{desc: "expected-signer", keyHash: keyHashes[1]},
+		{desc: "unexpected-signer", keyHash: keyHashes[0], wantErr: "unexpected signer key hash"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			// The signature itself is valid either way.
+			if _, err := NewFromSignedJSON(llData, sig.Signature, &keys[1].PublicKey); err != nil {
+				t.Fatalf("NewFromSignedJSON()=_,%v; want _,nil", err)
+			}
+			ll, err := NewFromPinnedSignedJSON(llData, sig.Signature, &keys[1].PublicKey, test.keyHash)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("NewFromPinnedSignedJSON()=_,%v; want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFromPinnedSignedJSON()=_,%v; want _,nil", err)
+			}
+			if !reflect.DeepEqual(*ll, sampleLogList) {
+				t.Errorf("NewFromPinnedSignedJSON()=%+v; want %+v", *ll, sampleLogList)
+			}
+		})
+	}
+
+	// A pinned key does not rescue a bad signature.
+	if _, err := NewFromPinnedSignedJSON(append(llData, ' '), sig.Signature, &keys[1].PublicKey, keyHashes[1]); err == nil {
+		t.Error("NewFromPinnedSignedJSON(modified data)=_,nil; want _,non-nil")
+	}
+}
+
+func TestNewFromSignedJSONKeyDER(t *testing.T) {
+	llData, err := json.Marshal(&sampleLogList)
+	if err != nil {
+		t.Fatalf("json.Marshal()=_,%v", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	sign := func(data []byte) []byte {
+		sig, err := tls.CreateSignature(*key, tls.SHA256, data)
+		if err != nil {
+			t.Fatalf("failed to sign log list: %v", err)
+		}
+		return sig.Signature
+	}
+	malformed := []byte(`{"logs": [`)
+
+	tests := []struct {
+		desc          string
+		data, sig     []byte
+		keyDER        []byte
+		wantErr       bool
+		wantSig       bool
+		wantMalformed bool
+	}{
+		{desc: "valid", data: llData, sig: sign(llData), keyDER: keyDER},
+		{desc: "tampered", data: append(append([]byte{}, llData...), ' '), sig: sign(llData), keyDER: keyDER, wantErr: true, wantSig: true},
+		{desc: "malformed", data: malformed, sig: sign(malformed), keyDER: keyDER, wantErr: true, wantMalformed: true},
+		{desc: "bad-key", data: llData, sig: sign(llData), keyDER: []byte("not a key"), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ll, err := NewFromSignedJSONKeyDER(test.data, test.sig, test.keyDER)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("NewFromSignedJSONKeyDER()=_,%v; want error %v", err, test.wantErr)
+			}
+			if got := errors.Is(err, ErrBadSignature); got != test.wantSig {
+				t.Errorf("NewFromSignedJSONKeyDER()=_,%v; want bad signature %v", err, test.wantSig)
+			}
+			if got := errors.Is(err, ErrMalformedJSON); got != test.wantMalformed {
+				t.Errorf("NewFromSignedJSONKeyDER()=_,%v; want malformed JSON %v", err, test.wantMalformed)
+			}
+			if err == nil && !reflect.DeepEqual(*ll, sampleLogList) {
+				t.Errorf("NewFromSignedJSONKeyDER()=%+v; want %+v", *ll, sampleLogList)
+			}
+		})
+	}
+}
+
 func TestFindLogByName(t *testing.T) {
 	var tests = []struct {
 		name, in string
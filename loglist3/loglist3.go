@@ -0,0 +1,280 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loglist3 allows parsing of the master CT Log list.
+// It expects the log list to conform to the v3 schema, and can convert it to
+// the flat form of package loglist for use with existing code.
+package loglist3
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist"
+	"github.com/google/certificate-transparency-go/loglist2"
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+const (
+	// LogListURL has the master URL for Google Chrome's log list.
+	LogListURL = "https://www.gstatic.com/ct/log_list/v3/log_list.json"
+	// LogListSignatureURL has the URL for the signature over Google Chrome's log list.
+	LogListSignatureURL = "https://www.gstatic.com/ct/log_list/v3/log_list.sig"
+	// AllLogListURL has the URL for the list of all known logs (which isn't signed).
+	AllLogListURL = "https://www.gstatic.com/ct/log_list/v3/all_logs_list.json"
+)
+
+// Manually mapped from https://www.gstatic.com/ct/log_list/v3/log_list_schema.json
+
+// LogList holds a collection of CT logs, grouped by operator.
+type LogList struct {
+	// Version is the version of the log list.
+	Version string `json:"version,omitempty"`
+	// LogListTimestamp is the time at which the log list was published.
+	LogListTimestamp time.Time `json:"log_list_timestamp,omitempty"`
+	// Operators is a list of CT log operators and the logs they operate.
+	Operators []*Operator `json:"operators"`
+}
+
+// Operator holds a collection of CT logs run by the same organisation.
+// It also provides information about that organisation, e.g. contact details.
+type Operator struct {
+	// Name is the name of the CT log operator.
+	Name string `json:"name"`
+	// Email lists the email addresses that can be used to contact this log
+	// operator.
+	Email []string `json:"email"`
+	// Logs is a list of CT logs run by this operator.
+	Logs []*Log `json:"logs"`
+	// TiledLogs is a list of static (tiled) CT logs run by this operator.
+	TiledLogs []*TiledLog `json:"tiled_logs,omitempty"`
+}
+
+// Log describes a single CT log.
+type Log struct {
+	// Description is a human-readable string that describes the log.
+	Description string `json:"description,omitempty"`
+	// LogID is the SHA-256 hash of the log's public key.
+	LogID []byte `json:"log_id"`
+	// Key is the public key with which signatures can be verified.
+	Key []byte `json:"key"`
+	// URL is the address of the HTTPS API.
+	URL string `json:"url"`
+	// DNS is the address of the DNS API.
+	DNS string `json:"dns,omitempty"`
+	// MMD is the Maximum Merge Delay, in seconds. All submitted
+	// certificates must be incorporated into the log within this time.
+	MMD int32 `json:"mmd"`
+	// PreviousOperators holds the operators that previously ran the log, if
+	// it has changed hands.
+	PreviousOperators []*PreviousOperator `json:"previous_operators,omitempty"`
+	// State is the current state of the log, from the perspective of the
+	// log list distributor.
+	State *LogStates `json:"state,omitempty"`
+	// TemporalInterval, if set, indicates that this log only accepts
+	// certificates with a NotAfter date in this time range.
+	TemporalInterval *TemporalInterval `json:"temporal_interval,omitempty"`
+	// Type indicates the purpose of this log, e.g. "test" or "prod".
+	Type string `json:"log_type,omitempty"`
+}
+
+// TiledLog describes a single static (tiled) CT log, which accepts submissions
+// through the RFC 6962 add-chain and add-pre-chain entrypoints but serves its
+// checkpoint and tiles, rather than the other RFC 6962 entrypoints, for
+// monitoring.
+type TiledLog struct {
+	// Description is a human-readable string that describes the log.
+	Description string `json:"description,omitempty"`
+	// LogID is the SHA-256 hash of the log's public key.
+	LogID []byte `json:"log_id"`
+	// Key is the public key with which signatures can be verified.
+	Key []byte `json:"key"`
+	// SubmissionURL is the address of the log's submission API.
+	SubmissionURL string `json:"submission_url"`
+	// MonitoringURL is the URL prefix of the log's checkpoint and tiles.
+	MonitoringURL string `json:"monitoring_url"`
+	// MMD is the Maximum Merge Delay, in seconds. All submitted
+	// certificates must be incorporated into the log within this time.
+	MMD int32 `json:"mmd"`
+	// PreviousOperators holds the operators that previously ran the log, if
+	// it has changed hands.
+	PreviousOperators []*PreviousOperator `json:"previous_operators,omitempty"`
+	// State is the current state of the log, from the perspective of the
+	// log list distributor.
+	State *LogStates `json:"state,omitempty"`
+	// TemporalInterval, if set, indicates that this log only accepts
+	// certificates with a NotAfter date in this time range.
+	TemporalInterval *TemporalInterval `json:"temporal_interval,omitempty"`
+	// Type indicates the purpose of this log, e.g. "test" or "prod".
+	Type string `json:"log_type,omitempty"`
+}
+
+// PreviousOperator holds information about a log operator and the time at
+// which it stopped running a log.
+type PreviousOperator struct {
+	// Name is the name of the CT log operator.
+	Name string `json:"name"`
+	// EndTime is the time at which the operator stopped running the log.
+	EndTime time.Time `json:"end_time"`
+}
+
+// The v3 schema describes log states and temporal intervals in the same way
+// as the v2 schema.
+type (
+	// TemporalInterval is a time range.
+	TemporalInterval = loglist2.TemporalInterval
+	// LogStates are the states that a CT log can be in; only one should be set.
+	LogStates = loglist2.LogStates
+	// LogState contains details on the current state of a CT log.
+	LogState = loglist2.LogState
+	// ReadOnlyLogState contains details on the current state of a read-only
+	// CT log.
+	ReadOnlyLogState = loglist2.ReadOnlyLogState
+	// TreeHead is the root hash and tree size of a CT log.
+	TreeHead = loglist2.TreeHead
+	// LogStatus indicates Log status.
+	LogStatus = loglist2.LogStatus
+)
+
+// LogStatus values
+const (
+	UndefinedLogStatus = loglist2.UndefinedLogStatus
+	PendingLogStatus   = loglist2.PendingLogStatus
+	QualifiedLogStatus = loglist2.QualifiedLogStatus
+	UsableLogStatus    = loglist2.UsableLogStatus
+	ReadOnlyLogStatus  = loglist2.ReadOnlyLogStatus
+	RetiredLogStatus   = loglist2.RetiredLogStatus
+	RejectedLogStatus  = loglist2.RejectedLogStatus
+)
+
+// NewFromJSON creates a LogList from JSON encoded data.
+func NewFromJSON(llData []byte) (*LogList, error) {
+	var ll LogList
+	if err := json.Unmarshal(llData, &ll); err != nil {
+		return nil, fmt.Errorf("failed to parse log list: %v", err)
+	}
+	return &ll, nil
+}
+
+// NewFromSignedJSON creates a LogList from JSON encoded data, checking a
+// signature along the way. The signature data should be provided as the
+// raw signature data.
+func NewFromSignedJSON(llData, rawSig []byte, pubKey crypto.PublicKey) (*LogList, error) {
+	var sigAlgo tls.SignatureAlgorithm
+	switch pkType := pubKey.(type) {
+	case *rsa.PublicKey:
+		sigAlgo = tls.RSA
+	case *ecdsa.PublicKey:
+		sigAlgo = tls.ECDSA
+	default:
+		return nil, fmt.Errorf("unsupported public key type %v", pkType)
+	}
+	tlsSig := tls.DigitallySigned{
+		Algorithm: tls.SignatureAndHashAlgorithm{
+			Hash:      tls.SHA256,
+			Signature: sigAlgo,
+		},
+		Signature: rawSig,
+	}
+	if err := tls.VerifySignature(pubKey, llData, tlsSig); err != nil {
+		return nil, fmt.Errorf("failed to verify signature: %v", err)
+	}
+	return NewFromJSON(llData)
+}
+
+// FindLogByKeyHash finds the log with the given key hash.
+func (ll *LogList) FindLogByKeyHash(keyhash [sha256.Size]byte) *Log {
+	for _, op := range ll.Operators {
+		for _, log := range op.Logs {
+			if bytes.Equal(log.LogID, keyhash[:]) {
+				return log
+			}
+		}
+	}
+	return nil
+}
+
+// ToLogList converts the log list to the flat form of package loglist, so
+// that it can be used with code that expects that form (such as
+// ctutil.LogInfoByKeyHash).  Operators are numbered in list order.  All logs
+// are included, whatever their state, which is kept in State so that the
+// result can be filtered (see loglist.LogList.WithStates).  For code that
+// only reads the v1 fields, a read-only log keeps serving its entries, so has
+// its final tree head recorded in FinalSTH (without a timestamp or signature,
+// which the v3 list does not give), and a retired or rejected log is marked
+// as disqualified from the start of that state.  A static (tiled) log has its
+// submission URL as its URL and keeps its MonitoringURL, which marks it as
+// static for ctutil.NewLogInfo; such logs follow the operator's other logs.
+// The temporal interval of a sharded log is kept in TemporalInterval, but
+// other information with no flat equivalent, such as a log's type or previous
+// operators, is dropped.
+func (ll *LogList) ToLogList() *loglist.LogList {
+	var result loglist.LogList
+	for i, op := range ll.Operators {
+		result.Operators = append(result.Operators, loglist.Operator{ID: i, Name: op.Name})
+		for _, log := range op.Logs {
+			l := loglist.Log{
+				Description:       log.Description,
+				Key:               log.Key,
+				MaximumMergeDelay: int(log.MMD),
+				OperatedBy:        []int{i},
+				URL:               log.URL,
+				DNSAPIEndpoint:    log.DNS,
+				State:             log.State,
+				TemporalInterval:  log.TemporalInterval,
+			}
+			setFlatState(&l, log.State)
+			result.Logs = append(result.Logs, l)
+		}
+		for _, log := range op.TiledLogs {
+			l := loglist.Log{
+				Description:       log.Description,
+				Key:               log.Key,
+				MaximumMergeDelay: int(log.MMD),
+				OperatedBy:        []int{i},
+				URL:               log.SubmissionURL,
+				MonitoringURL:     log.MonitoringURL,
+				State:             log.State,
+				TemporalInterval:  log.TemporalInterval,
+			}
+			setFlatState(&l, log.State)
+			result.Logs = append(result.Logs, l)
+		}
+	}
+	return &result
+}
+
+// setFlatState sets the v1 fields of the flat log that reflect its state.
+func setFlatState(l *loglist.Log, state *LogStates) {
+	if state == nil {
+		return
+	}
+	switch {
+	case state.ReadOnly != nil:
+		l.FinalSTH = &loglist.STH{
+			TreeSize:       int(state.ReadOnly.FinalTreeHead.TreeSize),
+			SHA256RootHash: state.ReadOnly.FinalTreeHead.SHA256RootHash,
+		}
+	case state.Retired != nil:
+		l.DisqualifiedAt = int(state.Retired.Timestamp.Unix())
+	case state.Rejected != nil:
+		l.DisqualifiedAt = int(state.Rejected.Timestamp.Unix())
+	}
+}
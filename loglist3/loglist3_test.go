@@ -0,0 +1,323 @@
+// Copyright 2020 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loglist3
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist"
+	"github.com/google/certificate-transparency-go/loglist2"
+)
+
+const (
+	aviatorKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE1/TMabLkDpCjiupacAlP7xNi0I1JYP8bQFAHDG1xhtolSY1l4QgNRzRrvSe8liE+NPWHdjGxfx3JhTsN9x8/6Q=="
+	argonKey   = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE6Tx2p1yKY4015NyIYvdrk36es0uAc1zA4PQ+TGRY+3ZjUTIYY9Wyu+3q/147JG4vNVKLtDWarZwVqGkg6lAYzA=="
+	bobKey     = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAECyPLhWKYYUgEc+tUXfPQB4wtGS2MNvXrjwFCCnyYJifBtd2Sk7Cu+Js9DNhMTh35FftHaHu6ZrclnNBKwmbbSA=="
+	carolKey   = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAETtK8v7MICve56qTHHDhhBOuV4IlUaESxZryCfk9QbG9co/CqPvTsgPDbCpp6oFtyAHwlDhnvr7JijXRD9Cb2FA=="
+)
+
+var sampleJSON = `{
+  "version": "12.34",
+  "log_list_timestamp": "2020-09-01T12:00:00Z",
+  "operators": [
+    {
+      "name": "Google",
+      "email": ["google-ct-logs@googlegroups.com", "ct-logs@example.com"],
+      "logs": [
+        {
+          "description": "Google 'Argon2021' log",
+          "log_id": "9lyUL9F3MCIUVBgIMJRWjuNNExkzv98MLyALzE7xZOM=",
+          "key": "` + argonKey + `",
+          "url": "https://ct.googleapis.com/logs/argon2021/",
+          "mmd": 86400,
+          "state": {"usable": {"timestamp": "2020-05-01T00:00:00Z"}},
+          "temporal_interval": {
+            "start_inclusive": "2021-01-01T00:00:00Z",
+            "end_exclusive": "2022-01-01T00:00:00Z"
+          },
+          "log_type": "prod"
+        },
+        {
+          "description": "Google 'Aviator' log",
+          "log_id": "aPaY+B9kgr46jO65KB1M/HFRXWeT1ETRCmesu09P+8Q=",
+          "key": "` + aviatorKey + `",
+          "url": "https://ct.googleapis.com/aviator/",
+          "dns": "aviator.ct.googleapis.com",
+          "mmd": 86400,
+          "state": {
+            "readonly": {
+              "timestamp": "2016-11-30T13:24:18.33Z",
+              "final_tree_head": {
+                "sha256_root_hash": "LcGcZRsm+LGYmrlyC5LXhV1T6OD8iH5dNlb0sEJl9bA=",
+                "tree_size": 46466472
+              }
+            }
+          }
+        }
+      ]
+    },
+    {
+      "name": "Bob's CT Log Shop",
+      "email": ["bob@example.com"],
+      "logs": [
+        {
+          "description": "Bob's Dubious Log",
+          "log_id": "zbUXm3/BwEb+6jETaj+PAC5hgvr4iW/syLL1tatgSQA=",
+          "key": "` + bobKey + `",
+          "url": "https://log.bob.io",
+          "mmd": 86400,
+          "previous_operators": [{"name": "Alice", "end_time": "2019-01-01T00:00:00Z"}],
+          "state": {"retired": {"timestamp": "2016-04-15T00:00:00Z"}}
+        },
+        {
+          "description": "Bob's Rejected Log",
+          "log_id": "KTxRllTIOWW6qlD8WAfUt2+/WHopctykwwz05UVH9Hg=",
+          "key": "` + carolKey + `",
+          "url": "https://rejected.bob.io",
+          "mmd": 86400,
+          "state": {"rejected": {"timestamp": "2017-01-01T00:00:00Z"}}
+        }
+      ]
+    }
+  ]
+}`
+
+func TestNewFromJSON(t *testing.T) {
+	ll, err := NewFromJSON([]byte(sampleJSON))
+	if err != nil {
+		t.Fatalf("NewFromJSON()=_,%v; want _,nil", err)
+	}
+	if got, want := ll.Version, "12.34"; got != want {
+		t.Errorf("Version=%q; want %q", got, want)
+	}
+	if got, want := ll.LogListTimestamp, time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("LogListTimestamp=%v; want %v", got, want)
+	}
+	if got, want := len(ll.Operators), 2; got != want {
+		t.Fatalf("len(Operators)=%d; want %d", got, want)
+	}
+	if got, want := ll.Operators[0].Email, []string{"google-ct-logs@googlegroups.com", "ct-logs@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Operators[0].Email=%v; want %v", got, want)
+	}
+
+	argon := ll.Operators[0].Logs[0]
+	if got, want := argon.State.LogStatus(), loglist2.UsableLogStatus; got != want {
+		t.Errorf("argon.State.LogStatus()=%v; want %v", got, want)
+	}
+	wantInterval := &TemporalInterval{
+		StartInclusive: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndExclusive:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(argon.TemporalInterval, wantInterval) {
+		t.Errorf("argon.TemporalInterval=%+v; want %+v", argon.TemporalInterval, wantInterval)
+	}
+	if got, want := argon.Type, "prod"; got != want {
+		t.Errorf("argon.Type=%q; want %q", got, want)
+	}
+
+	aviator := ll.Operators[0].Logs[1]
+	if got, want := aviator.State.LogStatus(), loglist2.ReadOnlyLogStatus; got != want {
+		t.Errorf("aviator.State.LogStatus()=%v; want %v", got, want)
+	}
+	if got, want := aviator.State.ReadOnly.FinalTreeHead.TreeSize, int64(46466472); got != want {
+		t.Errorf("aviator final tree size=%d; want %d", got, want)
+	}
+
+	bob := ll.Operators[1].Logs[0]
+	wantPrev := []*PreviousOperator{{Name: "Alice", EndTime: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	if !reflect.DeepEqual(bob.PreviousOperators, wantPrev) {
+		t.Errorf("bob.PreviousOperators=%+v; want %+v", bob.PreviousOperators, wantPrev)
+	}
+
+	var hash [sha256.Size]byte
+	copy(hash[:], deb64("aPaY+B9kgr46jO65KB1M/HFRXWeT1ETRCmesu09P+8Q="))
+	if got := ll.FindLogByKeyHash(hash); got != aviator {
+		t.Errorf("FindLogByKeyHash(%x)=%v; want Aviator", hash, got)
+	}
+
+	if _, err := NewFromJSON([]byte(`{"operators": [}`)); err == nil {
+		t.Error("NewFromJSON(invalid)=_,nil; want _,non-nil")
+	}
+}
+
+func TestToLogList(t *testing.T) {
+	ll, err := NewFromJSON([]byte(sampleJSON))
+	if err != nil {
+		t.Fatalf("NewFromJSON()=_,%v; want _,nil", err)
+	}
+	got := ll.ToLogList()
+	want := &loglist.LogList{
+		Operators: []loglist.Operator{
+			{ID: 0, Name: "Google"},
+			{ID: 1, Name: "Bob's CT Log Shop"},
+		},
+		Logs: []loglist.Log{
+			{
+				Description:       "Google 'Argon2021' log",
+				Key:               deb64(argonKey),
+				MaximumMergeDelay: 86400,
+				OperatedBy:        []int{0},
+				URL:               "https://ct.googleapis.com/logs/argon2021/",
+				State:             ll.Operators[0].Logs[0].State,
+				TemporalInterval:  ll.Operators[0].Logs[0].TemporalInterval,
+			},
+			{
+				Description:       "Google 'Aviator' log",
+				Key:               deb64(aviatorKey),
+				MaximumMergeDelay: 86400,
+				OperatedBy:        []int{0},
+				URL:               "https://ct.googleapis.com/aviator/",
+				DNSAPIEndpoint:    "aviator.ct.googleapis.com",
+				State:             ll.Operators[0].Logs[1].State,
+				FinalSTH: &loglist.STH{
+					TreeSize:       46466472,
+					SHA256RootHash: deb64("LcGcZRsm+LGYmrlyC5LXhV1T6OD8iH5dNlb0sEJl9bA="),
+				},
+			},
+			{
+				Description:       "Bob's Dubious Log",
+				Key:               deb64(bobKey),
+				MaximumMergeDelay: 86400,
+				OperatedBy:        []int{1},
+				URL:               "https://log.bob.io",
+				DisqualifiedAt:    1460678400,
+				State:             ll.Operators[1].Logs[0].State,
+			},
+			{
+				Description:       "Bob's Rejected Log",
+				Key:               deb64(carolKey),
+				MaximumMergeDelay: 86400,
+				OperatedBy:        []int{1},
+				URL:               "https://rejected.bob.io",
+				DisqualifiedAt:    1483228800,
+				State:             ll.Operators[1].Logs[1].State,
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToLogList()=%+v; want %+v", got, want)
+	}
+
+	// The converted list can be searched as usual.
+	if logs := got.FindLogByName("aviator"); len(logs) != 1 || logs[0].FinalSTH == nil {
+		t.Errorf("ToLogList().FindLogByName(aviator)=%+v; want read-only Aviator log", logs)
+	}
+	wantStatus := []LogStatus{UsableLogStatus, ReadOnlyLogStatus, RetiredLogStatus, RejectedLogStatus}
+	for i, l := range got.Logs {
+		if status := l.Status(); status != wantStatus[i] {
+			t.Errorf("ToLogList().Logs[%d].Status()=%v; want %v", i, status, wantStatus[i])
+		}
+	}
+}
+
+func TestToLogListTiled(t *testing.T) {
+	ll, err := NewFromJSON([]byte(`{
+  "operators": [
+    {
+      "name": "Bob's CT Log Shop",
+      "email": ["bob@example.com"],
+      "logs": [
+        {
+          "description": "Bob's Log",
+          "key": "` + bobKey + `",
+          "url": "https://log.bob.io",
+          "mmd": 86400
+        }
+      ],
+      "tiled_logs": [
+        {
+          "description": "Bob's Tiled Log",
+          "key": "` + carolKey + `",
+          "submission_url": "https://submit.bob.io/",
+          "monitoring_url": "https://tiles.bob.io/",
+          "mmd": 60,
+          "state": {"readonly": {"timestamp": "2021-01-01T00:00:00Z", "final_tree_head": {"sha256_root_hash": "LcGcZRsm+LGYmrlyC5LXhV1T6OD8iH5dNlb0sEJl9bA=", "tree_size": 12}}}
+        }
+      ]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("NewFromJSON()=_,%v; want _,nil", err)
+	}
+	got := ll.ToLogList()
+	want := []loglist.Log{
+		{
+			Description:       "Bob's Log",
+			Key:               deb64(bobKey),
+			MaximumMergeDelay: 86400,
+			OperatedBy:        []int{0},
+			URL:               "https://log.bob.io",
+		},
+		{
+			Description:       "Bob's Tiled Log",
+			Key:               deb64(carolKey),
+			MaximumMergeDelay: 60,
+			OperatedBy:        []int{0},
+			URL:               "https://submit.bob.io/",
+			MonitoringURL:     "https://tiles.bob.io/",
+			State:             ll.Operators[0].TiledLogs[0].State,
+			FinalSTH: &loglist.STH{
+				TreeSize:       12,
+				SHA256RootHash: deb64("LcGcZRsm+LGYmrlyC5LXhV1T6OD8iH5dNlb0sEJl9bA="),
+			},
+		},
+	}
+	if !reflect.DeepEqual(got.Logs, want) {
+		t.Errorf("ToLogList().Logs=%+v; want %+v", got.Logs, want)
+	}
+}
+
+func TestToLogListWithStates(t *testing.T) {
+	ll, err := NewFromJSON([]byte(sampleJSON))
+	if err != nil {
+		t.Fatalf("NewFromJSON()=_,%v; want _,nil", err)
+	}
+	flat := ll.ToLogList()
+	tests := []struct {
+		desc string
+		got  *loglist.LogList
+		want []string
+	}{
+		{desc: "active", got: flat.Active(), want: []string{"Google 'Argon2021' log"}},
+		{desc: "readonly-and-retired", got: flat.WithStates(ReadOnlyLogStatus, RetiredLogStatus), want: []string{"Google 'Aviator' log", "Bob's Dubious Log"}},
+		// A rejected log is distinguished from a retired one, although both
+		// are marked as disqualified.
+		{desc: "rejected", got: flat.WithStates(RejectedLogStatus), want: []string{"Bob's Rejected Log"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var got []string
+			for _, l := range test.got.Logs {
+				got = append(got, l.Description)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("WithStates()=%v; want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func deb64(b string) []byte {
+	data, err := base64.StdEncoding.DecodeString(b)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -19,6 +19,7 @@ import (
 	"log"
 	"math/big"
 	"regexp"
+	"strings"
 	"time"
 
 	ct "github.com/google/certificate-transparency-go"
@@ -117,6 +118,62 @@ func (m MatchSubjectRegex) PrecertificateMatches(p *ct.Precertificate) bool {
 	return false
 }
 
+// MatchDomain is a Matcher which matches Certificates and Precertificates
+// whose Subject CN (Common Name) or any Subject Alternative Name covers a
+// domain.  Names are compared case-insensitively, and a wildcard on either
+// side (e.g. "*.example.com") covers exactly one leftmost label.
+type MatchDomain struct {
+	Domain string
+}
+
+// CertificateMatches returns true if either CN or any SAN of c covers m.Domain.
+func (m MatchDomain) CertificateMatches(c *x509.Certificate) bool {
+	return m.namesMatch(c)
+}
+
+// PrecertificateMatches returns true if either CN or any SAN of p covers m.Domain.
+func (m MatchDomain) PrecertificateMatches(p *ct.Precertificate) bool {
+	return m.namesMatch(p.TBSCertificate)
+}
+
+func (m MatchDomain) namesMatch(c *x509.Certificate) bool {
+	if c == nil {
+		return false
+	}
+	if domainMatches(c.Subject.CommonName, m.Domain) {
+		return true
+	}
+	for _, alt := range c.DNSNames {
+		if domainMatches(alt, m.Domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainMatches indicates whether the DNS name from a certificate and the
+// domain being searched for cover each other, allowing for a wildcard in the
+// leftmost label of either.
+func domainMatches(name, domain string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if name == "" || domain == "" {
+		return false
+	}
+	if name == domain {
+		return true
+	}
+	nameLabels := strings.Split(name, ".")
+	domainLabels := strings.Split(domain, ".")
+	if len(nameLabels) != len(domainLabels) || len(nameLabels) < 3 {
+		return false
+	}
+	if nameLabels[0] != "*" && domainLabels[0] != "*" {
+		return false
+	}
+	return strings.Join(nameLabels[1:], ".") == strings.Join(domainLabels[1:], ".")
+}
+
 // MatchIssuerRegex matches on issuer CN (common name) by regex
 type MatchIssuerRegex struct {
 	CertificateIssuerRegex    *regexp.Regexp
@@ -244,9 +301,7 @@ func (m CertVerifyFailMatcher) Matches(leaf *ct.LeafEntry) bool {
 			log.Printf("Precert fails to parse as of %v: %v", opts.CurrentTime, err)
 			return true
 		}
-		// Ignore unhandled poison extension.
-		dropUnhandledExtension(precert, x509.OIDExtensionCTPoison)
-
+		opts.AcceptPrecertificates = true
 		for i := 1; i < len(chain); i++ {
 			// PolicyConstraints is legal (and critical) but unparsed.
 			dropUnhandledExtension(chain[i], x509.OIDExtensionPolicyConstraints)
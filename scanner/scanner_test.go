@@ -160,6 +160,39 @@ func TestScannerMatchSubjectRegexMatchesPrecertificateSAN(t *testing.T) {
 	}
 }
 
+func TestScannerMatchDomain(t *testing.T) {
+	tests := []struct {
+		cn     string
+		sans   []string
+		domain string
+		want   bool
+	}{
+		{cn: "www.example.com", domain: "www.example.com", want: true},
+		{cn: "WWW.Example.com", domain: "www.example.COM.", want: true},
+		{cn: "other.com", sans: []string{"a.com", "www.example.com"}, domain: "www.example.com", want: true},
+		{cn: "*.example.com", domain: "www.example.com", want: true},
+		{cn: "www.example.com", domain: "*.example.com", want: true},
+		{cn: "*.example.com", domain: "*.example.com", want: true},
+		{cn: "*.example.com", domain: "a.b.example.com", want: false},
+		{cn: "*.example.com", domain: "example.com", want: false},
+		{cn: "*.com", domain: "example.com", want: false},
+		{cn: "www.example.com", domain: "www.example.org", want: false},
+		{cn: "", domain: "", want: false},
+	}
+	for _, test := range tests {
+		var cert x509.Certificate
+		cert.Subject.CommonName = test.cn
+		cert.DNSNames = test.sans
+		m := MatchDomain{Domain: test.domain}
+		if got := m.CertificateMatches(&cert); got != test.want {
+			t.Errorf("MatchDomain{%q}.CertificateMatches(cn=%q, sans=%v)=%v; want %v", test.domain, test.cn, test.sans, got, test.want)
+		}
+		if got := m.PrecertificateMatches(&ct.Precertificate{TBSCertificate: &cert}); got != test.want {
+			t.Errorf("MatchDomain{%q}.PrecertificateMatches(cn=%q, sans=%v)=%v; want %v", test.domain, test.cn, test.sans, got, test.want)
+		}
+	}
+}
+
 func TestScannerEndToEnd(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
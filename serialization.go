@@ -15,10 +15,14 @@
 package ct
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 	"time"
 
@@ -258,10 +262,15 @@ func RawLogEntryFromLeaf(index int64, entry *LeafEntry) (*RawLogEntry, error) {
 		return nil, fmt.Errorf("MerkleTreeLeaf: trailing data %d bytes", len(rest))
 	}
 
+	extraData, err := decompressExtraData(entry.ExtraData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress extra data: %v", err)
+	}
+
 	switch eType := ret.Leaf.TimestampedEntry.EntryType; eType {
 	case X509LogEntryType:
 		var certChain CertificateChain
-		if rest, err := tls.Unmarshal(entry.ExtraData, &certChain); err != nil {
+		if rest, err := tls.Unmarshal(extraData, &certChain); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal CertificateChain: %v", err)
 		} else if len(rest) > 0 {
 			return nil, fmt.Errorf("CertificateChain: trailing data %d bytes", len(rest))
@@ -271,7 +280,7 @@ func RawLogEntryFromLeaf(index int64, entry *LeafEntry) (*RawLogEntry, error) {
 
 	case PrecertLogEntryType:
 		var precertChain PrecertChainEntry
-		if rest, err := tls.Unmarshal(entry.ExtraData, &precertChain); err != nil {
+		if rest, err := tls.Unmarshal(extraData, &precertChain); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal PrecertChainEntry: %v", err)
 		} else if len(rest) > 0 {
 			return nil, fmt.Errorf("PrecertChainEntry: trailing data %d bytes", len(rest))
@@ -288,6 +297,34 @@ func RawLogEntryFromLeaf(index int64, entry *LeafEntry) (*RawLogEntry, error) {
 	return &ret, nil
 }
 
+// MaxDecompressedExtraDataSize is the largest size that gzip-compressed extra
+// data in a log entry may expand to.
+const MaxDecompressedExtraDataSize = 4 << 20
+
+// decompressExtraData returns the given extra data from a log entry, which some
+// logs gzip-compress, in uncompressed form.  Data without a valid gzip header
+// is returned unchanged, as a TLS-encoded chain is very unlikely to start with
+// one (it would need to be at least 2MB long).
+func decompressExtraData(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return data, nil
+	}
+	defer zr.Close()
+	// Read one byte beyond the limit, to detect data that exceeds it.
+	out, err := ioutil.ReadAll(io.LimitReader(zr, MaxDecompressedExtraDataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedExtraDataSize {
+		return nil, fmt.Errorf("decompressed size exceeds %d bytes", MaxDecompressedExtraDataSize)
+	}
+	return out, nil
+}
+
 // ToLogEntry converts RawLogEntry to a LogEntry, which includes an x509-parsed
 // (pre-)certificate.
 //
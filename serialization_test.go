@@ -16,6 +16,7 @@ package ct
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/hex"
 	"encoding/pem"
 	"io/ioutil"
@@ -34,6 +35,18 @@ func dh(h string) []byte {
 	return r
 }
 
+func gz(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		panic(err)
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
 const (
 	defaultSCTLogIDString          string = "iamapublickeyshatwofivesixdigest"
 	defaultSCTTimestamp            uint64 = 1234
@@ -503,6 +516,35 @@ func TestLogEntryFromLeaf(t *testing.T) {
 			},
 			wantErr: "failed to unmarshal PrecertChainEntry",
 		},
+		{
+			leaf: LeafEntry{
+				LeafInput: dh("00" + "00" + "0000015dcc2b99c8" + "0000" + "0004f3" + leafDER + noExts),
+				ExtraData: gz(dh("000ba3" + "0005cc" + leafCA + "0005d1" + rootCA)),
+			},
+			wantCert: true,
+		},
+		{
+			leaf: LeafEntry{
+				LeafInput: dh("00" + "00" + "0000015dcc997890" + "0001" + issuerKeyHash + precertTBS + noExts),
+				ExtraData: gz(dh("000508" + precertDER +
+					("000ba3" + "0005cc" + precertCA + "0005d1" + precertRoot))),
+			},
+			wantPrecert: true,
+		},
+		{
+			leaf: LeafEntry{
+				LeafInput: dh("00" + "00" + "0000015dcc2b99c8" + "0000" + "0004f3" + leafDER + noExts),
+				ExtraData: gz(make([]byte, MaxDecompressedExtraDataSize+1)),
+			},
+			wantErr: "decompressed size exceeds",
+		},
+		{
+			leaf: LeafEntry{
+				LeafInput: dh("00" + "00" + "0000015dcc2b99c8" + "0000" + "0004f3" + leafDER + noExts),
+				ExtraData: gz(dh("000ba3" + "0005cc" + leafCA + "0005d1" + rootCA))[:100],
+			},
+			wantErr: "failed to decompress extra data",
+		},
 	}
 	for i, test := range tests {
 		got, err := LogEntryFromLeaf(int64(i), &test.leaf)
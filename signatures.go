@@ -59,6 +59,8 @@ type SignatureVerifier struct {
 }
 
 // NewSignatureVerifier creates a new SignatureVerifier using the passed in PublicKey.
+// ECDSA keys must be on the P256 curve or, for logs served by this fork, the
+// secp192r1 curve (see x509.Secp192r1).
 func NewSignatureVerifier(pk crypto.PublicKey) (*SignatureVerifier, error) {
 	switch pkType := pk.(type) {
 	case *rsa.PublicKey:
@@ -71,8 +73,8 @@ func NewSignatureVerifier(pk crypto.PublicKey) (*SignatureVerifier, error) {
 		}
 	case *ecdsa.PublicKey:
 		params := *(pkType.Params())
-		if params != *elliptic.P256().Params() {
-			e := fmt.Errorf("public is ECDSA, but not on the P256 curve")
+		if params != *elliptic.P256().Params() && params != *x509.Secp192r1().Params() {
+			e := fmt.Errorf("public is ECDSA, but not on the P256 or P192 curve")
 			if !AllowVerificationWithNonCompliantKeys {
 				return nil, e
 			}
@@ -87,6 +87,19 @@ const (
 		"2d3c916eb77f167323500d1b53dc4253321a106e441af343cf2f68630873" +
 		"abd43ca52629c586107eb7eb85f2c3ee"
 
+	// Signature over the same SCT as sigTestCertSCTSignatureEC, from the
+	// secp192r1 key sigTestEC192PublicKeyPEM.
+	sigTestCertSCTSignatureEC192 = "0403" + "0037" +
+		"3035021900d0b0352a3ef44d348b965239aac593b9a044cf1f0efe7e1d02187ae887f2" +
+		"29add6a71ed1756e8f573f48c81aeb06ab96dc50"
+
+	sigTestEC192PublicKeyPEM = "-----BEGIN PUBLIC KEY-----\n" +
+		"MEkwEwYHKoZIzj0CAQYIKoZIzj0DAQEDMgAEjxXxu5YhNULeKWCb0o14BO0wCwJ3\n" +
+		"r7kLwnfFQIWcBvNfP4H+tKDwmVDyXxN3Nq0b\n" +
+		"-----END PUBLIC KEY-----\n"
+
+	sigTestKeyIDEC192 = "2a674937d61e0d5c32028a007190f6c5c22891561339b8d2e7bdcabad2b689f7"
+
 	sigTestCertSCTSignatureUnsupportedSignatureAlgorithm = "0402" + "0000"
 
 	sigTestCertSCTSignatureUnsupportedHashAlgorithm = "0303" + "0000"
@@ -150,6 +163,15 @@ func sigTestECPublicKey(t *testing.T) crypto.PublicKey {
 	return pk
 }
 
+func sigTestEC192PublicKey(t *testing.T) crypto.PublicKey {
+	t.Helper()
+	pk, _, _, err := PublicKeyFromPEM([]byte(sigTestEC192PublicKeyPEM))
+	if err != nil {
+		t.Fatalf("Failed to parse sigTestEC192PublicKey: %v", err)
+	}
+	return pk
+}
+
 func sigTestECPublicKey2(t *testing.T) crypto.PublicKey {
 	t.Helper()
 	pk, _, _, err := PublicKeyFromPEM([]byte(sigTestEC256PublicKey2PEM))
@@ -233,6 +255,16 @@ func TestVerifySCTSignatureEC(t *testing.T) {
 
 }
 
+func TestVerifySCTSignatureEC192(t *testing.T) {
+	v := mustCreateSignatureVerifier(t, sigTestEC192PublicKey(t))
+	sct := sigTestSCTWithSignature(t, sigTestCertSCTSignatureEC192, sigTestKeyIDEC192)
+	if err := v.VerifySCTSignature(sct, sigTestCertLogEntry(t)); err != nil {
+		t.Fatalf("Failed to verify P192 signature on SCT: %v", err)
+	}
+	expectVerifySCTToFail(t, v, sigTestSCTEC(t), "Successfully verified P256 signature with P192 key")
+	testVerifySCTSignatureFailsForIncorrectSignature(t, sct, v)
+}
+
 func TestVerifySCTSignatureRSA(t *testing.T) {
 	v := mustCreateSignatureVerifier(t, sigTestRSAPublicKey(t))
 	if err := v.VerifySCTSignature(sigTestSCTRSA(t), sigTestCertLogEntry(t)); err != nil {
@@ -241,6 +273,42 @@ func TestVerifySCTSignatureRSA(t *testing.T) {
 
 }
 
+func TestVerifySCTSignatureRSAPSS(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	sct := sigTestSCTRSA(t)
+	data, err := SerializeSCTSignatureInput(sct, sigTestCertLogEntry(t))
+	if err != nil {
+		t.Fatalf("Failed to serialize SCT: %v", err)
+	}
+	for _, test := range []struct {
+		sigAlgo  tls.SignatureAlgorithm
+		hashType crypto.Hash
+	}{
+		{tls.RSAPSSWithSHA256, crypto.SHA256},
+		{tls.RSAPSSWithSHA384, crypto.SHA384},
+		{tls.RSAPSSWithSHA512, crypto.SHA512},
+	} {
+		hasher := test.hashType.New()
+		hasher.Write(data)
+		sig, err := rsa.SignPSS(rand.Reader, privKey, test.hashType, hasher.Sum(nil), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		if err != nil {
+			t.Fatalf("Failed to sign SCT with %v: %v", test.sigAlgo, err)
+		}
+		sct.Signature = DigitallySigned{
+			Algorithm: tls.SignatureAndHashAlgorithm{Hash: tls.Intrinsic, Signature: test.sigAlgo},
+			Signature: sig,
+		}
+		v := mustCreateSignatureVerifier(t, &privKey.PublicKey)
+		if err := v.VerifySCTSignature(sct, sigTestCertLogEntry(t)); err != nil {
+			t.Errorf("Failed to verify %v signature on SCT: %v", test.sigAlgo, err)
+		}
+		testVerifySCTSignatureFailsForIncorrectSignature(t, sct, v)
+	}
+}
+
 func TestVerifySCTSignatureFailsForMismatchedSignatureAlgorithm(t *testing.T) {
 	v := mustCreateSignatureVerifier(t, sigTestECPublicKey(t))
 	expectVerifySCTToFail(t, v, sigTestSCTRSA(t), "Successfully verified with mismatched signature algorithm")
@@ -421,6 +489,20 @@ func TestNewSignatureVerifierFailsWithBadKeyParametersForEC(t *testing.T) {
 	}
 }
 
+func TestNewSignatureVerifierAcceptsP192Key(t *testing.T) {
+	pemKey := `-----BEGIN PUBLIC KEY-----
+MEkwEwYHKoZIzj0CAQYIKoZIzj0DAQEDMgAExsjXPevTvlNYLlTI7TgwtQ8x+hrv
+Asw/JmeMHooBPRCyE1oQede1mR/wY/vryHVg
+-----END PUBLIC KEY-----`
+	k, _, _, err := PublicKeyFromPEM([]byte(pemKey))
+	if err != nil {
+		t.Fatalf("PublicKeyFromPEM(P192)=nil,%v; want _,nil", err)
+	}
+	if _, err := NewSignatureVerifier(k); err != nil {
+		t.Errorf("NewSignatureVerifier(P192)=nil,%v; want _,nil", err)
+	}
+}
+
 func TestNewSignatureVerifierFailsWithBadKeyParametersForRSA(t *testing.T) {
 	k, err := rsa.GenerateKey(rand.Reader, 1024)
 	if err != nil {
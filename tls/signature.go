@@ -62,9 +62,26 @@ func generateHash(algo HashAlgorithm, data []byte) ([]byte, crypto.Hash, error)
 	return hasher.Sum([]byte{}), hashType, nil
 }
 
+// pssHashes maps the RSA-PSS signature algorithms to the hash that each implies.
+var pssHashes = map[SignatureAlgorithm]HashAlgorithm{
+	RSAPSSWithSHA256: SHA256,
+	RSAPSSWithSHA384: SHA384,
+	RSAPSSWithSHA512: SHA512,
+}
+
 // VerifySignature verifies that the passed in signature over data was created by the given PublicKey.
+// ECDSA signatures are checked on the curve of the key, including secp192r1
+// (as parsed by x509.ParsePKIXPublicKey), against the digest given by the
+// signature's hash algorithm.
 func VerifySignature(pubKey crypto.PublicKey, data []byte, sig DigitallySigned) error {
-	hash, hashType, err := generateHash(sig.Algorithm.Hash, data)
+	hashAlgo := sig.Algorithm.Hash
+	if pssHash, ok := pssHashes[sig.Algorithm.Signature]; ok {
+		if hashAlgo != Intrinsic {
+			return fmt.Errorf("unsupported Algorithm.Hash %v for %v signature, want %v", hashAlgo, sig.Algorithm.Signature, Intrinsic)
+		}
+		hashAlgo = pssHash
+	}
+	hash, hashType, err := generateHash(hashAlgo, data)
 	if err != nil {
 		return err
 	}
@@ -78,6 +95,16 @@ func VerifySignature(pubKey crypto.PublicKey, data []byte, sig DigitallySigned)
 		if err := rsa.VerifyPKCS1v15(rsaKey, hashType, hash, sig.Signature); err != nil {
 			return fmt.Errorf("failed to verify rsa signature: %v", err)
 		}
+	case RSAPSSWithSHA256, RSAPSSWithSHA384, RSAPSSWithSHA512:
+		rsaKey, ok := pubKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("cannot verify RSA-PSS signature with %T key", pubKey)
+		}
+		// RFC 8446 s4.2.3 requires the salt length to equal the digest length.
+		opts := rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+		if err := rsa.VerifyPSS(rsaKey, hashType, hash, sig.Signature, &opts); err != nil {
+			return fmt.Errorf("failed to verify rsa-pss signature: %v", err)
+		}
 	case DSA:
 		dsaKey, ok := pubKey.(*dsa.PublicKey)
 		if !ok {
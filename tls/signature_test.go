@@ -60,6 +60,14 @@ func TestVerifySignature(t *testing.T) {
 		{PEM2PK(testdata.RsaPublicKeyPEM), "61626364", tls.SHA256, tls.RSA, "", testdata.RsaSignedAbcdHex},
 		{PEM2PK(testdata.DsaPublicKeyPEM), "61626364", tls.SHA1, tls.DSA, "", testdata.DsaSignedAbcdHex},
 		{PEM2PK(testdata.EcdsaPublicKeyPEM), "61626364", tls.SHA256, tls.ECDSA, "", testdata.EcdsaSignedAbcdHex},
+
+		{PEM2PK(testdata.RsaPublicKeyPEM), "61626364", tls.Intrinsic, tls.RSAPSSWithSHA256, "", testdata.RsaPSSSignedAbcdHex},
+		{PEM2PK(testdata.RsaPublicKeyPEM), "61626365", tls.Intrinsic, tls.RSAPSSWithSHA256, "verification error", testdata.RsaPSSSignedAbcdHex},
+		{PEM2PK(testdata.RsaPublicKeyPEM), "61626364", tls.Intrinsic, tls.RSAPSSWithSHA384, "verification error", testdata.RsaPSSSignedAbcdHex},
+		{PEM2PK(testdata.RsaPublicKeyPEM), "61626364", tls.SHA256, tls.RSAPSSWithSHA256, "unsupported Algorithm.Hash", testdata.RsaPSSSignedAbcdHex},
+		{PEM2PK(testdata.RsaPublicKeyPEM), "61626364", tls.SHA256, tls.RSA, "verification error", testdata.RsaPSSSignedAbcdHex},
+		{PEM2PK(testdata.RsaPublicKeyPEM), "61626364", tls.Intrinsic, tls.RSA, "unsupported Algorithm.Hash", testdata.RsaSignedAbcdHex},
+		{PEM2PK(testdata.EcdsaPublicKeyPEM), "61626364", tls.Intrinsic, tls.RSAPSSWithSHA256, "cannot verify RSA-PSS", testdata.RsaPSSSignedAbcdHex},
 	}
 	for _, test := range tests {
 		algo := tls.SignatureAndHashAlgorithm{Hash: test.hashAlgo, Signature: test.sigAlgo}
@@ -54,6 +54,12 @@ const (
 	SHA512 HashAlgorithm = 6
 )
 
+// Intrinsic is the HashAlgorithm value from RFC 8422 s5.1.3, used when the
+// hash is implied by the SignatureAlgorithm.  This happens for the TLS 1.3
+// SignatureScheme values (RFC 8446 s4.2.3) that have no TLS 1.2 equivalent,
+// such as rsa_pss_rsae_sha256 (0x0804).
+const Intrinsic HashAlgorithm = 8
+
 func (h HashAlgorithm) String() string {
 	switch h {
 	case None:
@@ -70,6 +76,8 @@ func (h HashAlgorithm) String() string {
 		return "SHA384"
 	case SHA512:
 		return "SHA512"
+	case Intrinsic:
+		return "Intrinsic"
 	default:
 		return fmt.Sprintf("UNKNOWN(%d)", h)
 	}
@@ -86,6 +94,15 @@ const (
 	ECDSA     SignatureAlgorithm = 3
 )
 
+// SignatureAlgorithm constants for RSASSA-PSS with an rsaEncryption key, which
+// are the low bytes of the rsa_pss_rsae_* SignatureScheme values from RFC 8446
+// s4.2.3.  They must be paired with the Intrinsic HashAlgorithm.
+const (
+	RSAPSSWithSHA256 SignatureAlgorithm = 4
+	RSAPSSWithSHA384 SignatureAlgorithm = 5
+	RSAPSSWithSHA512 SignatureAlgorithm = 6
+)
+
 func (s SignatureAlgorithm) String() string {
 	switch s {
 	case Anonymous:
@@ -96,6 +113,12 @@ func (s SignatureAlgorithm) String() string {
 		return "DSA"
 	case ECDSA:
 		return "ECDSA"
+	case RSAPSSWithSHA256:
+		return "RSAPSSWithSHA256"
+	case RSAPSSWithSHA384:
+		return "RSAPSSWithSHA384"
+	case RSAPSSWithSHA512:
+		return "RSAPSSWithSHA512"
 	default:
 		return fmt.Sprintf("UNKNOWN(%d)", s)
 	}
@@ -138,6 +138,44 @@ type PreCert struct {
 // nolint: golint
 type CTExtensions []byte // tls:"minlen:0,maxlen:65535"`
 
+// LeafIndexExtensionType is the extension type of the leaf_index extension,
+// with which static CT logs include the index of an entry in its SCT (and so
+// in its Merkle tree leaf).
+const LeafIndexExtensionType = 0
+
+// ctExtension is a single extension within a CTExtensions structure, in the
+// format used by static CT logs.
+type ctExtension struct {
+	ExtensionType uint8
+	ExtensionData []byte `tls:"minlen:0,maxlen:65535"`
+}
+
+// LeafIndex returns the leaf index held in the leaf_index extension, if the
+// extensions are a well-formed list that contains exactly one such extension
+// with a 40-bit index.  Otherwise it returns false.
+func (e CTExtensions) LeafIndex() (uint64, bool) {
+	var index uint64
+	found := false
+	for rest := []byte(e); len(rest) > 0; {
+		var ext ctExtension
+		var err error
+		if rest, err = tls.Unmarshal(rest, &ext); err != nil {
+			return 0, false
+		}
+		if ext.ExtensionType != LeafIndexExtensionType {
+			continue
+		}
+		if found || len(ext.ExtensionData) != 5 {
+			return 0, false
+		}
+		for _, b := range ext.ExtensionData {
+			index = index<<8 | uint64(b)
+		}
+		found = true
+	}
+	return index, found
+}
+
 // MerkleTreeNode represents an internal node in the CT tree.
 type MerkleTreeNode []byte
 
@@ -234,6 +272,19 @@ type LogEntry struct {
 	Chain []ASN1Cert
 }
 
+// IssuerKeyHash returns the SHA-256 hash of the issuer's public key that is
+// recorded for a precertificate entry, as needed to reconstruct its Merkle tree
+// leaf.  Returns false for entries that are not precertificates.
+func (e *LogEntry) IssuerKeyHash() ([sha256.Size]byte, bool) {
+	if te := e.Leaf.TimestampedEntry; te != nil && te.EntryType == PrecertLogEntryType && te.PrecertEntry != nil {
+		return te.PrecertEntry.IssuerKeyHash, true
+	}
+	if e.Precert != nil {
+		return e.Precert.IssuerKeyHash, true
+	}
+	return [sha256.Size]byte{}, false
+}
+
 // PrecertChainEntry holds an precertificate together with a validation chain
 // for it; see section 3.1.
 type PrecertChainEntry struct {
@@ -357,6 +408,27 @@ func (s SignedCertificateTimestamp) String() string {
 		s.Signature)
 }
 
+// LeafIndexFromExtensions returns the index of the SCT's entry in its log, as
+// given by the leaf_index extension of an SCT issued by a static CT log; see
+// CTExtensions.LeafIndex.  The index is only a hint from the log until it has
+// been checked with an inclusion proof.
+func (s *SignedCertificateTimestamp) LeafIndexFromExtensions() (uint64, bool) {
+	return s.Extensions.LeafIndex()
+}
+
+// ValidateV1 checks that the SCT meets the requirements of RFC 6962 for a v1
+// SCT: the version must be V1, and as no extensions are defined for v1, the
+// extensions must be empty.
+func (s SignedCertificateTimestamp) ValidateV1() error {
+	if s.SCTVersion != V1 {
+		return fmt.Errorf("SCT has version %v, want %v", s.SCTVersion, V1)
+	}
+	if len(s.Extensions) != 0 {
+		return fmt.Errorf("v1 SCT has %d bytes of extensions, want none", len(s.Extensions))
+	}
+	return nil
+}
+
 // TimestampedEntry is part of the MerkleTreeLeaf structure; see section 3.4.
 type TimestampedEntry struct {
 	Timestamp    uint64
@@ -545,6 +617,48 @@ type GetProofByHashResponse struct {
 	AuditPath [][]byte `json:"audit_path"` // An array of base64-encoded Merkle Tree nodes proving the inclusion of the chosen certificate.
 }
 
+// InclusionProof holds a proof that a leaf is included in a log's tree, along
+// with the tree size and root hash that it was verified against, so that it
+// can be stored and checked again later without contacting the log.
+type InclusionProof struct {
+	LeafIndex int64    `json:"leaf_index"` // The 0-based index of the leaf in the log.
+	TreeSize  uint64   `json:"tree_size"`  // The size of the tree the proof is for.
+	RootHash  []byte   `json:"root_hash"`  // The root hash of the tree of that size.
+	LeafHash  []byte   `json:"leaf_hash"`  // The Merkle leaf hash of the leaf.
+	AuditPath [][]byte `json:"audit_path"` // The Merkle tree nodes from the leaf to the root.
+}
+
+// ParseGetProofByHashResponse parses the JSON response to a get-proof-by-hash
+// request, as exchanged between auditors, checking that each of the audit path
+// entries is a validly-encoded hash.
+func ParseGetProofByHashResponse(data []byte) (*GetProofByHashResponse, error) {
+	var raw struct {
+		LeafIndex int64    `json:"leaf_index"`
+		AuditPath []string `json:"audit_path"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse get-proof-by-hash response: %v", err)
+	}
+	if raw.LeafIndex < 0 {
+		return nil, fmt.Errorf("leaf_index %d is negative", raw.LeafIndex)
+	}
+	rsp := GetProofByHashResponse{
+		LeafIndex: raw.LeafIndex,
+		AuditPath: make([][]byte, 0, len(raw.AuditPath)),
+	}
+	for i, b64 := range raw.AuditPath {
+		hash, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, fmt.Errorf("audit_path[%d] is not valid base64: %v", i, err)
+		}
+		if len(hash) != sha256.Size {
+			return nil, fmt.Errorf("audit_path[%d] is invalid length, expected %d got %d", i, sha256.Size, len(hash))
+		}
+		rsp.AuditPath = append(rsp.AuditPath, hash)
+	}
+	return &rsp, nil
+}
+
 // LeafEntry represents a leaf in the Log's Merkle tree, as returned by the get-entries
 // GET method from section 4.6.
 type LeafEntry struct {
@@ -67,6 +67,65 @@ func TestUnmarshalMerkleTreeLeaf(t *testing.T) {
 	}
 }
 
+func TestLogEntryIssuerKeyHash(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		entry  func() LogEntry
+		want   string // hex string
+		wantOK bool
+	}{
+		{
+			desc:  "x509-leaf",
+			entry: func() LogEntry { return LogEntry{Leaf: mustLeaf(t, CertEntry)} },
+		},
+		{
+			desc:   "precert-leaf",
+			entry:  func() LogEntry { return LogEntry{Leaf: mustLeaf(t, PrecertEntry)} },
+			want:   "3760e2790f33a498f9b6c149fecfca3993954b536fbf36ad45d0a8415b79337d",
+			wantOK: true,
+		},
+		{
+			desc: "parsed-precert-only",
+			entry: func() LogEntry {
+				precert := Precertificate{}
+				copy(precert.IssuerKeyHash[:], mustHexDecode(validRootHash))
+				return LogEntry{Precert: &precert}
+			},
+			want:   validRootHash,
+			wantOK: true,
+		},
+		{
+			desc:  "empty",
+			entry: func() LogEntry { return LogEntry{} },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			entry := test.entry()
+			got, ok := entry.IssuerKeyHash()
+			if ok != test.wantOK {
+				t.Fatalf("IssuerKeyHash()=_,%v; want _,%v", ok, test.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if gotHex := hex.EncodeToString(got[:]); gotHex != test.want {
+				t.Errorf("IssuerKeyHash()=%s,true; want %s,true", gotHex, test.want)
+			}
+		})
+	}
+}
+
+// mustLeaf parses a hex-encoded MerkleTreeLeaf.
+func mustLeaf(t *testing.T, in string) MerkleTreeLeaf {
+	t.Helper()
+	var leaf MerkleTreeLeaf
+	if _, err := tls.Unmarshal(mustHexDecode(in), &leaf); err != nil {
+		t.Fatalf("tls.Unmarshal(%s, &MerkleTreeLeaf)=nil,%v", in, err)
+	}
+	return leaf
+}
+
 func mustB64Decode(s string) []byte {
 	b, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
@@ -206,6 +265,63 @@ func TestToSignedTreeHead(t *testing.T) {
 	}
 }
 
+func TestParseGetProofByHashResponse(t *testing.T) {
+	hash := base64.StdEncoding.EncodeToString(mustHexDecode(validRootHash))
+	tests := []struct {
+		desc    string
+		data    string
+		wantErr string
+	}{
+		{
+			desc: "valid",
+			data: `{"leaf_index":3,"audit_path":["` + hash + `","` + hash + `"]}`,
+		},
+		{
+			desc: "empty-path",
+			data: `{"leaf_index":0,"audit_path":[]}`,
+		},
+		{
+			desc:    "corrupt-base64",
+			data:    `{"leaf_index":3,"audit_path":["` + hash + `","!!notbase64!!"]}`,
+			wantErr: "audit_path[1] is not valid base64",
+		},
+		{
+			desc:    "short-hash",
+			data:    `{"leaf_index":3,"audit_path":["` + base64.StdEncoding.EncodeToString([]byte("short")) + `"]}`,
+			wantErr: "audit_path[0] is invalid length",
+		},
+		{
+			desc:    "negative-index",
+			data:    `{"leaf_index":-1,"audit_path":[]}`,
+			wantErr: "negative",
+		},
+		{
+			desc:    "not-json",
+			data:    `{"leaf_index":`,
+			wantErr: "failed to parse",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			rsp, err := ParseGetProofByHashResponse([]byte(test.data))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("ParseGetProofByHashResponse()=%+v,%v; want _,err containing %q", rsp, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGetProofByHashResponse()=_,%v; want _,nil", err)
+			}
+			for i, node := range rsp.AuditPath {
+				if got, want := hex.EncodeToString(node), validRootHash; got != want {
+					t.Errorf("AuditPath[%d]=%s; want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
 func TestSTHString(t *testing.T) {
 	tests := []struct {
 		desc  string
@@ -246,3 +362,72 @@ func TestSTHString(t *testing.T) {
 		})
 	}
 }
+
+func TestSCTValidateV1(t *testing.T) {
+	tests := []struct {
+		desc    string
+		sct     SignedCertificateTimestamp
+		wantErr string
+	}{
+		{
+			desc: "compliant",
+			sct:  SignedCertificateTimestamp{SCTVersion: V1, Timestamp: 1527076172068},
+		},
+		{
+			desc: "empty-non-nil-extensions",
+			sct:  SignedCertificateTimestamp{SCTVersion: V1, Extensions: CTExtensions{}},
+		},
+		{
+			desc:    "extensions",
+			sct:     SignedCertificateTimestamp{SCTVersion: V1, Extensions: CTExtensions{0x00, 0x01}},
+			wantErr: "2 bytes of extensions",
+		},
+		{
+			desc:    "version",
+			sct:     SignedCertificateTimestamp{SCTVersion: Version(1)},
+			wantErr: "UnknownVersion(1)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.sct.ValidateV1()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateV1()=%v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ValidateV1()=%v; want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestLeafIndexFromExtensions(t *testing.T) {
+	tests := []struct {
+		desc      string
+		ext       CTExtensions
+		wantIndex uint64
+		wantOK    bool
+	}{
+		{desc: "none"},
+		{desc: "leaf-index", ext: CTExtensions{0x00, 0x00, 0x05, 0x00, 0x00, 0x01, 0x02, 0x03}, wantIndex: 0x010203, wantOK: true},
+		{desc: "max-leaf-index", ext: CTExtensions{0x00, 0x00, 0x05, 0xff, 0xff, 0xff, 0xff, 0xff}, wantIndex: 1<<40 - 1, wantOK: true},
+		{desc: "other-extension-first", ext: CTExtensions{0x07, 0x00, 0x01, 0xaa, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x09}, wantIndex: 9, wantOK: true},
+		{desc: "other-extension-only", ext: CTExtensions{0x07, 0x00, 0x01, 0xaa}},
+		{desc: "short-index", ext: CTExtensions{0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x09}},
+		{desc: "truncated", ext: CTExtensions{0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x09}},
+		{desc: "trailing-data", ext: CTExtensions{0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x09, 0x01}},
+		{desc: "duplicate", ext: CTExtensions{0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x09}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			sct := SignedCertificateTimestamp{SCTVersion: V1, Extensions: test.ext}
+			index, ok := sct.LeafIndexFromExtensions()
+			if index != test.wantIndex || ok != test.wantOK {
+				t.Errorf("LeafIndexFromExtensions()=%d,%v; want %d,%v", index, ok, test.wantIndex, test.wantOK)
+			}
+		})
+	}
+}
@@ -199,6 +199,12 @@ type VerifyOptions struct {
 	DisableEKUChecks               bool
 	DisablePathLenChecks           bool
 	DisableNameConstraintChecks    bool
+	// AcceptPrecertificates allows the leaf certificate to be a precertificate,
+	// i.e. to have the critical CT poison extension (RFC 6962 s3.1).  Otherwise
+	// the poison is treated as an unhandled critical extension, as a
+	// precertificate is not a valid certificate; it is never allowed in the
+	// rest of the chain.
+	AcceptPrecertificates bool
 	// KeyUsage specifies which Extended Key Usage values are acceptable. A leaf
 	// certificate is accepted if it contains any of the listed values. An empty
 	// list means ExtKeyUsageServerAuth. To accept any key usage, include
@@ -571,6 +577,9 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 	if !opts.DisableCriticalExtensionChecks && len(c.UnhandledCriticalExtensions) > 0 {
 		return UnhandledCriticalExtension{ID: c.UnhandledCriticalExtensions[0]}
 	}
+	if !opts.DisableCriticalExtensionChecks && c.IsPrecertificate() && (certType != leafCertificate || !opts.AcceptPrecertificates) {
+		return UnhandledCriticalExtension{ID: OIDExtensionCTPoison}
+	}
 
 	if !opts.DisableNameChecks && len(currentChain) > 0 {
 		child := currentChain[len(currentChain)-1]
@@ -26,6 +26,8 @@
 //     - RemoveSCTList() function for rebuilding CT leaf entry.
 //     - Pre-certificate processing (RemoveCTPoison(), BuildPrecertTBS(),
 //       ParseTBSCertificate(), IsPrecertificate()).
+//     - Parse the CT poison extension, and accept precertificates in
+//       Verify() only if VerifyOptions.AcceptPrecertificates is set.
 //  - Revocation list processing:
 //     - Detailed CRL parsing (in revoked.go)
 //     - Detailed error recording mechanism (in error.go, errors.go)
@@ -90,6 +92,10 @@ type pkixPublicKey struct {
 // It returns a *rsa.PublicKey, *dsa.PublicKey, *ecdsa.PublicKey, or
 // ed25519.PublicKey. More types might be supported in the future.
 //
+// Unlike certificate parsing, ECDSA keys on the secp192r1 curve (as used by
+// some CT logs) are accepted without error; the returned key uses the curve
+// from Secp192r1.
+//
 // This kind of key is commonly encoded in PEM blocks of type "PUBLIC KEY".
 func ParsePKIXPublicKey(derBytes []byte) (pub interface{}, err error) {
 	var pki publicKeyInfo
@@ -107,9 +113,12 @@ func ParsePKIXPublicKey(derBytes []byte) (pub interface{}, err error) {
 	if err != nil {
 		return pub, err
 	}
-	// Treat non-fatal errors as fatal for this entrypoint.
-	if len(nfe.Errors) > 0 {
-		return nil, nfe.Errors[0]
+	// Treat non-fatal errors as fatal for this entrypoint, apart from the
+	// use of secp192r1, which is expected for bare log keys.
+	for _, err := range nfe.Errors {
+		if err != errInsecureCurveP192 {
+			return nil, err
+		}
 	}
 	return pub, nil
 }
@@ -631,6 +640,8 @@ var (
 	OIDNamedCurveP192 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 1}
 )
 
+var errInsecureCurveP192 = errors.New("insecure curve (secp192r1) specified")
+
 func namedCurveFromOID(oid asn1.ObjectIdentifier, nfe *NonFatalErrors) elliptic.Curve {
 	switch {
 	case oid.Equal(OIDNamedCurveP224):
@@ -642,7 +653,7 @@ func namedCurveFromOID(oid asn1.ObjectIdentifier, nfe *NonFatalErrors) elliptic.
 	case oid.Equal(OIDNamedCurveP521):
 		return elliptic.P521()
 	case oid.Equal(OIDNamedCurveP192):
-		nfe.AddError(errors.New("insecure curve (secp192r1) specified"))
+		nfe.AddError(errInsecureCurveP192)
 		return Secp192r1()
 	}
 	return nil
@@ -2047,6 +2058,14 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 			out.RPKIAddressRanges = parseRPKIAddrBlocks(e.Value, &nfe)
 		} else if e.Id.Equal(OIDExtensionASList) {
 			out.RPKIASNumbers, out.RPKIRoutingDomainIDs = parseRPKIASIdentifiers(e.Value, &nfe)
+		} else if e.Id.Equal(OIDExtensionCTPoison) {
+			// RFC 6962 s3.1: the poison extension's value is an ASN.1 NULL. Its
+			// presence is reported by IsPrecertificate(), and it is removed
+			// for leaf hashing by RemoveCTPoison().  Verify() only accepts it
+			// with VerifyOptions.AcceptPrecertificates.
+			if !bytes.Equal(e.Value, asn1.NullBytes) {
+				nfe.AddError(errors.New("x509: CT poison extension value is not ASN.1 NULL"))
+			}
 		} else if e.Id.Equal(OIDExtensionCTSCT) {
 			if rest, err := asn1.Unmarshal(e.Value, &out.RawSCT); err != nil {
 				nfe.AddError(fmt.Errorf("failed to asn1.Unmarshal SCT list extension: %v", err))
@@ -90,6 +90,45 @@ func TestParsePKIXPublicKey(t *testing.T) {
 			t.Errorf("Value returned from ParsePKIXPublicKey was not an Ed25519 public key")
 		}
 	})
+	t.Run("ECDSA-P192", func(t *testing.T) {
+		pub := testParsePKIXPublicKey(t, pemP192PublicKey)
+		ecPub, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			t.Fatalf("Value returned from ParsePKIXPublicKey was not an ECDSA public key")
+		}
+		if ecPub.Curve != Secp192r1() {
+			t.Errorf("ParsePKIXPublicKey().Curve=%v; want Secp192r1()", ecPub.Curve.Params().Name)
+		}
+	})
+}
+
+func TestParsePKIXPublicKeyP192RoundTrip(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(Secp192r1(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(P192)=nil,%v; want _,nil", err)
+	}
+	der, err := MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey(P192)=nil,%v; want _,nil", err)
+	}
+	pub, err := ParsePKIXPublicKey(der)
+	if err != nil {
+		t.Fatalf("ParsePKIXPublicKey(P192)=nil,%v; want _,nil", err)
+	}
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("ParsePKIXPublicKey(P192)=%T; want *ecdsa.PublicKey", pub)
+	}
+	if ecPub.Curve != Secp192r1() || ecPub.X.Cmp(priv.X) != 0 || ecPub.Y.Cmp(priv.Y) != 0 {
+		t.Errorf("ParsePKIXPublicKey(P192)=%+v; want %+v", ecPub, priv.PublicKey)
+	}
+	der2, err := MarshalPKIXPublicKey(ecPub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey(reparsed P192)=nil,%v; want _,nil", err)
+	}
+	if !bytes.Equal(der2, der) {
+		t.Errorf("MarshalPKIXPublicKey(reparsed P192)=%x; want %x", der2, der)
+	}
 }
 
 func TestParsePKIXPublicKeyEd25519(t *testing.T) {
@@ -117,6 +156,12 @@ var pemPublicKeyEd25519 = `-----BEGIN PUBLIC KEY-----
 MCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=
 -----END PUBLIC KEY-----`
 
+var pemP192PublicKey = `-----BEGIN PUBLIC KEY-----
+MEkwEwYHKoZIzj0CAQYIKoZIzj0DAQEDMgAExsjXPevTvlNYLlTI7TgwtQ8x+hrv
+Asw/JmeMHooBPRCyE1oQede1mR/wY/vryHVg
+-----END PUBLIC KEY-----
+`
+
 var pemPublicKey = `-----BEGIN PUBLIC KEY-----
 MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA3VoPN9PKUjKFLMwOge6+
 wnDi8sbETGIx2FKXGgqtAKpzmem53kRGEQg8WeqRmp12wgp74TGpkEXsGae7RS1k
@@ -1443,6 +1488,68 @@ func TestIsPrecertificate(t *testing.T) {
 	}
 }
 
+func TestCTPoisonHandled(t *testing.T) {
+	precert, err := certificateFromPEM(pemPrecertificate)
+	if err != nil {
+		t.Fatalf("failed to parse precertificate: %v", err)
+	}
+	for _, oid := range precert.UnhandledCriticalExtensions {
+		if oid.Equal(OIDExtensionCTPoison) {
+			t.Errorf("UnhandledCriticalExtensions=%v; want no CT poison", precert.UnhandledCriticalExtensions)
+		}
+	}
+
+	// A precertificate chains to its issuer like any other certificate, but is
+	// only accepted as valid on request.
+	rootTemplate := Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "poison root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              KeyUsageCertSign,
+	}
+	root := makeCert(t, &rootTemplate, &rootTemplate)
+	precertTemplate := Certificate{
+		SerialNumber:    big.NewInt(2),
+		Subject:         pkix.Name{CommonName: "poison leaf"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}},
+	}
+	precert = makeCert(t, &precertTemplate, root)
+	if !precert.IsPrecertificate() {
+		t.Error("IsPrecertificate()=false; want true")
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+	opts := VerifyOptions{Roots: roots, KeyUsages: []ExtKeyUsage{ExtKeyUsageAny}}
+	if _, err := precert.Verify(opts); err == nil {
+		t.Error("Verify(precert)=_,nil; want _,non-nil")
+	} else if _, ok := err.(UnhandledCriticalExtension); !ok {
+		t.Errorf("Verify(precert)=_,%v; want UnhandledCriticalExtension", err)
+	}
+	opts.AcceptPrecertificates = true
+	if _, err := precert.Verify(opts); err != nil {
+		t.Errorf("Verify(precert, AcceptPrecertificates)=_,%v; want _,nil", err)
+	}
+
+	// A poison extension with a non-NULL value is reported, but not fatally.
+	precertTemplate.ExtraExtensions[0].Value = []byte{0x04, 0x00}
+	certData, err := CreateCertificate(rand.Reader, &precertTemplate, root, &testPrivateKey.PublicKey, testPrivateKey)
+	if err != nil {
+		t.Fatalf("failed to create pre-cert: %v", err)
+	}
+	precert, err = ParseCertificate(certData)
+	if err == nil || IsFatal(err) || !strings.Contains(err.Error(), "not ASN.1 NULL") {
+		t.Errorf("ParseCertificate(bad poison)=_,%v; want non-fatal error", err)
+	}
+	if !precert.IsPrecertificate() {
+		t.Error("IsPrecertificate(bad poison)=false; want true")
+	}
+}
+
 const ed25519CRLCertificate = `
 Certificate:
 Data: